@@ -17,15 +17,39 @@
 // users can take.
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // TODO: I am not sure if this is the best decision to bundle all up together.
 // All objects needed to perform actions.
 type Clients struct {
 	Telegram    *Telegram
 	Definer     *Definer
+	Usage       *UsageFetcher
 	Repetitions *Repetition
 	Settings    *SettingsConfig
+	Admin       *Admin
+	Quizzes     *Quizzes
+	Matches     *Matches
+	Practice    *PracticeSessions
+	Imports     *Imports
+	Lookups     *RecentLookups
+	ChatData    *ChatData
+	Decks       *Decks
+	Inactive    *InactiveChats
+	// APITokens is nil if the API is disabled.
+	APITokens *APITokens
+	// Web is nil if the dashboard is disabled.
+	Web *WebSessions
+	// OCR is nil if recognizing photos is disabled.
+	OCR OCR
+	// TTS is nil if there is no audio.
+	TTS TextToSpeech
+	// Anki is nil if syncing with Anki is disabled.
+	Anki  *Anki
+	Clock Clock
 }
 
 // TODO: Can I not extract word from the message? m.Text?
@@ -39,6 +63,27 @@ func flipWordCard(c *Clients, word string, m *Message, ks []*InlineKeyboard) err
 	if ks == nil {
 		ks = []*InlineKeyboard{}
 	}
+	if len(m.Photo) > 0 {
+		// The photo is the front of the card, so the word is a part of the
+		// answer.
+		caption := []rune(word + "\n\n" + def)
+		if len(caption) > maxCaptionLength {
+			caption = append(caption[:maxCaptionLength-1], '…')
+		}
+		r := &EditMessageCaption{
+			ChatId:    m.Chat.Id,
+			MessageId: m.Id,
+			Caption:   string(caption),
+			ReplyMarkup: ReplyMarkup{
+				InlineKeyboard: [][]*InlineKeyboard{ks},
+			},
+		}
+		var rm Message
+		if err := c.Telegram.Call("editMessageCaption", r, &rm); err != nil {
+			return fmt.Errorf("editing caption: %w", err)
+		}
+		return nil
+	}
 	r := &EditMessageText{
 		ChatId:    m.Chat.Id,
 		MessageId: m.Id,
@@ -57,3 +102,32 @@ func flipWordCard(c *Clients, word string, m *Message, ks []*InlineKeyboard) err
 	}
 	return nil
 }
+
+// editFailure returns the toast for a callback whose message couldn't be
+// edited for a benign reason: a double tap edits it into what it already is,
+// and deleted messages can't be edited. The callback should then succeed with
+// the toast. It's empty for other errors, which fail the callback.
+func editFailure(s *State, chatID int64, err error) string {
+	l := s.L(chatID)
+	var toast string
+	switch {
+	case errors.Is(err, errMessageNotModified):
+		toast = l.T("Already done.")
+	case errors.Is(err, errMessageToEditNotFound):
+		toast = l.T("The message was deleted.")
+	default:
+		return ""
+	}
+	s.Log.Info("Message not edited", "err", err)
+	return toast
+}
+
+// reportEditFailure sends the toast of editFailure as a message instead, for
+// callbacks answered before editing. Other errors are returned.
+func reportEditFailure(s *State, chatID int64, err error) error {
+	text := editFailure(s, chatID, err)
+	if text == "" {
+		return err
+	}
+	return s.Telegram.SendTextMessage(chatID, text)
+}
@@ -0,0 +1,300 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Commands for the bot maintainers. Replies here aren't localized, since they
+// are never seen by regular users.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLoggedErrors is the number of the latest errors kept for every chat.
+const maxLoggedErrors = 10
+
+// LoggedError is an internal error that happened while processing an update.
+type LoggedError struct {
+	Time time.Time
+	Err  string
+}
+
+// ErrorLog keeps the latest internal errors of every chat in memory, so that
+// admins can inspect them without digging through the logs.
+type ErrorLog struct {
+	clock Clock
+
+	mu     sync.Mutex
+	errors map[int64][]LoggedError
+}
+
+func NewErrorLog(clock Clock) *ErrorLog {
+	return &ErrorLog{clock: clock, errors: make(map[int64][]LoggedError)}
+}
+
+func (el *ErrorLog) Add(chatID int64, err error) {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	// Only the first line, stack traces of panics are too long for telegram.
+	msg := strings.SplitN(err.Error(), "\n", 2)[0]
+	es := append(el.errors[chatID], LoggedError{el.clock.Now(), msg})
+	if len(es) > maxLoggedErrors {
+		es = es[len(es)-maxLoggedErrors:]
+	}
+	el.errors[chatID] = es
+}
+
+// Clear forgets the errors of the chat.
+func (el *ErrorLog) Clear(chatID int64) {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	delete(el.errors, chatID)
+}
+
+// Get returns the latest errors of the chat, oldest first.
+func (el *ErrorLog) Get(chatID int64) []LoggedError {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	return append([]LoggedError(nil), el.errors[chatID]...)
+}
+
+// broadcastInterval keeps broadcasts well below telegram's limit of 30
+// messages per second.
+const broadcastInterval = 50 * time.Millisecond
+
+// Broadcaster sends the same message to many chats.
+type Broadcaster struct {
+	send func(chatID int64, text string) error
+	// markInactive records the chats that blocked the bot, nil if they
+	// aren't recorded.
+	markInactive func(chatID int64) error
+	interval     time.Duration
+
+	mu      sync.Mutex
+	running bool
+}
+
+// Start sends text to all chats in the background and calls done with the
+// number of chats that received it. Only one broadcast can run at a time.
+func (b *Broadcaster) Start(chatIDs []int64, text string, done func(sent int)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running {
+		return errors.New("another broadcast is in progress")
+	}
+	b.running = true
+	go func() {
+		sent := b.broadcast(chatIDs, text)
+		b.mu.Lock()
+		b.running = false
+		b.mu.Unlock()
+		done(sent)
+	}()
+	return nil
+}
+
+func (b *Broadcaster) broadcast(chatIDs []int64, text string) int {
+	sent := 0
+	for i, id := range chatIDs {
+		if i > 0 {
+			time.Sleep(b.interval)
+		}
+		// Users who blocked the bot are expected to fail, so keep going.
+		if err := b.send(id, text); err != nil {
+			slog.Warn("Broadcast failed", "chat_id", id, "err", err)
+			if errors.Is(err, errChatInactive) && b.markInactive != nil {
+				if err := b.markInactive(id); err != nil {
+					slog.Error("Marking chat inactive", "chat_id", id, "err", err)
+				}
+			}
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// Admin holds the state of the admin commands.
+type Admin struct {
+	Errors      *ErrorLog
+	Broadcaster *Broadcaster
+	// Backups is nil if backups are disabled.
+	Backups     *Backups
+	Maintenance *Maintenance
+}
+
+// adminCommand is available only to admins, see AdminOnly.
+const adminCommand = "/admin"
+
+const adminUsage = `Usage:
+/admin stats - global statistics
+/admin broadcast <text> - send the text to all chats
+/admin errors <chat ID> - latest internal errors of the chat
+/admin backup - snapshot of the database
+/admin maintenance - clean up and compact the database
+/admin sources - health of the sources of definitions`
+
+// adminCommands dispatches subcommands of /admin. It doesn't keep any state
+// between messages.
+type adminCommands struct{}
+
+func (adminCommands) Serialize() *SerializedCommand {
+	return nil
+}
+func (adminCommands) Init(*SerializedCommand) error {
+	return nil
+}
+
+// Should never be called.
+func (adminCommands) ProcessMessage(*State, *Message) (Command, error) {
+	return nil, nil
+}
+
+func (adminCommands) OnCommand(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	_, args := splitCommand(m.Text)
+	sub, arg := args, ""
+	if i := strings.IndexAny(args, " \n"); i >= 0 {
+		sub, arg = args[:i], strings.TrimSpace(args[i+1:])
+	}
+	var reply string
+	var err error
+	switch sub {
+	case "stats":
+		reply, err = adminStats(s)
+	case "broadcast":
+		reply, err = adminBroadcast(s, chatID, arg)
+	case "errors":
+		reply, err = adminErrors(s, chatID, arg)
+	case "backup":
+		reply, err = adminBackup(s, chatID)
+	case "maintenance":
+		reply, err = adminMaintenance(s)
+	case "sources":
+		reply = adminSources()
+	default:
+		reply = adminUsage
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.Telegram.SendTextMessage(chatID, reply)
+}
+
+func adminStats(s *State) (string, error) {
+	all, err := s.Settings.GetAll()
+	if err != nil {
+		return "", err
+	}
+	cards, err := s.Repetitions.CountCards()
+	if err != nil {
+		return "", err
+	}
+	now := s.Clock.Now()
+	day, err := s.Repetitions.CountReviews(now.Add(-24 * time.Hour))
+	if err != nil {
+		return "", err
+	}
+	week, err := s.Repetitions.CountReviews(now.AddDate(0, 0, -7))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Users: %d\nInactive users: %d\nCards: %d\nReviews in the last 24 hours: %d\nReviews per day over the last week: %.1f\nUpdates: %s",
+		len(all), s.Inactive.Count(), cards, day, float64(week)/7, updateMetrics.String()), nil
+}
+
+// adminSources describes the health of the sources, see Breaker.
+func adminSources() string {
+	var lines []string
+	for _, b := range breakers {
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func adminBroadcast(s *State, chatID int64, text string) (string, error) {
+	if text == "" {
+		return "", UserError{errors.New("nothing to broadcast"), chatID}
+	}
+	all, err := s.Settings.GetAll()
+	if err != nil {
+		return "", err
+	}
+	var ids []int64
+	for id := range all {
+		if !s.Inactive.Has(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	err = s.Admin.Broadcaster.Start(ids, text, func(sent int) {
+		msg := fmt.Sprintf("Broadcast delivered to %d of %d chats.", sent, len(ids))
+		if err := s.Telegram.SendTextMessage(chatID, msg); err != nil {
+			s.Log.Error("Reporting broadcast", "err", err)
+		}
+	})
+	if err != nil {
+		return "", UserError{err, chatID}
+	}
+	return fmt.Sprintf("Broadcasting to %d chats.", len(ids)), nil
+}
+
+func adminErrors(s *State, chatID int64, arg string) (string, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return "", UserError{fmt.Errorf("%q is not a chat ID", arg), chatID}
+	}
+	es := s.Admin.Errors.Get(id)
+	if len(es) == 0 {
+		return fmt.Sprintf("No errors logged for chat %d.", id), nil
+	}
+	lines := []string{fmt.Sprintf("Latest errors for chat %d:", id)}
+	for _, e := range es {
+		lines = append(lines, fmt.Sprintf("%s: %s", e.Time.UTC().Format("2006-01-02 15:04:05"), e.Err))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// adminMaintenance runs maintenance right away, see Maintenance.
+func adminMaintenance(s *State) (string, error) {
+	r, err := s.Admin.Maintenance.Run()
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// adminBackup takes a snapshot and sends it to the admin.
+func adminBackup(s *State, chatID int64) (string, error) {
+	if s.Admin.Backups == nil {
+		return "", UserError{errors.New("backups are disabled, set -backup_dir"), chatID}
+	}
+	p, err := s.Admin.Backups.Snapshot()
+	if err != nil {
+		return "", err
+	}
+	if err := s.Telegram.SendDocument(chatID, p); err != nil {
+		// Telegram doesn't accept large files, the snapshot is still there.
+		s.Log.Error("Sending backup", "path", p, "err", err)
+		return fmt.Sprintf("Backup saved to %s, but sending it failed.", p), nil
+	}
+	return fmt.Sprintf("Backup saved to %s.", p), nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestErrorLog(t *testing.T) {
+	el := NewErrorLog(SystemClock{})
+	for i := 0; i < maxLoggedErrors+2; i++ {
+		el.Add(1, fmt.Errorf("error %d\nstack", i))
+	}
+	es := el.Get(1)
+	if len(es) != maxLoggedErrors {
+		t.Fatalf("got %d errors want %d", len(es), maxLoggedErrors)
+	}
+	if got, want := es[0].Err, "error 2"; got != want {
+		t.Errorf("oldest error: got %q want %q", got, want)
+	}
+	if es := el.Get(2); len(es) != 0 {
+		t.Errorf("errors of another chat: got %v want none", es)
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	var mu sync.Mutex
+	var got []int64
+	release := make(chan struct{})
+	var inactive []int64
+	b := &Broadcaster{
+		send: func(chatID int64, text string) error {
+			<-release
+			if chatID == 2 {
+				return &StatusError{Code: http.StatusForbidden, Description: "Forbidden: bot was blocked by the user"}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, chatID)
+			return nil
+		},
+		markInactive: func(chatID int64) error {
+			inactive = append(inactive, chatID)
+			return nil
+		},
+	}
+	done := make(chan int)
+	if err := b.Start([]int64{1, 2, 3}, "hello", func(sent int) { done <- sent }); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start([]int64{1}, "hello again", func(int) {}); err == nil {
+		t.Errorf("Start during broadcast: got nil error")
+	}
+	close(release)
+	if sent := <-done; sent != 2 {
+		t.Errorf("sent to %d chats want 2", sent)
+	}
+	if diff := cmp.Diff([]int64{1, 3}, got); diff != "" {
+		t.Errorf("broadcast recipients (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int64{2}, inactive); diff != "" {
+		t.Errorf("inactive chats (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,732 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Syncing cards with Anki through AnkiConnect run by the user. New cards are
+// sent to a deck of the user's choice and reviews done in Anki are answered in
+// the bot. Only one side schedules the synced cards, the other one follows it.
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ankiTimeout limits a single request to AnkiConnect.
+const ankiTimeout = 30 * time.Second
+
+// ankiModel is the note type of the synced cards, the word is its Front field
+// and the definition the Back one.
+const ankiModel = "Basic"
+
+// Schedulers of the synced cards, see AnkiConfig.
+const (
+	// ankiSchedulerBot keeps the schedule of the bot, due dates in Anki are
+	// set to match it.
+	ankiSchedulerBot = "bot"
+	// ankiSchedulerAnki keeps the schedule of Anki, answers in the bot are
+	// sent to Anki and the next reviews are taken from it.
+	ankiSchedulerAnki = "anki"
+)
+
+// AnkiConnect is a client of AnkiConnect, the add-on serving the collection of
+// Anki over HTTP, see https://foosoft.net/projects/anki-connect/.
+type AnkiConnect struct {
+	hc  *http.Client
+	url string
+	// key is the API key AnkiConnect is configured with, empty if there is
+	// none.
+	key string
+}
+
+func NewAnkiConnect(url, key string) *AnkiConnect {
+	return &AnkiConnect{hc: &http.Client{Timeout: ankiTimeout, Transport: ankiTransport}, url: url, key: key}
+}
+
+// ankiTransport only connects to public addresses. The URL of AnkiConnect
+// comes from users, who could otherwise reach the services on the host of the
+// bot or in its network. Addresses are checked after they are resolved, so
+// that names of such addresses don't get through. Proxies aren't used.
+var ankiTransport = &http.Transport{
+	DialContext: (&net.Dialer{Timeout: ankiTimeout, Control: dialPublic}).DialContext,
+}
+
+// errNotPublic is returned when connecting to an address that isn't public,
+// see ankiTransport.
+var errNotPublic = errors.New("not a public address")
+
+// dialPublic is the net.Dialer Control rejecting loopback, private,
+// link-local and unspecified addresses.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("%s: %w", host, errNotPublic)
+	}
+	return nil
+}
+
+// AnkiConnectError is returned when AnkiConnect can't be reached or fails.
+type AnkiConnectError struct {
+	Action string
+	Err    error
+}
+
+func (e *AnkiConnectError) Error() string {
+	return fmt.Sprintf("AnkiConnect %s: %v", e.Action, e.Err)
+}
+
+func (e *AnkiConnectError) Unwrap() error {
+	return e.Err
+}
+
+// call runs the action with the params and decodes its result into result.
+func (a *AnkiConnect) call(action string, params, result any) error {
+	if err := a.do(action, params, result); err != nil {
+		return &AnkiConnectError{Action: action, Err: err}
+	}
+	return nil
+}
+
+func (a *AnkiConnect) do(action string, params, result any) error {
+	req := struct {
+		Action  string `json:"action"`
+		Version int    `json:"version"`
+		Params  any    `json:"params,omitempty"`
+		Key     string `json:"key,omitempty"`
+	}{action, 6, params, a.key}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	res, err := a.hc.Post(a.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1000))
+		return &StatusError{Code: res.StatusCode, Body: string(msg)}
+	}
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Error  *string         `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if r.Error != nil {
+		return errors.New(*r.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+// Version returns the version of the API, it checks that AnkiConnect can be
+// reached with the key.
+func (a *AnkiConnect) Version() (int, error) {
+	var v int
+	err := a.call("version", nil, &v)
+	return v, err
+}
+
+// CreateDeck creates the deck unless there is one already.
+func (a *AnkiConnect) CreateDeck(deck string) error {
+	return a.call("createDeck", map[string]string{"deck": deck}, nil)
+}
+
+// ankiNote is a note of ankiModel.
+type ankiNote struct {
+	DeckName  string            `json:"deckName"`
+	ModelName string            `json:"modelName"`
+	Fields    map[string]string `json:"fields"`
+	Tags      []string          `json:"tags"`
+	Options   map[string]any    `json:"options"`
+}
+
+// newAnkiNote returns the note of the card in the deck. Fields of notes are
+// HTML.
+func newAnkiNote(deck string, c *Card) *ankiNote {
+	return &ankiNote{
+		DeckName:  deck,
+		ModelName: ankiModel,
+		Fields: map[string]string{
+			"Front": html.EscapeString(c.Word),
+			"Back":  strings.ReplaceAll(html.EscapeString(c.Definition), "\n", "<br>"),
+		},
+		Tags:    []string{"words-bot"},
+		Options: map[string]any{"allowDuplicate": false, "duplicateScope": "deck"},
+	}
+}
+
+// CanAddNotes returns for every note whether it can be added, it can't if
+// it's a duplicate.
+func (a *AnkiConnect) CanAddNotes(notes []*ankiNote) ([]bool, error) {
+	var ok []bool
+	err := a.call("canAddNotes", map[string]any{"notes": notes}, &ok)
+	return ok, err
+}
+
+// AddNotes adds the notes and returns their IDs.
+func (a *AnkiConnect) AddNotes(notes []*ankiNote) ([]int64, error) {
+	var ids []*int64
+	if err := a.call("addNotes", map[string]any{"notes": notes}, &ids); err != nil {
+		return nil, err
+	}
+	r := make([]int64, len(ids))
+	for i, id := range ids {
+		if id == nil {
+			return nil, &AnkiConnectError{"addNotes", fmt.Errorf("note %q wasn't added", notes[i].Fields["Front"])}
+		}
+		r[i] = *id
+	}
+	return r, nil
+}
+
+// FindNotes returns the IDs of the notes matching the search query of Anki.
+func (a *AnkiConnect) FindNotes(query string) ([]int64, error) {
+	var ids []int64
+	err := a.call("findNotes", map[string]string{"query": query}, &ids)
+	return ids, err
+}
+
+// NoteCards returns the first card of each of the notes by note ID. Notes of
+// ankiModel have a single card.
+func (a *AnkiConnect) NoteCards(notes []int64) (map[int64]int64, error) {
+	var ns []struct {
+		NoteID int64   `json:"noteId"`
+		Cards  []int64 `json:"cards"`
+	}
+	if err := a.call("notesInfo", map[string]any{"notes": notes}, &ns); err != nil {
+		return nil, err
+	}
+	cs := make(map[int64]int64)
+	for _, n := range ns {
+		if len(n.Cards) > 0 {
+			cs[n.NoteID] = n.Cards[0]
+		}
+	}
+	return cs, nil
+}
+
+// AnkiReview is a review of a card in Anki.
+type AnkiReview struct {
+	// ID is the time of the review in milliseconds since UNIX epoch.
+	ID     int64
+	CardID int64
+	// Ease is the pressed button, 1 for Again.
+	Ease int
+	// Interval until the next review.
+	Interval time.Duration
+}
+
+// CardReviews returns the reviews of the cards in the deck after the review
+// with the ID, the earliest first.
+func (a *AnkiConnect) CardReviews(deck string, after int64) ([]*AnkiReview, error) {
+	// Every review is [reviewTime, cardID, usn, buttonPressed, newInterval,
+	// previousInterval, newFactor, reviewDuration, reviewType].
+	var rows [][]int64
+	if err := a.call("cardReviews", map[string]any{"deck": deck, "startID": after}, &rows); err != nil {
+		return nil, err
+	}
+	var rs []*AnkiReview
+	for _, r := range rows {
+		if len(r) < 5 {
+			return nil, &AnkiConnectError{"cardReviews", fmt.Errorf("unexpected review %v", r)}
+		}
+		// Positive intervals are in days, negative ones in seconds.
+		ivl := time.Duration(r[4]) * 24 * time.Hour
+		if r[4] < 0 {
+			ivl = time.Duration(-r[4]) * time.Second
+		}
+		rs = append(rs, &AnkiReview{ID: r[0], CardID: r[1], Ease: int(r[3]), Interval: ivl})
+	}
+	return rs, nil
+}
+
+// SetDueDate makes the cards due in the number of days, 0 for today.
+func (a *AnkiConnect) SetDueDate(cards []int64, days int) error {
+	var ok bool
+	return a.call("setDueDate", map[string]any{"cards": cards, "days": fmt.Sprint(days)}, &ok)
+}
+
+// AnswerCards answers the cards in order, with Good if known and with Again
+// otherwise.
+func (a *AnkiConnect) AnswerCards(cards []int64, known []bool) error {
+	type answer struct {
+		CardID int64 `json:"cardId"`
+		Ease   int   `json:"ease"`
+	}
+	var as []answer
+	for i, c := range cards {
+		ease := 1
+		if known[i] {
+			ease = 3
+		}
+		as = append(as, answer{c, ease})
+	}
+	var ok []bool
+	return a.call("answerCards", map[string]any{"answers": as}, &ok)
+}
+
+// ankiQuote quotes the text for a search query of Anki.
+func ankiQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `*`, `\*`, `_`, `\_`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// AnkiConfig is how a chat syncs with Anki.
+type AnkiConfig struct {
+	// URL of AnkiConnect.
+	URL string
+	// Key is the API key of AnkiConnect, empty if there is none.
+	Key  string
+	Deck string
+	// Scheduler is ankiSchedulerBot or ankiSchedulerAnki.
+	Scheduler string
+	// LastReview is the ID of the latest review in Anki that was synced.
+	LastReview int64
+	// Synced is when the chat was synced last, zero if it wasn't.
+	Synced time.Time
+}
+
+// Anki keeps the configs of syncing with Anki and which cards in Anki the
+// synced words are.
+type Anki struct {
+	db    *sql.DB
+	clock Clock
+	// connect returns the client of AnkiConnect of the config.
+	connect func(*AnkiConfig) *AnkiConnect
+}
+
+func NewAnki(db *sql.DB, clock Clock) *Anki {
+	return &Anki{db: db, clock: clock, connect: func(c *AnkiConfig) *AnkiConnect {
+		return NewAnkiConnect(c.URL, c.Key)
+	}}
+}
+
+// Config returns the config of the chat, sql.ErrNoRows if it doesn't sync.
+func (a *Anki) Config(chatID int64) (*AnkiConfig, error) {
+	c := &AnkiConfig{}
+	var synced int64
+	err := a.db.QueryRow(`
+		SELECT url, key, deck, scheduler, last_review_id, synced_seconds
+		FROM AnkiConfigs
+		WHERE chat_id = $0`,
+		chatID).Scan(&c.URL, &c.Key, &c.Deck, &c.Scheduler, &c.LastReview, &synced)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving Anki config of chat %d: %w", chatID, err)
+	}
+	if synced > 0 {
+		c.Synced = time.Unix(synced, 0)
+	}
+	return c, nil
+}
+
+// SetConfig starts syncing the chat with the config. The cards synced before
+// are forgotten, since they could be in another collection or deck.
+func (a *Anki) SetConfig(chatID int64, c *AnkiConfig) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("INTERNAL: setting Anki config of chat %d: %w", chatID, err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM AnkiCards WHERE chat_id = $0`, chatID); err != nil {
+		return fmt.Errorf("INTERNAL: setting Anki config of chat %d: %w", chatID, err)
+	}
+	if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO AnkiConfigs(chat_id, url, key, deck, scheduler, last_review_id, synced_seconds)
+		VALUES($0, $1, $2, $3, $4, 0, 0)`,
+		chatID, c.URL, c.Key, c.Deck, c.Scheduler); err != nil {
+		return fmt.Errorf("INTERNAL: setting Anki config of chat %d: %w", chatID, err)
+	}
+	return tx.Commit()
+}
+
+// Delete stops syncing the chat. Returns false if it didn't sync.
+func (a *Anki) Delete(chatID int64) (bool, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("INTERNAL: deleting Anki config of chat %d: %w", chatID, err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM AnkiCards WHERE chat_id = $0`, chatID); err != nil {
+		return false, fmt.Errorf("INTERNAL: deleting Anki config of chat %d: %w", chatID, err)
+	}
+	res, err := tx.Exec(`DELETE FROM AnkiConfigs WHERE chat_id = $0`, chatID)
+	if err != nil {
+		return false, fmt.Errorf("INTERNAL: deleting Anki config of chat %d: %w", chatID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+// Cards returns the words of the chat synced to Anki by their card IDs. Words
+// deleted in the bot are dropped.
+func (a *Anki) Cards(chatID int64) (map[int64]string, error) {
+	if _, err := a.db.Exec(`
+		DELETE FROM AnkiCards
+		WHERE chat_id = $0
+		  AND word NOT IN (SELECT word FROM Repetition WHERE chat_id = $1)`,
+		chatID, chatID); err != nil {
+		return nil, fmt.Errorf("INTERNAL: dropping deleted Anki cards of chat %d: %w", chatID, err)
+	}
+	rows, err := a.db.Query(`
+		SELECT word, card_id
+		FROM AnkiCards
+		WHERE chat_id = $0`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving Anki cards of chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	cs := make(map[int64]string)
+	for rows.Next() {
+		var w string
+		var id int64
+		if err := rows.Scan(&w, &id); err != nil {
+			return nil, err
+		}
+		cs[id] = w
+	}
+	return cs, rows.Err()
+}
+
+// AddCard records that the word is the card of the note in Anki.
+func (a *Anki) AddCard(chatID int64, word string, noteID, cardID int64) error {
+	if _, err := a.db.Exec(`
+		INSERT OR REPLACE INTO AnkiCards(chat_id, word, note_id, card_id)
+		VALUES($0, $1, $2, $3)`,
+		chatID, word, noteID, cardID); err != nil {
+		return fmt.Errorf("INTERNAL: recording Anki card of %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+// SetSynced records the latest synced review in Anki and the time of the
+// sync.
+func (a *Anki) SetSynced(chatID int64, lastReview int64, synced time.Time) error {
+	if _, err := a.db.Exec(`
+		UPDATE AnkiConfigs
+		SET last_review_id = $0, synced_seconds = $1
+		WHERE chat_id = $2`,
+		lastReview, synced.Unix(), chatID); err != nil {
+		return fmt.Errorf("INTERNAL: recording Anki sync of chat %d: %w", chatID, err)
+	}
+	return nil
+}
+
+// AnkiSyncResult counts what was synced.
+type AnkiSyncResult struct {
+	// Sent is the number of the cards sent to Anki.
+	Sent int
+	// Received is the number of the reviews in Anki answered in the bot.
+	Received int
+}
+
+// syncAnki syncs the chat with Anki: the reviews in Anki since the last sync
+// are answered in the bot, new cards are sent to Anki and the schedule of the
+// configured scheduler is copied to the other side.
+func syncAnki(s *State, chatID int64) (*AnkiSyncResult, error) {
+	cfg, err := s.Anki.Config(chatID)
+	if err == sql.ErrNoRows {
+		return nil, UserError{ChatID: chatID, Err: localizedErrorf("Set up syncing with /anki first.")}
+	}
+	if err != nil {
+		return nil, err
+	}
+	ac := s.Anki.connect(cfg)
+	cards, err := s.Anki.Cards(chatID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.Clock.Now()
+	// Answers in the bot are collected before the ones from Anki are
+	// logged, so that they aren't sent back.
+	var answers []*Review
+	if cfg.Scheduler == ankiSchedulerAnki {
+		rs, err := s.Repetitions.Reviews(chatID)
+		if err != nil {
+			return nil, err
+		}
+		synced := make(map[string]bool)
+		for _, w := range cards {
+			synced[w] = true
+		}
+		for _, r := range rs {
+			if r.Time.After(cfg.Synced) && synced[r.Word] {
+				answers = append(answers, r)
+			}
+		}
+	}
+
+	res := &AnkiSyncResult{}
+	last, n, err := pullAnkiReviews(s, chatID, ac, cfg, cfg.LastReview, cards, true)
+	if err != nil {
+		return nil, ankiError(chatID, err)
+	}
+	res.Received = n
+	if res.Sent, err = pushAnkiCards(s, chatID, ac, cfg.Deck, cards); err != nil {
+		return nil, ankiError(chatID, err)
+	}
+
+	switch cfg.Scheduler {
+	case ankiSchedulerAnki:
+		if len(answers) > 0 {
+			ids := make(map[string]int64)
+			for id, w := range cards {
+				ids[w] = id
+			}
+			var cs []int64
+			var known []bool
+			for _, r := range answers {
+				cs, known = append(cs, ids[r.Word]), append(known, r.Known)
+			}
+			if err := ac.AnswerCards(cs, known); err != nil {
+				return nil, ankiError(chatID, err)
+			}
+			// The answers are now reviews in Anki, which only
+			// reschedule the cards in the bot.
+			if last, _, err = pullAnkiReviews(s, chatID, ac, cfg, last, cards, false); err != nil {
+				return nil, ankiError(chatID, err)
+			}
+		}
+	default:
+		if err := pushAnkiSchedule(s, chatID, ac, cards, now); err != nil {
+			return nil, ankiError(chatID, err)
+		}
+	}
+	// The answers logged during the sync are in the past by now.
+	return res, s.Anki.SetSynced(chatID, last, s.Clock.Now())
+}
+
+// ankiError tells the user if AnkiConnect failed, other errors are returned
+// as they are.
+func ankiError(chatID int64, err error) error {
+	var ae *AnkiConnectError
+	if !errors.As(err, &ae) {
+		return err
+	}
+	return UserError{ChatID: chatID, Err: localizedErrorf("Couldn't sync with Anki: %s. Make sure that Anki is running and AnkiConnect can be reached from the internet.", err.Error())}
+}
+
+// pullAnkiReviews applies the reviews in Anki after the review with the ID
+// to the synced cards and returns the ID of the latest review and the number
+// of the applied ones. The reviews are answered in the bot if answer is true,
+// otherwise they only reschedule the cards if Anki is the scheduler.
+func pullAnkiReviews(s *State, chatID int64, ac *AnkiConnect, cfg *AnkiConfig, after int64, cards map[int64]string, answer bool) (int64, int, error) {
+	rs, err := ac.CardReviews(cfg.Deck, after)
+	if err != nil {
+		return 0, 0, err
+	}
+	last, n := after, 0
+	for _, r := range rs {
+		last = max(last, r.ID)
+		word, ok := cards[r.CardID]
+		if !ok {
+			continue
+		}
+		if answer {
+			if err := answerCard(s, chatID, word, r.Ease > 1, false); err != nil {
+				return 0, 0, err
+			}
+			n++
+		}
+		if cfg.Scheduler == ankiSchedulerAnki {
+			next := time.UnixMilli(r.ID).Add(r.Interval)
+			if err := s.Repetitions.Reschedule(chatID, word, next); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	return last, n, nil
+}
+
+// pushAnkiCards adds notes for the chat's cards that aren't synced yet to the
+// deck and records them in cards. The ones that are in the deck already are
+// only recorded. Returns the number of the added notes.
+func pushAnkiCards(s *State, chatID int64, ac *AnkiConnect, deck string, cards map[int64]string) (int, error) {
+	all, err := s.Repetitions.Cards(chatID)
+	if err != nil {
+		return 0, err
+	}
+	synced := make(map[string]bool)
+	for _, w := range cards {
+		synced[w] = true
+	}
+	var words []string
+	var notes []*ankiNote
+	for _, c := range all {
+		if !synced[c.Word] {
+			words = append(words, c.Word)
+			notes = append(notes, newAnkiNote(deck, c))
+		}
+	}
+	if len(notes) == 0 {
+		return 0, nil
+	}
+	if err := ac.CreateDeck(deck); err != nil {
+		return 0, err
+	}
+	ok, err := ac.CanAddNotes(notes)
+	if err != nil {
+		return 0, err
+	}
+	if len(ok) != len(notes) {
+		return 0, &AnkiConnectError{"canAddNotes", errors.New("unexpected number of results")}
+	}
+	var added []*ankiNote
+	var addedWords []string
+	noteWords := make(map[int64]string)
+	for i, n := range notes {
+		if ok[i] {
+			added, addedWords = append(added, n), append(addedWords, words[i])
+			continue
+		}
+		// Most likely the card was synced before.
+		ids, err := ac.FindNotes(fmt.Sprintf("deck:%s Front:%s", ankiQuote(deck), ankiQuote(n.Fields["Front"])))
+		if err != nil {
+			return 0, err
+		}
+		if len(ids) > 0 {
+			noteWords[ids[0]] = words[i]
+		}
+	}
+	if len(added) > 0 {
+		ids, err := ac.AddNotes(added)
+		if err != nil {
+			return 0, err
+		}
+		for i, id := range ids {
+			noteWords[id] = addedWords[i]
+		}
+	}
+	if len(noteWords) == 0 {
+		return 0, nil
+	}
+	var ids []int64
+	for id := range noteWords {
+		ids = append(ids, id)
+	}
+	nc, err := ac.NoteCards(ids)
+	if err != nil {
+		return 0, err
+	}
+	for id, w := range noteWords {
+		c, ok := nc[id]
+		if !ok {
+			continue
+		}
+		if err := s.Anki.AddCard(chatID, w, id, c); err != nil {
+			return 0, err
+		}
+		cards[c] = w
+	}
+	return len(added), nil
+}
+
+// pushAnkiSchedule makes the synced cards due in Anki when they are due in the
+// bot.
+func pushAnkiSchedule(s *State, chatID int64, ac *AnkiConnect, cards map[int64]string, now time.Time) error {
+	if len(cards) == 0 {
+		return nil
+	}
+	all, err := s.Repetitions.Cards(chatID)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]int64)
+	for id, w := range cards {
+		ids[w] = id
+	}
+	// Cards due on the same day are set at once.
+	byDays := make(map[int][]int64)
+	var days []int
+	for _, c := range all {
+		id, ok := ids[c.Word]
+		if !ok {
+			continue
+		}
+		// A card isn't due in Anki earlier than in the bot.
+		d := max(0, int(math.Ceil(c.NextReview.Sub(now).Hours()/24)))
+		if _, ok := byDays[d]; !ok {
+			days = append(days, d)
+		}
+		byDays[d] = append(byDays[d], id)
+	}
+	for _, d := range days {
+		if err := ac.SetDueDate(byDays[d], d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ankiEnabled returns a UserError if syncing with Anki is disabled.
+func ankiEnabled(s *State, chatID int64) error {
+	if s.Anki == nil {
+		return UserError{ChatID: chatID, Err: localizedErrorf("Syncing with Anki isn't enabled.")}
+	}
+	return nil
+}
+
+// ankiSyncReply syncs the chat with Anki and tells what was synced.
+func ankiSyncReply(s *State, chatID int64) error {
+	if err := ankiEnabled(s, chatID); err != nil {
+		return err
+	}
+	r, err := syncAnki(s, chatID)
+	if err != nil {
+		return err
+	}
+	return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("Synced with Anki: %d new cards sent, %d reviews received.", r.Sent, r.Received))
+}
+
+// ankiOffReply stops syncing the chat with Anki.
+func ankiOffReply(s *State, chatID int64) error {
+	if err := ankiEnabled(s, chatID); err != nil {
+		return err
+	}
+	ok, err := s.Anki.Delete(chatID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("Your cards aren't synced with Anki."))
+	}
+	return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("Stopped syncing with Anki, the cards in Anki are kept."))
+}
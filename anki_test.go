@@ -0,0 +1,304 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAnkiConnect keeps notes of a single deck, the card of every note has
+// the ID of the note plus 1000.
+type fakeAnkiConnect struct {
+	clock Clock
+	// Front fields of the notes by ID.
+	notes   map[int64]string
+	reviews [][]int64
+	// Days until the cards are due by ID, as set by setDueDate.
+	due map[int64]int
+}
+
+func (f *fakeAnkiConnect) review(card int64, ease, interval int64) {
+	id := f.clock.Now().UnixMilli() + int64(len(f.reviews))
+	f.reviews = append(f.reviews, []int64{id, card, 0, ease, interval, 0, 2500, 1000, 1})
+}
+
+func (f *fakeAnkiConnect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action string          `json:"action"`
+		Params json.RawMessage `json:"params"`
+		Key    string          `json:"key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result any
+	var notes struct {
+		Notes []ankiNote `json:"notes"`
+	}
+	json.Unmarshal(req.Params, &notes)
+	switch {
+	case req.Key != "secret":
+		json.NewEncoder(w).Encode(map[string]any{"result": nil, "error": "valid api key must be provided"})
+		return
+	case req.Action == "version":
+		result = 6
+	case req.Action == "createDeck":
+		result = 1
+	case req.Action == "canAddNotes":
+		var ok []bool
+		for _, n := range notes.Notes {
+			dup := false
+			for _, front := range f.notes {
+				dup = dup || front == n.Fields["Front"]
+			}
+			ok = append(ok, !dup)
+		}
+		result = ok
+	case req.Action == "addNotes":
+		var ids []int64
+		for _, n := range notes.Notes {
+			id := int64(len(f.notes) + 1)
+			f.notes[id] = n.Fields["Front"]
+			ids = append(ids, id)
+		}
+		result = ids
+	case req.Action == "findNotes":
+		var p struct{ Query string }
+		json.Unmarshal(req.Params, &p)
+		ids := []int64{}
+		for id, front := range f.notes {
+			if strings.HasSuffix(p.Query, "Front:"+ankiQuote(front)) {
+				ids = append(ids, id)
+			}
+		}
+		result = ids
+	case req.Action == "notesInfo":
+		var p struct{ Notes []int64 }
+		json.Unmarshal(req.Params, &p)
+		var ns []map[string]any
+		for _, id := range p.Notes {
+			ns = append(ns, map[string]any{"noteId": id, "cards": []int64{id + 1000}})
+		}
+		result = ns
+	case req.Action == "cardReviews":
+		var p struct{ StartID int64 }
+		json.Unmarshal(req.Params, &p)
+		rs := [][]int64{}
+		for _, r := range f.reviews {
+			if r[0] > p.StartID {
+				rs = append(rs, r)
+			}
+		}
+		result = rs
+	case req.Action == "setDueDate":
+		var p struct {
+			Cards []int64
+			Days  string
+		}
+		json.Unmarshal(req.Params, &p)
+		for _, c := range p.Cards {
+			var d int
+			json.Unmarshal([]byte(p.Days), &d)
+			f.due[c] = d
+		}
+		result = true
+	case req.Action == "answerCards":
+		var p struct {
+			Answers []struct {
+				CardID int64 `json:"cardId"`
+				Ease   int64 `json:"ease"`
+			}
+		}
+		json.Unmarshal(req.Params, &p)
+		var ok []bool
+		for _, a := range p.Answers {
+			// Anki schedules known cards in 4 days.
+			f.review(a.CardID, a.Ease, 4)
+			ok = append(ok, true)
+		}
+		result = ok
+	default:
+		json.NewEncoder(w).Encode(map[string]any{"result": nil, "error": "unsupported action"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"result": result, "error": nil})
+}
+
+func TestAnkiConnectPublicOnly(t *testing.T) {
+	server := httptest.NewServer(&fakeAnkiConnect{})
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{server.URL, "http://localhost:" + port} {
+		if _, err := NewAnkiConnect(url, "").Version(); !errors.Is(err, errNotPublic) {
+			t.Errorf("Version of %s: got %v want %v", url, err, errNotPublic)
+		}
+	}
+	for address, public := range map[string]bool{
+		"203.0.113.5:8765":        true,
+		"[2001:db8::1]:80":        true,
+		"10.1.2.3:8765":           false,
+		"192.168.0.1:8765":        false,
+		"169.254.169.254:80":      false,
+		"0.0.0.0:8765":            false,
+		"[::1]:8765":              false,
+		"[fe80::1]:8765":          false,
+		"[fd00::1]:8765":          false,
+		"[::ffff:127.0.0.1]:8765": false,
+	} {
+		if err := dialPublic("tcp", address, nil); (err == nil) != public {
+			t.Errorf("dialPublic(%q) = %v, want public %v", address, err, public)
+		}
+	}
+}
+
+func TestSyncAnki(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour, 48 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeAnkiConnect{clock: clock, notes: make(map[int64]string), due: make(map[int64]int)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	anki := NewAnki(db, clock)
+	// The fake is on a loopback address, which NewAnkiConnect refuses.
+	anki.connect = func(c *AnkiConfig) *AnkiConnect {
+		return &AnkiConnect{hc: server.Client(), url: c.URL, key: c.Key}
+	}
+	s := &State{Clients: &Clients{Repetitions: r, Settings: sc, Anki: anki, Clock: clock}, Log: slog.Default()}
+	const chatID int64 = 1
+	for _, w := range []string{"alma", "körte"} {
+		if err := r.Save(chatID, w, w+" definition", "hun"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := syncAnki(s, chatID); !errors.As(err, &UserError{}) {
+		t.Errorf("Sync before setting up: got %v want UserError", err)
+	}
+	cfg := &AnkiConfig{URL: server.URL, Key: "wrong", Deck: "Hungarian", Scheduler: ankiSchedulerBot}
+	if _, err := anki.connect(cfg).Version(); err == nil {
+		t.Error("Version with a wrong key: got nil want error")
+	}
+	cfg.Key = "secret"
+	if err := anki.SetConfig(chatID, cfg); err != nil {
+		t.Fatal(err)
+	}
+	res, err := syncAnki(s, chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 2 || res.Received != 0 || len(fake.notes) != 2 {
+		t.Errorf("First sync: got %+v with %d notes want 2 sent", res, len(fake.notes))
+	}
+	cards, err := anki.Cards(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alma, korte int64
+	for id, w := range cards {
+		switch w {
+		case "alma":
+			alma = id
+		case "körte":
+			korte = id
+		}
+	}
+	if alma == 0 || korte == 0 {
+		t.Fatalf("Synced cards: got %v want alma and körte", cards)
+	}
+
+	// The review in Anki is answered in the bot, which then sets the due
+	// date in Anki.
+	clock.Advance(time.Minute)
+	fake.review(alma, 3, 1)
+	if res, err = syncAnki(s, chatID); err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 0 || res.Received != 1 {
+		t.Errorf("Second sync: got %+v want 1 received", res)
+	}
+	if p, err := r.Progress(chatID, "alma"); err != nil || p.Stage != 1 {
+		t.Errorf("Progress of alma: got %+v, %v want stage 1", p, err)
+	}
+	// Due in an hour, but not earlier in Anki.
+	if fake.due[alma] != 1 || fake.due[korte] != 0 {
+		t.Errorf("Due dates: got %v want alma in 1 day and körte today", fake.due)
+	}
+	if res, err = syncAnki(s, chatID); err != nil || res.Received != 0 {
+		t.Errorf("Third sync: got %+v, %v want nothing received", res, err)
+	}
+
+	// The notes in the deck are found again after setting up once more.
+	cfg.Scheduler = ankiSchedulerAnki
+	if err := anki.SetConfig(chatID, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = syncAnki(s, chatID); err != nil || res.Sent != 0 || len(fake.notes) != 2 {
+		t.Errorf("Sync after setting up again: got %+v, %v with %d notes want none sent", res, err, len(fake.notes))
+	}
+	if cards, err := anki.Cards(chatID); err != nil || len(cards) != 2 {
+		t.Errorf("Synced cards after setting up again: got %v, %v want 2", cards, err)
+	}
+
+	// With Anki as the scheduler answers in the bot are sent to Anki, which
+	// then schedules the card.
+	clock.Advance(time.Minute)
+	if err := answerCard(s, chatID, "körte", true, false); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	n := len(fake.reviews)
+	if res, err = syncAnki(s, chatID); err != nil || res.Received != 0 {
+		t.Errorf("Sync with Anki scheduling: got %+v, %v want nothing received", res, err)
+	}
+	if len(fake.reviews) != n+1 || fake.reviews[n][1] != korte || fake.reviews[n][3] != 3 {
+		t.Errorf("Reviews in Anki: got %v want körte answered with Good", fake.reviews[n:])
+	}
+	if p, err := r.Progress(chatID, "körte"); err != nil || !p.NextReview.Equal(clock.Now().Add(96*time.Hour).Truncate(time.Second)) {
+		t.Errorf("Next review of körte: got %+v, %v want in 4 days", p, err)
+	}
+	// The answer isn't sent again.
+	if _, err = syncAnki(s, chatID); err != nil || len(fake.reviews) != n+1 {
+		t.Errorf("Sync again: got %d reviews, %v want %d", len(fake.reviews), err, n+1)
+	}
+
+	if ok, err := anki.Delete(chatID); err != nil || !ok {
+		t.Errorf("Delete: got %t, %v want true", ok, err)
+	}
+	if _, err := anki.Config(chatID); err == nil {
+		t.Error("Config after deleting: got nil error")
+	}
+}
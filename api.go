@@ -0,0 +1,257 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// JSON API for companion apps and scripts, served next to the webhook.
+// Requests are authenticated with a token issued by /token.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APITokens keeps hashes of the API tokens of chats, a chat has at most one.
+type APITokens struct {
+	db    *sql.DB
+	clock Clock
+}
+
+// newToken returns a random secret that can be put into URLs.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("INTERNAL: generating token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is what is stored in the database instead of the token, so that
+// a leaked database doesn't give access.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// Issue returns a new token of the chat, the previous one stops working.
+func (a *APITokens) Issue(chatID int64) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if _, err := a.db.Exec(`
+		INSERT OR REPLACE INTO APITokens(token_hash, chat_id, created_seconds)
+		VALUES($0, $1, $2)`,
+		hashToken(token), chatID, a.clock.Now().Unix()); err != nil {
+		return "", fmt.Errorf("INTERNAL: saving API token of chat %d: %w", chatID, err)
+	}
+	return token, nil
+}
+
+// Chat returns the chat the token was issued to, sql.ErrNoRows if the token
+// is unknown.
+func (a *APITokens) Chat(token string) (int64, error) {
+	var chatID int64
+	err := a.db.QueryRow(`SELECT chat_id FROM APITokens WHERE token_hash = $0`, hashToken(token)).Scan(&chatID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("INTERNAL: looking up API token: %w", err)
+	}
+	return chatID, err
+}
+
+const (
+	// defaultAPIDue and maxAPIDue limit the number of cards in /api/due.
+	defaultAPIDue = 20
+	maxAPIDue     = 100
+)
+
+type apiCard struct {
+	Word       string    `json:"word"`
+	Definition string    `json:"definition"`
+	Language   string    `json:"language"`
+	Stage      int       `json:"stage"`
+	NextReview time.Time `json:"next_review"`
+}
+
+type apiStats struct {
+	Cards int `json:"cards"`
+	Due   struct {
+		Now        int `json:"now"`
+		LaterToday int `json:"later_today"`
+		Tomorrow   int `json:"tomorrow"`
+		Week       int `json:"week"`
+	} `json:"due"`
+	Streak struct {
+		Current int `json:"current"`
+		Longest int `json:"longest"`
+	} `json:"streak"`
+}
+
+// APIHandler serves the cards, statistics and the review queue of the chat
+// owning the bearer token:
+//
+//	GET /api/cards - all cards.
+//	GET /api/stats - the number of cards, due forecast and streaks.
+//	GET /api/due?limit=N&language=L - cards due for review, the longest
+//	  overdue first. The language is optional, given by name or code.
+func APIHandler(s *State) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/cards", apiEndpoint(s, func(chatID int64, _ *http.Request) (interface{}, error) {
+		cs, err := s.Repetitions.Cards(chatID)
+		if err != nil {
+			return nil, err
+		}
+		return apiCards(cs), nil
+	}))
+	mux.HandleFunc("/api/stats", apiEndpoint(s, func(chatID int64, _ *http.Request) (interface{}, error) {
+		return apiStatsOf(s, chatID)
+	}))
+	mux.HandleFunc("/api/due", apiEndpoint(s, func(chatID int64, req *http.Request) (interface{}, error) {
+		limit := defaultAPIDue
+		if l := req.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				return nil, errBadAPIRequest
+			}
+			limit = min(n, maxAPIDue)
+		}
+		language := ""
+		if l := req.URL.Query().Get("language"); l != "" {
+			found, err := s.Settings.Languages.Find(l)
+			if err != nil {
+				return nil, errBadAPIRequest
+			}
+			language = found.ISO639_3
+		}
+		ws, err := s.Repetitions.DueWords(chatID, language, limit)
+		if err != nil {
+			return nil, err
+		}
+		cs, err := s.Repetitions.Cards(chatID)
+		if err != nil {
+			return nil, err
+		}
+		byWord := make(map[string]*Card)
+		for _, c := range cs {
+			byWord[c.Word] = c
+		}
+		due := make([]*Card, 0, len(ws))
+		for _, w := range ws {
+			if c, ok := byWord[w]; ok {
+				due = append(due, c)
+			}
+		}
+		return apiCards(due), nil
+	}))
+	return mux
+}
+
+// errBadAPIRequest is returned by endpoints for malformed parameters.
+var errBadAPIRequest = errors.New("bad request")
+
+// apiEndpoint authenticates the request and writes the result of get as JSON.
+func apiEndpoint(s *State, get func(chatID int64, req *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		chatID, err := s.APITokens.Chat(token)
+		if err == sql.ErrNoRows {
+			slog.Warn("API: unknown token", "remote_addr", req.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.Error("API", "path", req.URL.Path, "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		v, err := get(chatID, req)
+		if errors.Is(err, errBadAPIRequest) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.Error("API", "path", req.URL.Path, "chat_id", chatID, "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			slog.Error("API: writing response", "path", req.URL.Path, "err", err)
+		}
+	}
+}
+
+func apiCards(cs []*Card) []apiCard {
+	r := make([]apiCard, 0, len(cs))
+	for _, c := range cs {
+		r = append(r, apiCard{c.Word, c.Definition, c.Language, c.Stage, c.NextReview.UTC()})
+	}
+	return r
+}
+
+func apiStatsOf(s *State, chatID int64) (*apiStats, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.Clock.Now()
+	f, err := s.Repetitions.Forecast(chatID, now, settings.DayLocation())
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.Repetitions.Streak(chatID, now, settings.DayLocation())
+	if err != nil {
+		return nil, err
+	}
+	cs, err := s.Repetitions.Cards(chatID)
+	if err != nil {
+		return nil, err
+	}
+	r := &apiStats{Cards: len(cs)}
+	r.Due.Now, r.Due.LaterToday, r.Due.Tomorrow, r.Due.Week = f.Now, f.LaterToday, f.Tomorrow, f.Week
+	r.Streak.Current, r.Streak.Longest = st.Current, st.Longest
+	return r, nil
+}
+
+// tokenReply issues a new API token for the chat.
+func tokenReply(s *State, chatID int64) error {
+	l := s.L(chatID)
+	if s.APITokens == nil {
+		return s.Telegram.SendTextMessage(chatID, l.T("The API isn't enabled on this bot."))
+	}
+	token, err := s.APITokens.Issue(chatID)
+	if err != nil {
+		return err
+	}
+	return s.Telegram.SendTextMessage(chatID, l.T("Your API token, keep it secret:\n%s\n\nSend it in the Authorization: Bearer header. /token again replaces it.", token))
+}
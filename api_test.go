@@ -0,0 +1,135 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAPIHandler(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := &APITokens{db: db, clock: clock}
+	s := &State{Clients: &Clients{Repetitions: r, Settings: sc, APITokens: tokens, Clock: clock}, Log: slog.Default()}
+	const chatID, other int64 = 1, 2
+	for _, w := range []string{"foo", "bar"} {
+		if err := r.Save(chatID, w, w+" definition", "hun"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Save(other, "baz", "baz definition", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+
+	old, err := tokens.Issue(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := tokens.Issue(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := APIHandler(s)
+	get := func(path, token string, v interface{}) int {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code == http.StatusOK && v != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+				t.Fatalf("GET %s: %v", path, err)
+			}
+		}
+		return w.Code
+	}
+
+	for _, tc := range []struct{ path, token string }{
+		{"/api/cards", ""},
+		{"/api/cards", "wrong"},
+		// Issuing a new token revokes the previous one.
+		{"/api/cards", old},
+	} {
+		if code := get(tc.path, tc.token, nil); code != http.StatusUnauthorized {
+			t.Errorf("GET %s with token %q: got status %d want %d", tc.path, tc.token, code, http.StatusUnauthorized)
+		}
+	}
+
+	var cards []apiCard
+	if code := get("/api/cards", token, &cards); code != http.StatusOK {
+		t.Fatalf("GET /api/cards: got status %d", code)
+	}
+	var words []string
+	for _, c := range cards {
+		words = append(words, c.Word)
+	}
+	if diff := cmp.Diff([]string{"bar", "foo"}, words); diff != "" {
+		t.Errorf("GET /api/cards (-want +got):\n%s", diff)
+	}
+
+	var due []apiCard
+	if code := get("/api/due?limit=5", token, &due); code != http.StatusOK {
+		t.Fatalf("GET /api/due: got status %d", code)
+	}
+	if len(due) != 1 || due[0].Word != "bar" || due[0].Definition != "bar definition" {
+		t.Errorf("GET /api/due: got %+v want only bar", due)
+	}
+	if code := get("/api/due?limit=x", token, nil); code != http.StatusBadRequest {
+		t.Errorf("GET /api/due?limit=x: got status %d want %d", code, http.StatusBadRequest)
+	}
+	for path, want := range map[string]int{"/api/due?language=hun": 1, "/api/due?language=German": 0} {
+		due = nil
+		if code := get(path, token, &due); code != http.StatusOK || len(due) != want {
+			t.Errorf("GET %s: got status %d, %+v want %d and %d cards", path, code, due, http.StatusOK, want)
+		}
+	}
+	if code := get("/api/due?language=Klingon", token, nil); code != http.StatusBadRequest {
+		t.Errorf("GET /api/due?language=Klingon: got status %d want %d", code, http.StatusBadRequest)
+	}
+
+	var st apiStats
+	if code := get("/api/stats", token, &st); code != http.StatusOK {
+		t.Fatalf("GET /api/stats: got status %d", code)
+	}
+	if st.Cards != 2 || st.Due.Now != 1 || st.Streak.Current != 1 {
+		t.Errorf("GET /api/stats: got %+v want 2 cards, 1 due and a streak of 1 day", st)
+	}
+}
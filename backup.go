@@ -0,0 +1,119 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Online backups of the database. VACUUM INTO writes a consistent snapshot
+// while the bot keeps serving updates.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupPrefix     = "backup-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102-150405"
+)
+
+// Backups takes snapshots of the database into a directory.
+type Backups struct {
+	db    *sql.DB
+	clock Clock
+	dir   string
+	// keep is the number of the latest snapshots kept in dir, older ones
+	// are removed. All are kept if it's 0.
+	keep int
+	// upload is called with every scheduled snapshot, nil if snapshots are
+	// only kept in dir.
+	upload func(path string) error
+
+	// mu serializes snapshots, so that they don't race for the file name
+	// and pruning.
+	mu sync.Mutex
+}
+
+// Snapshot writes a snapshot of the database and returns its path.
+func (b *Backups) Snapshot() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return "", fmt.Errorf("INTERNAL: creating backup directory: %w", err)
+	}
+	p := filepath.Join(b.dir, backupPrefix+b.clock.Now().UTC().Format(backupTimeFormat)+backupSuffix)
+	if _, err := b.db.Exec(`VACUUM INTO $0`, p); err != nil {
+		return "", fmt.Errorf("INTERNAL: writing snapshot %q: %w", p, err)
+	}
+	if err := b.prune(); err != nil {
+		slog.Error("Pruning backups", "err", err)
+	}
+	return p, nil
+}
+
+// prune removes all but the latest keep snapshots. Names sort by time.
+func (b *Backups) prune() error {
+	if b.keep <= 0 {
+		return nil
+	}
+	fs, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, f := range fs {
+		if n := f.Name(); strings.HasPrefix(n, backupPrefix) && strings.HasSuffix(n, backupSuffix) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for len(names) > b.keep {
+		if err := os.Remove(filepath.Join(b.dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// Loop takes a snapshot on every tick and uploads it.
+func (b *Backups) Loop(ticker <-chan time.Time, cancel <-chan struct{}) {
+	for {
+		select {
+		case <-ticker:
+		case <-cancel:
+			return
+		}
+		p, err := b.Snapshot()
+		if err != nil {
+			slog.Error("Scheduled backup", "err", err)
+			continue
+		}
+		slog.Info("Backup saved", "path", p)
+		if b.upload == nil {
+			continue
+		}
+		if err := b.upload(p); err != nil {
+			slog.Error("Uploading backup", "path", p, "err", err)
+		}
+	}
+}
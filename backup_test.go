@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r, err := NewRepetition(db, []time.Duration{0}, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(1, "word", "definition", "hun"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := newFakeClock(time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC))
+	b := &Backups{db: db, clock: clock, dir: filepath.Join(dir, "backups"), keep: 2}
+	var paths []string
+	for i := 0; i < 3; i++ {
+		p, err := b.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+		clock.Advance(time.Hour)
+	}
+
+	fs, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range fs {
+		got = append(got, f.Name())
+	}
+	want := []string{"backup-20200501-130000.db", "backup-20200501-140000.db"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Snapshots after pruning (-want +got):\n%s", diff)
+	}
+
+	// The snapshot is a usable database.
+	s, err := OpenDB(paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	sr, err := NewRepetition(s, []time.Duration{0}, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := sr.GetDefinition(1, "word"); err != nil || d != "definition" {
+		t.Errorf("GetDefinition() from snapshot = %q, %v want \"definition\", nil", d, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Several bots served by one process, e.g. a beta next to the production bot
+// or a bot per language. Every bot has its own telegram client and database
+// of users' data, while the corpus and definitions are shared.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// botConfig is an extra bot given as name=token in the bots option.
+type botConfig struct {
+	name  string
+	token string
+}
+
+// botNameRe keeps names usable in file names.
+var botNameRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+func parseBot(s string) (botConfig, error) {
+	name, token, ok := strings.Cut(s, "=")
+	if !ok || token == "" {
+		return botConfig{}, fmt.Errorf("bot %q is not name=token", name)
+	}
+	if !botNameRe.MatchString(name) {
+		return botConfig{}, fmt.Errorf("bot name %q may contain only a-z, 0-9, _ and -", name)
+	}
+	return botConfig{name, token}, nil
+}
+
+// botDBPath returns the database of the bot next to the one of the main bot,
+// e.g. db.beta.sql for db.sql.
+func botDBPath(dbPath, name string) string {
+	ext := filepath.Ext(dbPath)
+	return strings.TrimSuffix(dbPath, ext) + "." + name + ext
+}
+
+// Corpus is shared by all bots of the process: usage examples and definitions
+// with their cache are kept in the database of the main bot.
+type Corpus struct {
+	Definer *Definer
+	Usage   *UsageFetcher
+}
+
+// NewCorpus creates the corpus kept in db.
+func NewCorpus(db *sql.DB, useCache bool, clock Clock) (*Corpus, error) {
+	var cache DefCacheInterface = &NoCache{}
+	if useCache {
+		var err error
+		if cache, err = NewDefCache(db, clock); err != nil {
+			return nil, fmt.Errorf("creating definitions cache: %w", err)
+		}
+	}
+	uf, err := NewUsageFetcher(db)
+	if err != nil {
+		return nil, fmt.Errorf("creating usage fetcher: %w", err)
+	}
+	return &Corpus{
+		Definer: &Definer{
+			usage: uf,
+			cache: cache,
+			http:  &http.Client{},
+		},
+		Usage: uf,
+	}, nil
+}
+
+// forBot returns options of the extra bot sharing the corpus with the main
+// one. The API and the dashboard are served only for the main bot.
+func (o *CommanderOptions) forBot(b botConfig, corpus *Corpus) *CommanderOptions {
+	bo := *o
+	bo.dbPath = botDBPath(o.dbPath, b.name)
+	if o.backupDir != "" {
+		bo.backupDir = filepath.Join(o.backupDir, b.name)
+	}
+	bo.api, bo.dashboard = false, false
+	bo.bots = nil
+	bo.corpus = corpus
+	return &bo
+}
+
+// NewBots creates commanders of the extra bots, their telegram clients use the
+// same http client as the main one.
+func NewBots(main *Commander, opts *CommanderOptions) ([]*Commander, error) {
+	corpus := &Corpus{Definer: main.Definer, Usage: main.Usage}
+	var cs []*Commander
+	for _, b := range opts.bots {
+		c, err := NewCommander(&Telegram{hc: main.Telegram.hc, token: b.token}, opts.forBot(b, corpus))
+		if err != nil {
+			return nil, fmt.Errorf("bot %s: %w", b.name, err)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
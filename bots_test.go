@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBotDBPath(t *testing.T) {
+	for _, tc := range []struct{ path, want string }{
+		{"./db.sql", "./db.beta.sql"},
+		{"/words-vol/db/db.sql", "/words-vol/db/db.beta.sql"},
+		{"db", "db.beta"},
+	} {
+		if got := botDBPath(tc.path, "beta"); got != tc.want {
+			t.Errorf("botDBPath(%q): got %q want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestNewBots(t *testing.T) {
+	fk := startFakeTelegram(t)
+	defer fk.server.Close()
+	dir := t.TempDir()
+	opts := &CommanderOptions{
+		dbPath:    filepath.Join(dir, "db.sql"),
+		stages:    []time.Duration{0},
+		backupDir: filepath.Join(dir, "backups"),
+		api:       true,
+		bots:      []botConfig{{"beta", "123:abc"}},
+	}
+	main, err := NewCommander(&Telegram{hc: *fk.server.Client()}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	others, err := NewBots(main, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(others) != 1 {
+		t.Fatalf("got %d bots want 1", len(others))
+	}
+	beta := others[0]
+	if beta.Telegram.token != "123:abc" {
+		t.Errorf("token: got %q want 123:abc", beta.Telegram.token)
+	}
+	if beta.Definer != main.Definer || beta.Usage != main.Usage {
+		t.Error("the corpus isn't shared with the main bot")
+	}
+	if beta.APITokens != nil {
+		t.Error("the API is enabled for the extra bot")
+	}
+	if got, want := beta.Admin.Backups.dir, filepath.Join(dir, "backups", "beta"); got != want {
+		t.Errorf("backup dir: got %q want %q", got, want)
+	}
+	// Users' data is kept apart.
+	if err := beta.Repetitions.Save(1, "fekete", "black", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := main.Repetitions.GetDefinition(1, "fekete"); err == nil {
+		t.Error("card saved with the extra bot is visible to the main one")
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "db.beta.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Repetition`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("cards in db.beta.sql: got %d, %v want 1", n, err)
+	}
+}
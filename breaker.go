@@ -0,0 +1,155 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Health of the external sources of definitions. A source that keeps failing
+// is skipped for a while, so that lookups don't wait for it every time.
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// breakerWindow is the number of the latest requests to a source by which its
+// health is judged.
+const breakerWindow = 20
+
+// breakerMinRequests is the number of requests a source gets before it can be
+// skipped, so that a single failure doesn't trip the breaker.
+const breakerMinRequests = 5
+
+// breakerMaxFailures is the share of the failed latest requests at which the
+// source is skipped.
+const breakerMaxFailures = 0.5
+
+// breakerCooldown is how long a failing source is skipped. After that a single
+// trial request decides whether it's used again.
+var breakerCooldown = time.Minute
+
+// errSourceDown is returned instead of sending requests to a skipped source.
+var errSourceDown = errors.New("skipped after failing requests")
+
+// sourceMetrics counts requests, failures and trips of the breakers by source,
+// e.g. "wiktionary.failures".
+var sourceMetrics = expvar.NewMap("sources")
+
+// breakers are the breakers of all sources, see newBreaker.
+var breakers []*Breaker
+
+// Breaker tracks failures of requests to a source and trips when too many of
+// the latest ones failed, see breakerMaxFailures. The source is skipped until
+// a trial request after breakerCooldown succeeds.
+type Breaker struct {
+	Name  string
+	clock Clock
+
+	mu sync.Mutex
+	// Latest outcomes of the requests, true for failures.
+	failures []bool
+	// Until when the source is skipped, zero if it isn't.
+	openUntil time.Time
+	// Whether the trial request after the cooldown is in progress.
+	trial bool
+	trips int
+}
+
+// newBreaker returns the breaker of the source and registers it for
+// adminSources.
+func newBreaker(name string, clock Clock) *Breaker {
+	b := &Breaker{Name: name, clock: clock}
+	breakers = append(breakers, b)
+	return b
+}
+
+// Allow returns errSourceDown if the source is skipped. Otherwise the request
+// can be sent, and its outcome must be passed to Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	if b.clock.Now().Before(b.openUntil) || b.trial {
+		return fmt.Errorf("%s: %w", b.Name, errSourceDown)
+	}
+	b.trial = true
+	return nil
+}
+
+// Record counts the outcome of a request allowed by Allow, err is nil if it
+// succeeded.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sourceMetrics.Add(b.Name+".requests", 1)
+	if err != nil {
+		sourceMetrics.Add(b.Name+".failures", 1)
+	}
+	if !b.openUntil.IsZero() {
+		// Requests sent before the breaker tripped don't decide anything.
+		if !b.trial {
+			return
+		}
+		b.trial = false
+		if err != nil {
+			b.openUntil = b.clock.Now().Add(breakerCooldown)
+			slog.Warn("Source is still failing", "source", b.Name, "until", b.openUntil, "err", err)
+			return
+		}
+		b.openUntil, b.failures = time.Time{}, nil
+		slog.Info("Source recovered", "source", b.Name)
+		return
+	}
+	b.failures = append(b.failures, err != nil)
+	if len(b.failures) > breakerWindow {
+		b.failures = b.failures[len(b.failures)-breakerWindow:]
+	}
+	if n := b.failed(); len(b.failures) >= breakerMinRequests && float64(n) >= breakerMaxFailures*float64(len(b.failures)) {
+		b.openUntil = b.clock.Now().Add(breakerCooldown)
+		b.trips++
+		sourceMetrics.Add(b.Name+".trips", 1)
+		slog.Warn("Source is failing, skipping it", "source", b.Name, "failed", n, "of", len(b.failures), "until", b.openUntil, "err", err)
+	}
+}
+
+// failed returns the number of the failed latest requests.
+func (b *Breaker) failed() int {
+	n := 0
+	for _, f := range b.failures {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+// String describes the health of the source for adminSources.
+func (b *Breaker) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := "ok"
+	switch {
+	case b.trial:
+		state = "trying again"
+	case !b.openUntil.IsZero():
+		state = "skipped until " + b.openUntil.Format(time.DateTime)
+	}
+	return fmt.Sprintf("%s: %s, %d of %d latest requests failed, tripped %d times",
+		b.Name, state, b.failed(), len(b.failures), b.trips)
+}
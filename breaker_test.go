@@ -0,0 +1,65 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	b := &Breaker{Name: "test", clock: clock}
+	failure := errors.New("failure")
+	request := func(step string, err error) {
+		t.Helper()
+		if err := b.Allow(); err != nil {
+			t.Fatalf("%s: Allow() = %v, want nil", step, err)
+		}
+		b.Record(err)
+	}
+	skipped := func(step string) {
+		t.Helper()
+		if err := b.Allow(); !errors.Is(err, errSourceDown) {
+			t.Errorf("%s: Allow() = %v, want %v", step, err, errSourceDown)
+		}
+	}
+
+	request("success", nil)
+	request("success", nil)
+	request("failure", failure)
+	request("failure", failure)
+	// 2 of 4 failed, but it's too few requests to judge.
+	request("failure", failure)
+	skipped("3 of 5 failed")
+	if got, want := b.String(), "test: skipped until 2020-06-01 12:01:00, 3 of 5 latest requests failed, tripped 1 times"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	clock.Advance(breakerCooldown)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("after cooldown: Allow() = %v, want nil", err)
+	}
+	skipped("during the trial")
+	b.Record(failure)
+	skipped("after the failed trial")
+
+	clock.Advance(breakerCooldown)
+	request("trial", nil)
+	request("recovered", failure)
+	if got, want := b.String(), "test: ok, 1 of 1 latest requests failed, tripped 1 times"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
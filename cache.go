@@ -34,27 +34,13 @@ func (*NoCache) Save(_, _, _ string) error {
 }
 
 type DefCache struct {
-	db *sql.DB
+	db    *sql.DB
+	clock Clock
 }
 
-// NewDefCache create DefCache using path to the database, creates a database
-// if it doesn't exist already.
-// FIXME: db should created in main and passed over here. (because it should be easy to replace it)
-func NewDefCache(path string) (*DefCache, error) {
-	db, err := sql.Open("sqlite3", path)
-	if err != nil {
-		return nil, err
-	}
-	// TODO: How schema changes would work?
-	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS Definitions (
-			query string UNIQUE NOT NULL, -- user's query
-			word string, -- the corresponding word (can be different from query in case of typos)
-			definition string);
-	`); err != nil {
-		return nil, err
-	}
-	return &DefCache{db}, nil
+// NewDefCache creates DefCache in the database.
+func NewDefCache(db *sql.DB, clock Clock) (*DefCache, error) {
+	return &DefCache{db, clock}, nil
 }
 
 // Lookup returns possible corrected word with it's definition
@@ -66,7 +52,7 @@ func (c *DefCache) Lookup(q string) (string, string, error) {
 }
 
 func (c *DefCache) Save(q, w, d string) error {
-	_, err := c.db.Exec(`INSERT INTO Definitions(query, word, definition)
-		VALUES($0, $1, $2)`, q, w, d)
+	_, err := c.db.Exec(`INSERT INTO Definitions(query, word, definition, cached_seconds)
+		VALUES($0, $1, $2, $3)`, q, w, d, c.clock.Now().Unix())
 	return err
 }
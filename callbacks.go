@@ -13,39 +13,80 @@
 // limitations under the License.
 package main
 
-import "fmt"
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// answerCard records the answer with the scheduler chosen by the user. ahead
+// is true when studying ahead.
+func answerCard(s *State, chatID int64, word string, known, ahead bool) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	return s.Repetitions.AnswerCard(chatID, word, known, ahead, settings.Scheduling())
+}
 
 type KnowCallback struct {
 	Word string
+	// If true when clicking another practice card will be shown.
+	Practice bool
+	// Language to which the practice is limited, empty for all.
+	Language string
 }
 
 func (KnowCallback) Call(s *State, q *CallbackQuery) error {
-	defer s.Telegram.AnswerCallbackLog(q.Id, "")
 	chatID := q.Message.Chat.Id
-	word := CallbackInfoFromString(q.Data).Word
+	info := CallbackInfoFromString(q.Data)
+	word := info.Word
+	// Answered before recording the answer and flipping the card, the
+	// flipped card is the outcome.
+	s.AnswerCallback(q, "")
 
+	ahead := info.Action == PracticeKnowAction && s.Practice.Ahead(chatID)
 	// TODO: Need to handle 2 rapid taps to avoid saving it as known 2 times in a row.
-	if err := s.Repetitions.AnswerKnow(chatID, word); err != nil {
+	if err := answerCard(s, chatID, word, true, ahead); err != nil {
 		return err
 	}
 
-	if err := flipWordCard(s.Clients, word, q.Message, []*InlineKeyboard{DontKnowCallback{word, false}.AsInlineKeyboard()}); err != nil {
+	l := s.L(chatID)
+	star, err := starCallback(s, chatID, word)
+	if err != nil {
 		return err
 	}
-	return practiceReply(s, chatID)
+	if err := flipWordCard(s.Clients, word, q.Message, []*InlineKeyboard{
+		DontKnowCallback{word, false, ""}.AsInlineKeyboard(l),
+		AddNoteCallback{word}.AsInlineKeyboard(l),
+		star.AsInlineKeyboard(l),
+	}); err != nil {
+		if err := reportEditFailure(s, chatID, err); err != nil {
+			return err
+		}
+	}
+	if info.Action == PracticeKnowActionNoPractice {
+		return nil
+	}
+	return continuePractice(s, chatID, info.Setting, true)
 }
 
 func (KnowCallback) Match(s *State, q *CallbackQuery) bool {
 	info := CallbackInfoFromString(q.Data)
-	return info.Action == PracticeKnowAction
+	return info.Action == PracticeKnowAction || info.Action == PracticeKnowActionNoPractice
 }
 
-func (k KnowCallback) AsInlineKeyboard() *InlineKeyboard {
+func (k KnowCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	a := PracticeKnowActionNoPractice
+	if k.Practice {
+		a = PracticeKnowAction
+	}
 	return &InlineKeyboard{
-		Text: "Know",
+		Text: l.T("Know"),
 		CallbackData: CallbackInfo{
-			Action: PracticeKnowAction,
-			Word:   k.Word,
+			Action:  a,
+			Word:    k.Word,
+			Setting: k.Language,
 		}.String(),
 	}
 }
@@ -54,26 +95,40 @@ type DontKnowCallback struct {
 	Word string
 	// If true when clicking another practice card will be shown.
 	Practice bool
+	// Language to which the practice is limited, empty for all.
+	Language string
 }
 
 func (DontKnowCallback) Call(s *State, q *CallbackQuery) error {
-	defer s.Telegram.AnswerCallbackLog(q.Id, "Reset progress")
 	info := CallbackInfoFromString(q.Data)
 	chatID := q.Message.Chat.Id
 	word := info.Word
+	// The outcome is known from the button, so it's shown before recording
+	// the answer and flipping the card.
+	s.AnswerCallback(q, s.L(chatID).T("Reset progress"))
 
-	if err := s.Repetitions.AnswerDontKnow(chatID, word); err != nil {
+	if err := answerCard(s, chatID, word, false, false); err != nil {
 		return err
 	}
 
-	if err := flipWordCard(s.Clients, word, q.Message, nil); err != nil {
+	l := s.L(chatID)
+	star, err := starCallback(s, chatID, word)
+	if err != nil {
 		return err
 	}
+	if err := flipWordCard(s.Clients, word, q.Message, []*InlineKeyboard{
+		AddNoteCallback{word}.AsInlineKeyboard(l),
+		star.AsInlineKeyboard(l),
+	}); err != nil {
+		if err := reportEditFailure(s, chatID, err); err != nil {
+			return err
+		}
+	}
 
 	if info.Action == PracticeDontKnowActionNoPractice {
 		return nil
 	}
-	return practiceReply(s, chatID)
+	return continuePractice(s, chatID, info.Setting, false)
 }
 
 func (DontKnowCallback) Match(_ *State, q *CallbackQuery) bool {
@@ -81,16 +136,17 @@ func (DontKnowCallback) Match(_ *State, q *CallbackQuery) bool {
 	return info.Action == PracticeDontKnowAction || info.Action == PracticeDontKnowActionNoPractice
 }
 
-func (c DontKnowCallback) AsInlineKeyboard() *InlineKeyboard {
+func (c DontKnowCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
 	a := PracticeDontKnowActionNoPractice
 	if c.Practice {
 		a = PracticeDontKnowAction
 	}
 	return &InlineKeyboard{
-		Text: "Don't know",
+		Text: l.T("Don't know"),
 		CallbackData: CallbackInfo{
-			Action: a,
-			Word:   c.Word,
+			Action:  a,
+			Word:    c.Word,
+			Setting: c.Language,
 		}.String(),
 	}
 }
@@ -99,35 +155,180 @@ type ResetProgressCallback struct {
 	Word string
 }
 
-// ResetProgress type is just a convenience placeholder to create inline keyboards.
-// Logic will be handled by dont know callback.
-func (ResetProgressCallback) Call(_ *State, _ *CallbackQuery) error {
-	return nil
+// Call asks for confirmation by replacing the button with Yes/No ones.
+func (ResetProgressCallback) Call(s *State, q *CallbackQuery) error {
+	word := CallbackInfoFromString(q.Data).Word
+	return editKeyboard(s, q.Message, resetProgressConfirmation.Keyboard(word))
 }
 
-func (ResetProgressCallback) Match(_ *State, _ *CallbackQuery) bool {
-	return false
+func (ResetProgressCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == ResetProgressAction
 }
 
-func (c ResetProgressCallback) AsInlineKeyboard() *InlineKeyboard {
+func (c ResetProgressCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
 	return &InlineKeyboard{
-		Text: "Reset progress",
+		Text: l.T("Reset progress"),
 		CallbackData: CallbackInfo{
-			Action: PracticeDontKnowActionNoPractice,
+			Action: ResetProgressAction,
 			Word:   c.Word,
 		}.String(),
 	}
 }
 
+// ConfirmCallback is a Yes or No answer to the ConfirmCommand.
+type ConfirmCallback struct {
+	// Name of the ConfirmCommand.
+	Name string
+	Word string
+	Yes  bool
+}
+
+func (ConfirmCallback) Call(s *State, q *CallbackQuery) error {
+	info := CallbackInfoFromString(q.Data)
+	var c *ConfirmCommand
+	for _, cc := range ConfirmCommands {
+		if cc.Name == info.Confirm {
+			c = cc
+		}
+	}
+	if c == nil {
+		return fmt.Errorf("INTERNAL: unknown confirmation %q", info.Confirm)
+	}
+	l := s.L(q.Message.Chat.Id)
+	msg := l.T("Cancelled")
+	if info.Action == ConfirmAction {
+		var err error
+		if msg, err = c.Do(s, q.Message.Chat.Id, info.Word); err != nil {
+			return err
+		}
+	}
+	if c.Question == nil {
+		// Buttons were attached to the user's message, it shouldn't be
+		// replaced.
+		s.AnswerCallback(q, msg)
+		return editKeyboard(s, q.Message, nil)
+	}
+	r := &EditMessageText{
+		ChatId:    q.Message.Chat.Id,
+		MessageId: q.Message.Id,
+		Text:      msg,
+		ReplyMarkup: ReplyMarkup{
+			InlineKeyboard: [][]*InlineKeyboard{
+				[]*InlineKeyboard{},
+			},
+		},
+	}
+	var rm Message
+	if err := s.Telegram.Call("editMessageText", r, &rm); err != nil {
+		return fmt.Errorf("editing message text: %w", err)
+	}
+	return nil
+}
+
+func (ConfirmCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == ConfirmAction || info.Action == DeclineAction
+}
+
+func (c ConfirmCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	t, a := "No", DeclineAction
+	if c.Yes {
+		t, a = "Yes", ConfirmAction
+	}
+	return &InlineKeyboard{
+		Text: l.T(t),
+		CallbackData: CallbackInfo{
+			Action:  a,
+			Word:    c.Word,
+			Confirm: c.Name,
+		}.String(),
+	}
+}
+
+// editKeyboard replaces inline keyboard of the message.
+func editKeyboard(s *State, m *Message, callbacks []Callback) error {
+	l := s.L(m.Chat.Id)
+	ik := []*InlineKeyboard{}
+	for _, c := range callbacks {
+		ik = append(ik, c.AsInlineKeyboard(l))
+	}
+	r := &EditMessageText{
+		ChatId:    m.Chat.Id,
+		MessageId: m.Id,
+		ReplyMarkup: ReplyMarkup{
+			InlineKeyboard: [][]*InlineKeyboard{ik},
+		},
+	}
+	var rm Message
+	if err := s.Telegram.Call("editMessageReplyMarkup", r, &rm); err != nil {
+		return fmt.Errorf("editing message reply markup: %w", err)
+	}
+	return nil
+}
+
+// removeButton removes the pressed button from the message, keeping the rest.
+func removeButton(s *State, q *CallbackQuery) error {
+	return replaceButton(s, q, nil)
+}
+
+// replaceButton replaces the pressed button in the message with k, keeping the
+// rest. The button is removed if k is nil.
+func replaceButton(s *State, q *CallbackQuery, k *InlineKeyboard) error {
+	m := q.Message
+	r := &EditMessageText{
+		ChatId:    m.Chat.Id,
+		MessageId: m.Id,
+	}
+	for _, row := range m.ReplyMarkup.InlineKeyboard {
+		var ks []*InlineKeyboard
+		for _, b := range row {
+			switch {
+			case b.CallbackData != q.Data:
+				ks = append(ks, b)
+			case k != nil:
+				ks = append(ks, k)
+			}
+		}
+		r.ReplyMarkup.InlineKeyboard = append(r.ReplyMarkup.InlineKeyboard, ks)
+	}
+	var rm Message
+	if err := s.Telegram.Call("editMessageReplyMarkup", r, &rm); err != nil {
+		return fmt.Errorf("editing message reply markup: %w", err)
+	}
+	return nil
+}
+
 type LearnCallback struct {
 	Word string
+	// ISO 639-3 code of the language in which the word was looked up.
+	Language string
 }
 
 func (LearnCallback) Call(s *State, q *CallbackQuery) error {
+	// Answered before saving the card, the edited buttons are the outcome.
+	s.AnswerCallback(q, "")
 	// FIXME: Next 3 lines are very common.
 	chatID := q.Message.Chat.Id
-	word := CallbackInfoFromString(q.Data).Word
-	if err := s.Repetitions.Save(chatID, word, q.Message.Text); err != nil {
+	info := CallbackInfoFromString(q.Data)
+	word := info.Word
+	p, err := s.Repetitions.Progress(chatID, word)
+	if err == nil {
+		return offerSavedCardChoices(s, q, word, p)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	language := info.Setting
+	if language == "" {
+		// Buttons sent before cards had a language.
+		settings, err := s.Settings.Get(chatID)
+		if err != nil {
+			return err
+		}
+		language = settings.InputLanguageISO639_3
+	}
+	if err := saveCard(s, chatID, word, q.Message.Text, language); err != nil {
 		return err
 	}
 	m := q.Message
@@ -141,11 +342,10 @@ func (LearnCallback) Call(s *State, q *CallbackQuery) error {
 		},
 	}
 	var rm Message
-	if err := s.Telegram.Call("editMessageReplyMarkup", r, &rm); err != nil {
+	// The card is saved even if the button can't be removed anymore.
+	if err := s.Telegram.Call("editMessageReplyMarkup", r, &rm); err != nil && editFailure(s, chatID, err) == "" {
 		return fmt.Errorf("editing message reply markup: %w", err)
 	}
-	msg := fmt.Sprintf("Saved %q for learning", word)
-	s.Telegram.AnswerCallbackLog(q.Id, msg)
 	return nil
 }
 
@@ -154,12 +354,605 @@ func (LearnCallback) Match(_ *State, q *CallbackQuery) bool {
 	return info.Action == SaveWordAction
 }
 
-func (c LearnCallback) AsInlineKeyboard() *InlineKeyboard {
+func (c LearnCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Learn"),
+		CallbackData: CallbackInfo{
+			Action:  SaveWordAction,
+			Word:    c.Word,
+			Setting: c.Language,
+		}.String(),
+	}
+}
+
+// AddNoteCallback asks for a note to append to the definition of the word,
+// see NoteCommandFactory.
+type AddNoteCallback struct {
+	Word string
+}
+
+// Should never be called, see StartCommand.
+func (AddNoteCallback) Call(*State, *CallbackQuery) error {
+	return nil
+}
+
+func (AddNoteCallback) StartCommand(s *State, q *CallbackQuery) (Command, error) {
+	m := &Message{Text: "/note " + CallbackInfoFromString(q.Data).Word}
+	m.Chat.Id = q.Message.Chat.Id
+	return NoteCommandFactory()("/note").OnCommand(s, m)
+}
+
+func (AddNoteCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == AddNoteAction
+}
+
+func (c AddNoteCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
 	return &InlineKeyboard{
-		Text: "Learn",
+		Text: l.T("Add note"),
 		CallbackData: CallbackInfo{
-			Action: SaveWordAction,
+			Action: AddNoteAction,
 			Word:   c.Word,
 		}.String(),
 	}
 }
+
+// AttachImageCallback asks for a photo to put on the front of the word's card,
+// see ImageCommandFactory.
+type AttachImageCallback struct {
+	Word string
+}
+
+// Should never be called, see StartCommand.
+func (AttachImageCallback) Call(*State, *CallbackQuery) error {
+	return nil
+}
+
+func (AttachImageCallback) StartCommand(s *State, q *CallbackQuery) (Command, error) {
+	m := &Message{Text: "/image " + CallbackInfoFromString(q.Data).Word}
+	m.Chat.Id = q.Message.Chat.Id
+	return ImageCommandFactory()("/image").OnCommand(s, m)
+}
+
+func (AttachImageCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == AttachImageAction
+}
+
+func (c AttachImageCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Attach image"),
+		CallbackData: CallbackInfo{
+			Action: AttachImageAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// MoreDefinitionsCallback sends the definitions that didn't fit into the reply.
+type MoreDefinitionsCallback struct {
+	Word string
+	// Language in which the word was defined.
+	Language string
+}
+
+// defineAgain returns the definitions of the word in the language, as sent
+// before with the buttons.
+func defineAgain(s *State, chatID int64, word, language string) ([]*Formatted, *Formatted, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if language == "" {
+		language = settings.InputLanguageISO639_3
+	}
+	if settings, err = s.Settings.ForLanguage(settings, language); err != nil {
+		return nil, nil, err
+	}
+	// Most likely cached.
+	return s.Definer.Define(word, settings)
+}
+
+func (MoreDefinitionsCallback) Call(s *State, q *CallbackQuery) error {
+	// The definitions may have to be fetched again.
+	s.AnswerCallback(q, "")
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	_, more, err := defineAgain(s, chatID, info.Word, info.Setting)
+	if err != nil {
+		return err
+	}
+	// The other buttons are kept, so that the word can still be saved.
+	if err := removeButton(s, q); err != nil {
+		return err
+	}
+	if more == nil {
+		return nil
+	}
+	return s.Telegram.SendMessage(&MessageReply{
+		ChatId:   chatID,
+		Text:     more.Text,
+		Entities: more.Entities,
+	})
+}
+
+func (MoreDefinitionsCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == MoreDefinitionsAction
+}
+
+func (c MoreDefinitionsCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Show more definitions"),
+		CallbackData: CallbackInfo{
+			Action:  MoreDefinitionsAction,
+			Word:    c.Word,
+			Setting: c.Language,
+		}.String(),
+	}
+}
+
+// PickDefinitionCallback replaces the buttons with the ones saving a single
+// definition, see SaveDefinitionCallback.
+type PickDefinitionCallback struct {
+	Word string
+	// Language in which the word was defined.
+	Language string
+}
+
+func (PickDefinitionCallback) Call(s *State, q *CallbackQuery) error {
+	// The definitions may have to be fetched again.
+	s.AnswerCallback(q, "")
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	ds, _, err := defineAgain(s, chatID, info.Word, info.Setting)
+	if err != nil {
+		return err
+	}
+	var cs []Callback
+	for i := range senses(ds[0]) {
+		cs = append(cs, SaveDefinitionCallback{info.Word, info.Setting, i + 1})
+	}
+	return editKeyboard(s, q.Message, cs)
+}
+
+func (PickDefinitionCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == PickDefinitionAction
+}
+
+func (c PickDefinitionCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Pick definition"),
+		CallbackData: CallbackInfo{
+			Action:  PickDefinitionAction,
+			Word:    c.Word,
+			Setting: c.Language,
+		}.String(),
+	}
+}
+
+// SaveDefinitionCallback saves the word with only one of its definitions.
+type SaveDefinitionCallback struct {
+	Word string
+	// Language in which the word was defined.
+	Language string
+	// Number of the definition, starting from 1.
+	Number int
+}
+
+func (SaveDefinitionCallback) Call(s *State, q *CallbackQuery) error {
+	// Answered before saving the card, the edited buttons are the outcome.
+	s.AnswerCallback(q, "")
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	word := info.Word
+	p, err := s.Repetitions.Progress(chatID, word)
+	if err == nil {
+		return offerSavedCardChoices(s, q, word, p)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	ds, _, err := defineAgain(s, chatID, word, info.Setting)
+	if err != nil {
+		return err
+	}
+	ss := senses(ds[0])
+	n, err := strconv.Atoi(info.Index)
+	if err != nil || n < 1 || n > len(ss) {
+		return fmt.Errorf("INTERNAL: no definition %q of %q", info.Index, word)
+	}
+	language := info.Setting
+	if language == "" {
+		settings, err := s.Settings.Get(chatID)
+		if err != nil {
+			return err
+		}
+		language = settings.InputLanguageISO639_3
+	}
+	if err := saveCard(s, chatID, word, word+"\n\n"+ss[n-1], language); err != nil {
+		return err
+	}
+	return editKeyboard(s, q.Message, nil)
+}
+
+func (SaveDefinitionCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == SaveDefinitionAction
+}
+
+func (c SaveDefinitionCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: fmt.Sprintf("#%d", c.Number),
+		CallbackData: CallbackInfo{
+			Action:  SaveDefinitionAction,
+			Word:    c.Word,
+			Setting: c.Language,
+			Index:   strconv.Itoa(c.Number),
+		}.String(),
+	}
+}
+
+// offerSavedCardChoices shows the progress of the word that was saved before
+// and lets the user decide what to do with the definition in the message. The
+// callback is answered by then, so the progress is sent as a message.
+func offerSavedCardChoices(s *State, q *CallbackQuery, word string, p *CardProgress) error {
+	chatID := q.Message.Chat.Id
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	if err := editKeyboard(s, q.Message, []Callback{
+		AppendDefinitionCallback{word},
+		ResetProgressCallback{word},
+		KeepCardCallback{word},
+	}); err != nil {
+		return err
+	}
+	return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("%q is already saved: stage %d of %d, next review on %s.",
+		word, p.Stage+1, p.Stages, p.NextReview.In(settings.Location()).Format("2006-01-02 15:04")))
+}
+
+// AppendDefinitionCallback adds the text of the message to the definition of
+// the saved word.
+type AppendDefinitionCallback struct {
+	Word string
+}
+
+func (AppendDefinitionCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	word := CallbackInfoFromString(q.Data).Word
+	// The outcome is known from the button, so it's shown before the card is
+	// changed.
+	s.AnswerCallback(q, s.L(chatID).T("Added the definition to the card for %q", word))
+	if err := s.Repetitions.AppendDefinition(chatID, word, q.Message.Text); err != nil {
+		return err
+	}
+	return editKeyboard(s, q.Message, nil)
+}
+
+func (AppendDefinitionCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == AppendDefinitionAction
+}
+
+func (c AppendDefinitionCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Append this definition"),
+		CallbackData: CallbackInfo{
+			Action: AppendDefinitionAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// KeepCardCallback leaves the saved word as it is.
+type KeepCardCallback struct {
+	Word string
+}
+
+func (KeepCardCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	word := CallbackInfoFromString(q.Data).Word
+	s.AnswerCallback(q, s.L(chatID).T("Kept the card for %q as is", word))
+	return editKeyboard(s, q.Message, nil)
+}
+
+func (KeepCardCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == KeepCardAction
+}
+
+func (c KeepCardCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Keep as is"),
+		CallbackData: CallbackInfo{
+			Action: KeepCardAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// UpdateCardCallback replaces the definition of the saved word with the text
+// of the message.
+type UpdateCardCallback struct {
+	Word string
+}
+
+func (UpdateCardCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	word := CallbackInfoFromString(q.Data).Word
+	// The outcome is known from the button, so it's shown before the card is
+	// changed.
+	s.AnswerCallback(q, s.L(chatID).T("Updated the card for %q", word))
+	if err := s.Repetitions.UpdateDefinition(chatID, word, q.Message.Text); err != nil {
+		return err
+	}
+	return editKeyboard(s, q.Message, nil)
+}
+
+func (UpdateCardCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == UpdateCardAction
+}
+
+func (c UpdateCardCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Update card"),
+		CallbackData: CallbackInfo{
+			Action: UpdateCardAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+type TranslationLanguageCallback struct {
+	Language string
+	Enabled  bool
+}
+
+func (TranslationLanguageCallback) Call(s *State, q *CallbackQuery) error {
+	// Answered before the settings are changed, the edited buttons are the
+	// outcome and errors are sent as messages.
+	s.AnswerCallback(q, "")
+	chatID := q.Message.Chat.Id
+	language := CallbackInfoFromString(q.Data).Setting
+	settings, err := s.Settings.ToggleTranslationLanguage(chatID, language)
+	if err != nil {
+		return UserError{ChatID: chatID, Err: err}
+	}
+	m := q.Message
+	ik, err := translationLanguagesKeyboard(s, settings)
+	if err != nil {
+		return err
+	}
+	r := &EditMessageText{
+		ChatId:    m.Chat.Id,
+		MessageId: m.Id,
+		ReplyMarkup: ReplyMarkup{
+			InlineKeyboard: [][]*InlineKeyboard{ik},
+		},
+	}
+	var rm Message
+	if err := s.Telegram.Call("editMessageReplyMarkup", r, &rm); err != nil {
+		return fmt.Errorf("editing message reply markup: %w", err)
+	}
+	return nil
+}
+
+func (TranslationLanguageCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == ToggleTranslationAction
+}
+
+func (c TranslationLanguageCallback) AsInlineKeyboard(*Localizer) *InlineKeyboard {
+	t := "❌ " + c.Language
+	if c.Enabled {
+		t = "✅ " + c.Language
+	}
+	return &InlineKeyboard{
+		Text: t,
+		CallbackData: CallbackInfo{
+			Action:  ToggleTranslationAction,
+			Setting: c.Language,
+		}.String(),
+	}
+}
+
+// translationLanguagesKeyboard returns toggle buttons for all translation
+// languages available with the settings.
+func translationLanguagesKeyboard(state *State, s *Settings) ([]*InlineKeyboard, error) {
+	ls, err := state.Settings.AvailableTranslationLanguages(s)
+	if err != nil {
+		return nil, err
+	}
+	var ik []*InlineKeyboard
+	for _, l := range ls {
+		ik = append(ik, TranslationLanguageCallback{l, s.TranslationLanguages[l]}.AsInlineKeyboard(nil))
+	}
+	return ik, nil
+}
+
+// ShowAnswerCallback flips the card shown out of schedule. Progress changes
+// only if the user answers after that.
+type ShowAnswerCallback struct {
+	Word string
+	// If true the card is answered as a practice card and the practice
+	// continues after the answer, e.g. for the card shown in a reminder.
+	Practice bool
+}
+
+func (ShowAnswerCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	l := s.L(chatID)
+	info := CallbackInfoFromString(q.Data)
+	word := info.Word
+	practice := info.Action == ShowAnswerPracticeAction
+	if practice {
+		s.Practice.SetCard(chatID, "", word, q.Message.Id, s.Clock.Now())
+	}
+	err := flipWordCard(s.Clients, word, q.Message, []*InlineKeyboard{
+		KnowCallback{word, practice, ""}.AsInlineKeyboard(l),
+		DontKnowCallback{word, practice, ""}.AsInlineKeyboard(l),
+	})
+	toast := ""
+	if err != nil {
+		if toast = editFailure(s, chatID, err); toast == "" {
+			return err
+		}
+	}
+	s.AnswerCallback(q, toast)
+	return nil
+}
+
+func (ShowAnswerCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == ShowAnswerAction || info.Action == ShowAnswerPracticeAction
+}
+
+func (c ShowAnswerCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	a := ShowAnswerAction
+	if c.Practice {
+		a = ShowAnswerPracticeAction
+	}
+	return &InlineKeyboard{
+		Text: l.T("Show answer"),
+		CallbackData: CallbackInfo{
+			Action: a,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// SwitchLanguageCallback makes one of the studied languages the input language.
+type SwitchLanguageCallback struct {
+	Language *Language
+	Current  bool
+}
+
+func (SwitchLanguageCallback) Call(s *State, q *CallbackQuery) error {
+	// Answered before the settings are changed, the edited buttons are the
+	// outcome and errors are sent as messages.
+	s.AnswerCallback(q, "")
+	chatID := q.Message.Chat.Id
+	language := CallbackInfoFromString(q.Data).Setting
+	if err := s.Settings.AddLanguage(chatID, language); err != nil {
+		return UserError{ChatID: chatID, Err: err}
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	cs, err := studiedLanguagesKeyboard(s, settings, false)
+	if err != nil {
+		return err
+	}
+	return editKeyboard(s, q.Message, cs)
+}
+
+func (SwitchLanguageCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == SwitchLanguageAction
+}
+
+func (c SwitchLanguageCallback) AsInlineKeyboard(*Localizer) *InlineKeyboard {
+	t := c.Language.Name
+	if c.Current {
+		t = "✅ " + t
+	}
+	return &InlineKeyboard{
+		Text: t,
+		CallbackData: CallbackInfo{
+			Action:  SwitchLanguageAction,
+			Setting: c.Language.ISO639_3,
+		}.String(),
+	}
+}
+
+// RemoveLanguageCallback stops studying the language.
+type RemoveLanguageCallback struct {
+	Language *Language
+}
+
+func (RemoveLanguageCallback) Call(s *State, q *CallbackQuery) error {
+	// Answered before the settings are changed, the edited buttons are the
+	// outcome and errors are sent as messages.
+	s.AnswerCallback(q, "")
+	chatID := q.Message.Chat.Id
+	l, err := s.Settings.Languages.Find(CallbackInfoFromString(q.Data).Setting)
+	if err != nil {
+		return err
+	}
+	if err := s.Settings.RemoveLanguage(chatID, l.ISO639_3); err != nil {
+		return UserError{ChatID: chatID, Err: err}
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	cs, err := studiedLanguagesKeyboard(s, settings, true)
+	if err != nil {
+		return err
+	}
+	return editKeyboard(s, q.Message, cs)
+}
+
+func (RemoveLanguageCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == RemoveLanguageAction
+}
+
+func (c RemoveLanguageCallback) AsInlineKeyboard(*Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: "❌ " + c.Language.Name,
+		CallbackData: CallbackInfo{
+			Action:  RemoveLanguageAction,
+			Setting: c.Language.ISO639_3,
+		}.String(),
+	}
+}
+
+// studiedLanguagesKeyboard returns a button for every studied language, to
+// remove it if remove is true and to switch to it otherwise. There are no
+// removal buttons for the only language.
+func studiedLanguagesKeyboard(state *State, s *Settings, remove bool) ([]Callback, error) {
+	codes := s.Languages()
+	if remove && len(codes) == 1 {
+		return nil, nil
+	}
+	var cs []Callback
+	for _, code := range codes {
+		l, err := state.Settings.Languages.Find(code)
+		if err != nil {
+			return nil, err
+		}
+		if remove {
+			cs = append(cs, RemoveLanguageCallback{l})
+		} else {
+			cs = append(cs, SwitchLanguageCallback{l, code == s.InputLanguageISO639_3})
+		}
+	}
+	return cs, nil
+}
+
+type StartPracticeCallback struct{}
+
+func (StartPracticeCallback) Call(s *State, q *CallbackQuery) error {
+	s.AnswerCallback(q, "")
+	return startPractice(s, q.Message.Chat.Id, "", false)
+}
+
+func (StartPracticeCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == StartPracticeAction
+}
+
+func (StartPracticeCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Start practice"),
+		CallbackData: CallbackInfo{
+			Action: StartPracticeAction,
+		}.String(),
+	}
+}
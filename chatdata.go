@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Data of a chat across all tables, so that users can wipe it with
+// /deletemydata.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// chatTables are all tables that keep rows of a chat in the chat_id column.
+// The Definitions cache isn't here, it's shared by all chats.
+var chatTables = []string{
+	"Repetition",
+	"ReviewLog",
+	"Settings",
+	"Reminders",
+	"Summaries",
+	"StreakWarnings",
+	"GoalNudges",
+	"ReviewQueues",
+	"ReminderPrompts",
+	"AnkiConfigs",
+	"AnkiCards",
+	"Decks",
+	"DeckFollowers",
+	"APITokens",
+	"WebLogins",
+	"WebSessions",
+	"InactiveChats",
+}
+
+// ChatData manages rows of a chat in all tables.
+type ChatData struct {
+	db *sql.DB
+}
+
+// Delete removes all rows of the chat at once.
+func (c *ChatData) Delete(chatID int64) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("INTERNAL: deleting data of chat %d: %w", chatID, err)
+	}
+	defer tx.Rollback()
+	for _, t := range chatTables {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE chat_id = $0", t), chatID); err != nil {
+			return fmt.Errorf("INTERNAL: deleting data of chat %d from %s: %w", chatID, t, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("INTERNAL: deleting data of chat %d: %w", chatID, err)
+	}
+	return nil
+}
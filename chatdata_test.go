@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChatDataDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chatdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// All tables with rows of chats have to be wiped.
+	var withChatID []string
+	rows, err := db.Query(`
+		SELECT m.name
+		FROM sqlite_master AS m, pragma_table_info(m.name) AS c
+		WHERE m.type = 'table' AND c.name = 'chat_id'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		withChatID = append(withChatID, n)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string(nil), chatTables...)
+	sort.Strings(want)
+	sort.Strings(withChatID)
+	if diff := cmp.Diff(want, withChatID); diff != "" {
+		t.Errorf("chatTables don't match tables with chat_id (-want +got):\n%s", diff)
+	}
+
+	r, err := NewRepetition(db, []time.Duration{0}, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chatID := range []int64{1, 2} {
+		if err := r.Save(chatID, "word", "definition", "hun"); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.AnswerKnow(chatID, "word"); err != nil {
+			t.Fatal(err)
+		}
+		for _, tb := range []string{"Settings", "Reminders", "Summaries", "StreakWarnings", "GoalNudges", "ReviewQueues", "ReminderPrompts", "AnkiConfigs", "AnkiCards", "Decks", "DeckFollowers", "APITokens", "WebLogins", "WebSessions", "InactiveChats"} {
+			if _, err := db.Exec(fmt.Sprintf("INSERT INTO %s(chat_id) VALUES($0)", tb), chatID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cd := &ChatData{db}
+	if err := cd.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	count := func(table string, chatID int64) int {
+		t.Helper()
+		var n int
+		row := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE chat_id = $0", table), chatID)
+		if err := row.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	for _, tb := range chatTables {
+		if n := count(tb, 1); n != 0 {
+			t.Errorf("%s: got %d rows of the deleted chat want 0", tb, n)
+		}
+		if n := count(tb, 2); n != 1 {
+			t.Errorf("%s: got %d rows of the other chat want 1", tb, n)
+		}
+	}
+}
@@ -15,10 +15,16 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -44,34 +50,6 @@ const (
 const UsePractice = PracticeKnowledge
 
 var (
-	SupportedInputLanguages = map[string]Settings{
-		"Hungarian": Settings{
-			InputLanguage:         "Hungarian",
-			InputLanguageISO639_3: "hun",
-			TranslationLanguages: map[string]bool{
-				"eng": true,
-				"rus": true,
-				"ukr": true,
-			},
-		},
-		"English": Settings{
-			InputLanguage:         "English",
-			InputLanguageISO639_3: "eng",
-			TranslationLanguages: map[string]bool{
-				"rus": true,
-				"ukr": true,
-			},
-		},
-		"German": Settings{
-			InputLanguage:         "German",
-			InputLanguageISO639_3: "deu",
-			TranslationLanguages: map[string]bool{
-				"eng": true,
-				"rus": true,
-				"ukr": true,
-			},
-		},
-	}
 	TimeZones = func() map[string]bool {
 		timeZones := make(map[string]bool)
 		for i := -12; i < 12; i++ {
@@ -89,6 +67,35 @@ const (
 	PracticeKnowAction
 	PracticeDontKnowAction
 	PracticeDontKnowActionNoPractice
+	ToggleTranslationAction
+	StartPracticeAction
+	ResetProgressAction
+	ConfirmAction
+	DeclineAction
+	QuizKnowAction
+	QuizDontKnowAction
+	UpdateCardAction
+	PracticeKnowActionNoPractice
+	ShowAnswerAction
+	AppendDefinitionAction
+	KeepCardAction
+	SwitchLanguageAction
+	RemoveLanguageAction
+	ContinuePracticeAction
+	StudyAheadAction
+	MoreDefinitionsAction
+	PickDefinitionAction
+	SaveDefinitionAction
+	AddNoteAction
+	LearnWordAction
+	SnoozeAction
+	StarAction
+	PracticeStarredAction
+	DefineWordAction
+	ListenAction
+	MatchAction
+	ShowAnswerPracticeAction
+	AttachImageAction
 )
 
 // Make sure all fields are Public, otherwise encoding will not work
@@ -100,28 +107,93 @@ type CallbackInfo struct {
 	// One of below is set depending on the action.
 	Word    string
 	Setting string
+	// Name of the ConfirmCommand for ConfirmAction and DeclineAction.
+	Confirm string `json:",omitempty"`
+	// Index of the chosen item, e.g. of the definition to save.
+	Index string `json:",omitempty"`
 }
 
+// callbackVersion is the first byte of the encoded CallbackInfo. Buttons stay
+// in chats forever, so decoding of older versions must be kept.
+//
+// Version 1 is the action as uvarint followed by Word, Setting, Confirm and
+// Index, each as uvarint length and bytes. Missing trailing fields are empty, so new
+// fields can be appended without a new version.
+const callbackVersion = 1
+
 // FIXME: Should return an error?
 func CallbackInfoFromString(s string) CallbackInfo {
-	var c CallbackInfo
-	if err := json.Unmarshal([]byte(s), &c); err != nil {
-		panic(err)
+	c, err := decodeCallbackInfo(s)
+	if err != nil {
+		panic(fmt.Sprintf("decoding callback data %q: %v", s, err))
 	}
 	return c
 }
 
-func (c CallbackInfo) String() string {
-	m, err := json.Marshal(c)
+func decodeCallbackInfo(s string) (CallbackInfo, error) {
+	var c CallbackInfo
+	// Buttons sent before versioning have JSON, which is never valid
+	// base64.
+	if strings.HasPrefix(s, "{") {
+		err := json.Unmarshal([]byte(s), &c)
+		return c, err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if len(b) == 0 || b[0] != callbackVersion {
+		return c, errors.New("unknown version")
+	}
+	r := bytes.NewReader(b[1:])
+	a, err := binary.ReadUvarint(r)
 	if err != nil {
-		panic(err)
+		return c, fmt.Errorf("action: %w", err)
+	}
+	c.Action = CallbackAction(a)
+	for _, f := range []*string{&c.Word, &c.Setting, &c.Confirm, &c.Index} {
+		if r.Len() == 0 {
+			break
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return c, err
+		}
+		if n > uint64(r.Len()) {
+			return c, io.ErrUnexpectedEOF
+		}
+		v := make([]byte, n)
+		r.Read(v)
+		*f = string(v)
+	}
+	return c, nil
+}
+
+func (c CallbackInfo) String() string {
+	b := []byte{callbackVersion}
+	b = appendUvarint(b, uint64(c.Action))
+	fs := []string{c.Word, c.Setting, c.Confirm, c.Index}
+	// Trailing empty fields are omitted.
+	for len(fs) > 0 && fs[len(fs)-1] == "" {
+		fs = fs[:len(fs)-1]
+	}
+	for _, f := range fs {
+		b = appendUvarint(b, uint64(len(f)))
+		b = append(b, f...)
 	}
-	return string(m)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
 }
 
 type Commander struct {
 	*Clients
 	bot *Bot
+	// reminder is nil if reminders are disabled.
+	reminder *Reminder
 }
 
 type CommanderOptions struct {
@@ -132,69 +204,109 @@ type CommanderOptions struct {
 	keyPath  string
 	ip       string
 	push     bool
-	stages   []time.Duration
-}
-
-func escapeMarkdown(s string) string {
-	r := strings.NewReplacer(
-		"_", "\\_",
-		"*", "\\*",
-		"[", "\\[",
-		"]", "\\]",
-		"(", "\\(",
-		")", "\\)",
-		"~", "\\~",
-		"`", "\\`",
-		">", "\\>",
-		"#", "\\#",
-		"+", "\\+",
-		"-", "\\-",
-		"=", "\\=",
-		"|", "\\|",
-		"{", "\\{",
-		"}", "\\}",
-		".", "\\.",
-		"!", "\\!",
-	)
-	return r.Replace(s)
+	// webhookURL is where a reverse proxy terminating TLS forwards updates
+	// to the plain HTTP port. TLS is served directly if it's empty.
+	webhookURL string
+	// webhookSecret is expected in every pushed update if not empty.
+	webhookSecret string
+	// api serves APIHandler next to the webhook.
+	api bool
+	// dashboard serves WebHandler next to the webhook.
+	dashboard bool
+	// ocr recognizes words on photos, which are rejected if it's nil.
+	ocr OCR
+	// tts speaks card fronts, there is no audio if it's nil.
+	tts TextToSpeech
+	// anki lets users sync their cards with AnkiConnect.
+	anki   bool
+	stages []time.Duration
+	// reminderInterval is how often to check whether reminders should be
+	// sent. Reminders are disabled if it's 0.
+	reminderInterval time.Duration
+	// rateLimit is the maximum number of updates per minute from a single
+	// chat. Rate limiting is disabled if it's 0.
+	rateLimit int
+	// admins are chat IDs allowed to use admin commands.
+	admins map[int64]bool
+	// backupDir is where snapshots of the database are written. Backups
+	// are disabled if it's empty.
+	backupDir string
+	// backupInterval is how often to take a snapshot. Only /admin backup
+	// takes them if it's 0.
+	backupInterval time.Duration
+	// backupKeep is the number of the latest snapshots to keep, 0 keeps all.
+	backupKeep int
+	// backupUpload sends scheduled snapshots to admins.
+	backupUpload bool
+	// maintenanceInterval is how often to run maintenance of the database.
+	// Only /admin maintenance runs it if it's 0.
+	maintenanceInterval time.Duration
+	// cacheTTL is how long cached definitions are kept, 0 keeps them
+	// forever.
+	cacheTTL time.Duration
+	// errorChat receives internal errors. Relaying is disabled if it's 0.
+	errorChat int64
+	// clock is the time of scheduling, SystemClock if nil.
+	clock Clock
+	// bots are served by the same process next to the main one.
+	bots []botConfig
+	// corpus is shared with the main bot, the commander builds its own if
+	// it's nil.
+	corpus *Corpus
 }
 
 func NewCommander(tm *Telegram, opts *CommanderOptions) (*Commander, error) {
-	hc := &http.Client{}
-	var cache DefCacheInterface
-	if opts.useCache {
-		var err error
-		cache, err = NewDefCache(opts.dbPath)
-		if err != nil {
-			return nil, fmt.Errorf("new cache(%q): %w", opts.dbPath, err)
-		}
-	} else {
-		cache = &NoCache{}
-	}
-	// TODO: Can use errgroup if there is a need to paralelize. This is the
-	// slowest step in initialization.
-	uf, err := NewUsageFetcher(opts.dbPath)
+	// All components share the database, so that sqlite connections are
+	// pooled in one place.
+	db, err := OpenDB(opts.dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("creating usage fetcher: %w", err)
+		return nil, err
+	}
+	clock := opts.clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	corpus := opts.corpus
+	if corpus == nil {
+		if corpus, err = NewCorpus(db, opts.useCache, clock); err != nil {
+			return nil, err
+		}
 	}
-	sc, err := NewSettingsConfig(opts.dbPath)
+	sc, err := NewSettingsConfig(db)
 	if err != nil {
 		return nil, fmt.Errorf("creating settings config: %w", err)
 	}
-	d := &Definer{
-		usage: uf,
-		cache: cache,
-		http:  hc,
+	r, err := NewRepetition(db, opts.stages, clock)
+	if err != nil {
+		return nil, err
 	}
-	r, err := NewRepetition(opts.dbPath, opts.stages)
+	inactive, err := NewInactiveChats(db, clock)
 	if err != nil {
 		return nil, err
 	}
 	c := &Clients{
 		Telegram:    tm,
-		Definer:     d,
+		Definer:     corpus.Definer,
+		Usage:       corpus.Usage,
 		Repetitions: r,
 		Settings:    sc,
+		Quizzes:     NewQuizzes(clock),
+		Matches:     NewMatches(),
+		Practice:    NewPracticeSessions(),
+		Imports:     NewImports(),
+		Lookups:     NewRecentLookups(),
+		ChatData:    &ChatData{db},
+		Decks:       &Decks{db: db, clock: clock},
+		Inactive:    inactive,
+		Clock:       clock,
+		Admin: &Admin{
+			Errors: NewErrorLog(clock),
+			Broadcaster: &Broadcaster{
+				send:         tm.SendTextMessage,
+				markInactive: inactive.Mark,
+				interval:     broadcastInterval,
+			},
+		},
 	}
 
 	// Make sure that telegram client is setup correctly
@@ -202,17 +314,107 @@ func NewCommander(tm *Telegram, opts *CommanderOptions) (*Commander, error) {
 	if err := tm.Call("getMe", nil, &raw); err != nil {
 		return nil, err
 	}
-	log.Printf("getMe: %s", string(raw))
+	slog.Info("getMe", "bot", string(raw))
+
+	if opts.api {
+		c.APITokens = &APITokens{db: db, clock: clock}
+	}
+	c.OCR, c.TTS = opts.ocr, opts.tts
+	if opts.anki {
+		c.Anki = NewAnki(db, clock)
+	}
+	if opts.dashboard {
+		url := fmt.Sprintf("https://%s:%d/web/", opts.ip, opts.port)
+		if opts.webhookURL != "" {
+			url = strings.TrimSuffix(opts.webhookURL, "/") + "/web/"
+		}
+		c.Web = &WebSessions{db: db, clock: clock, url: url}
+	}
+
+	c.Admin.Maintenance = &Maintenance{db: db, clock: clock, cacheTTL: opts.cacheTTL}
+	if opts.backupDir != "" {
+		c.Admin.Backups = &Backups{db: db, clock: clock, dir: opts.backupDir, keep: opts.backupKeep}
+		if opts.backupUpload {
+			c.Admin.Backups.upload = func(path string) error {
+				for id := range opts.admins {
+					if err := tm.SendDocument(id, path); err != nil {
+						return fmt.Errorf("sending to chat %d: %w", id, err)
+					}
+				}
+				return nil
+			}
+		}
+	}
+
+	var rm *Reminder
+	if opts.reminderInterval > 0 {
+		if rm, err = NewReminder(c, db); err != nil {
+			return nil, fmt.Errorf("creating reminder: %w", err)
+		}
+	}
+
+	mws := []Middleware{Logging(), Metrics(updateMetrics), RecordErrors(c.Admin.Errors), Reactivate(inactive)}
+	if opts.errorChat != 0 {
+		mws = append(mws, RelayErrors(opts.errorChat, tm.SendTextMessage))
+	}
+	if opts.rateLimit > 0 {
+		mws = append(mws, RateLimit(opts.rateLimit, time.Minute, clock))
+	}
+	mws = append(mws, AdminOnly(opts.admins, adminCommand), Recover())
 
 	return &Commander{
 		Clients: c,
 		bot: &Bot{
-			state:   &State{c},
-			command: make(map[int64]Command),
+			state:      &State{Clients: c},
+			command:    make(map[int64]Command),
+			middleware: mws,
 		},
+		reminder: rm,
 	}, nil
 }
 
+// StartReminders sends reminders every interval in the background until ctx
+// is done. It does nothing if reminders are disabled.
+func (c *Commander) StartReminders(ctx context.Context, interval time.Duration) {
+	if c.reminder == nil {
+		slog.Info("Reminders are disabled")
+		return
+	}
+	t := time.NewTicker(interval)
+	go func() {
+		defer t.Stop()
+		c.reminder.Loop(t.C, ctx.Done())
+	}()
+}
+
+// StartBackups takes snapshots every interval in the background until ctx is
+// done. It does nothing if backups are disabled or not scheduled.
+func (c *Commander) StartBackups(ctx context.Context, interval time.Duration) {
+	if c.Admin.Backups == nil || interval <= 0 {
+		slog.Info("Scheduled backups are disabled")
+		return
+	}
+	t := time.NewTicker(interval)
+	go func() {
+		defer t.Stop()
+		c.Admin.Backups.Loop(t.C, ctx.Done())
+	}()
+}
+
+// StartMaintenance runs maintenance of the database every interval in the
+// background until ctx is done. It does nothing if it's not scheduled.
+func (c *Commander) StartMaintenance(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		slog.Info("Scheduled maintenance is disabled")
+		return
+	}
+	t := time.NewTicker(interval)
+	go func() {
+		defer t.Stop()
+		c.Admin.Maintenance.Loop(t.C, ctx.Done())
+	}()
+}
+
 // Update processes the user's update and spit out output.
 // Should return an error only on unrecoverable errors due to which we cannot
 // continue execution.
@@ -221,7 +423,7 @@ func (c *Commander) Update(u *Update) error {
 	err := c.bot.Update(u)
 	if err != nil {
 		// Not sure what to do otherwise, but crashing isn't nice.
-		log.Printf("INTENAL ERROR for update %v: %v", u, err)
+		u.Logger().Error("Processing update", "err", err)
 	}
 	return nil
 }
@@ -232,10 +434,7 @@ func (c *Commander) PollAndProcess() error {
 	if err != nil {
 		return err
 	}
-	log.Printf("updates: %v", updates)
-	if len(updates) > 0 {
-		log.Printf("sample message: %v", updates[0].Message)
-	}
+	slog.Debug("Polled updates", "count", len(updates))
 	// query:
 	// for definitions: https://ertelmezo.oszk.hu/kereses.php?kereses=dal
 	// for wiktionary:
@@ -252,37 +451,67 @@ func (c *Commander) PollAndProcess() error {
 	return nil
 }
 
-func (c *Commander) handleUpdate(req *http.Request) error {
-	if req.Method != "POST" {
-		return fmt.Errorf("want POST; got %s", req.Method)
-	}
-	b := new(bytes.Buffer)
-	if _, err := b.ReadFrom(req.Body); err != nil {
-		return fmt.Errorf("reading body of req %v: %v", req, err)
-	}
-	var update Update
-	if err := json.Unmarshal(b.Bytes(), &update); err != nil {
-		return fmt.Errorf("json.Unmarshal(%q): %w", b.String(), err)
+// WebhookHandler processes updates pushed by telegram. Requests without the
+// secret in secretTokenHeader are rejected, unless secret is empty.
+func (c *Commander) WebhookHandler(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			slog.Warn("Webhook: unexpected method", "method", req.Method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		got := req.Header.Get(secretTokenHeader)
+		if secret != "" && subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			slog.Warn("Webhook: wrong secret token", "remote_addr", req.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b := new(bytes.Buffer)
+		if _, err := b.ReadFrom(req.Body); err != nil {
+			slog.Error("Webhook: reading body", "err", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var update Update
+		if err := json.Unmarshal(b.Bytes(), &update); err != nil {
+			slog.Error("Webhook: malformed update", "body", b.String(), "err", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := c.Update(&update); err != nil {
+			slog.Error("Webhook", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
 	}
-	return c.Update(&update)
 }
 
-func (c *Commander) WebhookCallback(w http.ResponseWriter, req *http.Request) {
-	if err := c.handleUpdate(req); err != nil {
-		log.Printf("INTERNAL ERROR[Webhook]: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+// StartPush serves webhooks of c and the other bots of the process on one
+// server, each under the path of its token. The API and the dashboard are
+// served only for c.
+func (c *Commander) StartPush(opts *CommanderOptions, others []*Commander) error {
+	bots := append([]*Commander{c}, others...)
+	mux := http.NewServeMux()
+	for _, b := range bots {
+		mux.Handle("/"+b.Telegram.token, b.WebhookHandler(opts.webhookSecret))
 	}
-	w.WriteHeader(http.StatusOK)
-}
-
-func (c *Commander) StartPush(opts *CommanderOptions) error {
-	addr := fmt.Sprintf("https://%s:%d/%s", opts.ip, opts.port, BotToken)
-	if err := c.Telegram.SetWebhook(addr, opts.certPath); err != nil {
-		return err
+	if opts.api {
+		mux.Handle("/api/", APIHandler(c.bot.state))
+	}
+	if opts.dashboard {
+		mux.Handle("/web/", WebHandler(c.bot.state))
+	}
+	if opts.webhookURL != "" {
+		return startPlainPush(opts, mux, bots)
+	}
+	addr := fmt.Sprintf("https://%s:%d/", opts.ip, opts.port)
+	for _, b := range bots {
+		if err := b.Telegram.SetWebhook(addr+b.Telegram.token, opts.certPath, opts.webhookSecret); err != nil {
+			return err
+		}
+		b.Telegram.LogWebhookInfo()
 	}
-	c.Telegram.LogWebhookInfo()
-	mux := http.NewServeMux()
-	mux.HandleFunc("/"+BotToken, c.WebhookCallback)
 	cfg := &tls.Config{
 		MinVersion:               tls.VersionTLS12,
 		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
@@ -300,22 +529,60 @@ func (c *Commander) StartPush(opts *CommanderOptions) error {
 		TLSConfig:    cfg,
 		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
 	}
-	log.Printf("Starting serving on %s", addr)
+	slog.Info("Starting serving", "addr", addr)
 	return srv.ListenAndServeTLS(opts.certPath, opts.keyPath)
 }
 
-// TODO: Accept time.Ticker channel -> Will give an ability to inline
-// PollAndProcess and test Start in addition to the rest.
+// startPlainPush serves the webhooks on plain HTTP, for running behind a
+// reverse proxy that terminates TLS at opts.webhookURL.
+func startPlainPush(opts *CommanderOptions, mux *http.ServeMux, bots []*Commander) error {
+	for _, b := range bots {
+		url := strings.TrimSuffix(opts.webhookURL, "/") + "/" + b.Telegram.token
+		if err := b.Telegram.SetWebhook(url, "", opts.webhookSecret); err != nil {
+			return err
+		}
+		b.Telegram.LogWebhookInfo()
+	}
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", opts.port),
+		Handler: mux,
+	}
+	slog.Info("Starting serving plain HTTP", "addr", srv.Addr, "webhook_url", opts.webhookURL)
+	return srv.ListenAndServe()
+}
+
+const (
+	pollInterval   = 3 * time.Second
+	maxPollBackoff = 5 * time.Minute
+)
+
+// StartPoll processes updates until polling fails with an unrecoverable error.
 func (c *Commander) StartPoll() error {
 	// Reset webhook, otherwise getUpdates would not work!
-	if err := c.Telegram.SetWebhook("", ""); err != nil {
+	if err := c.Telegram.SetWebhook("", "", ""); err != nil {
 		return err
 	}
 	c.Telegram.LogWebhookInfo()
+	return pollLoop(c.PollAndProcess, time.Sleep)
+}
+
+// pollLoop calls poll every pollInterval. Failures, e.g. network hiccups, are
+// retried with exponential backoff up to maxPollBackoff, only unrecoverable
+// errors, like a wrong bot token, are returned.
+func pollLoop(poll func() error, sleep func(time.Duration)) error {
+	wait := pollInterval
 	for {
-		if err := c.PollAndProcess(); err != nil {
+		err := poll()
+		var se *StatusError
+		switch {
+		case err == nil:
+			wait = pollInterval
+		case errors.As(err, &se) && se.Unrecoverable():
 			return err
+		default:
+			wait = min(2*wait, maxPollBackoff)
+			slog.Warn("Polling failed", "err", err, "retry_in", wait)
 		}
-		time.Sleep(time.Second * 3)
+		sleep(wait)
 	}
 }
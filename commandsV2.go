@@ -19,23 +19,52 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 type Callback interface {
 	Call(*State, *CallbackQuery) error
 	Match(*State, *CallbackQuery) bool
-	AsInlineKeyboard() *InlineKeyboard
+	AsInlineKeyboard(*Localizer) *InlineKeyboard
+}
+
+// commandCallback is implemented by the callbacks that start a command, e.g.
+// to ask a question. StartCommand is used instead of Call for them.
+type commandCallback interface {
+	StartCommand(*State, *CallbackQuery) (Command, error)
 }
 
 type State struct {
 	*Clients
+	// Log tags lines with the update being processed.
+	Log *slog.Logger
+	// answered is true once the callback query of the update is answered,
+	// telegram accepts a single answer.
+	answered bool
+}
+
+// AnswerCallback shows the text as a toast and stops the spinner on the
+// button. Callbacks answer as soon as the outcome is known, before slow edits
+// and messages, or with an empty text before a slow outcome. Later answers are
+// ignored, Bot.handle answers the callbacks that didn't.
+func (s *State) AnswerCallback(q *CallbackQuery, text string) {
+	if s.answered {
+		return
+	}
+	s.answered = true
+	s.Telegram.AnswerCallbackLog(q.Id, text)
 }
 
 // TODO:
@@ -49,12 +78,65 @@ func (s *State) SaveCommand(chatID int64, _ *SerializedCommand) error {
 }
 
 type Bot struct {
-	state   *State
+	state *State
+	// mu guards command and locks.
+	mu      sync.Mutex
 	command map[int64]Command
+	// locks serialize updates of the same chat, since its command is a state
+	// machine. Updates of different chats are processed concurrently.
+	locks map[int64]*chatLock
+	// middleware wraps processing of every update, the first one is the
+	// outermost.
+	middleware []Middleware
+}
+
+// chatLock is removed from Bot.locks once nobody holds or waits for it.
+type chatLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockChat waits until no other update of the chat is processed and returns a
+// function to let the next one in.
+func (b *Bot) lockChat(chatID int64) (unlock func()) {
+	b.mu.Lock()
+	if b.locks == nil {
+		b.locks = make(map[int64]*chatLock)
+	}
+	l := b.locks[chatID]
+	if l == nil {
+		l = &chatLock{}
+		b.locks[chatID] = l
+	}
+	l.refs++
+	b.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(b.locks, chatID)
+		}
+	}
+}
+
+func (b *Bot) getCommand(chatID int64) Command {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.command[chatID]
 }
 
+func (b *Bot) setCommand(chatID int64, cmd Command) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.command[chatID] = cmd
+}
+
+// fetchCommand and updateCommand must be called with the chat locked.
 func (b *Bot) fetchCommand(chatID int64) Command {
-	cmd := b.command[chatID]
+	cmd := b.getCommand(chatID)
 	if cmd == nil {
 		s, err := b.state.LoadCommand(chatID)
 		if err == nil {
@@ -62,18 +144,18 @@ func (b *Bot) fetchCommand(chatID int64) Command {
 		}
 		if err != nil {
 			cmd = nil
-			log.Printf("INTERNAL ERROR: couldn't fetch command for chat %d: %v", chatID, err)
+			slog.Error("Fetching command", "chat_id", chatID, "err", err)
 		}
 		if cmd == nil {
 			cmd = CommandsTemplate.DefaultCommand("")
 		}
 	}
-	b.command[chatID] = cmd
+	b.setCommand(chatID, cmd)
 	return cmd
 }
 
 func (b *Bot) updateCommand(chatID int64, cmd Command) error {
-	b.command[chatID] = cmd
+	b.setCommand(chatID, cmd)
 	var s *SerializedCommand
 	if cmd != nil {
 		s = cmd.Serialize()
@@ -86,29 +168,61 @@ func (b *Bot) Update(u *Update) (err error) {
 	if err != nil {
 		return err
 	}
+	// Unlocked last, after the command is reset on errors.
+	defer b.lockChat(chatId)()
+	b.tagUpdate(u)
+	s := &State{Clients: b.state.Clients, Log: u.Logger()}
 
 	// Try surfacing UserError and update the bot accordingly on internal error.
 	defer func() {
 		var e UserError
 		if errors.As(err, &e) {
-			err = e.Surface(b.state)
+			err = e.Surface(s)
 		}
 		if err == nil {
 			return
 		}
-		log.Printf("INTERNAL ERROR: %v", err)
+		s.Log.Error("Internal error", "err", err)
 		// Reset command so that user wouldn't be stuck with internal errors
 		// with no way out.
 		if upErr := b.updateCommand(chatId, nil); upErr != nil {
-			log.Printf("INTERNAL ERROR: updateCommand(%d, nil): %v; while handling %v", chatId, upErr, err)
+			s.Log.Error("Resetting command", "err", upErr)
 		}
 	}()
 
+	return chain(func(u *Update) error { return b.handle(s, u) }, b.middleware...)(u)
+}
+
+// handle dispatches the update to the matching callback or command.
+func (b *Bot) handle(s *State, u *Update) (err error) {
+	chatId, _ := u.ChatId()
 	if u.CallbackQuery != nil {
+		defer func() {
+			// User errors are also surfaced as messages, but the toast
+			// shows which tap failed.
+			text := ""
+			var e UserError
+			if errors.As(err, &e) {
+				text = s.L(chatId).Error(e.Err)
+			}
+			s.AnswerCallback(u.CallbackQuery, text)
+		}()
 		for _, c := range CommandsTemplate.Callbacks {
-			if c.Match(b.state, u.CallbackQuery) {
-				return c.Call(b.state, u.CallbackQuery)
+			if !c.Match(s, u.CallbackQuery) {
+				continue
+			}
+			cc, ok := c.(commandCallback)
+			if !ok {
+				return c.Call(s, u.CallbackQuery)
+			}
+			cmd, err := cc.StartCommand(s, u.CallbackQuery)
+			if err != nil {
+				return err
+			}
+			if cmd == nil {
+				cmd = CommandsTemplate.DefaultCommand("")
 			}
+			return b.updateCommand(chatId, cmd)
 		}
 		return fmt.Errorf("INTERNAL ERROR: Did find a corresponding callback for callback query: %v", u.CallbackQuery)
 	}
@@ -119,11 +233,14 @@ func (b *Bot) Update(u *Update) (err error) {
 	}
 
 	// Update is a Message.
-	msg := u.Message.Text
+	name, _ := splitCommand(u.Message.Text)
+	if name == cancelCommand {
+		return b.cancel(chatId, "")
+	}
 	for n, f := range CommandsTemplate.Commands {
-		if msg == n {
+		if name == n {
 			cmd := f(n)
-			cmd, err = cmd.OnCommand(b.state, u.Message)
+			cmd, err = cmd.OnCommand(s, u.Message)
 			if err != nil {
 				return err
 			}
@@ -136,7 +253,20 @@ func (b *Bot) Update(u *Update) (err error) {
 
 	// None of the commands match, so process the message.
 	cmd := b.fetchCommand(chatId)
-	cmd, err = cmd.ProcessMessage(b.state, u.Message)
+	if name != "" {
+		l := s.L(chatId)
+		reason := l.T("%s is not a known command.", name)
+		if ss := suggestCommands(name); len(ss) > 0 {
+			reason += " " + l.T("Did you mean %s?", strings.Join(ss, l.T(" or ")))
+		}
+		if cmd.Serialize() != nil {
+			// User most likely wanted to escape the flow with a mistyped
+			// command, it shouldn't be taken as an answer.
+			return b.cancel(chatId, reason)
+		}
+		return s.Telegram.SendTextMessage(chatId, reason)
+	}
+	cmd, err = cmd.ProcessMessage(s, u.Message)
 	// On user caused error command should still be updated accordingly.
 	if err == nil || errors.Is(err, UserError{}) {
 		return b.updateCommand(chatId, cmd)
@@ -144,6 +274,112 @@ func (b *Bot) Update(u *Update) (err error) {
 	return err
 }
 
+// cancelCommand aborts the command in progress, e.g. /add waiting for an
+// answer.
+const cancelCommand = "/cancel"
+
+// cancel resets the current command and confirms it to the user with an
+// optional reason.
+func (b *Bot) cancel(chatID int64, reason string) error {
+	l := b.state.L(chatID)
+	msg := l.T("Nothing to cancel.")
+	if s := b.fetchCommand(chatID).Serialize(); s != nil {
+		msg = l.T("Cancelled %s.", s.Name)
+	}
+	if reason != "" {
+		msg = reason + " " + msg
+	}
+	if err := b.updateCommand(chatID, nil); err != nil {
+		return err
+	}
+	return b.state.Telegram.SendTextMessage(chatID, msg)
+}
+
+// maxSuggestions is the maximum number of commands suggested instead of an
+// unknown one.
+const maxSuggestions = 3
+
+// suggestCommands returns known commands closest to the unknown one. Commands
+// are tappable in telegram, so they can be used as is in the reply.
+func suggestCommands(name string) []string {
+	names := []string{cancelCommand}
+	for n := range CommandsTemplate.Commands {
+		// Don't advertise admin commands to everyone.
+		if n != adminCommand {
+			names = append(names, n)
+		}
+	}
+	// Allow roughly one typo per 3 characters.
+	maxDist := len(name) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+	dist := make(map[string]int)
+	var ss []string
+	for _, n := range names {
+		if d := editDistance(name, n); d <= maxDist {
+			dist[n] = d
+			ss = append(ss, n)
+		}
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		if dist[ss[i]] != dist[ss[j]] {
+			return dist[ss[i]] < dist[ss[j]]
+		}
+		return ss[i] < ss[j]
+	})
+	if len(ss) > maxSuggestions {
+		ss = ss[:maxSuggestions]
+	}
+	return ss
+}
+
+// editDistance returns Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// splitCommand splits a message like "/delete falu" into the command name and
+// its arguments.
+func splitCommand(text string) (name, args string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	ts := strings.SplitN(text, " ", 2)
+	if len(ts) < 2 {
+		return ts[0], ""
+	}
+	return ts[0], strings.TrimSpace(ts[1])
+}
+
 type SerializedCommand struct {
 	// Name of the command
 	Name string
@@ -191,11 +427,11 @@ func (u UserError) Error() string {
 
 // Surface surfaces error to the user.
 func (u UserError) Surface(s *State) error {
-	e := u.Error()
+	e := []rune(s.L(u.ChatID).Error(u.Err))
 	if len(e) > 0 {
-		e = strings.ToUpper(e[:1]) + e[1:]
+		e[0] = unicode.ToUpper(e[0])
 	}
-	return s.Telegram.SendTextMessage(u.ChatID, e)
+	return s.Telegram.SendTextMessage(u.ChatID, string(e))
 }
 
 type question struct {
@@ -203,6 +439,17 @@ type question struct {
 	ask      func(s *State, chatID int64) error
 	validate func(*State, *Message) error
 	answer   string
+	// photo is true if the question can be answered with a photo, then its
+	// caption is the answer.
+	photo bool
+	// image is the file_id of the photo in the answer, if any.
+	image string
+}
+
+// answered returns whether the question was answered either with text or with
+// a photo.
+func (q *question) answered() bool {
+	return q.answer != "" || q.image != ""
 }
 
 type multiQuestionCommand struct {
@@ -219,9 +466,16 @@ type multiQuestionCommand struct {
 
 func MultiQuestionCommandFactory(questions []*question, save func(state *State, chatID int64, questions []*question) error) CommandFactory {
 	return func(name string) Command {
+		// Answers are stored in questions, so each command needs its own
+		// copy.
+		qs := make([]*question, len(questions))
+		for i, q := range questions {
+			qc := *q
+			qs[i] = &qc
+		}
 		return &multiQuestionCommand{
 			name:      name,
-			questions: questions,
+			questions: qs,
 			save:      save,
 		}
 	}
@@ -229,6 +483,7 @@ func MultiQuestionCommandFactory(questions []*question, save func(state *State,
 
 type multiQuestionCommandSerialized struct {
 	answers      map[string]string
+	images       map[string]string
 	lastQuestion string
 }
 
@@ -236,16 +491,21 @@ func (c *multiQuestionCommand) Serialize() *SerializedCommand {
 	// No need to serialize question names, they should be the same in CommandsTemplate.
 	// Need to serialize answers to the questions though.
 	a := make(map[string]string)
+	is := make(map[string]string)
 	for _, q := range c.questions {
 		a[q.name] = q.answer
+		if q.image != "" {
+			is[q.name] = q.image
+		}
 	}
 	cs := &multiQuestionCommandSerialized{
 		answers:      a,
+		images:       is,
 		lastQuestion: c.lastQuestion,
 	}
 	b, err := json.Marshal(cs)
 	if err != nil {
-		log.Printf("INTERNAL ERROR: Couldn't serialize %v: %v", cs, err)
+		slog.Error("Serializing command", "command", c.name, "err", err)
 	}
 	return &SerializedCommand{
 		Name: c.name,
@@ -260,6 +520,7 @@ func (c *multiQuestionCommand) Init(s *SerializedCommand) error {
 	}
 	for _, q := range c.questions {
 		q.answer = cs.answers[q.name]
+		q.image = cs.images[q.name]
 	}
 	c.lastQuestion = cs.lastQuestion
 	return nil
@@ -271,6 +532,14 @@ func (c *multiQuestionCommand) OnCommand(s *State, m *Message) (Command, error)
 		// With to questions, no reason to have this command process messages.
 		return nil, c.save(s, chatID, c.questions)
 	}
+	if _, args := splitCommand(m.Text); args != "" {
+		// Arguments are an answer to the first question, so there is no need
+		// to ask it.
+		c.lastQuestion = c.questions[0].name
+		am := *m
+		am.Text = args
+		return c.ProcessMessage(s, &am)
+	}
 	if err := c.questions[0].ask(s, chatID); err != nil {
 		return nil, err
 	}
@@ -294,11 +563,15 @@ func (c *multiQuestionCommand) ProcessMessage(s *State, m *Message) (Command, er
 		// so we return c to be a new command.
 		return c, err
 	}
-	q.answer = m.Text
+	if q.photo && len(m.Photo) > 0 {
+		q.answer, q.image = m.Caption, m.LargestPhoto().FileId
+	} else {
+		q.answer = m.PlainText()
+	}
 
 	var next *question = nil
 	for _, qe := range c.questions {
-		if qe.answer == "" {
+		if !qe.answered() {
 			next = qe
 			break
 		}
@@ -325,10 +598,12 @@ func ReplyCommand(reply func(s *State, chatID int64) error) CommandFactory {
 	)
 }
 
-func NewMessageReply(chatID int64, text string, callbacks []Callback) *MessageReply {
+// NewMessageReply returns a message with buttons for callbacks labeled in the
+// language of l.
+func NewMessageReply(l *Localizer, chatID int64, text string, callbacks []Callback) *MessageReply {
 	var ik []*InlineKeyboard
 	for _, c := range callbacks {
-		ik = append(ik, c.AsInlineKeyboard())
+		ik = append(ik, c.AsInlineKeyboard(l))
 	}
 	var rm *ReplyMarkup
 	if len(ik) > 0 {
@@ -343,22 +618,171 @@ func NewMessageReply(chatID int64, text string, callbacks []Callback) *MessageRe
 	}
 }
 
-// practiceReply sends practice card to the user.
-func practiceReply(s *State, chatID int64) error {
-	switch UsePractice {
-	case PracticeKnowledge:
-	default:
-		panic(fmt.Sprintf("INTERNAL: Unimplemented practice type: %v", UsePractice))
+// dueReply sends the number of words that are due now and in the upcoming days.
+func dueReply(s *State, chatID int64) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	f, err := s.Repetitions.Forecast(chatID, s.Clock.Now(), settings.DayLocation())
+	if err != nil {
+		return err
+	}
+	l := s.L(chatID)
+	msg := strings.Join([]string{
+		l.T("Your review forecast:"),
+		l.T("Due now: %d", f.Now),
+		l.T("Later today: %d", f.LaterToday),
+		l.T("Tomorrow: %d", f.Tomorrow),
+		l.T("Next 7 days: %d", f.Week),
+	}, "\n")
+	var cs []Callback
+	if f.Now > 0 {
+		cs = append(cs, StartPracticeCallback{})
+	}
+	return s.Telegram.SendMessage(NewMessageReply(l, chatID, msg, cs))
+}
+
+// goalLine formats the progress toward the daily goal, empty if the user has
+// no goal.
+func goalLine(s *State, l *Localizer, chatID int64, settings *Settings) (string, error) {
+	if settings.DailyGoal == 0 {
+		return "", nil
+	}
+	n, err := s.Repetitions.ReviewsToday(chatID, s.Clock.Now(), settings.DayLocation())
+	if err != nil {
+		return "", err
+	}
+	if n >= settings.DailyGoal {
+		return l.T("Daily goal reached: %d of %d reviews today.", n, settings.DailyGoal), nil
+	}
+	return l.T("Daily goal: %d of %d reviews today.", n, settings.DailyGoal), nil
+}
+
+// statsReply shows the current and the longest practice streaks and the
+// progress toward the daily goal.
+func statsReply(s *State, chatID int64) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	st, err := s.Repetitions.Streak(chatID, s.Clock.Now(), settings.DayLocation())
+	if err != nil {
+		return err
+	}
+	l := s.L(chatID)
+	lines := []string{
+		l.T("Current streak: %d days", st.Current),
+		l.T("Best streak: %d days", st.Longest),
+	}
+	goal, err := goalLine(s, l, chatID, settings)
+	if err != nil {
+		return err
+	}
+	if goal != "" {
+		lines = append(lines, goal)
+	}
+	var cs []Callback
+	if st.Current > 0 && !st.ReviewedToday {
+		lines = append(lines, l.T("Practice today to keep your streak!"))
+		cs = append(cs, StartPracticeCallback{})
+	}
+	return s.Telegram.SendMessage(NewMessageReply(l, chatID, strings.Join(lines, "\n"), cs))
+}
+
+// retentionLine formats the retention of the bucket, empty if there were no
+// reviews.
+func retentionLine(l *Localizer, name string, b RetentionBucket) string {
+	if b.Total == 0 {
+		return ""
+	}
+	return l.T("%s: %d%% (%d of %d)", name, b.Known*100/b.Total, b.Known, b.Total)
+}
+
+// retentionReply shows the share of known answers by the previous answer and
+// by the interval since the previous review.
+func retentionReply(s *State, chatID int64) error {
+	rep, err := s.Repetitions.Retention(chatID)
+	if err != nil {
+		return err
+	}
+	l := s.L(chatID)
+	if rep.FirstReview.Total == 0 {
+		return s.Telegram.SendTextMessage(chatID, l.T("You haven't practiced any words yet."))
+	}
+	lines := []string{l.T("Known answers by the previous answer:")}
+	for _, line := range []string{
+		retentionLine(l, l.T("After Know"), rep.AfterKnow),
+		retentionLine(l, l.T("After Don't know"), rep.AfterDontKnow),
+		retentionLine(l, l.T("First review"), rep.FirstReview),
+	} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, "", l.T("Known answers by the time since the previous review:"))
+	names := []string{l.T("Less than a day"), l.T("1 to 7 days"), l.T("7 to 30 days"), l.T("More than 30 days")}
+	for i, b := range rep.ByInterval {
+		if line := retentionLine(l, names[i], b); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return s.Telegram.SendTextMessage(chatID, strings.Join(lines, "\n"))
+}
+
+// historyCSV formats the reviews as CSV with times in loc. Intervals are in
+// seconds and empty if unknown.
+func historyCSV(rs []*Review, loc *time.Location) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"time", "word", "known", "interval_before_seconds", "interval_after_seconds"})
+	seconds := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return strconv.FormatInt(int64(d.Seconds()), 10)
+	}
+	for _, r := range rs {
+		known := "0"
+		if r.Known {
+			known = "1"
+		}
+		w.Write([]string{r.Time.In(loc).Format(time.RFC3339), r.Word, known, seconds(r.Since), seconds(r.Interval)})
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// exportHistoryReply sends the review log as a CSV file.
+func exportHistoryReply(s *State, chatID int64) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
 	}
-	word, err := s.Repetitions.RepeatWord(chatID)
+	rs, err := s.Repetitions.Reviews(chatID)
+	if err != nil {
+		return err
+	}
+	if len(rs) == 0 {
+		return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("You haven't practiced any words yet."))
+	}
+	data, err := historyCSV(rs, settings.Location())
+	if err != nil {
+		return fmt.Errorf("INTERNAL: formatting history of chat %d: %w", chatID, err)
+	}
+	return s.Telegram.SendDocumentData(chatID, "history.csv", data)
+}
+
+// randomReply sends a random saved card regardless of its schedule.
+func randomReply(s *State, chatID int64) error {
+	word, err := s.Repetitions.RandomWord(chatID)
 	if err == sql.ErrNoRows {
-		// FIXME: Make this user error instead.
-		return s.Telegram.SendTextMessage(chatID, "No more rows to practice; exiting practice mode.")
+		return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("You have no saved words yet."))
 	}
 	if err != nil {
-		return fmt.Errorf("retrieving word for repetition: %w", err)
+		return err
 	}
-	return s.Telegram.SendMessage(NewMessageReply(chatID, word, []Callback{KnowCallback{word}, DontKnowCallback{word, true}}))
+	return s.Telegram.SendMessage(NewMessageReply(s.L(chatID), chatID, word, []Callback{ShowAnswerCallback{Word: word}}))
 }
 
 // settingsReply sends current settings and instructions on how to change them.
@@ -379,18 +803,147 @@ func settingsReply(state *State, chatID int64) error {
 		cmds = append(cmds, "  "+k)
 	}
 	sort.Strings(cmds)
-	msg := fmt.Sprintf(`
-Current settings:
+	l := NewLocalizer(s.BotLanguage)
+	maxLength := l.T("unlimited")
+	if s.MaxExampleLength > 0 {
+		maxLength = l.T("%d characters", s.MaxExampleLength)
+	}
+	reminders := l.T("off")
+	if !s.Muted && len(s.Windows()) > 0 {
+		reminders = l.T("%d per day", s.RemindersPerDay()) + " " + l.T("between %s", s.Windows())
+		if s.OnVacation(state.Clock.Now()) {
+			reminders += ", " + l.T("paused until %s", s.VacationUntil.In(s.Location()).Format("2006-01-02"))
+		}
+	}
+	catchUp := l.T("off")
+	if s.CatchUp > 0 {
+		catchUp = l.T("%d overdue cards per day", s.CatchUp)
+	}
+	newOrder := l.T("in the order added")
+	switch s.NewCardOrder {
+	case NewCardsRandom:
+		newOrder = l.T("in random order")
+	case NewCardsFrequent:
+		newOrder = l.T("most frequent first")
+	}
+	steps := l.T("off")
+	if len(s.LearningSteps) > 0 {
+		steps = FormatLearningSteps(s.LearningSteps)
+	}
+	maxInterval := l.T("unlimited")
+	if s.MaxIntervalDays > 0 {
+		maxInterval = l.T("%d days", s.MaxIntervalDays)
+	}
+	goal := l.T("off")
+	if s.DailyGoal > 0 {
+		goal = l.T("%d reviews", s.DailyGoal)
+	}
+	scheduler := l.T("default")
+	if s.Scheduler == LeitnerScheduler {
+		scheduler = l.T("Leitner boxes")
+	}
+	botLanguage := BotLanguages[defaultBotLanguage]
+	if n, ok := BotLanguages[s.BotLanguage]; ok {
+		botLanguage = n
+	}
+	var studied []string
+	for _, code := range s.StudiedLanguages {
+		if code != s.InputLanguageISO639_3 {
+			studied = append(studied, code)
+		}
+	}
+	if len(studied) == 0 {
+		studied = []string{l.T("none")}
+	}
+	lines := []string{
+		l.T("Input language: %q", s.InputLanguage),
+		l.T("Input language in ISO 639-3: %q", s.InputLanguageISO639_3),
+		l.T("Translation languages in ISO 639-3: %s", strings.Join(ls, ",")),
+		l.T("Other studied languages in ISO 639-3: %s", strings.Join(studied, ",")),
+		l.T("Time Zone: %s", s.TimeZone),
+		l.T("Day starts at: %02d:00", s.DayStartHour),
+		l.T("Max usage example length: %s", maxLength),
+		l.T("Transliteration of translations: %s", onOff(l, s.Transliterate)),
+		l.T("Practice session: %d cards", s.PracticeLength()),
+		l.T("Scheduler: %s", scheduler),
+		l.T("New cards: %s", newOrder),
+		l.T("Learning steps: %s", steps),
+		l.T("Maximum interval: %s", maxInterval),
+		l.T("Sentences in practice: %s", onOff(l, s.ClozePractice)),
+		l.T("Listening practice: %s", onOff(l, s.ListeningPractice)),
+		l.T("Daily goal: %s", goal),
+		l.T("Catch-up: %s", catchUp),
+		l.T("Save looked up words: %s", onOff(l, s.AutoSave)),
+		l.T("Reminders: %s", reminders),
+		l.T("Card in reminders: %s", onOff(l, s.ReminderCard)),
+		l.T("Weekly summary: %s", onOff(l, s.WeeklySummary)),
+		l.T("Bot language: %s", botLanguage),
+	}
+	msg := "\n" + l.T("Current settings:") + "\n\n" +
+		strings.Join(lines, "\n") + "\n\n" +
+		l.T("To modify settings use one of the commands below:") + "\n" +
+		strings.Join(cmds, "\n") + "\n"
+	return state.Telegram.SendMessage(NewMessageReply(l, chatID, msg, nil))
+}
+
+// onOff shows the value of a setting that is either on or off.
+func onOff(l *Localizer, v bool) string {
+	if v {
+		return l.T("on")
+	}
+	return l.T("off")
+}
+
+// languagesReply sends buttons to switch the input language between the
+// studied ones.
+func languagesReply(state *State, chatID int64) error {
+	s, err := state.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	cs, err := studiedLanguagesKeyboard(state, s, false)
+	if err != nil {
+		return err
+	}
+	l := state.L(chatID)
+	return state.Telegram.SendMessage(NewMessageReply(l, chatID,
+		l.T("Tap the language to look up words in it first. Add languages with /addlanguage, practice one of them with /practice <language>."), cs))
+}
 
-Input language: %q
-Input language in ISO 639-3: %q
-Translation languages in ISO 639-3: %s
-Time Zone: %s
+// removeLanguageReply sends buttons to stop studying one of the languages.
+func removeLanguageReply(state *State, chatID int64) error {
+	s, err := state.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	cs, err := studiedLanguagesKeyboard(state, s, true)
+	if err != nil {
+		return err
+	}
+	l := state.L(chatID)
+	if len(cs) == 0 {
+		return state.Telegram.SendTextMessage(chatID, l.T("You study only %s, use /language to change it.", s.InputLanguage))
+	}
+	return state.Telegram.SendMessage(NewMessageReply(l, chatID, l.T("Tap the language to stop studying it. Its cards are kept."), cs))
+}
 
-To modify settings use one of the commands below:
-%s
-`, s.InputLanguage, s.InputLanguageISO639_3, strings.Join(ls, ","), s.TimeZone, strings.Join(cmds, "\n"))
-	return state.Telegram.SendMessage(NewMessageReply(chatID, msg, nil))
+// translationsReply sends buttons to toggle translation languages.
+func translationsReply(state *State, chatID int64) error {
+	s, err := state.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	ik, err := translationLanguagesKeyboard(state, s)
+	if err != nil {
+		return err
+	}
+	return state.Telegram.SendMessage(&MessageReply{
+		ChatId: chatID,
+		Text:   state.L(chatID).T("Tap the language to toggle translations of usage examples into it."),
+		ReplyMarkup: &ReplyMarkup{
+			InlineKeyboard: [][]*InlineKeyboard{ik},
+		},
+	})
 }
 
 // This inteface is a bit redundant. We need it though to avoid initialization
@@ -417,17 +970,42 @@ func SimpleQuestionCommandFactory(c SimpleQuestionCommand) CommandFactory {
 
 type SimpleSettingCommand struct {
 	question string
-	validate func(s *State, answer string) error
-	save     func(s *State, chatID int64, answer string) error
+	// questionf is used instead of question if the question depends on the
+	// state.
+	questionf func(s *State, l *Localizer) (string, error)
+	validate  func(s *State, answer string) error
+	save      func(s *State, chatID int64, answer string) error
+}
+
+// onOffSetting asks to switch the setting returned by field on or off.
+func onOffSetting(question string, field func(*Settings) *bool) *SimpleSettingCommand {
+	return &SimpleSettingCommand{
+		question: question,
+		validate: func(_ *State, answer string) error {
+			_, err := parseOnOff(answer)
+			return err
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetOnOff(chatID, answer, field)
+		},
+	}
 }
 
 func (c *SimpleSettingCommand) Ask(s *State, chatID int64) error {
-	return askQuestion(c.question)(s, chatID)
+	l := s.L(chatID)
+	q := l.T(c.question)
+	if c.questionf != nil {
+		var err error
+		if q, err = c.questionf(s, l); err != nil {
+			return err
+		}
+	}
+	return s.Telegram.SendTextMessage(chatID, q)
 }
 
 func (c *SimpleSettingCommand) Validate(s *State, m *Message) error {
 	if err := c.validate(s, m.Text); err != nil {
-		return UserError{ChatID: m.Chat.Id, Err: fmt.Errorf("%w. Please try again.", err)}
+		return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("%s. Please try again.", s.L(m.Chat.Id).Error(err))}
 	}
 	return nil
 }
@@ -439,9 +1017,11 @@ func (c *SimpleSettingCommand) Save(s *State, chatID int64, answer string) error
 	return settingsReply(s, chatID)
 }
 
+// askQuestion returns a function sending the question translated into the
+// chat's language.
 func askQuestion(q string) func(s *State, chatID int64) error {
 	return func(s *State, chatID int64) error {
-		return s.Telegram.SendTextMessage(chatID, q)
+		return s.Telegram.SendTextMessage(chatID, s.L(chatID).T(q))
 	}
 }
 
@@ -449,31 +1029,49 @@ func AddCommandFactory() CommandFactory {
 	noopValidate := func(*State, *Message) error { return nil }
 	return MultiQuestionCommandFactory(
 		[]*question{{
-			name:     "front",
-			ask:      askQuestion("Enter front of the card (word, expression, question)."),
-			validate: noopValidate,
+			name: "front",
+			ask:  askQuestion("Enter front of the card (word, expression, question) or send a photo with the word as its caption."),
+			validate: func(s *State, m *Message) error {
+				if len(m.Photo) > 0 && strings.TrimSpace(m.Caption) == "" {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("Send the photo with the word as its caption.")}
+				}
+				return nil
+			},
+			photo: true,
 		}, {
 			name:     "back",
 			ask:      askQuestion("Enter back of the card (definition, answer)."),
 			validate: noopValidate,
 		}},
 		func(s *State, chatID int64, qs []*question) error {
-			var front string
+			var front, image string
 			var back string
 			for _, q := range qs {
 				switch q.name {
 				case "front":
-					front = q.answer
+					front, image = q.answer, q.image
 				case "back":
 					back = q.answer
 				default:
 					return fmt.Errorf("unexpected question in save: %v", q)
 				}
 			}
-			if err := s.Repetitions.Save(chatID, front, back); err != nil {
+			settings, err := s.Settings.Get(chatID)
+			if err != nil {
+				return err
+			}
+			if err := saveCard(s, chatID, front, back, settings.InputLanguageISO639_3); err != nil {
+				return err
+			}
+			l := s.L(chatID)
+			if image == "" {
+				return s.Telegram.SendMessage(NewMessageReply(l, chatID, l.T("Added %q for learning!", front),
+					[]Callback{AttachImageCallback{front}}))
+			}
+			if err := s.Repetitions.SetImage(chatID, front, image); err != nil {
 				return err
 			}
-			return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Added %q for learning!", front))
+			return s.Telegram.SendTextMessage(chatID, l.T("Added %q for learning!", front))
 		},
 	)
 }
@@ -489,73 +1087,460 @@ func DeleteCommandFactory() CommandFactory {
 					return err
 				}
 				if !e {
-					return UserError{ChatID: m.Chat.Id, Err: fmt.Errorf("Word %q isn't saved for learning!", m.Text)}
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("Word %q isn't saved for learning!", m.Text)}
+				}
+				return nil
+			},
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			return deleteConfirmation.Ask(s, chatID, qs[0].answer)
+		},
+	)
+}
+
+// VacationCommandFactory postpones all words of the chat by the number of days
+// of the vacation and pauses reminders for it, so that the words don't pile up
+// while the user is away.
+func VacationCommandFactory() CommandFactory {
+	return MultiQuestionCommandFactory(
+		[]*question{{
+			name: "days",
+			ask: func(s *State, chatID int64) error {
+				return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("For how many days are you going away? "+
+					"Enter a number between 1 and %d or \"off\" to end the vacation.", maxVacationDays))
+			},
+			validate: func(s *State, m *Message) error {
+				if err := s.Settings.ValidateVacation(m.Text); err != nil {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("%s. Please try again.", s.L(m.Chat.Id).Error(err))}
 				}
 				return nil
 			},
 		}},
 		func(s *State, chatID int64, qs []*question) error {
-			if err := s.Repetitions.Delete(chatID, qs[0].answer); err != nil {
+			l := s.L(chatID)
+			if qs[0].answer == "off" {
+				if err := s.Settings.SetVacation(chatID, nil); err != nil {
+					return err
+				}
+				return s.Telegram.SendTextMessage(chatID, l.T("Welcome back! Reminders are resumed."))
+			}
+			// Already validated.
+			days, _ := strconv.Atoi(qs[0].answer)
+			d := time.Duration(days) * 24 * time.Hour
+			n, err := s.Repetitions.Postpone(chatID, d)
+			if err != nil {
+				return err
+			}
+			until := s.Clock.Now().Add(d)
+			if err := s.Settings.SetVacation(chatID, &until); err != nil {
+				return err
+			}
+			settings, err := s.Settings.Get(chatID)
+			if err != nil {
 				return err
 			}
-			return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Deleted %q!", qs[0].answer))
+			return s.Telegram.SendTextMessage(chatID, l.T("Enjoy your vacation! %d words are postponed by %d days, reminders are paused until %s.",
+				n, days, until.In(settings.Location()).Format("2006-01-02")))
 		},
 	)
 }
 
-type defaultCommand struct{}
-
-func (defaultCommand) Serialize() *SerializedCommand {
-	return nil
-}
-func (defaultCommand) Init(*SerializedCommand) error {
-	return nil
+// NoteCommandFactory appends a note to the definition of a saved word.
+func NoteCommandFactory() CommandFactory {
+	return MultiQuestionCommandFactory(
+		[]*question{{
+			name: "word",
+			ask:  askQuestion("Enter the word to add a note to."),
+			validate: func(s *State, m *Message) error {
+				e, err := s.Repetitions.Exists(m.Chat.Id, m.Text)
+				if err != nil {
+					return err
+				}
+				if !e {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("Word %q isn't saved for learning!", m.Text)}
+				}
+				return nil
+			},
+		}, {
+			name:     "note",
+			ask:      askQuestion("Enter the note to add to the card."),
+			validate: func(*State, *Message) error { return nil },
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			word, note := qs[0].answer, qs[1].answer
+			if err := s.Repetitions.AppendDefinition(chatID, word, note); err != nil {
+				return err
+			}
+			return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("Added the note to %q.", word))
+		},
+	)
 }
-func (defaultCommand) ProcessMessage(s *State, m *Message) (Command, error) {
-	chatID := m.Chat.Id
 
-	if len(strings.Split(m.Text, " ")) > 1 {
-		return nil, UserError{ChatID: chatID, Err: fmt.Errorf("For now this bot doesn't work with expressions. Try entering a single work without spaces.")}
-	}
-
-	def, err := s.Repetitions.GetDefinition(m.Chat.Id, m.Text)
-	if err == nil {
-		return nil, s.Telegram.SendMessage(NewMessageReply(
-			m.Chat.Id, def,
-			[]Callback{ResetProgressCallback{m.Text}}))
+// ImageCommandFactory puts a photo on the front of a saved word's card, so that
+// the photo is shown instead of the word in practice.
+func ImageCommandFactory() CommandFactory {
+	return MultiQuestionCommandFactory(
+		[]*question{{
+			name: "word",
+			ask:  askQuestion("Enter the word to attach an image to."),
+			validate: func(s *State, m *Message) error {
+				e, err := s.Repetitions.Exists(m.Chat.Id, m.Text)
+				if err != nil {
+					return err
+				}
+				if !e {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("Word %q isn't saved for learning!", m.Text)}
+				}
+				return nil
+			},
+		}, {
+			name: "image",
+			ask:  askQuestion("Send the photo for the front of the card."),
+			validate: func(s *State, m *Message) error {
+				if len(m.Photo) == 0 {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("Send a photo, please.")}
+				}
+				return nil
+			},
+			photo: true,
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			word, image := qs[0].answer, qs[1].image
+			if err := s.Repetitions.SetImage(chatID, word, image); err != nil {
+				return err
+			}
+			return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("Attached the image to %q.", word))
+		},
+	)
+}
+
+// AnkiCommandFactory sets up syncing the cards with Anki on the user's
+// computer and syncs them for the first time, see syncAnki.
+func AnkiCommandFactory() CommandFactory {
+	return MultiQuestionCommandFactory(
+		[]*question{{
+			name: "url",
+			ask: func(s *State, chatID int64) error {
+				if err := ankiEnabled(s, chatID); err != nil {
+					return err
+				}
+				return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("Enter the address of AnkiConnect, e.g. http://203.0.113.5:8765. "+
+					"It has to be reachable from the internet."))
+			},
+			validate: func(s *State, m *Message) error {
+				if err := ankiEnabled(s, m.Chat.Id); err != nil {
+					return err
+				}
+				if !strings.HasPrefix(m.Text, "http://") && !strings.HasPrefix(m.Text, "https://") {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("The address should start with http:// or https://. Please try again.")}
+				}
+				return nil
+			},
+		}, {
+			name:     "key",
+			ask:      askQuestion("Enter the API key of AnkiConnect or \"none\" if it has none."),
+			validate: func(*State, *Message) error { return nil },
+		}, {
+			name:     "deck",
+			ask:      askQuestion("Enter the name of the Anki deck for the cards."),
+			validate: func(*State, *Message) error { return nil },
+		}, {
+			name: "scheduler",
+			ask: askQuestion("Which should schedule the reviews of the synced cards, \"bot\" or \"anki\"? " +
+				"The other one follows its schedule."),
+			validate: func(s *State, m *Message) error {
+				if a := strings.ToLower(m.Text); a != ankiSchedulerBot && a != ankiSchedulerAnki {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("Answer should be \"bot\" or \"anki\". Please try again.")}
+				}
+				return nil
+			},
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			c := &AnkiConfig{
+				URL:       qs[0].answer,
+				Key:       qs[1].answer,
+				Deck:      qs[2].answer,
+				Scheduler: strings.ToLower(qs[3].answer),
+			}
+			if c.Key == "none" {
+				c.Key = ""
+			}
+			if _, err := s.Anki.connect(c).Version(); err != nil {
+				return ankiError(chatID, err)
+			}
+			if err := s.Anki.SetConfig(chatID, c); err != nil {
+				return err
+			}
+			return ankiSyncReply(s, chatID)
+		},
+	)
+}
+
+// DefineCommandFactory shows definitions from the sources even if the word is
+// saved, so that a stale card can be updated.
+func DefineCommandFactory() CommandFactory {
+	return MultiQuestionCommandFactory(
+		[]*question{{
+			name: "word",
+			ask:  askQuestion("Enter the word to define."),
+			validate: func(s *State, m *Message) error {
+				return validateWord(m)
+			},
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			word := qs[0].answer
+			saved, err := s.Repetitions.Exists(chatID, word)
+			if err != nil {
+				return err
+			}
+			if saved {
+				_, err := sendDefinitions(s, chatID, word, func(string) Callback { return UpdateCardCallback{word} })
+				return err
+			}
+			_, err = sendDefinitions(s, chatID, word, func(l string) Callback { return LearnCallback{word, l} })
+			return err
+		},
+	)
+}
+
+// ConfirmCommand asks user to confirm a destructive action with Yes/No buttons
+// before performing it. Every ConfirmCommand should be listed in
+// ConfirmCommands, so that ConfirmCallback can find it by name.
+type ConfirmCommand struct {
+	// Name is unique and short, as it's stored in callback data.
+	Name string
+	// Question returns a confirmation question. If nil, buttons are attached
+	// to the message with which user has requested the action.
+	Question func(l *Localizer, word string) string
+	// Do performs the action and returns the text describing the result.
+	Do func(s *State, chatID int64, word string) (string, error)
+}
+
+// Keyboard returns Yes/No buttons for the action on the word.
+func (c *ConfirmCommand) Keyboard(word string) []Callback {
+	return []Callback{ConfirmCallback{c.Name, word, true}, ConfirmCallback{c.Name, word, false}}
+}
+
+// Ask sends confirmation question with Yes/No buttons.
+func (c *ConfirmCommand) Ask(s *State, chatID int64, word string) error {
+	l := s.L(chatID)
+	return s.Telegram.SendMessage(NewMessageReply(l, chatID, c.Question(l, word), c.Keyboard(word)))
+}
+
+var deleteConfirmation = &ConfirmCommand{
+	Name: "delete",
+	Question: func(l *Localizer, word string) string {
+		return l.T("Delete %q from learning? All progress will be lost.", word)
+	},
+	Do: func(s *State, chatID int64, word string) (string, error) {
+		if err := s.Repetitions.Delete(chatID, word); err != nil {
+			return "", err
+		}
+		return s.L(chatID).T("Deleted %q!", word), nil
+	},
+}
+
+var resetProgressConfirmation = &ConfirmCommand{
+	Name: "reset",
+	Do: func(s *State, chatID int64, word string) (string, error) {
+		if err := s.Repetitions.ResetProgress(chatID, word); err != nil {
+			return "", err
+		}
+		return s.L(chatID).T("Progress for %q was reset", word), nil
+	},
+}
+
+// deleteDataConfirmation wipes everything the bot knows about the chat. The
+// word is unused.
+var deleteDataConfirmation = &ConfirmCommand{
+	Name: "deletedata",
+	Question: func(l *Localizer, _ string) string {
+		return l.T("Delete all your data? Saved words, progress, settings and reminders will be lost for good.")
+	},
+	Do: func(s *State, chatID int64, _ string) (string, error) {
+		// The reply is localized before the settings are gone.
+		msg := s.L(chatID).T("All your data was deleted. Send /start to set up the bot again.")
+		if err := s.ChatData.Delete(chatID); err != nil {
+			return "", err
+		}
+		s.Quizzes.Stop(chatID)
+		s.Matches.Stop(chatID)
+		s.Practice.Stop(chatID)
+		s.Admin.Errors.Clear(chatID)
+		return msg, nil
+	},
+}
+
+func deleteDataReply(s *State, chatID int64) error {
+	return deleteDataConfirmation.Ask(s, chatID, "")
+}
+
+var ConfirmCommands = []*ConfirmCommand{
+	deleteConfirmation,
+	resetProgressConfirmation,
+	deleteDataConfirmation,
+}
+
+type defaultCommand struct{}
+
+func (defaultCommand) Serialize() *SerializedCommand {
+	return nil
+}
+func (defaultCommand) Init(*SerializedCommand) error {
+	return nil
+}
+
+// validateWord makes sure that the message can be looked up in the sources.
+func validateWord(m *Message) error {
+	if len(strings.Split(m.Text, " ")) > 1 {
+		return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("For now this bot doesn't work with expressions. Try entering a single work without spaces.")}
 	}
-	if err != sql.ErrNoRows {
-		log.Printf("ERROR: Repetitions(%d, %s): %v", m.Chat.Id, m.Text, err)
+	return nil
+}
+
+func (defaultCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+
+	if ok, err := practiceAnswer(s, m); ok {
+		return nil, err
 	}
-	settings, err := s.Settings.Get(chatID)
+	if len(m.Photo) > 0 {
+		return nil, photoReply(s, m)
+	}
+	if len(strings.Fields(m.Text)) > 1 {
+		return nil, sentenceReply(s, chatID, m.Text)
+	}
+
+	return nil, defineReply(s, chatID, m.Text)
+}
+
+// defineReply sends the card of the saved word or the definitions of a new one
+// with buttons to learn it. If the same reply was sent a moment ago, it's
+// pointed to instead, see RecentLookups.
+func defineReply(s *State, chatID int64, word string) error {
+	now := s.Clock.Now()
+	def, err := s.Repetitions.GetDefinition(chatID, word)
+	if err != nil && err != sql.ErrNoRows {
+		s.Log.Error("Retrieving definition", "word", word, "err", err)
+	}
+	// The card of the saved word is its definition, the definitions from
+	// the sources have no key.
+	key := ""
+	if err == nil {
+		key = def
+	}
+	if id, ok := s.Lookups.Find(chatID, word, key, now); ok {
+		return recentLookupReply(s, chatID, word, id)
+	}
+	if err == nil {
+		star, err := starCallback(s, chatID, word)
+		if err != nil {
+			return err
+		}
+		m, err := s.Telegram.Send(NewMessageReply(
+			s.L(chatID), chatID, def,
+			[]Callback{ResetProgressCallback{word}, star, AttachImageCallback{word}}))
+		if err != nil {
+			return err
+		}
+		s.Lookups.Add(chatID, word, key, m.Id, now)
+		return nil
+	}
+	id, err := sendDefinitions(s, chatID, word, func(l string) Callback { return LearnCallback{word, l} })
 	if err != nil {
-		return nil, fmt.Errorf("get settings: %v", err)
+		return err
 	}
-	ds, err := s.Definer.Define(m.Text, settings)
+	s.Lookups.Add(chatID, word, key, id, now)
+	return nil
+}
+
+// sendDefinitions fetches definitions of the word from the sources and sends
+// them with the button under every message. The word is looked up in the
+// studied languages in order until it's found, button gets the language in
+// which it was. Returns the ID of the first sent message.
+func sendDefinitions(s *State, chatID int64, word string, button func(language string) Callback) (int64, error) {
+	settings, err := s.Settings.Get(chatID)
 	if err != nil {
+		return 0, fmt.Errorf("get settings: %v", err)
+	}
+	var ds []*Formatted
+	var more *Formatted
+	language := ""
+	for _, l := range settings.Languages() {
+		ls, err := s.Settings.ForLanguage(settings, l)
+		if err != nil {
+			s.Log.Warn("Studied language", "language", l, "err", err)
+			continue
+		}
+		if ds, more, err = s.Definer.Define(word, ls); err == nil {
+			language = l
+			break
+		}
 		// TODO: Might be good to post debug logs to the reply in the debug mode.
-		log.Printf("Error fetching the definition: %w", err)
+		s.Log.Warn("Fetching definition", "word", word, "language", l, "err", err)
+	}
+	if language == "" {
 		// TODO: Add search url to the reply?
-		return nil, UserError{
-			ChatID: m.Chat.Id,
-			Err:    fmt.Errorf("Couldn't find definitions."),
+		return 0, UserError{
+			ChatID: chatID,
+			Err:    localizedErrorf("Couldn't find definitions."),
 		}
 	}
-	for _, d := range ds {
-		if err := s.Telegram.SendMessage(&MessageReply{
-			ChatId:    m.Chat.Id,
-			Text:      d,
-			ParseMode: "MarkdownV2",
+	l := NewLocalizer(settings.BotLanguage)
+	saved := false
+	if _, learn := button(language).(LearnCallback); learn && settings.AutoSave {
+		var texts []string
+		for _, d := range ds {
+			texts = append(texts, d.Text)
+		}
+		if saved, err = autoSave(s, chatID, word, strings.Join(texts, "\n\n"), language); err != nil {
+			return 0, err
+		}
+		if saved {
+			button = func(string) Callback { return UpdateCardCallback{word} }
+		}
+	}
+	var first int64
+	for i, d := range ds {
+		ks := []*InlineKeyboard{button(language).AsInlineKeyboard(l)}
+		if _, learn := button(language).(LearnCallback); learn && len(senses(d)) > 1 {
+			ks = append(ks, PickDefinitionCallback{word, language}.AsInlineKeyboard(l))
+		}
+		if more != nil && i == len(ds)-1 {
+			ks = append(ks, MoreDefinitionsCallback{word, language}.AsInlineKeyboard(l))
+		}
+		m, err := s.Telegram.Send(&MessageReply{
+			ChatId:   chatID,
+			Text:     d.Text,
+			Entities: d.Entities,
 			ReplyMarkup: &ReplyMarkup{
-				InlineKeyboard: [][]*InlineKeyboard{[]*InlineKeyboard{
-					LearnCallback{m.Text}.AsInlineKeyboard(),
-				}},
+				InlineKeyboard: [][]*InlineKeyboard{ks},
 			},
-		}); err != nil {
-			return nil, err
+		})
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			first = m.Id
 		}
 	}
-	return nil, nil
+	if saved {
+		return first, s.Telegram.SendTextMessage(chatID, l.T("Saved %q for learning", word))
+	}
+	return first, nil
+}
+
+// autoSave saves the word with the definitions from the message unless it's
+// already saved, and returns whether it did.
+func autoSave(s *State, chatID int64, word, msg, language string) (bool, error) {
+	exists, err := s.Repetitions.Exists(chatID, word)
+	if err != nil || exists {
+		return false, err
+	}
+	if err := saveCard(s, chatID, word, msg, language); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // Should never be called.
@@ -581,31 +1566,123 @@ func joinCommands(m1, m2 map[string]CommandFactory) map[string]CommandFactory {
 // textReply sends a message and resets state.
 func textReply(text string) CommandFactory {
 	return ReplyCommand(func(s *State, chatID int64) error {
-		return s.Telegram.SendTextMessage(chatID, text)
+		return s.Telegram.SendTextMessage(chatID, s.L(chatID).T(text))
 	})
 }
 
-// SettingsCommands contains all settings-related commands. They are bundled
-// together for convenience to have everything in one place.
-var SettingsCommands = map[string]CommandFactory{
-	"/language": SimpleQuestionCommandFactory(&SimpleSettingCommand{
-		question: func() string {
+const welcomeMessage = "Welcome to the language bot. Still in development. No instructions " +
+	"so far. " +
+	"All sentences and translations are from Tatoeba's (https://tatoeba.org) " +
+	"dataset, released under a CC-BY 2.0 FR."
+
+// onboardingSettings are asked in order on the first contact.
+var onboardingSettings = []*SimpleSettingCommand{
+	languageSetting,
+	{
+		question: "Enter ISO 639-3 codes of languages into which to translate usage examples separated by comma " +
+			"(e.g. \"eng,deu\") or \"default\" to use the defaults for your input language.",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateTranslationLanguages(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetTranslationLanguages(chatID, answer)
+		},
+	},
+	timeZoneSetting,
+	{
+		question: "When can I send you reminders to practice? Enter time range in your time zone " +
+			"in the format HH:MM-HH:MM, e.g. 09:00-21:00, or \"off\" to not receive reminders.",
+		validate: func(s *State, answer string) error {
+			if answer == "off" {
+				return nil
+			}
+			return s.Settings.ValidateAvailabilityWindow(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			if answer == "off" {
+				return s.Settings.SetReminderFrequency(chatID, answer)
+			}
+			return s.Settings.SetAvailabilityWindow(chatID, answer)
+		},
+	},
+}
+
+// startCommand greets the user and guides through the setup if the chat has
+// no settings yet.
+type startCommand struct {
+	*multiQuestionCommand
+}
+
+func StartCommandFactory() CommandFactory {
+	var qs []*question
+	for i, c := range onboardingSettings {
+		qs = append(qs, &question{
+			name:     fmt.Sprint(i),
+			ask:      c.Ask,
+			validate: c.Validate,
+		})
+	}
+	f := MultiQuestionCommandFactory(qs, func(s *State, chatID int64, qs []*question) error {
+		// Questions are in the same order as onboardingSettings, so that
+		// translation languages are set after the input language.
+		for i, q := range qs {
+			if err := onboardingSettings[i].save(s, chatID, q.answer); err != nil {
+				return err
+			}
+		}
+		if err := s.Telegram.SendTextMessage(chatID, s.L(chatID).T("All set! Send me a word to get its definition.")); err != nil {
+			return err
+		}
+		return settingsReply(s, chatID)
+	})
+	return func(name string) Command {
+		return startCommand{f(name).(*multiQuestionCommand)}
+	}
+}
+
+func (c startCommand) OnCommand(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	if err := s.Telegram.SendTextMessage(chatID, s.L(chatID).T(welcomeMessage)); err != nil {
+		return nil, err
+	}
+	e, err := s.Settings.Exists(chatID)
+	if err != nil || e {
+		return nil, err
+	}
+	if err := s.Telegram.SendTextMessage(chatID, s.L(chatID).T("Let's set things up first.")); err != nil {
+		return nil, err
+	}
+	// Arguments of /start are deep link parameters, not answers to the
+	// questions.
+	sm := *m
+	sm.Text = "/start"
+	return c.multiQuestionCommand.OnCommand(s, &sm)
+}
+
+// Settings used both by settings commands and onboarding.
+var (
+	languageSetting = &SimpleSettingCommand{
+		questionf: func(s *State, l *Localizer) (string, error) {
+			all, err := s.Settings.Languages.All()
+			if err != nil {
+				return "", err
+			}
 			var ls []string
-			for l, _ := range SupportedInputLanguages {
-				ls = append(ls, fmt.Sprintf("%q", l))
+			for _, l := range all {
+				ls = append(ls, fmt.Sprintf("%q", l.Name))
 			}
-			sort.Strings(ls)
-			return fmt.Sprintf("Enter input language of your choice. Supported are %s",
-				strings.Join(ls, ","))
-		}(),
+			return l.T("Enter input language of your choice. Supported are %s "+
+				"or any other language by its ISO 639-3 code (e.g. \"fra\")",
+				strings.Join(ls, ",")), nil
+		},
 		validate: func(s *State, answer string) error {
 			return s.Settings.ValidateLanguage(answer)
 		},
 		save: func(s *State, chatID int64, answer string) error {
 			return s.Settings.SetLanguage(chatID, answer)
 		},
-	}),
-	"/timezone": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+	}
+	timeZoneSetting = &SimpleSettingCommand{
 		question: "Input your timezone in one of the formats: UTC, UTC+X or UTC-X.",
 		validate: func(s *State, answer string) error {
 			return s.Settings.ValidateTimeZone(answer)
@@ -613,6 +1690,175 @@ var SettingsCommands = map[string]CommandFactory{
 		save: func(s *State, chatID int64, answer string) error {
 			return s.Settings.SetTimeZone(chatID, answer)
 		},
+	}
+	availabilitySetting = &SimpleSettingCommand{
+		question: "When can I send you reminders? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00. " +
+			"To limit it to some days, put them before the range, and separate several ranges with \";\", e.g. mon-fri 07:00-09:00; sat,sun 10:00-12:00.",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateAvailabilityWindow(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetAvailabilityWindow(chatID, answer)
+		},
+	}
+)
+
+// SettingsCommands contains all settings-related commands. They are bundled
+// together for convenience to have everything in one place.
+var SettingsCommands = map[string]CommandFactory{
+	"/language":     SimpleQuestionCommandFactory(languageSetting),
+	"/timezone":     SimpleQuestionCommandFactory(timeZoneSetting),
+	"/translations": ReplyCommand(translationsReply),
+	"/languages":    ReplyCommand(languagesReply),
+	"/addlanguage": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "Enter the language to study in addition to the current ones. It becomes the input language, words not found in it are looked up in the others.",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateLanguage(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.AddLanguage(chatID, answer)
+		},
+	}),
+	"/removelanguage": ReplyCommand(removeLanguageReply),
+	"/remind": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		questionf: func(_ *State, l *Localizer) (string, error) {
+			return l.T("How many times a day should I remind you to practice? "+
+				"Enter a number between 1 and %d or \"off\" to stop reminders.", maxReminderFrequency), nil
+		},
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateReminderFrequency(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetReminderFrequency(chatID, answer)
+		},
+	}),
+	"/availability": SimpleQuestionCommandFactory(availabilitySetting),
+	"/remindercard": SimpleQuestionCommandFactory(onOffSetting("Do you want reminders to show the first card ready for practice, so that you can answer it right away? Enter \"on\" or \"off\".",
+		func(s *Settings) *bool { return &s.ReminderCard })),
+	"/summary": SimpleQuestionCommandFactory(onOffSetting("Do you want to receive a weekly summary of your progress? Enter \"on\" or \"off\".",
+		func(s *Settings) *bool { return &s.WeeklySummary })),
+	"/botlanguage": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		questionf: func(_ *State, l *Localizer) (string, error) {
+			return l.T("Enter the language of the bot interface. Supported are %s.",
+				strings.Join(botLanguageNames(), ",")), nil
+		},
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateBotLanguage(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetBotLanguage(chatID, answer)
+		},
+	}),
+	"/sessionlength": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		questionf: func(_ *State, l *Localizer) (string, error) {
+			return l.T("How many cards should a practice session have? Enter a number between 1 and %d.", maxPracticeSessionLength), nil
+		},
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidatePracticeSessionLength(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetPracticeSessionLength(chatID, answer)
+		},
+	}),
+	"/scheduler": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "How should I schedule the reviews? Enter \"default\" to move the words through the stages of learning " +
+			"or \"leitner\" for 5 Leitner boxes reviewed every 1, 2, 4, 8 and 16 days.",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateScheduler(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetScheduler(chatID, answer)
+		},
+	}),
+	"/neworder": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "In which order should new cards come to practice? Enter \"added\" for the order you added them in, " +
+			"\"random\" or \"frequent\" for the words that are more common in sentences first.",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateNewCardOrder(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetNewCardOrder(chatID, answer)
+		},
+	}),
+	"/cloze": SimpleQuestionCommandFactory(onOffSetting("Do you want to practice with sentences in which the word is blanked out, when there are any? Enter \"on\" or \"off\".",
+		func(s *Settings) *bool { return &s.ClozePractice })),
+	"/catchup": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		questionf: func(_ *State, l *Localizer) (string, error) {
+			return l.T("How many overdue cards do you want to practice a day? The rest are spread over the following days. Enter a number between 1 and %d or \"off\".", maxCatchUp), nil
+		},
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateCatchUp(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			if err := s.Settings.SetCatchUp(chatID, answer); err != nil {
+				return err
+			}
+			return spreadBacklog(s, chatID)
+		},
+	}),
+	"/maxinterval": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		questionf: func(_ *State, l *Localizer) (string, error) {
+			return l.T("How many days at most should pass between reviews of a card? Enter a number between 1 and %d or \"off\".", maxMaxIntervalDays), nil
+		},
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateMaxInterval(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			if err := s.Settings.SetMaxInterval(chatID, answer); err != nil {
+				return err
+			}
+			settings, err := s.Settings.Get(chatID)
+			if err != nil || settings.MaxInterval() == 0 {
+				return err
+			}
+			// Cards already scheduled further come back sooner.
+			return s.Repetitions.CapInterval(chatID, "", settings.MaxInterval())
+		},
+	}),
+	"/steps": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "Enter the intervals between reviews of new and forgotten cards before they are learned, e.g. \"1m 10m 1d\", or \"off\".",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateLearningSteps(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetLearningSteps(chatID, answer)
+		},
+	}),
+	"/daystart": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "At which hour should your day start for streaks, daily goals and forecasts? Enter a number between 0 and 23, " +
+			"e.g. 4 to count practice until 4 in the morning to the previous day.",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateDayStartHour(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetDayStartHour(chatID, answer)
+		},
+	}),
+	"/goal": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		questionf: func(_ *State, l *Localizer) (string, error) {
+			return l.T("How many reviews do you want to do every day? Enter a number between 1 and %d or \"off\".", maxDailyGoal), nil
+		},
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateDailyGoal(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetDailyGoal(chatID, answer)
+		},
+	}),
+	"/listening": SimpleQuestionCommandFactory(onOffSetting("Do you want to hear the words in practice instead of reading them, for the languages with a voice? Enter \"on\" or \"off\".",
+		func(s *Settings) *bool { return &s.ListeningPractice })),
+	"/autosave": SimpleQuestionCommandFactory(onOffSetting("Do you want to save every word you look up for learning without pressing Learn? Enter \"on\" or \"off\".",
+		func(s *Settings) *bool { return &s.AutoSave })),
+	"/transliterate": SimpleQuestionCommandFactory(onOffSetting("Do you want translations of usage examples in Russian and Ukrainian to be followed by their transliteration into latin letters? Enter \"on\" or \"off\".",
+		func(s *Settings) *bool { return &s.Transliterate })),
+	"/examplelength": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "Input maximum length of usage examples in characters (0 to show examples of any length).",
+		validate: func(s *State, answer string) error {
+			return s.Settings.ValidateMaxExampleLength(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetMaxExampleLength(chatID, answer)
+		},
 	}),
 }
 
@@ -632,16 +1878,36 @@ var CommandsTemplate = struct {
 }{
 	Commands: joinCommands(
 		map[string]CommandFactory{
-			"/start": textReply(
-				"Welcome to the language bot. Still in development. No instructions " +
-					"so far. " +
-					"All sentences and translations are from Tatoeba's (https://tatoeba.org) " +
-					"dataset, released under a CC-BY 2.0 FR."),
-			"/stop":     textReply("Stopped. Input the word to get it's definition."),
-			"/practice": ReplyCommand(practiceReply),
-			"/settings": ReplyCommand(settingsReply),
-			"/add":      AddCommandFactory(),
-			"/delete":   DeleteCommandFactory(),
+			"/start":          StartCommandFactory(),
+			"/stop":           textReply("Stopped. Input the word to get it's definition."),
+			"/practice":       PracticeCommandFactory(),
+			"/quiz":           ReplyCommand(quizReply),
+			"/match":          ReplyCommand(matchReply),
+			"/random":         ReplyCommand(randomReply),
+			"/settings":       ReplyCommand(settingsReply),
+			"/add":            AddCommandFactory(),
+			"/delete":         DeleteCommandFactory(),
+			"/due":            ReplyCommand(dueReply),
+			"/stats":          ReplyCommand(statsReply),
+			"/retention":      ReplyCommand(retentionReply),
+			"/export_history": ReplyCommand(exportHistoryReply),
+			"/token":          ReplyCommand(tokenReply),
+			"/dashboard":      ReplyCommand(dashboardReply),
+			"/starred":        ReplyCommand(starredReply),
+			"/share":          ReplyCommand(shareReply),
+			"/unshare":        ReplyCommand(unshareReply),
+			"/subscribe":      SubscribeCommandFactory(),
+			"/unsubscribe":    UnsubscribeCommandFactory(),
+			"/define":         DefineCommandFactory(),
+			"/import":         ImportCommandFactory(),
+			"/vacation":       VacationCommandFactory(),
+			"/note":           NoteCommandFactory(),
+			"/image":          ImageCommandFactory(),
+			"/anki":           AnkiCommandFactory(),
+			"/ankisync":       ReplyCommand(ankiSyncReply),
+			"/ankioff":        ReplyCommand(ankiOffReply),
+			"/deletemydata":   ReplyCommand(deleteDataReply),
+			adminCommand:      func(string) Command { return adminCommands{} },
 		},
 		SettingsCommands,
 	),
@@ -649,6 +1915,31 @@ var CommandsTemplate = struct {
 		KnowCallback{},
 		DontKnowCallback{},
 		LearnCallback{},
+		TranslationLanguageCallback{},
+		StartPracticeCallback{},
+		ResetProgressCallback{},
+		ConfirmCallback{},
+		QuizAnswerCallback{},
+		UpdateCardCallback{},
+		AppendDefinitionCallback{},
+		KeepCardCallback{},
+		SwitchLanguageCallback{},
+		RemoveLanguageCallback{},
+		ContinuePracticeCallback{},
+		StudyAheadCallback{},
+		SnoozeCallback{},
+		StarCallback{},
+		PracticeStarredCallback{},
+		DefineWordCallback{},
+		ListenCallback{},
+		MatchCallback{},
+		MoreDefinitionsCallback{},
+		PickDefinitionCallback{},
+		SaveDefinitionCallback{},
+		AddNoteCallback{},
+		LearnWordCallback{},
+		ShowAnswerCallback{},
+		AttachImageCallback{},
 	},
 	DefaultCommand: func(string) Command { return defaultCommand{} },
 }
@@ -0,0 +1,46 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBotLockChat(t *testing.T) {
+	b := &Bot{state: &State{Clients: &Clients{}}, command: make(map[int64]Command)}
+	const chats = 5
+	var active [chats]int32
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(chatID int64) {
+			defer wg.Done()
+			defer b.lockChat(chatID)()
+			if n := atomic.AddInt32(&active[chatID], 1); n != 1 {
+				t.Errorf("Chat %d: %d updates processed at once want 1", chatID, n)
+			}
+			b.fetchCommand(chatID)
+			if err := b.updateCommand(chatID, nil); err != nil {
+				t.Error(err)
+			}
+			atomic.AddInt32(&active[chatID], -1)
+		}(int64(i % chats))
+	}
+	wg.Wait()
+	if len(b.locks) != 0 {
+		t.Errorf("Got %d chat locks after all updates want 0", len(b.locks))
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCallbackInfo(t *testing.T) {
+	for _, c := range []CallbackInfo{
+		{Action: SaveWordAction},
+		{Action: SaveWordAction, Word: "fekete"},
+		{Action: ToggleTranslationAction, Setting: "rus"},
+		{Action: ConfirmAction, Word: "слово", Confirm: "delete"},
+		{Action: DeclineAction, Confirm: "deletedata"},
+		{Action: SaveDefinitionAction, Word: "fekete", Setting: "hun", Index: "8"},
+		{Action: CallbackAction(300), Word: "big action"},
+	} {
+		s := c.String()
+		if len(s) > 64 {
+			t.Errorf("%+v: encoded to %d bytes, more than telegram allows", c, len(s))
+		}
+		if got := CallbackInfoFromString(s); got != c {
+			t.Errorf("CallbackInfoFromString(%q) = %+v want %+v", s, got, c)
+		}
+	}
+}
+
+func TestCallbackInfoLegacy(t *testing.T) {
+	got := CallbackInfoFromString(`{"Action":7,"Word":"fekete","Setting":"","Confirm":"delete"}`)
+	want := CallbackInfo{Action: ConfirmAction, Word: "fekete", Confirm: "delete"}
+	if got != want {
+		t.Errorf("CallbackInfoFromString(JSON) = %+v want %+v", got, want)
+	}
+}
+
+func TestCallbackInfoInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"not base64!",
+		// Unknown version.
+		"Ag",
+		// Word longer than the data.
+		"AQAF",
+	} {
+		if c, err := decodeCallbackInfo(s); err == nil {
+			t.Errorf("decodeCallbackInfo(%q) = %+v want error", s, c)
+		}
+	}
+}
+
+func TestPollLoop(t *testing.T) {
+	transient := errors.New("connection reset")
+	fatal := &StatusError{Code: http.StatusUnauthorized}
+	results := []error{nil, transient, transient, nil, transient, fatal}
+	var waits []time.Duration
+	poll := func() error {
+		err := results[0]
+		results = results[1:]
+		return err
+	}
+	sleep := func(d time.Duration) { waits = append(waits, d) }
+	if err := pollLoop(poll, sleep); err != fatal {
+		t.Fatalf("pollLoop() = %v want %v", err, fatal)
+	}
+	want := []time.Duration{pollInterval, 2 * pollInterval, 4 * pollInterval, pollInterval, 2 * pollInterval}
+	if diff := cmp.Diff(want, waits); diff != "" {
+		t.Errorf("waits (-want +got):\n%s", diff)
+	}
+}
+
+func TestPollLoopMaxBackoff(t *testing.T) {
+	n := 0
+	poll := func() error {
+		n++
+		if n > 20 {
+			return &StatusError{Code: http.StatusNotFound}
+		}
+		return errors.New("timeout")
+	}
+	var last time.Duration
+	pollLoop(poll, func(d time.Duration) { last = d })
+	if last != maxPollBackoff {
+		t.Errorf("backoff after 20 failures = %v want %v", last, maxPollBackoff)
+	}
+}
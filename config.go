@@ -0,0 +1,392 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Configuration of the bot. Every option can be set in a TOML file, overridden
+// by an environment variable and then by a flag of the same name, e.g. db_path
+// in the file, WORDS_DB_PATH and -db_path.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// envPrefix is the prefix of environment variables overriding options.
+const envPrefix = "WORDS_"
+
+// Config has all options of the bot. The toml tag is the name of the option
+// and usage is shown by -help.
+type Config struct {
+	DBPath              string          `toml:"db_path" usage:"Path to the persistent sqlite3 database."`
+	UseCache            bool            `toml:"use_cache" usage:"If true definitions from the sources are cached in the database."`
+	Push                bool            `toml:"push" usage:"If true will register webhook, otherwise will rely on polling to get updates."`
+	IP                  string          `toml:"ip" usage:"IP address of the server. Needed only if push is set to true."`
+	WebhookSecret       string          `toml:"webhook_secret" usage:"Secret token telegram sends with every pushed update, 1-256 characters of A-Z, a-z, 0-9, _ and -. Empty disables the check."`
+	WebhookURL          string          `toml:"webhook_url" usage:"Externally visible HTTPS URL of a reverse proxy forwarding to port. If set, push listens on plain HTTP and ip, cert_path and key_path are ignored."`
+	API                 bool            `toml:"api" usage:"If true the webhook server also serves the JSON API under /api/ to holders of tokens issued by /token. Needs push."`
+	Dashboard           bool            `toml:"dashboard" usage:"If true the webhook server also serves a read-only dashboard under /web/, users log in with links sent by /dashboard. Needs push."`
+	OCRURL              string          `toml:"ocr_url" usage:"URL of a tesseract server (github.com/hertzg/tesseract-server) recognizing words on photos users send. Empty disables photos."`
+	TTSKey              string          `toml:"tts_key" usage:"API key of Google Cloud Text-to-Speech speaking card fronts. Empty disables audio."`
+	TTSVoices           []string        `toml:"tts_voices" usage:"Comma separated voices of text-to-speech as language=voice, e.g. hun=hu-HU-Wavenet-A. Cards in other languages have no audio."`
+	Anki                bool            `toml:"anki" usage:"If true users can sync their cards with Anki through AnkiConnect with /anki. The bot then sends requests to the addresses users give."`
+	Bots                []string        `toml:"bots" usage:"Comma separated extra bots served by the same process as name=token. Each keeps users' data in its own database next to db_path, e.g. db.beta.sql, and shares the corpus with the main bot."`
+	Port                int             `toml:"port" usage:"Port of which webhook should listen. Needed only if push is set to true."`
+	CertPath            string          `toml:"cert_path" usage:"TLS certificate. Needed only if push is set to true."`
+	KeyPath             string          `toml:"key_path" usage:"Private key for TLS. Needed only if push is set to true."`
+	ReminderInterval    time.Duration   `toml:"reminder_interval" usage:"How often to check if reminders should be sent. 0 disables reminders."`
+	Admins              []int64         `toml:"admins" usage:"Comma separated chat IDs allowed to use /admin."`
+	RateLimit           int             `toml:"rate_limit" usage:"Maximum number of updates per minute from a single chat. 0 disables rate limiting."`
+	BackupDir           string          `toml:"backup_dir" usage:"Directory for snapshots of the database. Empty disables backups."`
+	BackupInterval      time.Duration   `toml:"backup_interval" usage:"How often to take a snapshot. 0 disables scheduled backups, /admin backup still works."`
+	BackupKeep          int             `toml:"backup_keep" usage:"Number of the latest snapshots to keep. 0 keeps all."`
+	BackupUpload        bool            `toml:"backup_upload" usage:"If true scheduled snapshots are sent to admins."`
+	MaintenanceInterval time.Duration   `toml:"maintenance_interval" usage:"How often to remove expired rows and compact the database. 0 disables scheduled maintenance, /admin maintenance still works."`
+	CacheTTL            time.Duration   `toml:"cache_ttl" usage:"How long cached definitions are kept. 0 keeps them forever."`
+	ErrorChat           int64           `toml:"error_chat" usage:"Chat ID to which internal errors are sent. 0 disables relaying."`
+	DebugPort           int             `toml:"debug_port" usage:"Port on 127.0.0.1 serving pprof profiles and metrics. 0 disables the endpoint."`
+	LogLevel            string          `toml:"log_level" usage:"Minimum level of logged lines: debug, info, warn or error."`
+	Stages              []time.Duration `toml:"stages" usage:"Comma separated intervals between reviews of a card, one for every stage of learning."`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		DBPath:              "./db.sql",
+		Port:                8443,
+		CertPath:            "webhook.crt",
+		KeyPath:             "webhook.key",
+		ReminderInterval:    time.Minute,
+		RateLimit:           30,
+		BackupInterval:      24 * time.Hour,
+		BackupKeep:          7,
+		MaintenanceInterval: 24 * time.Hour,
+		CacheTTL:            90 * 24 * time.Hour,
+		LogLevel:            "info",
+		Stages: []time.Duration{
+			20 * time.Second,
+			1 * time.Hour * 23,
+			2 * time.Hour * 23,
+			3 * time.Hour * 23,
+			5 * time.Hour * 23,
+			8 * time.Hour * 24,
+			13 * time.Hour * 24,
+			21 * time.Hour * 24,
+			34 * time.Hour * 24,
+			55 * time.Hour * 24,
+			89 * time.Hour * 24,
+			144 * time.Hour * 24,
+			233 * time.Hour * 24,
+			377 * time.Hour * 24,
+		},
+	}
+}
+
+// ParseConfig reads the config file given with -config, applies overrides from
+// the environment and flags in args, and validates the result.
+func ParseConfig(name string, args []string, getenv func(string) string) (*Config, error) {
+	return parseConfig(flag.NewFlagSet(name, flag.ContinueOnError), args, getenv)
+}
+
+// parseConfig is ParseConfig with the flags of a subcommand already defined in
+// fs, so that they can be mixed with the options.
+func parseConfig(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	c := DefaultConfig()
+	path := fs.String("config", "", "Path to a TOML config file. Environment variables "+envPrefix+"<OPTION> and flags override it.")
+	// Flags are applied after the file and the environment.
+	var flags [][2]string
+	for _, o := range c.options() {
+		fs.Var(&configFlag{c.field(o.key), o.key, &flags}, o.key, o.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *path != "" {
+		md, err := toml.DecodeFile(*path, c)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", *path, err)
+		}
+		if u := md.Undecoded(); len(u) > 0 {
+			return nil, fmt.Errorf("reading %s: unknown options %v", *path, u)
+		}
+	}
+	for _, o := range c.options() {
+		env := envPrefix + strings.ToUpper(o.key)
+		if v := getenv(env); v != "" {
+			if err := setOption(c.field(o.key), v); err != nil {
+				return nil, fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+	for _, f := range flags {
+		if err := setOption(c.field(f[0]), f[1]); err != nil {
+			return nil, fmt.Errorf("-%s: %w", f[0], err)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks that options make sense together.
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	check(c.DBPath != "", "db_path is empty")
+	if c.Push {
+		check(c.Port > 0 && c.Port < 1<<16, "port %d is out of range", c.Port)
+		if c.WebhookURL != "" {
+			check(strings.HasPrefix(c.WebhookURL, "https://"), "webhook_url %q is not an HTTPS URL", c.WebhookURL)
+		} else {
+			check(c.IP != "", "ip is required with push")
+			check(c.CertPath != "" && c.KeyPath != "", "cert_path and key_path are required with push")
+		}
+	}
+	check(!c.API || c.Push, "api requires push")
+	check(!c.Dashboard || c.Push, "dashboard requires push")
+	check(c.OCRURL == "" || strings.HasPrefix(c.OCRURL, "http://") || strings.HasPrefix(c.OCRURL, "https://"), "ocr_url %q is not an HTTP URL", c.OCRURL)
+	check(c.TTSKey == "" || len(c.TTSVoices) > 0, "tts_key requires tts_voices")
+	check(c.TTSKey != "" || len(c.TTSVoices) == 0, "tts_voices requires tts_key")
+	for _, v := range c.TTSVoices {
+		if _, _, err := parseVoice(v); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	names := make(map[string]bool)
+	for _, s := range c.Bots {
+		b, err := parseBot(s)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		check(!names[b.name], "bot %s is given twice", b.name)
+		names[b.name] = true
+	}
+	check(validSecretToken(c.WebhookSecret), "webhook_secret may contain only A-Z, a-z, 0-9, _ and - and be at most 256 characters long")
+	check(c.ReminderInterval >= 0, "reminder_interval is negative")
+	check(c.RateLimit >= 0, "rate_limit is negative")
+	check(c.DebugPort >= 0 && c.DebugPort < 1<<16, "debug_port is out of range")
+	check(c.BackupInterval >= 0, "backup_interval is negative")
+	check(c.BackupKeep >= 0, "backup_keep is negative")
+	check(!c.BackupUpload || (c.BackupDir != "" && len(c.Admins) > 0), "backup_upload requires backup_dir and admins")
+	check(c.MaintenanceInterval >= 0, "maintenance_interval is negative")
+	check(c.CacheTTL >= 0, "cache_ttl is negative")
+	check(len(c.Stages) > 0, "stages are empty")
+	for i, s := range c.Stages {
+		check(s >= 0, "stage %d is negative", i)
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validSecretToken reports whether telegram accepts s as the secret_token of
+// a webhook. The empty one means no secret.
+func validSecretToken(s string) bool {
+	if len(s) > 256 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// CommanderOptions returns options of the Commander from the config.
+func (c *Config) CommanderOptions() *CommanderOptions {
+	admins := make(map[int64]bool)
+	for _, id := range c.Admins {
+		admins[id] = true
+	}
+	var bots []botConfig
+	for _, s := range c.Bots {
+		// Bots are checked by Validate.
+		if b, err := parseBot(s); err == nil {
+			bots = append(bots, b)
+		}
+	}
+	var ocr OCR
+	if c.OCRURL != "" {
+		ocr = NewTesseractServer(c.OCRURL)
+	}
+	var tts TextToSpeech
+	if c.TTSKey != "" {
+		voices := make(map[string]string)
+		for _, v := range c.TTSVoices {
+			// Voices are checked by Validate.
+			if language, voice, err := parseVoice(v); err == nil {
+				voices[language] = voice
+			}
+		}
+		tts = NewGoogleTTS(c.TTSKey, voices)
+	}
+	return &CommanderOptions{
+		useCache:            c.UseCache,
+		dbPath:              c.DBPath,
+		port:                c.Port,
+		certPath:            c.CertPath,
+		keyPath:             c.KeyPath,
+		ip:                  c.IP,
+		push:                c.Push,
+		webhookURL:          c.WebhookURL,
+		webhookSecret:       c.WebhookSecret,
+		api:                 c.API,
+		dashboard:           c.Dashboard,
+		ocr:                 ocr,
+		tts:                 tts,
+		anki:                c.Anki,
+		bots:                bots,
+		stages:              c.Stages,
+		reminderInterval:    c.ReminderInterval,
+		rateLimit:           c.RateLimit,
+		admins:              admins,
+		backupDir:           c.BackupDir,
+		backupInterval:      c.BackupInterval,
+		backupKeep:          c.BackupKeep,
+		backupUpload:        c.BackupUpload,
+		maintenanceInterval: c.MaintenanceInterval,
+		cacheTTL:            c.CacheTTL,
+		errorChat:           c.ErrorChat,
+	}
+}
+
+type option struct {
+	key   string
+	usage string
+	index int
+}
+
+func (c *Config) options() []option {
+	t := reflect.TypeOf(c).Elem()
+	var opts []option
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		opts = append(opts, option{f.Tag.Get("toml"), f.Tag.Get("usage"), i})
+	}
+	return opts
+}
+
+func (c *Config) field(key string) reflect.Value {
+	for _, o := range c.options() {
+		if o.key == key {
+			return reflect.ValueOf(c).Elem().Field(o.index)
+		}
+	}
+	panic("unknown option " + key)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setOption parses the value from an environment variable or a flag into the
+// field. Lists are comma separated.
+func setOption(f reflect.Value, s string) error {
+	if f.Kind() == reflect.Slice {
+		var items []string
+		for _, e := range strings.Split(s, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				items = append(items, e)
+			}
+		}
+		l := reflect.MakeSlice(f.Type(), len(items), len(items))
+		for i, e := range items {
+			if err := setOption(l.Index(i), e); err != nil {
+				return err
+			}
+		}
+		f.Set(l)
+		return nil
+	}
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.String:
+		f.SetString(s)
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case f.Kind() == reflect.Int || f.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	default:
+		panic(fmt.Sprintf("unsupported option type %v", f.Type()))
+	}
+	return nil
+}
+
+// formatOption is the inverse of setOption.
+func formatOption(f reflect.Value) string {
+	if f.Kind() == reflect.Slice {
+		var items []string
+		for i := 0; i < f.Len(); i++ {
+			items = append(items, formatOption(f.Index(i)))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// configFlag records the flag to be applied after the file and the
+// environment.
+type configFlag struct {
+	field reflect.Value
+	key   string
+	flags *[][2]string
+}
+
+func (f *configFlag) String() string {
+	if f == nil || !f.field.IsValid() {
+		return ""
+	}
+	return formatOption(f.field)
+}
+
+func (f *configFlag) Set(s string) error {
+	// Fail early on malformed values.
+	if err := setOption(reflect.New(f.field.Type()).Elem(), s); err != nil {
+		return err
+	}
+	*f.flags = append(*f.flags, [2]string{f.key, s})
+	return nil
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.field.Kind() == reflect.Bool
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "words.toml")
+	if err := ioutil.WriteFile(path, []byte(`
+db_path = "/var/words/db.sql"
+port = 8000
+reminder_interval = "5m"
+admins = [7]
+stages = ["1m", "1h"]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"WORDS_PORT":   "9000",
+		"WORDS_ADMINS": " 1,-2,,3 ",
+	}
+	got, err := ParseConfig("words", []string{"-config", path, "-port", "9001", "-push", "-ip", "1.2.3.4"},
+		func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultConfig()
+	want.DBPath = "/var/words/db.sql"
+	want.Port = 9001
+	want.Push = true
+	want.IP = "1.2.3.4"
+	want.ReminderInterval = 5 * time.Minute
+	want.Admins = []int64{1, -2, 3}
+	want.Stages = []time.Duration{time.Minute, time.Hour}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseConfig (-want +got):\n%s", diff)
+	}
+
+	noEnv := func(string) string { return "" }
+	if got, err := ParseConfig("words", nil, noEnv); err != nil {
+		t.Errorf("ParseConfig with defaults: %v", err)
+	} else if diff := cmp.Diff(DefaultConfig(), got); diff != "" {
+		t.Errorf("ParseConfig with defaults (-want +got):\n%s", diff)
+	}
+	// Behind a reverse proxy neither ip nor TLS are needed.
+	if _, err := ParseConfig("words", []string{"-push", "-webhook_url", "https://words.example.com", "-cert_path", ""}, noEnv); err != nil {
+		t.Errorf("ParseConfig with webhook_url: %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`db_pth = "typo"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		args []string
+		env  map[string]string
+	}{
+		"unknown option in file": {args: []string{"-config", path}},
+		"malformed env":          {env: map[string]string{"WORDS_ADMINS": "1,admin"}},
+		"malformed flag":         {args: []string{"-backup_interval", "daily"}},
+		"push without ip":        {args: []string{"-push"}},
+		"unknown log level":      {env: map[string]string{"WORDS_LOG_LEVEL": "verbose"}},
+		"webhook_url not https":  {args: []string{"-push", "-webhook_url", "http://words.example.com"}},
+		"webhook_secret invalid": {env: map[string]string{"WORDS_WEBHOOK_SECRET": "not so secret"}},
+		"bot without token":      {args: []string{"-bots", "beta"}},
+		"bot name invalid":       {args: []string{"-bots", "../beta=123:abc"}},
+		"bot given twice":        {env: map[string]string{"WORDS_BOTS": "beta=123:abc,beta=456:def"}},
+		"ocr_url not HTTP":       {args: []string{"-ocr_url", "localhost:8884"}},
+		"tts_key without voices": {args: []string{"-tts_key", "key"}},
+		"tts voice invalid":      {args: []string{"-tts_key", "key", "-tts_voices", "hu-HU-Wavenet-A"}},
+	} {
+		_, err := ParseConfig("words", tc.args, func(k string) string { return tc.env[k] })
+		if err == nil {
+			t.Errorf("%s: ParseConfig succeeded want error", name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"words/schema"
+)
+
+// busyTimeoutMillis is how long a connection waits for the database to be
+// unlocked by another one before failing with SQLITE_BUSY.
+const busyTimeoutMillis = 5000
+
+// OpenDB opens the sqlite database shared by all components and brings its
+// schema up to date. WAL journal lets reads proceed while there is a write in
+// progress.
+func OpenDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=%d", path, busyTimeoutMillis))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening %q: %w", path, err)
+	}
+	if err := schema.Migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating %q: %w", path, err)
+	}
+	return db, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSharedDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode;`).Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode: got %q, %v want wal, nil", mode, err)
+	}
+
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour}, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hold a connection, so that the updates below have to use other ones
+	// from the pool. All of them should see the same tables and wait for
+	// each other instead of failing.
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM Stages;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(chatID int64) {
+			defer wg.Done()
+			w := fmt.Sprintf("word%d", chatID)
+			if err := r.Save(chatID, w, "definition", "hun"); err != nil {
+				errs <- err
+				return
+			}
+			if err := r.AnswerKnow(chatID, w); err != nil {
+				errs <- err
+				return
+			}
+			errs <- sc.Set(chatID, DefaultSettings())
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n, err := r.CountCards(); err != nil || n != cap(errs) {
+		t.Errorf("CountCards: got %d, %v want %d, nil", n, err, cap(errs))
+	}
+}
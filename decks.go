@@ -0,0 +1,274 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Decks let users share their cards with others.
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"strings"
+)
+
+// Decks keeps share codes of chats' cards and who follows them. All cards of
+// a chat form its deck.
+type Decks struct {
+	db    *sql.DB
+	clock Clock
+}
+
+// newShareCode returns a random code that is easy to type.
+func newShareCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+// normalizeShareCode makes codes typed by users comparable to the generated
+// ones.
+func normalizeShareCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Share returns the share code of the chat's deck, creating one if the deck
+// isn't shared yet.
+func (d *Decks) Share(chatID int64) (string, error) {
+	var code string
+	err := d.db.QueryRow(`SELECT code FROM Decks WHERE chat_id = $0`, chatID).Scan(&code)
+	if err == nil {
+		return code, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("INTERNAL: retrieving share code of chat %d: %w", chatID, err)
+	}
+	if code, err = newShareCode(); err != nil {
+		return "", fmt.Errorf("INTERNAL: generating share code: %w", err)
+	}
+	if _, err := d.db.Exec(`
+		INSERT INTO Decks(code, chat_id, created_seconds)
+		VALUES($0, $1, $2)`,
+		code, chatID, d.clock.Now().Unix()); err != nil {
+		return "", fmt.Errorf("INTERNAL: sharing deck of chat %d: %w", chatID, err)
+	}
+	return code, nil
+}
+
+// Unshare invalidates the share code of the chat's deck and stops updates to
+// its followers. Returns false if the deck wasn't shared.
+func (d *Decks) Unshare(chatID int64) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		DELETE FROM DeckFollowers
+		WHERE code IN (SELECT code FROM Decks WHERE chat_id = $0)`,
+		chatID); err != nil {
+		return false, fmt.Errorf("INTERNAL: removing followers of chat %d: %w", chatID, err)
+	}
+	res, err := tx.Exec(`DELETE FROM Decks WHERE chat_id = $0`, chatID)
+	if err != nil {
+		return false, fmt.Errorf("INTERNAL: unsharing deck of chat %d: %w", chatID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+// Owner returns the chat that shared the deck, sql.ErrNoRows if the code is
+// unknown.
+func (d *Decks) Owner(code string) (int64, error) {
+	var chatID int64
+	err := d.db.QueryRow(`SELECT chat_id FROM Decks WHERE code = $0`, normalizeShareCode(code)).Scan(&chatID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("INTERNAL: looking up deck %q: %w", code, err)
+	}
+	return chatID, err
+}
+
+// Follow makes the chat receive cards added to the deck from now on.
+func (d *Decks) Follow(chatID int64, code string) error {
+	if _, err := d.db.Exec(`
+		INSERT OR IGNORE INTO DeckFollowers(code, chat_id)
+		VALUES($0, $1)`,
+		normalizeShareCode(code), chatID); err != nil {
+		return fmt.Errorf("INTERNAL: following deck %q: %w", code, err)
+	}
+	return nil
+}
+
+// Unfollow stops updates of the deck to the chat. Returns false if the chat
+// didn't follow it.
+func (d *Decks) Unfollow(chatID int64, code string) (bool, error) {
+	res, err := d.db.Exec(`
+		DELETE FROM DeckFollowers
+		WHERE code = $0
+		  AND chat_id = $1`,
+		normalizeShareCode(code), chatID)
+	if err != nil {
+		return false, fmt.Errorf("INTERNAL: unfollowing deck %q: %w", code, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Followers returns the chats following the deck of the chat.
+func (d *Decks) Followers(chatID int64) ([]int64, error) {
+	rows, err := d.db.Query(`
+		SELECT f.chat_id
+		FROM DeckFollowers f
+		JOIN Decks d ON d.code = f.code
+		WHERE d.chat_id = $0
+		ORDER BY f.chat_id`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving followers of chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// saveCard saves the card for the chat and copies it to the followers of its
+// deck. Failing to update followers doesn't fail the save.
+func saveCard(s *State, chatID int64, word, definition, language string) error {
+	if err := s.Repetitions.Save(chatID, word, definition, language); err != nil {
+		return err
+	}
+	fs, err := s.Decks.Followers(chatID)
+	if err != nil {
+		s.Log.Error("Updating followers", "err", err)
+		return nil
+	}
+	for _, f := range fs {
+		if _, err := s.Repetitions.CopyCards(chatID, f, word); err != nil {
+			s.Log.Error("Updating follower", "follower", f, "err", err)
+		}
+	}
+	return nil
+}
+
+func shareReply(s *State, chatID int64) error {
+	code, err := s.Decks.Share(chatID)
+	if err != nil {
+		return err
+	}
+	return s.Telegram.SendTextMessage(chatID, s.L(chatID).T(
+		"Your share code is %s. Others can copy your words with /subscribe %s, /unshare stops sharing.", code, code))
+}
+
+func unshareReply(s *State, chatID int64) error {
+	ok, err := s.Decks.Unshare(chatID)
+	if err != nil {
+		return err
+	}
+	msg := "Your words aren't shared anymore."
+	if !ok {
+		msg = "Your words aren't shared."
+	}
+	return s.Telegram.SendTextMessage(chatID, s.L(chatID).T(msg))
+}
+
+// deckCodeQuestion asks for the share code of someone else's deck.
+var deckCodeQuestion = &question{
+	name: "code",
+	ask:  askQuestion("Enter the share code of the deck."),
+	validate: func(s *State, m *Message) error {
+		owner, err := s.Decks.Owner(m.Text)
+		if err == sql.ErrNoRows {
+			return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("there is no deck with the code %q", m.Text)}
+		}
+		if err != nil {
+			return err
+		}
+		if owner == m.Chat.Id {
+			return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("this is your own deck")}
+		}
+		return nil
+	},
+}
+
+// SubscribeCommandFactory copies cards of a shared deck and optionally keeps
+// copying the ones added later.
+func SubscribeCommandFactory() CommandFactory {
+	return MultiQuestionCommandFactory(
+		[]*question{deckCodeQuestion, {
+			name: "mode",
+			ask:  askQuestion("Enter \"copy\" to copy the words once or \"follow\" to also get the words added later."),
+			validate: func(s *State, m *Message) error {
+				if a := strings.ToLower(strings.TrimSpace(m.Text)); a != "copy" && a != "follow" {
+					return UserError{ChatID: m.Chat.Id, Err: localizedErrorf("expected \"copy\" or \"follow\"")}
+				}
+				return nil
+			},
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			code := normalizeShareCode(qs[0].answer)
+			owner, err := s.Decks.Owner(code)
+			if err != nil {
+				return err
+			}
+			n, err := s.Repetitions.CopyCards(owner, chatID, "")
+			if err != nil {
+				return err
+			}
+			l := s.L(chatID)
+			msg := l.T("Copied %d words for learning.", n)
+			if strings.ToLower(strings.TrimSpace(qs[1].answer)) == "follow" {
+				if err := s.Decks.Follow(chatID, code); err != nil {
+					return err
+				}
+				msg += " " + l.T("Words added to the deck later will be copied too, /unsubscribe %s stops that.", code)
+			}
+			return s.Telegram.SendTextMessage(chatID, msg)
+		},
+	)
+}
+
+// UnsubscribeCommandFactory stops copying new cards of the deck, the copied
+// ones stay.
+func UnsubscribeCommandFactory() CommandFactory {
+	return MultiQuestionCommandFactory(
+		[]*question{{
+			name:     "code",
+			ask:      askQuestion("Enter the share code of the deck."),
+			validate: func(*State, *Message) error { return nil },
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			ok, err := s.Decks.Unfollow(chatID, qs[0].answer)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return UserError{ChatID: chatID, Err: localizedErrorf("you don't follow the deck %q", qs[0].answer)}
+			}
+			return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("New words of the deck won't be copied anymore, the copied ones stay."))
+		},
+	)
+}
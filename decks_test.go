@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "decks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Decks{db: db, clock: clock}
+	s := &State{Clients: &Clients{Repetitions: r, Decks: d}, Log: slog.Default()}
+	const owner, copier, follower int64 = 1, 2, 3
+
+	for _, w := range []string{"foo", "bar"} {
+		if err := saveCard(s, owner, w, w+" definition", "hun"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Progress of the owner isn't copied.
+	if err := r.AnswerKnow(owner, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	code, err := d.Share(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := d.Share(owner); err != nil || again != code {
+		t.Errorf("Share again: got %q, %v want %q, nil", again, err, code)
+	}
+	if got, err := d.Owner(" " + strings.ToLower(code)); err != nil || got != owner {
+		t.Errorf("Owner(%q): got %d, %v want %d, nil", code, got, err, owner)
+	}
+	if _, err := d.Owner("NOSUCHCODE"); err != sql.ErrNoRows {
+		t.Errorf("Owner of unknown code: got %v want %v", err, sql.ErrNoRows)
+	}
+
+	if err := r.Save(copier, "bar", "own bar", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.CopyCards(owner, copier, ""); err != nil || n != 1 {
+		t.Errorf("CopyCards: got %d, %v want 1, nil", n, err)
+	}
+	if def, err := r.GetDefinition(copier, "bar"); err != nil || def != "own bar" {
+		t.Errorf("copying replaced the existing card: got %q, %v", def, err)
+	}
+	clock.Advance(time.Hour)
+	if n, err := r.DueCount(copier); err != nil || n != 2 {
+		t.Errorf("DueCount of the copier: got %d, %v want 2, nil", n, err)
+	}
+
+	if _, err := r.CopyCards(owner, follower, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Follow(follower, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveCard(s, owner, "baz", "baz definition", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		chatID int64
+		want   bool
+	}{{copier, false}, {follower, true}} {
+		if got, err := r.Exists(tc.chatID, "baz"); err != nil || got != tc.want {
+			t.Errorf("chat %d has the new card: got %t, %v want %t, nil", tc.chatID, got, err, tc.want)
+		}
+	}
+
+	if ok, err := d.Unshare(owner); err != nil || !ok {
+		t.Errorf("Unshare: got %t, %v want true, nil", ok, err)
+	}
+	if fs, err := d.Followers(owner); err != nil || len(fs) != 0 {
+		t.Errorf("Followers after Unshare: got %v, %v want none", fs, err)
+	}
+	if ok, err := d.Unfollow(follower, code); err != nil || ok {
+		t.Errorf("Unfollow after Unshare: got %t, %v want false, nil", ok, err)
+	}
+}
@@ -18,25 +18,161 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
+
+	"golang.org/x/sync/singleflight"
+
+	"words/translit"
 )
 
 type Definer struct {
 	usage *UsageFetcher
 	cache DefCacheInterface
 	http  *http.Client
+
+	// calls are the lookups in progress by the cache query, see Define.
+	calls singleflight.Group
+}
+
+// attribution returns a link to the example's source, as required by its
+// CC-BY license.
+func attribution(l *Localizer, e *UsageExample) *Formatted {
+	t := fmt.Sprintf("#%d", e.ID)
+	if e.Author != "" {
+		t = l.T("#%d by %s", e.ID, e.Author)
+	}
+	return new(Formatted).Plain("(").Link(t, e.URL()).Plain(")")
+}
+
+// maxShownDefinitions is the number of definitions in the reply, the rest are
+// sent on request.
+const maxShownDefinitions = 8
+
+// formatDefinition formats the definition number n. Labels are in italics,
+// examples and notes follow the gloss on separate lines.
+func formatDefinition(n int, d *WikiDefinition) *Formatted {
+	f := new(Formatted).Plain(fmt.Sprintf("%d. [", n)).Bold(strings.ToLower(string(d.SpeechPart))).Plain("] ")
+	if len(d.Labels) > 0 {
+		f.Italic("(" + strings.Join(d.Labels, ", ") + ")").Plain(" ")
+	}
+	f.Plain(d.Gloss)
+	for _, l := range append(append([]string(nil), d.Examples...), d.Notes...) {
+		f.Plain("\n" + l)
+	}
+	return f
+}
+
+// definiteArticles are the articles of the genders by input language, "pl" is
+// the article of plurals.
+var definiteArticles = map[string]map[string]string{
+	"German": {"m": "der", "f": "die", "n": "das", "pl": "die"},
+}
+
+// headword returns the gender and the plural of the first noun among defs,
+// e.g. "der Hund, die Hunde" for German, empty if there are none.
+func headword(l *Localizer, language string, defs []*WikiDefinition) string {
+	for _, d := range defs {
+		if d.Gender == "" && d.Plural == "" {
+			continue
+		}
+		articles := definiteArticles[language]
+		var parts []string
+		switch a, ok := articles[d.Gender]; {
+		case ok:
+			parts = append(parts, a+" "+d.Word)
+		case d.Gender != "":
+			parts = append(parts, d.Word+" "+d.Gender)
+		default:
+			parts = append(parts, d.Word)
+		}
+		switch a, ok := articles["pl"]; {
+		case d.Plural == "":
+		case ok:
+			parts = append(parts, a+" "+d.Plural)
+		default:
+			parts = append(parts, l.T("plural %s", d.Plural))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// senseNumber matches the beginning of a definition formatted by
+// formatDefinition.
+var senseNumber = regexp.MustCompile(`^\d+\. `)
+
+// senses returns the text of the definitions in the message returned by
+// Define, without their numbers.
+func senses(msg *Formatted) []string {
+	ps := strings.Split(msg.Text, "\n\n")
+	if len(ps) < 2 {
+		return nil
+	}
+	var ss []string
+	for _, line := range strings.Split(ps[1], "\n") {
+		switch {
+		case senseNumber.MatchString(line):
+			ss = append(ss, senseNumber.ReplaceAllString(line, ""))
+		case len(ss) > 0:
+			// Definitions with examples span several lines.
+			ss[len(ss)-1] += "\n" + line
+		}
+	}
+	return ss
+}
+
+// defineQuery returns the key of the definitions of the word in the cache.
+func defineQuery(word string, settings *Settings) string {
+	// FIXME: Cache ignores settings apart from the input language, so
+	// definitions are shown with usage examples and interface language of the
+	// user who looked up the word first.
+	query := settings.InputLanguageISO639_3 + ":" + word
+	if settings.Transliterate {
+		// Otherwise users who don't want transliteration would get it.
+		query = "translit:" + query
+	}
+	return query
+}
+
+// Define returns the messages with the definitions of the word. more contains
+// the definitions that didn't fit into them, if any. Concurrent lookups of
+// the same word in the same language share a single lookup of the cache and
+// the sources.
+func (d *Definer) Define(word string, settings *Settings) (ds []*Formatted, more *Formatted, err error) {
+	query := defineQuery(word, settings)
+	v, err, _ := d.calls.Do(query, func() (interface{}, error) {
+		ds, more, err := d.define(query, word, settings)
+		return &cachedDefinitions{ds, more}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	c := v.(*cachedDefinitions)
+	return c.Messages, c.More, nil
 }
 
-func (d *Definer) Define(word string, settings *Settings) (ds []string, err error) {
-	// TODO: Not very elegant, is there a better way?
-	const separator = "lsjelrzprhkvzvxzquhurhcakugvuhtqkrklggdpdseus----="
-	_, def, err := d.cache.Lookup(word)
+// cachedDefinitions are the messages returned by Define as kept in the cache.
+type cachedDefinitions struct {
+	Messages []*Formatted `json:"messages"`
+	More     *Formatted   `json:"more,omitempty"`
+}
+
+// define looks up the definitions of the word in the cache under query, and
+// in the sources if they aren't there.
+func (d *Definer) define(query, word string, settings *Settings) (ds []*Formatted, more *Formatted, err error) {
+	_, def, err := d.cache.Lookup(query)
 	if err == nil {
-		return strings.Split(def, separator), nil
+		var c cachedDefinitions
+		if err := json.Unmarshal([]byte(def), &c); err != nil {
+			return nil, nil, fmt.Errorf("INTERNAL: cached definitions of %q: %w", query, err)
+		}
+		return c.Messages, c.More, nil
 	}
 	if errors.Is(err, sql.ErrNoRows) {
 		defer func() {
@@ -45,13 +181,17 @@ func (d *Definer) Define(word string, settings *Settings) (ds []string, err erro
 			if len(ds) == 0 || err != nil {
 				return
 			}
-			if err := d.cache.Save(word, word, strings.Join(ds, separator)); err != nil {
-				log.Printf("cache.Save(%q): %w", word, err)
+			def, err := json.Marshal(&cachedDefinitions{ds, more})
+			if err == nil {
+				err = d.cache.Save(query, word, string(def))
+			}
+			if err != nil {
+				slog.Error("Saving to cache", "word", word, "err", err)
 			}
 		}()
 	} else {
 		// At this point err != nil
-		log.Printf("ERROR: cache.Lookup(%q): %w", word, err)
+		slog.Error("Looking up cache", "word", word, "err", err)
 	}
 
 	p := WikiParser{
@@ -59,37 +199,54 @@ func (d *Definer) Define(word string, settings *Settings) (ds []string, err erro
 	}
 	defs, err := FetchWikiDefinition(p, d.http, word)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	word = defs[0].Word
 
-	ex, err := d.usage.FetchExamples(word, settings.InputLanguageISO639_3, settings.TranslationLanguages)
+	ex, err := d.usage.FetchExamples(word, settings)
 	if err != nil {
 		ex = nil
-		log.Printf("ERROR: FetchExamples(%s): %v", word, err)
-		log.Printf("WARNING Did not find usage examples for %q", word)
+		slog.Warn("Did not find usage examples", "word", word, "err", err)
+	}
+	l := NewLocalizer(settings.BotLanguage)
+	msg := new(Formatted).Bold(word).Plain("\n")
+	if h := headword(l, settings.InputLanguage, defs); h != "" {
+		msg.Italic(h).Plain("\n")
+	}
+	if f := defs[0].Form; f != "" {
+		msg.Italic(l.T("%s looks like a form of %s", f, word)).Plain("\n")
 	}
-	msg := "*" + escapeMarkdown(word) + "*\n"
 	for i, d := range defs {
-		if i > 7 {
-			msg += "\n"
-			msg += fmt.Sprintf("_\\[truncated %d definitions\\]_", len(defs)-i)
+		if i >= maxShownDefinitions {
+			// In a paragraph of its own, so that it isn't taken for a
+			// part of the last definition, see senses.
+			msg.Plain("\n\n").Italic(l.T("[truncated %d definitions]", len(defs)-i))
 			break
 		}
-		msg += "\n"
-		msg += fmt.Sprintf(`%d\. \[*%s*\] %s`, i+1, strings.ToLower(d.SpeechPart), escapeMarkdown(d.Definition))
+		msg.Plain("\n").Append(formatDefinition(i+1, d))
+	}
+	if len(defs) > maxShownDefinitions {
+		more = new(Formatted).Bold(word).Plain("\n")
+		for i, d := range defs[maxShownDefinitions:] {
+			more.Plain("\n").Append(formatDefinition(maxShownDefinitions+i+1, d))
+		}
 	}
 	if len(ex) > 0 {
-		msg += "\n\nUsage examples:"
+		msg.Plain("\n\n" + l.T("Usage examples:"))
 		for i, e := range ex {
-			msg += "\n\n"
-			msg += fmt.Sprintf(`%d\. %s`, i+1, escapeMarkdown(e.Text))
+			msg.Plain(fmt.Sprintf("\n\n%d. %s ", i+1, e.Text)).Append(attribution(l, e))
 			for _, t := range e.Translations {
-				msg += "\n" + fmt.Sprintf(`  _%s_`, escapeMarkdown(t))
+				msg.Plain("\n  ").Italic(t.Text)
+				if !settings.Transliterate {
+					continue
+				}
+				if r, ok := translit.Romanize(t.Language, t.Text); ok {
+					msg.Plain("\n  " + r)
+				}
 			}
 		}
 	} else {
-		msg += escapeMarkdown("\n\nDidn't find usage examples.")
+		msg.Plain("\n\n" + l.T("Didn't find usage examples."))
 	}
-	return []string{msg}, nil
+	return []*Formatted{msg}, more, nil
 }
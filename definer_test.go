@@ -0,0 +1,124 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSenses(t *testing.T) {
+	msg := new(Formatted).Bold("falu").Plain("\n\n").
+		Append(formatDefinition(1, &WikiDefinition{SpeechPart: "Noun", Gloss: "village", Examples: []string{"A világ egy falu."}})).
+		Plain("\n").
+		Append(formatDefinition(2, &WikiDefinition{SpeechPart: "Noun", Gloss: "the people of a village"})).
+		Plain("\n\n").Italic("[truncated 3 definitions]").
+		Plain("\n\nUsage examples:\n\n1. Ez egy falu.")
+	want := []string{
+		"[noun] village\nA világ egy falu.",
+		"[noun] the people of a village",
+	}
+	if got := senses(msg); !reflect.DeepEqual(got, want) {
+		t.Errorf("senses(%q) = %q want %q", msg.Text, got, want)
+	}
+	if got := senses(new(Formatted).Bold("falu")); got != nil {
+		t.Errorf("senses without definitions = %q want none", got)
+	}
+}
+
+func TestHeadword(t *testing.T) {
+	l := NewLocalizer("")
+	adjective := &WikiDefinition{Word: "neu", SpeechPart: "Adjective"}
+	for _, tc := range []struct {
+		language string
+		defs     []*WikiDefinition
+		want     string
+	}{
+		{"German", []*WikiDefinition{adjective, {Word: "Hund", SpeechPart: "Noun", Gender: "m", Plural: "Hunde"}}, "der Hund, die Hunde"},
+		{"German", []*WikiDefinition{{Word: "Joghurt", SpeechPart: "Noun", Gender: "m or n"}}, "Joghurt m or n"},
+		{"Hungarian", []*WikiDefinition{{Word: "falu", SpeechPart: "Noun", Plural: "falvak"}}, "falu, plural falvak"},
+		{"German", []*WikiDefinition{adjective}, ""},
+	} {
+		if got := headword(l, tc.language, tc.defs); got != tc.want {
+			t.Errorf("headword(%q, %v) = %q; want %q", tc.language, tc.defs, got, tc.want)
+		}
+	}
+}
+
+// blockingCache counts lookups, which wait until release is closed.
+type blockingCache struct {
+	mu      sync.Mutex
+	lookups int
+	release chan struct{}
+}
+
+func (c *blockingCache) Lookup(string) (string, string, error) {
+	c.mu.Lock()
+	c.lookups++
+	c.mu.Unlock()
+	<-c.release
+	return "ház", `{"messages": [{"text": "ház"}]}`, nil
+}
+
+func (*blockingCache) Save(_, _, _ string) error {
+	return nil
+}
+
+func TestDefineCoalesces(t *testing.T) {
+	cache := &blockingCache{release: make(chan struct{})}
+	d := &Definer{cache: cache}
+	s := DefaultSettings()
+	const lookups = 3
+	results := make(chan []*Formatted, lookups)
+	for i := 0; i < lookups; i++ {
+		go func() {
+			ds, _, err := d.Define("ház", s)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- ds
+		}()
+	}
+	// Let all lookups start before the first one gets the definition.
+	for {
+		cache.mu.Lock()
+		started := cache.lookups > 0
+		cache.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(cache.release)
+	for i := 0; i < lookups; i++ {
+		if ds := <-results; !reflect.DeepEqual(ds, []*Formatted{{Text: "ház"}}) {
+			t.Errorf("Define() = %+v, want [ház]", ds)
+		}
+	}
+	if cache.lookups != 1 {
+		t.Errorf("Looked up the cache %d times for %d concurrent lookups; want 1", cache.lookups, lookups)
+	}
+
+	// Other languages are looked up separately.
+	s.InputLanguageISO639_3 = "deu"
+	if _, _, err := d.Define("ház", s); err != nil {
+		t.Fatal(err)
+	}
+	if cache.lookups != 2 {
+		t.Errorf("Looked up the cache %d times after a lookup in another language; want 2", cache.lookups)
+	}
+}
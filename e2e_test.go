@@ -18,7 +18,6 @@ package main
 
 import (
 	"bytes"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -35,12 +34,11 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// TODO: have a fake wiki server.
 func TestTelegramBotE2E(t *testing.T) {
-	// TODO: mock wiki
-	// TODO: test edit message
 	type Test struct {
-		// if prefixed with b: button is pressed
+		// If prefixed with b: the button is pressed on the last message. If
+		// prefixed with b^: it's pressed on the message before it, and that
+		// message is checked instead of the last one.
 		Send        string
 		Want        string
 		WantButtons []string
@@ -50,6 +48,16 @@ func TestTelegramBotE2E(t *testing.T) {
 	send := strings.Split(strings.Trim(`
 /start
 
+Klingon
+
+Hungarian
+
+default
+
+UTC+1
+
+09:00-21:00
+
 many words
 
 oijasdki#noresults#
@@ -58,9 +66,13 @@ oijasdki#noresults#
 
 fekete
 
+b:Show more definitions
+
 fekete
 
-b:Learn
+b:Pick definition
+
+b:#1
 
 fekete
 
@@ -70,15 +82,23 @@ b:Don't know
 
 /settings
 
+/cloze on
+
 /practice
 
 b:Don't know
 
 b:Know
 
+b:Study ahead
+
+/cloze off
+
 falu
 
-b:Learn
+/stop
+
+b^:Learn
 
 /practice
 
@@ -110,6 +130,20 @@ Hungarian
 
 falu
 
+b:Yes
+
+/autosave on
+
+falu
+
+/autosave off
+
+/delete
+
+falu
+
+b:Yes
+
 /practice
 
 /add
@@ -124,6 +158,210 @@ cardback (definitions or what not)
 
 cardfront
 
+b:Reset progress
+
+b:No
+
+cardfront
+
+b:☆ Star
+
+/starred
+
+b:Practice starred
+
+b:Don't know
+
+/practice
+
+1
+
+again
+
+b:Later
+
+/sessionlength 2
+
+/practice
+
+1
+
+again
+
+b:Continue
+
+/vacation
+
+0
+
+off
+
+/scheduler
+
+weekly
+
+leitner
+
+/scheduler default
+
+/transliterate on
+
+/define fekete
+
+/transliterate off
+
+/translations
+
+b:✅ eng
+
+b:❌ deu
+
+/settings
+
+/add inline
+
+inline card back
+
+/delete notsaved
+
+/delete inline
+
+b:Yes
+
+/timezone UTC+2
+
+/add
+
+/cancel
+
+/cancel
+
+/delete
+
+/dlete
+
+/start
+
+/practise
+
+/stat
+
+/xyzzyplugh
+
+/botlanguage
+
+Klingon
+
+Russian
+
+/settings
+
+/remind
+
+never
+
+/stop
+
+/botlanguage English
+
+/admin stats
+
+/add quizone
+
+quizone back
+
+/add quiztwo
+
+quiztwo back
+
+/quiz
+
+b:Know
+
+b:Don't know
+
+b:Know
+
+/quiz
+
+/define many words
+
+/cancel
+
+/random
+
+b:Show answer
+
+b:Know
+
+b:Add note
+
+mnemonic: card
+
+/goal 30
+
+/stats
+
+/goal off
+
+/retention
+
+/token
+
+/dashboard
+
+/match
+
+b:… back
+
+b:quizone
+
+b:… back
+
+b:fekete
+
+b:… back
+
+b:quiztwo
+
+b:… back
+
+b:cardfront
+
+b:cardback (definitions or what not)
+
+b:fekete
+
+b:[adjective] black (absorbing all light a…
+
+Fekete falu.
+
+b:Learn falu
+
+falu
+
+/addlanguage
+
+English
+
+/languages
+
+b:Hungarian
+
+/practice English
+
+/removelanguage
+
+b:❌ English
+
+/deletemydata
+
+b:No
+
+/deletemydata
+
+b:Yes
+
 /practice
 `, "\n"), "\n\n")
 
@@ -135,7 +373,7 @@ cardfront
 	dbPath := filepath.Join(dir, "tmpdb")
 
 	// Initiate db with some usage examples.
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := OpenDB(dbPath)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -143,6 +381,14 @@ cardfront
 		t.Fatal(err)
 	}
 
+	// Make /random deterministic.
+	defer func(f func(int) int) { randIntn = f }(randIntn)
+	randIntn = func(int) int { return 0 }
+	// Words are looked up again on purpose.
+	defer func(d time.Duration) { recentLookupWindow = d }(recentLookupWindow)
+	recentLookupWindow = 0
+
+	startFakeWiki(t)
 	fk := startFakeTelegram(t)
 	defer fk.server.Close()
 	tm := &Telegram{hc: *fk.server.Client()}
@@ -150,6 +396,9 @@ cardfront
 	c, err := NewCommander(tm, &CommanderOptions{
 		useCache: true,
 		dbPath:   dbPath,
+		// Cards are ordered by the second of their review, which
+		// shouldn't depend on how long the scenario runs.
+		clock: newFakeClock(time.Now()),
 		stages: []time.Duration{
 			0,
 			2 * time.Minute,
@@ -162,17 +411,33 @@ cardfront
 	var got []Test
 	for _, msg := range send {
 		t.Logf("msg: %s", msg)
-		if strings.HasPrefix(msg, "b:") {
+		answers := len(fk.toasts)
+		var pressed *Message
+		switch {
+		case strings.HasPrefix(msg, "b:"):
 			if err := fk.PressButton(strings.TrimPrefix(msg, "b:")); err != nil {
 				t.Error(err)
 			}
-		} else {
+		case strings.HasPrefix(msg, "b^:"):
+			pressed = fk.messages[len(fk.messages)-2]
+			if err := fk.PressButtonOn(pressed, strings.TrimPrefix(msg, "b^:")); err != nil {
+				t.Error(err)
+			}
+		default:
 			fk.SendMessage(msg)
 		}
 		if err := c.PollAndProcess(); err != nil {
 			t.Fatal(err)
 		}
+		// Telegram accepts a single answer to a callback query.
+		button := strings.HasPrefix(msg, "b:") || strings.HasPrefix(msg, "b^:")
+		if n := len(fk.toasts) - answers; button && n != 1 {
+			t.Errorf("%s: answered the callback %d times want once", msg, n)
+		}
 		lm := fk.messages[len(fk.messages)-1]
+		if pressed != nil {
+			lm = pressed
+		}
 		var bs []string
 		for _, ks := range lm.ReplyMarkup.InlineKeyboard {
 			for _, k := range ks {
@@ -211,6 +476,242 @@ cardfront
 	}
 }
 
+func TestImageCardE2E(t *testing.T) {
+	fk := startFakeTelegram(t)
+	defer fk.server.Close()
+	c, err := NewCommander(&Telegram{hc: *fk.server.Client()}, &CommanderOptions{
+		dbPath: filepath.Join(t.TempDir(), "tmpdb"),
+		stages: []time.Duration{0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	process := func() {
+		t.Helper()
+		if err := c.PollAndProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fk.SendMessage("/add")
+	process()
+	// The photo without a caption is asked again.
+	fk.SendPhoto("photo-of-apple", "")
+	process()
+	if got, want := fk.messages[len(fk.messages)-1].Text, "Send the photo with the word as its caption."; got != want {
+		t.Errorf("Photo without caption: got %q want %q", got, want)
+	}
+	fk.SendPhoto("photo-of-apple", "alma")
+	process()
+	fk.SendMessage("apple")
+	process()
+	if got, want := fk.messages[len(fk.messages)-1].Text, "Added \"alma\" for learning!"; got != want {
+		t.Errorf("Adding: got %q want %q", got, want)
+	}
+
+	fk.SendMessage("/practice")
+	process()
+	lm := fk.messages[len(fk.messages)-1]
+	if len(lm.Photo) != 1 || lm.Photo[0].FileId != "photo-of-apple" || lm.Caption != "Which word is it?" {
+		t.Errorf("Practice card: got photo %v with caption %q want photo-of-apple", lm.Photo, lm.Caption)
+	}
+	if err := fk.PressButton("Know"); err != nil {
+		t.Fatal(err)
+	}
+	process()
+	// The next message tells that there is nothing more to practice.
+	card := fk.messages[len(fk.messages)-2]
+	if want := "alma\n\napple"; card.Caption != want {
+		t.Errorf("Flipped card: got caption %q want %q", card.Caption, want)
+	}
+}
+
+// TestPracticeShortcutE2E answers with a shortcut a card of the session
+// started with a button rather than /practice.
+func TestPracticeShortcutE2E(t *testing.T) {
+	fk := startFakeTelegram(t)
+	defer fk.server.Close()
+	c, err := NewCommander(&Telegram{hc: *fk.server.Client()}, &CommanderOptions{
+		dbPath: filepath.Join(t.TempDir(), "tmpdb"),
+		stages: []time.Duration{0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	process := func() {
+		t.Helper()
+		if err := c.PollAndProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range []string{"/add", "alma", "apple", "alma"} {
+		fk.SendMessage(m)
+		process()
+	}
+	if err := fk.PressButton("☆ Star"); err != nil {
+		t.Fatal(err)
+	}
+	process()
+	fk.SendMessage("/starred")
+	process()
+	if err := fk.PressButton("Practice starred"); err != nil {
+		t.Fatal(err)
+	}
+	process()
+	fk.SendMessage("2")
+	process()
+	// The card is flipped as if the "Know" button was pressed.
+	card := fk.messages[len(fk.messages)-2]
+	if want := "apple"; card.Text != want {
+		t.Errorf("Card answered with 2: got %q want %q", card.Text, want)
+	}
+	if ks := card.ReplyMarkup.InlineKeyboard; len(ks) != 1 || len(ks[0]) != 3 {
+		t.Errorf("Card answered with 2: got keyboard %v, want Don't know, note and star buttons", ks)
+	}
+	if got, want := fk.messages[len(fk.messages)-1].Text, "No more starred cards to practice."; got != want {
+		t.Errorf("After the answer: got %q want %q", got, want)
+	}
+}
+
+// TestTranslationLanguagesE2E toggles translation languages, the callbacks are
+// answered right away and errors are sent as messages.
+func TestTranslationLanguagesE2E(t *testing.T) {
+	fk := startFakeTelegram(t)
+	defer fk.server.Close()
+	c, err := NewCommander(&Telegram{hc: *fk.server.Client()}, &CommanderOptions{
+		dbPath: filepath.Join(t.TempDir(), "tmpdb"),
+		stages: []time.Duration{0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fk.SendMessage("/translations")
+	if err := c.PollAndProcess(); err != nil {
+		t.Fatal(err)
+	}
+	m := fk.messages[len(fk.messages)-1]
+	for _, b := range []string{"✅ eng", "✅ rus", "✅ ukr"} {
+		if err := fk.PressButtonOn(m, b); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.PollAndProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"", "", ""}; !reflect.DeepEqual(fk.toasts, want) {
+		t.Errorf("toasts: got %q want %q", fk.toasts, want)
+	}
+	var bs []string
+	for _, k := range m.ReplyMarkup.InlineKeyboard[0] {
+		bs = append(bs, k.Text)
+	}
+	if want := []string{"❌ deu", "❌ eng", "❌ rus", "✅ ukr"}; !reflect.DeepEqual(bs, want) {
+		t.Errorf("buttons: got %q want %q", bs, want)
+	}
+	if got, want := fk.messages[len(fk.messages)-1].Text, "At least one translation language should stay enabled"; got != want {
+		t.Errorf("last message: got %q want %q", got, want)
+	}
+}
+
+func TestWebhookE2E(t *testing.T) {
+	dir, err := ioutil.TempDir("", "e2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fk := startFakeTelegram(t)
+	defer fk.server.Close()
+	c, err := NewCommander(&Telegram{hc: *fk.server.Client()}, &CommanderOptions{
+		dbPath: filepath.Join(dir, "tmpdb"),
+		stages: []time.Duration{0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const secret = "s3cret-token"
+	s := httptest.NewServer(c.WebhookHandler(secret))
+	defer s.Close()
+
+	update := func(text string) string {
+		fk.SendMessage(text)
+		b, err := json.Marshal(fk.updates[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fk.updates = nil
+		return string(b)
+	}
+	for _, tc := range []struct {
+		name       string
+		method     string
+		secret     string
+		body       string
+		wantStatus int
+		// wantReply is the text of the message sent in response, empty if
+		// nothing should be sent.
+		wantReply string
+	}{
+		{
+			name:       "update",
+			method:     "POST",
+			secret:     secret,
+			body:       update("/stop"),
+			wantStatus: http.StatusOK,
+			wantReply:  "Stopped. Input the word to get it's definition.",
+		},
+		{
+			name:       "no secret",
+			method:     "POST",
+			body:       update("/stop"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong secret",
+			method:     "POST",
+			secret:     "guessed",
+			body:       update("/stop"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "GET",
+			method:     "GET",
+			secret:     secret,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "malformed update",
+			method:     "POST",
+			secret:     secret,
+			body:       `{"update_id": "one"`,
+			wantStatus: http.StatusBadRequest,
+		},
+	} {
+		sent := len(fk.messages)
+		req, err := http.NewRequest(tc.method, s.URL, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.secret != "" {
+			req.Header.Set(secretTokenHeader, tc.secret)
+		}
+		res, err := s.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s: got status %d want %d", tc.name, res.StatusCode, tc.wantStatus)
+		}
+		var reply string
+		if len(fk.messages) > sent {
+			reply = fk.messages[len(fk.messages)-1].Text
+		}
+		if reply != tc.wantReply {
+			t.Errorf("%s: got reply %q want %q", tc.name, reply, tc.wantReply)
+		}
+	}
+}
+
 func startFakeTelegram(t *testing.T) *fakeTelegram {
 	marshal := func(i interface{}) []byte {
 		raw := struct {
@@ -226,10 +727,15 @@ func startFakeTelegram(t *testing.T) *fakeTelegram {
 		}
 		return msg
 	}
+	notFound := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: message to edit not found"}`))
+	}
 	fk := &fakeTelegram{}
 	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		m := strings.TrimLeft(r.URL.Path, "/")
-		switch m {
+		// Paths are /bot<token>/<method>.
+		method := path.Base(r.URL.Path)
+		switch method {
 		case "getUpdates":
 			w.Write(marshal(fk.updates))
 			fk.updates = nil
@@ -242,13 +748,67 @@ func startFakeTelegram(t *testing.T) *fakeTelegram {
 			if err := json.Unmarshal(b.Bytes(), &m); err != nil {
 				t.Fatal(err)
 			}
-			// TODO: To make tests more realistic might make sense to give
-			// messages ids and respect them here.
-			// assume that edits are always for the last message
-			lm := fk.messages[len(fk.messages)-1]
-			lm.Text = m.Text
-			lm.ReplyMarkup = m.ReplyMarkup
-			w.Write(marshal(lm))
+			em := fk.message(m.MessageId)
+			if em == nil {
+				notFound(w)
+				return
+			}
+			if method == "editMessageText" {
+				em.Text = m.Text
+			}
+			em.ReplyMarkup = m.ReplyMarkup
+			w.Write(marshal(em))
+		case "editMessageCaption":
+			b := new(bytes.Buffer)
+			if _, err := b.ReadFrom(r.Body); err != nil {
+				t.Fatal(err)
+			}
+			var m EditMessageCaption
+			if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+				t.Fatal(err)
+			}
+			em := fk.message(m.MessageId)
+			if em == nil {
+				notFound(w)
+				return
+			}
+			em.Caption = m.Caption
+			em.ReplyMarkup = m.ReplyMarkup
+			w.Write(marshal(em))
+		case "sendPhoto":
+			b := new(bytes.Buffer)
+			if _, err := b.ReadFrom(r.Body); err != nil {
+				t.Fatal(err)
+			}
+			var p PhotoReply
+			if err := json.Unmarshal(b.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			m := &Message{
+				Id:      fk.newID(),
+				Photo:   []PhotoSize{{FileId: p.Photo}},
+				Caption: p.Caption,
+			}
+			m.Chat.Id = p.ChatId
+			if p.ReplyMarkup != nil {
+				m.ReplyMarkup = *p.ReplyMarkup
+			}
+			fk.messages = append(fk.messages, m)
+			w.Write(marshal(m))
+		case "answerCallbackQuery":
+			b := new(bytes.Buffer)
+			if _, err := b.ReadFrom(r.Body); err != nil {
+				t.Fatal(err)
+			}
+			var a struct {
+				Id   string `json:"callback_query_id"`
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(b.Bytes(), &a); err != nil {
+				t.Fatal(err)
+			}
+			fk.toasts = append(fk.toasts, a.Text)
+			w.Write(marshal(true))
 		case "getMe":
 			w.Write(marshal("getMe was called. This is fake telegram."))
 		case "sendMessage":
@@ -260,12 +820,18 @@ func startFakeTelegram(t *testing.T) *fakeTelegram {
 			if err := json.Unmarshal(b.Bytes(), &m); err != nil {
 				t.Fatal(err)
 			}
+			var reply MessageReply
+			if err := json.Unmarshal(b.Bytes(), &reply); err != nil {
+				t.Fatal(err)
+			}
+			m.Id = fk.newID()
+			m.Chat.Id = reply.ChatId
 			fk.messages = append(fk.messages, &m)
 			w.Write(marshal(m))
 		}
 	}))
 	fk.server = s
-	telegramApiPrefix = s.URL
+	telegramApiPrefix = s.URL + "/bot"
 	return fk
 }
 
@@ -273,15 +839,34 @@ type fakeTelegram struct {
 	server *httptest.Server
 	// all the messages ever received
 	messages []*Message
+	// lastID is the id of the last message, of the bot or of the user.
+	lastID int64
+	// texts of the answers to callback queries, empty ones included
+	toasts []string
 	// updates to return on the call to "getUpdates"
 	updates []Update
 }
 
+func (fk *fakeTelegram) newID() int64 {
+	fk.lastID++
+	return fk.lastID
+}
+
+// message returns the message of the bot with the id, nil if there is none.
+func (fk *fakeTelegram) message(id int64) *Message {
+	for _, m := range fk.messages {
+		if m.Id == id {
+			return m
+		}
+	}
+	return nil
+}
+
 func (fk *fakeTelegram) SendMessage(s string) {
 	fk.updates = append(fk.updates, Update{
 		UpdateId: 0,
 		Message: &Message{
-			Id:   0,
+			Id:   fk.newID(),
 			Text: s,
 			Chat: struct {
 				Id int64 `json:"id"`
@@ -292,16 +877,31 @@ func (fk *fakeTelegram) SendMessage(s string) {
 	})
 }
 
+// SendPhoto sends a photo with the caption from the user.
+func (fk *fakeTelegram) SendPhoto(fileID, caption string) {
+	m := &Message{
+		Id:      fk.newID(),
+		Photo:   []PhotoSize{{FileId: fileID, Width: 100, Height: 100}},
+		Caption: caption,
+	}
+	fk.updates = append(fk.updates, Update{Message: m})
+}
+
+// PressButton presses the button on the last message of the bot.
 func (fk *fakeTelegram) PressButton(button string) error {
-	lm := fk.messages[len(fk.messages)-1]
-	for _, ks := range lm.ReplyMarkup.InlineKeyboard {
+	return fk.PressButtonOn(fk.messages[len(fk.messages)-1], button)
+}
+
+// PressButtonOn presses the button on the message m of the bot.
+func (fk *fakeTelegram) PressButtonOn(m *Message, button string) error {
+	for _, ks := range m.ReplyMarkup.InlineKeyboard {
 		for _, k := range ks {
 			if k.Text == button {
 				fk.updates = append(fk.updates, Update{
 					UpdateId: 0,
 					CallbackQuery: &CallbackQuery{
 						Id:      "0",
-						Message: lm,
+						Message: m,
 						Data:    k.CallbackData,
 					},
 				})
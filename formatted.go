@@ -0,0 +1,76 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Formatted text built with explicit telegram entities instead of MarkdownV2,
+// so that words with special characters don't have to be escaped.
+package main
+
+import "unicode/utf16"
+
+// Formatted is text with its bold, italic and link entities. The methods
+// append to it and return it, so that calls can be chained.
+type Formatted struct {
+	Text     string          `json:"text"`
+	Entities []MessageEntity `json:"entities,omitempty"`
+}
+
+// utf16Len returns the length of s in UTF-16 code units, in which telegram
+// counts entity offsets.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// Plain appends s without formatting.
+func (f *Formatted) Plain(s string) *Formatted {
+	f.Text += s
+	return f
+}
+
+// Bold appends s in bold.
+func (f *Formatted) Bold(s string) *Formatted {
+	return f.entity(MessageEntity{Type: "bold"}, s)
+}
+
+// Italic appends s in italics.
+func (f *Formatted) Italic(s string) *Formatted {
+	return f.entity(MessageEntity{Type: "italic"}, s)
+}
+
+// Link appends s linking to url.
+func (f *Formatted) Link(s, url string) *Formatted {
+	return f.entity(MessageEntity{Type: "text_link", URL: url}, s)
+}
+
+// Append appends other with its formatting.
+func (f *Formatted) Append(other *Formatted) *Formatted {
+	offset := utf16Len(f.Text)
+	for _, e := range other.Entities {
+		e.Offset += offset
+		f.Entities = append(f.Entities, e)
+	}
+	f.Text += other.Text
+	return f
+}
+
+// entity appends s formatted as e. Telegram rejects empty entities.
+func (f *Formatted) entity(e MessageEntity, s string) *Formatted {
+	if s == "" {
+		return f
+	}
+	e.Offset, e.Length = utf16Len(f.Text), utf16Len(s)
+	f.Entities = append(f.Entities, e)
+	f.Text += s
+	return f
+}
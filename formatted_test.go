@@ -0,0 +1,42 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormatted(t *testing.T) {
+	// Nothing is escaped, and offsets are in UTF-16, the emoji takes 2
+	// units.
+	example := new(Formatted).Plain("1. 😀 *a_b* ").Link("#1", "https://tatoeba.org/eng/sentences/show/1")
+	got := new(Formatted).Bold("fekete").Plain("\n").Italic("").Italic("(informal)").Plain("\n").Append(example)
+	want := &Formatted{
+		Text: "fekete\n(informal)\n1. 😀 *a_b* #1",
+		Entities: []MessageEntity{
+			{Type: "bold", Offset: 0, Length: 6},
+			{Type: "italic", Offset: 7, Length: 10},
+			{Type: "text_link", Offset: 30, Length: 2, URL: "https://tatoeba.org/eng/sentences/show/1"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Formatted (-want +got):\n%s", diff)
+	}
+	m := &Message{Text: got.Text, Entities: got.Entities}
+	if p, want := m.PlainText(), "fekete\n(informal)\n1. 😀 *a_b* #1 (https://tatoeba.org/eng/sentences/show/1)"; p != want {
+		t.Errorf("PlainText() = %q want %q", p, want)
+	}
+}
@@ -1,10 +1,12 @@
 module words
 
-go 1.13
+go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/google/go-cmp v0.4.0
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible
 	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
 	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
+	golang.org/x/text v0.3.3
 )
@@ -0,0 +1,1255 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Localization of the bot interface. Messages are keyed by their English
+// format strings, so that code stays readable and untranslated messages fall
+// back to English. To translate a new message add its format string with a
+// translation into every catalog below.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultBotLanguage is the ISO 639-3 code of the language in which messages
+// are written in the code.
+const defaultBotLanguage = "eng"
+
+// BotLanguages maps ISO 639-3 codes of the interface languages to their
+// names.
+var BotLanguages = map[string]string{
+	"eng": "English",
+	"rus": "Russian",
+	"ukr": "Ukrainian",
+	"hun": "Hungarian",
+	"deu": "German",
+}
+
+// FindBotLanguage returns ISO 639-3 code of the interface language by its code
+// or English name.
+func FindBotLanguage(nameOrCode string) (string, error) {
+	for c, n := range BotLanguages {
+		if strings.EqualFold(c, nameOrCode) || strings.EqualFold(n, nameOrCode) {
+			return c, nil
+		}
+	}
+	return "", localizedErrorf("unsupported interface language %q", nameOrCode)
+}
+
+// botLanguageNames returns sorted names of all interface languages.
+func botLanguageNames() []string {
+	var ns []string
+	for _, n := range BotLanguages {
+		ns = append(ns, fmt.Sprintf("%q", n))
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// Localizer translates messages into a single language.
+type Localizer struct {
+	messages map[string]string
+}
+
+// NewLocalizer returns a localizer for the ISO 639-3 code. Unknown languages
+// get English.
+func NewLocalizer(language string) *Localizer {
+	return &Localizer{catalog[language]}
+}
+
+// T is like fmt.Sprintf, but uses the translation of the format if there is
+// one.
+func (l *Localizer) T(format string, args ...interface{}) string {
+	if l != nil {
+		if t, ok := l.messages[format]; ok {
+			format = t
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// localizedError keeps format and arguments of the error message, so that
+// it can be translated later when the language of the user is known.
+type localizedError struct {
+	format string
+	args   []interface{}
+}
+
+func (e *localizedError) Error() string {
+	return fmt.Sprintf(e.format, e.args...)
+}
+
+// localizedErrorf is like fmt.Errorf, but the message of the returned error can
+// be translated with Localizer.Error.
+func localizedErrorf(format string, args ...interface{}) error {
+	return &localizedError{format, args}
+}
+
+// Error returns translated message of the error.
+func (l *Localizer) Error(err error) string {
+	var le *localizedError
+	if errors.As(err, &le) {
+		return l.T(le.format, le.args...)
+	}
+	return l.T(err.Error())
+}
+
+// L returns the localizer for the chat's interface language.
+func (c *Clients) L(chatID int64) *Localizer {
+	if c.Settings == nil {
+		return NewLocalizer(defaultBotLanguage)
+	}
+	s, err := c.Settings.Get(chatID)
+	if err != nil {
+		// Better to reply in English than not to reply at all.
+		return NewLocalizer(defaultBotLanguage)
+	}
+	return NewLocalizer(s.BotLanguage)
+}
+
+// catalog maps ISO 639-3 codes of the interface languages to translations of
+// the messages.
+var catalog = map[string]map[string]string{
+	"rus": {
+		"Know":                       "Знаю",
+		"Reset progress":             "Сбросить прогресс",
+		"Don't know":                 "Не знаю",
+		"Cancelled":                  "Отменено",
+		"Saved %q for learning":      "%q сохранено для изучения",
+		"Learn":                      "Учить",
+		"Start practice":             "Начать практику",
+		"%s is not a known command.": "%s — неизвестная команда.",
+		"Did you mean %s?":           "Возможно, вы имели в виду %s?",
+		" or ":                       " или ",
+		"Nothing to cancel.":         "Нечего отменять.",
+		"Cancelled %s.":              "%s отменено.",
+		"No more rows to practice; exiting practice mode.": "Больше нечего повторять; выход из режима практики.",
+		"unlimited":                              "без ограничений",
+		"%d characters":                          "%d символов",
+		"off":                                    "выкл.",
+		"%d per day":                             "%d в день",
+		"between %s":                             "в промежутке %s",
+		"on":                                     "вкл.",
+		"Input language: %q":                     "Язык ввода: %q",
+		"Input language in ISO 639-3: %q":        "Язык ввода в ISO 639-3: %q",
+		"Translation languages in ISO 639-3: %s": "Языки перевода в ISO 639-3: %s",
+		"Time Zone: %s":                          "Часовой пояс: %s",
+		"Max usage example length: %s":           "Макс. длина примеров: %s",
+		"Reminders: %s":                          "Напоминания: %s",
+		"Weekly summary: %s":                     "Еженедельная сводка: %s",
+		"Bot language: %s":                       "Язык бота: %s",
+		"Current settings:":                      "Текущие настройки:",
+		"To modify settings use one of the commands below:":                  "Чтобы изменить настройки, используйте одну из команд ниже:",
+		"Tap the language to toggle translations of usage examples into it.": "Нажмите на язык, чтобы включить или выключить перевод примеров на него.",
+		"%s. Please try again.": "%s. Попробуйте ещё раз.",
+		"Enter front of the card (word, expression, question) or send a photo with the word as its caption.": "Введите лицевую сторону карточки (слово, выражение, вопрос) или отправьте фото со словом в подписи.",
+		"Enter back of the card (definition, answer).":                                                       "Введите обратную сторону карточки (определение, ответ).",
+		"Added %q for learning!":                              "%q добавлено для изучения!",
+		"Enter the word you want to delete from learning!":    "Введите слово, которое хотите удалить из изучения!",
+		"Word %q isn't saved for learning!":                   "Слово %q не сохранено для изучения!",
+		"Delete %q from learning? All progress will be lost.": "Удалить %q из изучения? Весь прогресс будет потерян.",
+		"Deleted %q!":               "%q удалено!",
+		"Progress for %q was reset": "Прогресс для %q сброшен",
+		"For now this bot doesn't work with expressions. Try entering a single work without spaces.": "Пока бот не работает с выражениями. Попробуйте ввести одно слово без пробелов.",
+		"Couldn't find definitions.": "Не удалось найти определения.",
+		"Welcome to the language bot. Still in development. No instructions so far. All sentences and translations are from Tatoeba's (https://tatoeba.org) dataset, released under a CC-BY 2.0 FR.": "Добро пожаловать в языкового бота. Он всё ещё в разработке. Инструкций пока нет. Все предложения и переводы взяты из набора данных Tatoeba (https://tatoeba.org), опубликованного под лицензией CC-BY 2.0 FR.",
+		"Enter ISO 639-3 codes of languages into which to translate usage examples separated by comma (e.g. \"eng,deu\") or \"default\" to use the defaults for your input language.":                "Введите через запятую коды ISO 639-3 языков, на которые переводить примеры (например, \"eng,deu\"), или \"default\", чтобы использовать значения по умолчанию для вашего языка ввода.",
+		"When can I send you reminders to practice? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00, or \"off\" to not receive reminders.":                            "Когда можно присылать напоминания о практике? Введите промежуток времени в вашем часовом поясе в формате HH:MM-HH:MM, например 09:00-21:00, или \"off\", чтобы не получать напоминания.",
+		"All set! Send me a word to get its definition.": "Всё готово! Отправьте мне слово, чтобы получить его определение.",
+		"Let's set things up first.":                     "Сначала давайте всё настроим.",
+		"Enter input language of your choice. Supported are %s or any other language by its ISO 639-3 code (e.g. \"fra\")": "Введите язык ввода. Поддерживаются %s или любой другой язык по коду ISO 639-3 (например, \"fra\")",
+		"Input your timezone in one of the formats: UTC, UTC+X or UTC-X.":                                                  "Введите часовой пояс в одном из форматов: UTC, UTC+X или UTC-X.",
+		"When can I send you reminders? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00. To limit it to some days, put them before the range, and separate several ranges with \";\", e.g. mon-fri 07:00-09:00; sat,sun 10:00-12:00.": "Когда можно присылать напоминания? Введите промежуток времени в вашем часовом поясе в формате HH:MM-HH:MM, например 09:00-21:00. Чтобы ограничить его некоторыми днями, укажите их перед промежутком, а несколько промежутков разделите \";\", например mon-fri 07:00-09:00; sat,sun 10:00-12:00.",
+		"How many times a day should I remind you to practice? Enter a number between 1 and %d or \"off\" to stop reminders.":                                                                                                                                        "Сколько раз в день напоминать о практике? Введите число от 1 до %d или \"off\", чтобы отключить напоминания.",
+		"Do you want to receive a weekly summary of your progress? Enter \"on\" or \"off\".":                                                                                                                                                                         "Хотите получать еженедельную сводку о прогрессе? Введите \"on\" или \"off\".",
+		"Enter the language of the bot interface. Supported are %s.":                                                                                                                                                                                                 "Введите язык интерфейса бота. Поддерживаются %s.",
+		"Input maximum length of usage examples in characters (0 to show examples of any length).":                                                                                                                                                                   "Введите максимальную длину примеров в символах (0, чтобы показывать примеры любой длины).",
+		"Stopped. Input the word to get it's definition.":                                                                                                                                                                                                            "Остановлено. Введите слово, чтобы получить его определение.",
+		"#%d by %s":                             "#%d от %s",
+		"[truncated %d definitions]":            "[скрыто определений: %d]",
+		"Usage examples:":                       "Примеры использования:",
+		"Didn't find usage examples.":           "Примеры использования не найдены.",
+		"unsupported interface language %q":     "неподдерживаемый язык интерфейса %q",
+		"unsupported language %q":               "неподдерживаемый язык %q",
+		"You have %d words ready for practice!": "Слов, готовых к практике: %d!",
+		"You have 1 word ready for practice!":   "У вас 1 слово готово к практике!",
+		"Your week in review:":                  "Ваша неделя:",
+		"Reviews done: %d":                      "Повторений: %d",
+		"New words added: %d":                   "Новых слов: %d",
+		"Retention: %.0f%%":                     "Запоминание: %.0f%%",
+		"Longest streak: %d days":               "Самая длинная серия: %d дн.",
+		"Hardest words: %s":                     "Самые трудные слова: %s",
+		"availability window should be in the format HH:MM-HH:MM, e.g. 09:00-21:00": "промежуток времени должен быть в формате HH:MM-HH:MM, например 09:00-21:00",
+		"unsupported time zone (format should be UTC, UTC+X or UTC-X)":              "неподдерживаемый часовой пояс (формат: UTC, UTC+X или UTC-X)",
+		"length should be a number between 0 and %d":                                "длина должна быть числом от 0 до %d",
+		"unsupported translation language %q":                                       "неподдерживаемый язык перевода %q",
+		"at least one translation language should stay enabled":                     "хотя бы один язык перевода должен остаться включённым",
+		"no languages entered":                                                      "не введено ни одного языка",
+		"%q is not an ISO 639-3 code":                                               "%q — не код ISO 639-3",
+		"frequency should be \"off\" or a number between 1 and %d":                  "частота должна быть \"off\" или числом от 1 до %d",
+		"answer should be \"on\" or \"off\"":                                        "ответ должен быть \"on\" или \"off\"",
+		"No":                                                                        "Нет",
+		"Yes":                                                                       "Да",
+		"too many messages, please wait a bit":                                      "слишком много сообщений, подождите немного",
+		"%s is available only to admins":                                            "%s доступна только администраторам",
+		"No words are due for practice, nothing to quiz you on.":                    "Нет слов для повторения, тест устроить не из чего.",
+		"Quiz finished! Score: %d/%d.":                                              "Тест завершён! Результат: %d/%d.",
+		"Time: %s.":                                                                 "Время: %s.",
+		"Words to revisit: %s.":                                                     "Слова для повторения: %s.",
+		"This quiz is over.":                                                        "Этот тест уже закончился.",
+		"Enter the word to define.":                                                 "Введите слово, чтобы получить определение.",
+		"Updated the card for %q":                                                   "Карточка для %q обновлена",
+		"Update card":                                                               "Обновить карточку",
+		"You have no saved words yet.":                                              "У вас пока нет сохранённых слов.",
+		"Show answer":                                                               "Показать ответ",
+		"Your review forecast:":                                                     "Прогноз повторений:",
+		"Due now: %d":                                                               "Сейчас: %d",
+		"Later today: %d":                                                           "Позже сегодня: %d",
+		"Tomorrow: %d":                                                              "Завтра: %d",
+		"Next 7 days: %d":                                                           "Следующие 7 дней: %d",
+		"Delete all your data? Saved words, progress, settings and reminders will be lost for good.": "Удалить все ваши данные? Сохранённые слова, прогресс, настройки и напоминания будут потеряны навсегда.",
+		"All your data was deleted. Send /start to set up the bot again.":                            "Все ваши данные удалены. Отправьте /start, чтобы снова настроить бота.",
+		"Current streak: %d days":                                          "Текущая серия: %d дн.",
+		"Best streak: %d days":                                             "Лучшая серия: %d дн.",
+		"Practice today to keep your streak!":                              "Позанимайтесь сегодня, чтобы не прервать серию!",
+		"Your %d-day streak ends at midnight, practice a word to keep it!": "Ваша серия в %d дн. прервётся в полночь, повторите хотя бы одно слово, чтобы её сохранить!",
+		"Your share code is %s. Others can copy your words with /subscribe %s, /unshare stops sharing.": "Ваш код для обмена: %s. Другие могут скопировать ваши слова командой /subscribe %s, /unshare прекращает обмен.",
+		"Your words aren't shared anymore.": "Ваши слова больше не доступны другим.",
+		"Your words aren't shared.":         "Ваши слова не доступны другим.",
+		"Enter the share code of the deck.": "Введите код колоды.",
+		"there is no deck with the code %q": "колоды с кодом %q нет",
+		"this is your own deck":             "это ваша собственная колода",
+		"Enter \"copy\" to copy the words once or \"follow\" to also get the words added later.": "Введите \"copy\", чтобы скопировать слова один раз, или \"follow\", чтобы получать и слова, добавленные позже.",
+		"expected \"copy\" or \"follow\"":                                               "ожидается \"copy\" или \"follow\"",
+		"Copied %d words for learning.":                                                 "Скопировано слов для изучения: %d.",
+		"Words added to the deck later will be copied too, /unsubscribe %s stops that.": "Слова, добавленные в колоду позже, тоже будут скопированы, /unsubscribe %s это прекращает.",
+		"you don't follow the deck %q":                                                  "вы не подписаны на колоду %q",
+		"New words of the deck won't be copied anymore, the copied ones stay.":          "Новые слова колоды больше не будут копироваться, скопированные останутся.",
+		"Imported %d words.":                                                            "Импортировано слов: %d.",
+		"Already saved: %d.":                                                            "Уже сохранено: %d.",
+		"No definitions found for: %s.":                                                 "Не найдены определения для: %s.",
+		"%d words were left out, import at most %d words at once.":                      "Пропущено слов: %d, за раз можно импортировать не больше %d слов.",
+		"Send vocab.db from your Kindle (in the system folder) or its CSV export to create cards for the looked up words.": "Отправьте vocab.db с вашего Kindle (в папке system) или его экспорт в CSV, чтобы создать карточки для найденных слов.",
+		"send the file as a document or /cancel":                  "отправьте файл как документ или /cancel",
+		"the file is too large":                                   "файл слишком большой",
+		"couldn't read words from the file":                       "не удалось прочитать слова из файла",
+		"no words in the file":                                    "в файле нет слов",
+		"Importing %d words, it can take a while.":                "Импортирую слов: %d, это может занять время.",
+		"Importing %d words: %d done.":                            "Импортирую слов: %d, готово: %d.",
+		"The import failed, try again later.":                     "Импорт не удался, попробуйте позже.",
+		"another import is in progress, wait for it to finish":    "другой импорт ещё идёт, дождитесь его окончания",
+		"%q is already saved: stage %d of %d, next review on %s.": "%q уже сохранено: этап %d из %d, следующее повторение %s.",
+		"Added the definition to the card for %q":                 "Определение добавлено в карточку %q",
+		"Append this definition":                                  "Добавить это определение",
+		"Kept the card for %q as is":                              "Карточка %q оставлена без изменений",
+		"Keep as is":                                              "Оставить как есть",
+		"Other studied languages in ISO 639-3: %s":                "Другие изучаемые языки в ISO 639-3: %s",
+		"none": "нет",
+		"Enter the language to study in addition to the current ones. It becomes the input language, words not found in it are looked up in the others.": "Введите язык, который хотите изучать помимо текущих. Он станет языком ввода, слова, не найденные в нём, будут искаться в остальных.",
+		"Tap the language to look up words in it first. Add languages with /addlanguage, practice one of them with /practice <language>.":                "Нажмите на язык, чтобы искать слова сначала в нём. Добавить языки можно командой /addlanguage, практиковать один из них — /practice <язык>.",
+		"You study only %s, use /language to change it.":            "Вы изучаете только %s, используйте /language, чтобы его изменить.",
+		"Tap the language to stop studying it. Its cards are kept.": "Нажмите на язык, чтобы перестать его изучать. Его карточки сохранятся.",
+		"%s is not studied": "язык %s не изучается",
+		"can't remove the only studied language, use /language to change it": "нельзя удалить единственный изучаемый язык, используйте /language, чтобы его изменить",
+		"Session finished! Known: %d of %d (%d%%).":                          "Сессия окончена! Знаю: %d из %d (%d%%).",
+		"Still due: %d": "Ещё к повторению: %d",
+		"Continue":      "Продолжить",
+		"How many cards should a practice session have? Enter a number between 1 and %d.": "Сколько карточек должно быть в сессии практики? Введите число от 1 до %d.",
+		"length should be a number between 1 and %d":                                      "длина должна быть числом от 1 до %d",
+		"Practice session: %d cards":                                                      "Сессия практики: %d карточек",
+		"Study ahead":                                                                     "Учить заранее",
+		"vacation should be \"off\" or a number of days between 1 and %d":                 "отпуск должен быть \"off\" или числом дней от 1 до %d",
+		"paused until %s":                                                                 "приостановлены до %s",
+		"For how many days are you going away? Enter a number between 1 and %d or \"off\" to end the vacation.": "На сколько дней вы уезжаете? Введите число от 1 до %d или \"off\", чтобы закончить отпуск.",
+		"Welcome back! Reminders are resumed.":                                                   "С возвращением! Напоминания возобновлены.",
+		"Enjoy your vacation! %d words are postponed by %d days, reminders are paused until %s.": "Хорошего отпуска! Слов отложено: %d на %d дн., напоминания приостановлены до %s.",
+		"default":       "по умолчанию",
+		"Leitner boxes": "ящики Лейтнера",
+		"Scheduler: %s": "Расписание повторений: %s",
+		"answer should be \"default\" or \"leitner\"": "ответ должен быть \"default\" или \"leitner\"",
+		"How should I schedule the reviews? Enter \"default\" to move the words through the stages of learning or \"leitner\" for 5 Leitner boxes reviewed every 1, 2, 4, 8 and 16 days.": "Как планировать повторения? Введите \"default\", чтобы слова проходили этапы изучения, или \"leitner\" для 5 ящиков Лейтнера, которые повторяются каждые 1, 2, 4, 8 и 16 дней.",
+		"Show more definitions":              "Показать ещё определения",
+		"Pick definition":                    "Выбрать определение",
+		"Enter the word to add a note to.":   "Введите слово, к которому добавить заметку.",
+		"Enter the note to add to the card.": "Введите заметку для карточки.",
+		"Added the note to %q.":              "Заметка добавлена к %q.",
+		"Add note":                           "Добавить заметку",
+		"Sentences in practice: %s":          "Предложения в практике: %s",
+		"Do you want to practice with sentences in which the word is blanked out, when there are any? Enter \"on\" or \"off\".": "Хотите практиковаться на предложениях, в которых слово пропущено, если такие есть? Введите \"on\" или \"off\".",
+		"Save looked up words: %s": "Сохранять найденные слова: %s",
+		"Do you want to save every word you look up for learning without pressing Learn? Enter \"on\" or \"off\".": "Сохранять каждое найденное слово для изучения без нажатия Learn? Введите \"on\" или \"off\".",
+		"%s: %d%% (%d of %d)":                   "%s: %d%% (%d из %d)",
+		"You haven't practiced any words yet.":  "Вы ещё не практиковали ни одного слова.",
+		"Known answers by the previous answer:": "Доля ответов «знаю» по предыдущему ответу:",
+		"After Know":                            "После «знаю»",
+		"After Don't know":                      "После «не знаю»",
+		"First review":                          "Первое повторение",
+		"Known answers by the time since the previous review:": "Доля ответов «знаю» по времени с предыдущего повторения:",
+		"Less than a day":                     "Меньше дня",
+		"1 to 7 days":                         "От 1 до 7 дней",
+		"7 to 30 days":                        "От 7 до 30 дней",
+		"More than 30 days":                   "Больше 30 дней",
+		"plural %s":                           "мн. ч. %s",
+		"Transliteration of translations: %s": "Транслитерация переводов: %s",
+		"Do you want translations of usage examples in Russian and Ukrainian to be followed by their transliteration into latin letters? Enter \"on\" or \"off\".": "Добавлять к переводам примеров на русский и украинский их транслитерацию латиницей? Введите \"on\" или \"off\".",
+		"no definitions found":               "определений не найдено",
+		"Learn %s":                           "Учить %s",
+		"%q is already saved":                "%q уже сохранено",
+		"The API isn't enabled on this bot.": "API не включён в этом боте.",
+		"Your API token, keep it secret:\n%s\n\nSend it in the Authorization: Bearer header. /token again replaces it.": "Ваш токен API, храните его в секрете:\n%s\n\nПередавайте его в заголовке Authorization: Bearer. Повторная команда /token заменит его.",
+		"The dashboard isn't enabled on this bot.":                        "Панель не включена в этом боте.",
+		"Open your dashboard within %d minutes, the link works once:\n%s": "Откройте панель в течение %d минут, ссылка работает один раз:\n%s",
+		"Reviews in the last %d weeks":                                    "Повторения за последние %d недель",
+		"Cards: %d":                                                       "Карточки: %d",
+		"Search":                                                          "Поиск",
+		"Word":                                                            "Слово",
+		"Definition":                                                      "Определение",
+		"Next review":                                                     "Следующее повторение",
+		"Later":                                                           "Позже",
+		"%q will be back in %d minutes":                                   "%q вернётся через %d минут",
+		"Nothing else to practice now, the cards put off for later come back in a few minutes.": "Больше нечего повторять, отложенные карточки вернутся через несколько минут.",
+		"☆ Star":       "☆ В избранное",
+		"★ Unstar":     "★ Убрать из избранного",
+		"Starred %q":   "%q в избранном",
+		"Unstarred %q": "%q убрано из избранного",
+		"No starred cards yet. Star cards with the ☆ Star button under them.": "В избранном пока нет карточек. Добавляйте их кнопкой ☆ В избранное под карточкой.",
+		"Starred cards: %d":                          "Избранные карточки: %d",
+		"… and %d more":                              "… и ещё %d",
+		"stage %d":                                   "этап %d",
+		"Practice starred":                           "Повторить избранное",
+		"No more starred cards to practice.":         "Больше нет избранных карточек для повторения.",
+		"Recognizing words on photos isn't enabled.": "Распознавание слов на фото не включено.",
+		"Couldn't recognize the text on the photo, try again later.": "Не удалось распознать текст на фото, попробуйте позже.",
+		"Couldn't find any words on the photo.":                      "На фото не найдено слов.",
+		"Tap a word to define it:":                                   "Нажмите на слово, чтобы узнать его значение:",
+		"🔊 Listen":                                                   "🔊 Послушать",
+		"Audio isn't available anymore.":                             "Аудио больше недоступно.",
+		"🔊 Which word did you hear?":                                 "🔊 Какое слово вы услышали?",
+		"Listening practice: %s":                                     "Практика на слух: %s",
+		"Do you want to hear the words in practice instead of reading them, for the languages with a voice? Enter \"on\" or \"off\".": "Хотите слушать слова при повторении вместо чтения, для языков с озвучкой? Введите \"on\" или \"off\".",
+		"Save at least 2 words to play.":                                           "Сохраните хотя бы 2 слова, чтобы играть.",
+		"Match the words with their definitions: tap a word, then its definition.": "Сопоставьте слова с их значениями: нажмите на слово, затем на его значение.",
+		"This game is over.":     "Эта игра окончена.",
+		"Tap a word first.":      "Сначала нажмите на слово.",
+		"Not a pair, try again.": "Это не пара, попробуйте ещё раз.",
+		"All pairs matched! Right on the first try: %d of %d. Time: %s.":                            "Все пары найдены! С первой попытки: %d из %d. Время: %s.",
+		"goal should be \"off\" or a number between 1 and %d":                                       "цель должна быть \"off\" или числом от 1 до %d",
+		"How many reviews do you want to do every day? Enter a number between 1 and %d or \"off\".": "Сколько повторений вы хотите делать каждый день? Введите число от 1 до %d или \"off\".",
+		"%d reviews":     "%d повторений",
+		"Daily goal: %s": "Цель на день: %s",
+		"Daily goal reached: %d of %d reviews today.":                        "Цель на день достигнута: %d из %d повторений сегодня.",
+		"Daily goal: %d of %d reviews today.":                                "Цель на день: %d из %d повторений сегодня.",
+		"%d more reviews to reach your daily goal of %d!":                    "Ещё %d повторений до цели на день в %d!",
+		"the number of cards should be \"off\" or a number between 1 and %d": "количество карточек должно быть \"off\" или числом от 1 до %d",
+		"How many overdue cards do you want to practice a day? The rest are spread over the following days. Enter a number between 1 and %d or \"off\".": "Сколько просроченных карточек вы хотите повторять в день? Остальные распределятся по следующим дням. Введите число от 1 до %d или \"off\".",
+		"%d overdue cards per day": "%d просроченных карточек в день",
+		"Catch-up: %s":             "Наверстывание: %s",
+		"interval should be \"off\" or a number of days between 1 and %d":                                          "интервал должен быть \"off\" или числом дней от 1 до %d",
+		"How many days at most should pass between reviews of a card? Enter a number between 1 and %d or \"off\".": "Сколько дней максимум может пройти между повторениями карточки? Введите число от 1 до %d или \"off\".",
+		"%d days":                                "%d дней",
+		"Maximum interval: %s":                   "Максимальный интервал: %s",
+		"there should be between 1 and %d steps": "должно быть от 1 до %d шагов",
+		"step %q should be a duration between 1m and 30d, e.g. 10m, 2h or 1d":                                                     "шаг %q должен быть длительностью от 1m до 30d, например 10m, 2h или 1d",
+		"Enter the intervals between reviews of new and forgotten cards before they are learned, e.g. \"1m 10m 1d\", or \"off\".": "Введите интервалы между повторениями новых и забытых карточек, пока они не выучены, например \"1m 10m 1d\", или \"off\".",
+		"Learning steps: %s": "Шаги изучения: %s",
+		"answer should be \"added\", \"random\" or \"frequent\"": "ответ должен быть \"added\", \"random\" или \"frequent\"",
+		"In which order should new cards come to practice? Enter \"added\" for the order you added them in, \"random\" or \"frequent\" for the words that are more common in sentences first.": "В каком порядке новые карточки должны попадать в практику? Введите \"added\" для порядка добавления, \"random\" или \"frequent\", чтобы сначала шли слова, чаще встречающиеся в предложениях.",
+		"in the order added":                       "в порядке добавления",
+		"in random order":                          "в случайном порядке",
+		"most frequent first":                      "сначала самые частые",
+		"New cards: %s":                            "Новые карточки: %s",
+		"hour should be a number between 0 and 23": "час должен быть числом от 0 до 23",
+		"At which hour should your day start for streaks, daily goals and forecasts? Enter a number between 0 and 23, e.g. 4 to count practice until 4 in the morning to the previous day.": "В котором часу должен начинаться ваш день для серий, целей на день и прогнозов? Введите число от 0 до 23, например 4, чтобы практика до 4 утра засчитывалась в предыдущий день.",
+		"Day starts at: %02d:00": "День начинается в: %02d:00",
+		"Your %d-day streak ends at %02d:00, practice a word to keep it!": "Ваша серия в %d дн. прервётся в %02d:00, повторите хотя бы одно слово, чтобы её сохранить!",
+		"unknown day %q, days are mon, tue, wed, thu, fri, sat and sun":   "неизвестный день %q, дни: mon, tue, wed, thu, fri, sat и sun",
+		"Your first card is ready for practice!":                          "Ваша первая карточка готова к практике!",
+		"Card in reminders: %s":                                           "Карточка в напоминаниях: %s",
+		"Do you want reminders to show the first card ready for practice, so that you can answer it right away? Enter \"on\" or \"off\".": "Показывать ли в напоминаниях первую карточку, готовую к практике, чтобы сразу на неё ответить? Введите \"on\" или \"off\".",
+		"Reminders to practice are now sent only if you choose when. Use /availability to set them up.":                                   "Напоминания о практике теперь отправляются, только если вы выберете, когда их получать. Используйте /availability, чтобы их настроить.",
+		"%s looks like a form of %s":                   "%s похоже на форму слова %s",
+		"Send the photo with the word as its caption.": "Отправьте фото со словом в подписи.",
+		"Enter the word to attach an image to.":        "Введите слово, к которому прикрепить изображение.",
+		"Send the photo for the front of the card.":    "Отправьте фото для лицевой стороны карточки.",
+		"Send a photo, please.":                        "Пожалуйста, отправьте фото.",
+		"Attached the image to %q.":                    "Изображение прикреплено к %q.",
+		"Attach image":                                 "Прикрепить изображение",
+		"Which word is it?":                            "Что это за слово?",
+		"Set up syncing with /anki first.":             "Сначала настройте синхронизацию с помощью /anki.",
+		"Couldn't sync with Anki: %s. Make sure that Anki is running and AnkiConnect can be reached from the internet.": "Не удалось синхронизироваться с Anki: %s. Убедитесь, что Anki запущен и AnkiConnect доступен из интернета.",
+		"Syncing with Anki isn't enabled.":                                                                                "Синхронизация с Anki не включена.",
+		"Synced with Anki: %d new cards sent, %d reviews received.":                                                       "Синхронизировано с Anki: отправлено новых карточек: %d, получено повторений: %d.",
+		"Your cards aren't synced with Anki.":                                                                             "Ваши карточки не синхронизируются с Anki.",
+		"Stopped syncing with Anki, the cards in Anki are kept.":                                                          "Синхронизация с Anki остановлена, карточки в Anki сохранены.",
+		"Enter the address of AnkiConnect, e.g. http://203.0.113.5:8765. It has to be reachable from the internet.":       "Введите адрес AnkiConnect, например http://203.0.113.5:8765. Он должен быть доступен из интернета.",
+		"The address should start with http:// or https://. Please try again.":                                            "Адрес должен начинаться с http:// или https://. Попробуйте ещё раз.",
+		"Enter the API key of AnkiConnect or \"none\" if it has none.":                                                    "Введите API-ключ AnkiConnect или \"none\", если его нет.",
+		"Enter the name of the Anki deck for the cards.":                                                                  "Введите название колоды Anki для карточек.",
+		"Which should schedule the reviews of the synced cards, \"bot\" or \"anki\"? The other one follows its schedule.": "Кто должен планировать повторения синхронизированных карточек, \"bot\" или \"anki\"? Другая сторона следует его расписанию.",
+		"Answer should be \"bot\" or \"anki\". Please try again.":                                                         "Ответ должен быть \"bot\" или \"anki\". Попробуйте ещё раз.",
+		"You've just looked up %q, the reply is above.":                                                                   "Вы только что искали %q, ответ выше.",
+		"Already done.":            "Уже сделано.",
+		"The message was deleted.": "Сообщение было удалено.",
+	},
+	"ukr": {
+		"Know":                       "Знаю",
+		"Reset progress":             "Скинути прогрес",
+		"Don't know":                 "Не знаю",
+		"Cancelled":                  "Скасовано",
+		"Saved %q for learning":      "%q збережено для вивчення",
+		"Learn":                      "Вчити",
+		"Start practice":             "Почати практику",
+		"%s is not a known command.": "%s — невідома команда.",
+		"Did you mean %s?":           "Можливо, ви мали на увазі %s?",
+		" or ":                       " або ",
+		"Nothing to cancel.":         "Нічого скасовувати.",
+		"Cancelled %s.":              "%s скасовано.",
+		"No more rows to practice; exiting practice mode.": "Більше нічого повторювати; вихід із режиму практики.",
+		"unlimited":                              "без обмежень",
+		"%d characters":                          "%d символів",
+		"off":                                    "вимк.",
+		"%d per day":                             "%d на день",
+		"between %s":                             "у проміжку %s",
+		"on":                                     "увімк.",
+		"Input language: %q":                     "Мова введення: %q",
+		"Input language in ISO 639-3: %q":        "Мова введення в ISO 639-3: %q",
+		"Translation languages in ISO 639-3: %s": "Мови перекладу в ISO 639-3: %s",
+		"Time Zone: %s":                          "Часовий пояс: %s",
+		"Max usage example length: %s":           "Макс. довжина прикладів: %s",
+		"Reminders: %s":                          "Нагадування: %s",
+		"Weekly summary: %s":                     "Щотижневий підсумок: %s",
+		"Bot language: %s":                       "Мова бота: %s",
+		"Current settings:":                      "Поточні налаштування:",
+		"To modify settings use one of the commands below:":                  "Щоб змінити налаштування, скористайтеся однією з команд нижче:",
+		"Tap the language to toggle translations of usage examples into it.": "Натисніть на мову, щоб увімкнути або вимкнути переклад прикладів нею.",
+		"%s. Please try again.": "%s. Спробуйте ще раз.",
+		"Enter front of the card (word, expression, question) or send a photo with the word as its caption.": "Введіть лицьовий бік картки (слово, вираз, питання) або надішліть фото зі словом у підписі.",
+		"Enter back of the card (definition, answer).":                                                       "Введіть зворотний бік картки (визначення, відповідь).",
+		"Added %q for learning!":                              "%q додано для вивчення!",
+		"Enter the word you want to delete from learning!":    "Введіть слово, яке хочете видалити з вивчення!",
+		"Word %q isn't saved for learning!":                   "Слово %q не збережено для вивчення!",
+		"Delete %q from learning? All progress will be lost.": "Видалити %q з вивчення? Увесь прогрес буде втрачено.",
+		"Deleted %q!":               "%q видалено!",
+		"Progress for %q was reset": "Прогрес для %q скинуто",
+		"For now this bot doesn't work with expressions. Try entering a single work without spaces.": "Поки що бот не працює з виразами. Спробуйте ввести одне слово без пробілів.",
+		"Couldn't find definitions.": "Не вдалося знайти визначення.",
+		"Welcome to the language bot. Still in development. No instructions so far. All sentences and translations are from Tatoeba's (https://tatoeba.org) dataset, released under a CC-BY 2.0 FR.": "Ласкаво просимо до мовного бота. Він усе ще в розробці. Інструкцій поки немає. Усі речення та переклади взято з набору даних Tatoeba (https://tatoeba.org), опублікованого під ліцензією CC-BY 2.0 FR.",
+		"Enter ISO 639-3 codes of languages into which to translate usage examples separated by comma (e.g. \"eng,deu\") or \"default\" to use the defaults for your input language.":                "Введіть через кому коди ISO 639-3 мов, якими перекладати приклади (наприклад, \"eng,deu\"), або \"default\", щоб використовувати типові значення для вашої мови введення.",
+		"When can I send you reminders to practice? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00, or \"off\" to not receive reminders.":                            "Коли можна надсилати нагадування про практику? Введіть проміжок часу у вашому часовому поясі у форматі HH:MM-HH:MM, наприклад 09:00-21:00, або \"off\", щоб не отримувати нагадувань.",
+		"All set! Send me a word to get its definition.": "Усе готово! Надішліть мені слово, щоб отримати його визначення.",
+		"Let's set things up first.":                     "Спершу давайте все налаштуємо.",
+		"Enter input language of your choice. Supported are %s or any other language by its ISO 639-3 code (e.g. \"fra\")": "Введіть мову введення. Підтримуються %s або будь-яка інша мова за кодом ISO 639-3 (наприклад, \"fra\")",
+		"Input your timezone in one of the formats: UTC, UTC+X or UTC-X.":                                                  "Введіть часовий пояс в одному з форматів: UTC, UTC+X або UTC-X.",
+		"When can I send you reminders? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00. To limit it to some days, put them before the range, and separate several ranges with \";\", e.g. mon-fri 07:00-09:00; sat,sun 10:00-12:00.": "Коли можна надсилати нагадування? Введіть проміжок часу у вашому часовому поясі у форматі HH:MM-HH:MM, наприклад 09:00-21:00. Щоб обмежити його деякими днями, вкажіть їх перед проміжком, а кілька проміжків розділіть \";\", наприклад mon-fri 07:00-09:00; sat,sun 10:00-12:00.",
+		"How many times a day should I remind you to practice? Enter a number between 1 and %d or \"off\" to stop reminders.":                                                                                                                                        "Скільки разів на день нагадувати про практику? Введіть число від 1 до %d або \"off\", щоб вимкнути нагадування.",
+		"Do you want to receive a weekly summary of your progress? Enter \"on\" or \"off\".":                                                                                                                                                                         "Бажаєте отримувати щотижневий підсумок прогресу? Введіть \"on\" або \"off\".",
+		"Enter the language of the bot interface. Supported are %s.":                                                                                                                                                                                                 "Введіть мову інтерфейсу бота. Підтримуються %s.",
+		"Input maximum length of usage examples in characters (0 to show examples of any length).":                                                                                                                                                                   "Введіть максимальну довжину прикладів у символах (0, щоб показувати приклади будь-якої довжини).",
+		"Stopped. Input the word to get it's definition.":                                                                                                                                                                                                            "Зупинено. Введіть слово, щоб отримати його визначення.",
+		"#%d by %s":                             "#%d від %s",
+		"[truncated %d definitions]":            "[приховано визначень: %d]",
+		"Usage examples:":                       "Приклади використання:",
+		"Didn't find usage examples.":           "Приклади використання не знайдено.",
+		"unsupported interface language %q":     "непідтримувана мова інтерфейсу %q",
+		"unsupported language %q":               "непідтримувана мова %q",
+		"You have %d words ready for practice!": "Слів, готових до практики: %d!",
+		"You have 1 word ready for practice!":   "У вас 1 слово готове до практики!",
+		"Your week in review:":                  "Ваш тиждень:",
+		"Reviews done: %d":                      "Повторень: %d",
+		"New words added: %d":                   "Нових слів: %d",
+		"Retention: %.0f%%":                     "Запам'ятовування: %.0f%%",
+		"Longest streak: %d days":               "Найдовша серія: %d дн.",
+		"Hardest words: %s":                     "Найскладніші слова: %s",
+		"availability window should be in the format HH:MM-HH:MM, e.g. 09:00-21:00": "проміжок часу має бути у форматі HH:MM-HH:MM, наприклад 09:00-21:00",
+		"unsupported time zone (format should be UTC, UTC+X or UTC-X)":              "непідтримуваний часовий пояс (формат: UTC, UTC+X або UTC-X)",
+		"length should be a number between 0 and %d":                                "довжина має бути числом від 0 до %d",
+		"unsupported translation language %q":                                       "непідтримувана мова перекладу %q",
+		"at least one translation language should stay enabled":                     "хоча б одна мова перекладу має залишитися ввімкненою",
+		"no languages entered":                                                      "не введено жодної мови",
+		"%q is not an ISO 639-3 code":                                               "%q — не код ISO 639-3",
+		"frequency should be \"off\" or a number between 1 and %d":                  "частота має бути \"off\" або числом від 1 до %d",
+		"answer should be \"on\" or \"off\"":                                        "відповідь має бути \"on\" або \"off\"",
+		"No":                                                                        "Ні",
+		"Yes":                                                                       "Так",
+		"too many messages, please wait a bit":                                      "забагато повідомлень, зачекайте трохи",
+		"%s is available only to admins":                                            "%s доступна лише адміністраторам",
+		"No words are due for practice, nothing to quiz you on.":                    "Немає слів для повторення, тест влаштувати немає з чого.",
+		"Quiz finished! Score: %d/%d.":                                              "Тест завершено! Результат: %d/%d.",
+		"Time: %s.":                                                                 "Час: %s.",
+		"Words to revisit: %s.":                                                     "Слова для повторення: %s.",
+		"This quiz is over.":                                                        "Цей тест уже закінчився.",
+		"Enter the word to define.":                                                 "Введіть слово, щоб отримати визначення.",
+		"Updated the card for %q":                                                   "Картку для %q оновлено",
+		"Update card":                                                               "Оновити картку",
+		"You have no saved words yet.":                                              "У вас поки немає збережених слів.",
+		"Show answer":                                                               "Показати відповідь",
+		"Your review forecast:":                                                     "Прогноз повторень:",
+		"Due now: %d":                                                               "Зараз: %d",
+		"Later today: %d":                                                           "Пізніше сьогодні: %d",
+		"Tomorrow: %d":                                                              "Завтра: %d",
+		"Next 7 days: %d":                                                           "Наступні 7 днів: %d",
+		"Delete all your data? Saved words, progress, settings and reminders will be lost for good.": "Видалити всі ваші дані? Збережені слова, прогрес, налаштування та нагадування буде втрачено назавжди.",
+		"All your data was deleted. Send /start to set up the bot again.":                            "Усі ваші дані видалено. Надішліть /start, щоб знову налаштувати бота.",
+		"Current streak: %d days":                                          "Поточна серія: %d дн.",
+		"Best streak: %d days":                                             "Найкраща серія: %d дн.",
+		"Practice today to keep your streak!":                              "Позаймайтеся сьогодні, щоб не перервати серію!",
+		"Your %d-day streak ends at midnight, practice a word to keep it!": "Ваша серія в %d дн. перерветься опівночі, повторіть хоча б одне слово, щоб її зберегти!",
+		"Your share code is %s. Others can copy your words with /subscribe %s, /unshare stops sharing.": "Ваш код для обміну: %s. Інші можуть скопіювати ваші слова командою /subscribe %s, /unshare припиняє обмін.",
+		"Your words aren't shared anymore.": "Ваші слова більше не доступні іншим.",
+		"Your words aren't shared.":         "Ваші слова не доступні іншим.",
+		"Enter the share code of the deck.": "Введіть код колоди.",
+		"there is no deck with the code %q": "колоди з кодом %q немає",
+		"this is your own deck":             "це ваша власна колода",
+		"Enter \"copy\" to copy the words once or \"follow\" to also get the words added later.": "Введіть \"copy\", щоб скопіювати слова один раз, або \"follow\", щоб отримувати й слова, додані пізніше.",
+		"expected \"copy\" or \"follow\"":                                               "очікується \"copy\" або \"follow\"",
+		"Copied %d words for learning.":                                                 "Скопійовано слів для вивчення: %d.",
+		"Words added to the deck later will be copied too, /unsubscribe %s stops that.": "Слова, додані до колоди пізніше, теж буде скопійовано, /unsubscribe %s це припиняє.",
+		"you don't follow the deck %q":                                                  "ви не підписані на колоду %q",
+		"New words of the deck won't be copied anymore, the copied ones stay.":          "Нові слова колоди більше не копіюватимуться, скопійовані залишаться.",
+		"Imported %d words.":                                                            "Імпортовано слів: %d.",
+		"Already saved: %d.":                                                            "Вже збережено: %d.",
+		"No definitions found for: %s.":                                                 "Не знайдено визначень для: %s.",
+		"%d words were left out, import at most %d words at once.":                      "Пропущено слів: %d, за раз можна імпортувати не більше %d слів.",
+		"Send vocab.db from your Kindle (in the system folder) or its CSV export to create cards for the looked up words.": "Надішліть vocab.db з вашого Kindle (у теці system) або його експорт у CSV, щоб створити картки для знайдених слів.",
+		"send the file as a document or /cancel":                  "надішліть файл як документ або /cancel",
+		"the file is too large":                                   "файл занадто великий",
+		"couldn't read words from the file":                       "не вдалося прочитати слова з файлу",
+		"no words in the file":                                    "у файлі немає слів",
+		"Importing %d words, it can take a while.":                "Імпортую слів: %d, це може зайняти час.",
+		"Importing %d words: %d done.":                            "Імпортую слів: %d, готово: %d.",
+		"The import failed, try again later.":                     "Імпорт не вдався, спробуйте пізніше.",
+		"another import is in progress, wait for it to finish":    "інший імпорт ще триває, дочекайтеся його завершення",
+		"%q is already saved: stage %d of %d, next review on %s.": "%q вже збережено: етап %d з %d, наступне повторення %s.",
+		"Added the definition to the card for %q":                 "Визначення додано до картки %q",
+		"Append this definition":                                  "Додати це визначення",
+		"Kept the card for %q as is":                              "Картку %q залишено без змін",
+		"Keep as is":                                              "Залишити як є",
+		"Other studied languages in ISO 639-3: %s":                "Інші мови, що вивчаються, в ISO 639-3: %s",
+		"none": "немає",
+		"Enter the language to study in addition to the current ones. It becomes the input language, words not found in it are looked up in the others.": "Введіть мову, яку хочете вивчати на додаток до поточних. Вона стане мовою введення, слова, не знайдені в ній, шукатимуться в інших.",
+		"Tap the language to look up words in it first. Add languages with /addlanguage, practice one of them with /practice <language>.":                "Натисніть на мову, щоб шукати слова спершу в ній. Додати мови можна командою /addlanguage, практикувати одну з них — /practice <мова>.",
+		"You study only %s, use /language to change it.":            "Ви вивчаєте лише %s, використовуйте /language, щоб її змінити.",
+		"Tap the language to stop studying it. Its cards are kept.": "Натисніть на мову, щоб припинити її вивчення. Її картки збережуться.",
+		"%s is not studied": "мова %s не вивчається",
+		"can't remove the only studied language, use /language to change it": "не можна видалити єдину мову, що вивчається, використовуйте /language, щоб її змінити",
+		"Session finished! Known: %d of %d (%d%%).":                          "Сесію завершено! Знаю: %d з %d (%d%%).",
+		"Still due: %d": "Ще до повторення: %d",
+		"Continue":      "Продовжити",
+		"How many cards should a practice session have? Enter a number between 1 and %d.": "Скільки карток має бути в сесії практики? Введіть число від 1 до %d.",
+		"length should be a number between 1 and %d":                                      "довжина має бути числом від 1 до %d",
+		"Practice session: %d cards":                                                      "Сесія практики: %d карток",
+		"Study ahead":                                                                     "Вчити наперед",
+		"vacation should be \"off\" or a number of days between 1 and %d":                 "відпустка має бути \"off\" або числом днів від 1 до %d",
+		"paused until %s":                                                                 "призупинено до %s",
+		"For how many days are you going away? Enter a number between 1 and %d or \"off\" to end the vacation.": "На скільки днів ви їдете? Введіть число від 1 до %d або \"off\", щоб завершити відпустку.",
+		"Welcome back! Reminders are resumed.":                                                   "З поверненням! Нагадування відновлено.",
+		"Enjoy your vacation! %d words are postponed by %d days, reminders are paused until %s.": "Гарної відпустки! Слів відкладено: %d на %d дн., нагадування призупинено до %s.",
+		"default":       "за замовчуванням",
+		"Leitner boxes": "скриньки Лейтнера",
+		"Scheduler: %s": "Розклад повторень: %s",
+		"answer should be \"default\" or \"leitner\"": "відповідь має бути \"default\" або \"leitner\"",
+		"How should I schedule the reviews? Enter \"default\" to move the words through the stages of learning or \"leitner\" for 5 Leitner boxes reviewed every 1, 2, 4, 8 and 16 days.": "Як планувати повторення? Введіть \"default\", щоб слова проходили етапи вивчення, або \"leitner\" для 5 скриньок Лейтнера, які повторюються кожні 1, 2, 4, 8 і 16 днів.",
+		"Show more definitions":              "Показати ще визначення",
+		"Pick definition":                    "Вибрати визначення",
+		"Enter the word to add a note to.":   "Введіть слово, до якого додати нотатку.",
+		"Enter the note to add to the card.": "Введіть нотатку для картки.",
+		"Added the note to %q.":              "Нотатку додано до %q.",
+		"Add note":                           "Додати нотатку",
+		"Sentences in practice: %s":          "Речення в практиці: %s",
+		"Do you want to practice with sentences in which the word is blanked out, when there are any? Enter \"on\" or \"off\".": "Бажаєте практикуватися на реченнях, у яких слово пропущено, якщо такі є? Введіть \"on\" або \"off\".",
+		"Save looked up words: %s": "Зберігати знайдені слова: %s",
+		"Do you want to save every word you look up for learning without pressing Learn? Enter \"on\" or \"off\".": "Зберігати кожне знайдене слово для вивчення без натискання Learn? Введіть \"on\" або \"off\".",
+		"%s: %d%% (%d of %d)":                   "%s: %d%% (%d з %d)",
+		"You haven't practiced any words yet.":  "Ви ще не практикували жодного слова.",
+		"Known answers by the previous answer:": "Частка відповідей «знаю» за попередньою відповіддю:",
+		"After Know":                            "Після «знаю»",
+		"After Don't know":                      "Після «не знаю»",
+		"First review":                          "Перше повторення",
+		"Known answers by the time since the previous review:": "Частка відповідей «знаю» за часом від попереднього повторення:",
+		"Less than a day":                     "Менше дня",
+		"1 to 7 days":                         "Від 1 до 7 днів",
+		"7 to 30 days":                        "Від 7 до 30 днів",
+		"More than 30 days":                   "Більше 30 днів",
+		"plural %s":                           "мн. %s",
+		"Transliteration of translations: %s": "Транслітерація перекладів: %s",
+		"Do you want translations of usage examples in Russian and Ukrainian to be followed by their transliteration into latin letters? Enter \"on\" or \"off\".": "Додавати до перекладів прикладів російською та українською їх транслітерацію латиницею? Введіть \"on\" або \"off\".",
+		"no definitions found":               "визначень не знайдено",
+		"Learn %s":                           "Вчити %s",
+		"%q is already saved":                "%q вже збережено",
+		"The API isn't enabled on this bot.": "API не ввімкнено в цьому боті.",
+		"Your API token, keep it secret:\n%s\n\nSend it in the Authorization: Bearer header. /token again replaces it.": "Ваш токен API, тримайте його в секреті:\n%s\n\nПередавайте його в заголовку Authorization: Bearer. Повторна команда /token замінить його.",
+		"The dashboard isn't enabled on this bot.":                        "Панель не ввімкнено в цьому боті.",
+		"Open your dashboard within %d minutes, the link works once:\n%s": "Відкрийте панель протягом %d хвилин, посилання працює один раз:\n%s",
+		"Reviews in the last %d weeks":                                    "Повторення за останні %d тижнів",
+		"Cards: %d":                                                       "Картки: %d",
+		"Search":                                                          "Пошук",
+		"Word":                                                            "Слово",
+		"Definition":                                                      "Визначення",
+		"Next review":                                                     "Наступне повторення",
+		"Later":                                                           "Пізніше",
+		"%q will be back in %d minutes":                                   "%q повернеться через %d хвилин",
+		"Nothing else to practice now, the cards put off for later come back in a few minutes.": "Більше нічого повторювати, відкладені картки повернуться за кілька хвилин.",
+		"☆ Star":       "☆ До обраного",
+		"★ Unstar":     "★ Прибрати з обраного",
+		"Starred %q":   "%q в обраному",
+		"Unstarred %q": "%q прибрано з обраного",
+		"No starred cards yet. Star cards with the ☆ Star button under them.": "В обраному поки немає карток. Додавайте їх кнопкою ☆ До обраного під карткою.",
+		"Starred cards: %d":                          "Обрані картки: %d",
+		"… and %d more":                              "… і ще %d",
+		"stage %d":                                   "етап %d",
+		"Practice starred":                           "Повторити обране",
+		"No more starred cards to practice.":         "Більше немає обраних карток для повторення.",
+		"Recognizing words on photos isn't enabled.": "Розпізнавання слів на фото не ввімкнено.",
+		"Couldn't recognize the text on the photo, try again later.": "Не вдалося розпізнати текст на фото, спробуйте пізніше.",
+		"Couldn't find any words on the photo.":                      "На фото не знайдено слів.",
+		"Tap a word to define it:":                                   "Натисніть на слово, щоб дізнатися його значення:",
+		"🔊 Listen":                                                   "🔊 Послухати",
+		"Audio isn't available anymore.":                             "Аудіо більше недоступне.",
+		"🔊 Which word did you hear?":                                 "🔊 Яке слово ви почули?",
+		"Listening practice: %s":                                     "Практика на слух: %s",
+		"Do you want to hear the words in practice instead of reading them, for the languages with a voice? Enter \"on\" or \"off\".": "Бажаєте слухати слова під час повторення замість читання, для мов з озвученням? Введіть \"on\" або \"off\".",
+		"Save at least 2 words to play.":                                           "Збережіть хоча б 2 слова, щоб грати.",
+		"Match the words with their definitions: tap a word, then its definition.": "Зіставте слова з їхніми значеннями: натисніть на слово, потім на його значення.",
+		"This game is over.":     "Ця гра закінчена.",
+		"Tap a word first.":      "Спочатку натисніть на слово.",
+		"Not a pair, try again.": "Це не пара, спробуйте ще раз.",
+		"All pairs matched! Right on the first try: %d of %d. Time: %s.":                            "Усі пари знайдено! З першої спроби: %d з %d. Час: %s.",
+		"goal should be \"off\" or a number between 1 and %d":                                       "мета має бути \"off\" або числом від 1 до %d",
+		"How many reviews do you want to do every day? Enter a number between 1 and %d or \"off\".": "Скільки повторень ви хочете робити щодня? Введіть число від 1 до %d або \"off\".",
+		"%d reviews":     "%d повторень",
+		"Daily goal: %s": "Мета на день: %s",
+		"Daily goal reached: %d of %d reviews today.":                        "Мету на день досягнуто: %d з %d повторень сьогодні.",
+		"Daily goal: %d of %d reviews today.":                                "Мета на день: %d з %d повторень сьогодні.",
+		"%d more reviews to reach your daily goal of %d!":                    "Ще %d повторень до мети на день у %d!",
+		"the number of cards should be \"off\" or a number between 1 and %d": "кількість карток має бути \"off\" або числом від 1 до %d",
+		"How many overdue cards do you want to practice a day? The rest are spread over the following days. Enter a number between 1 and %d or \"off\".": "Скільки прострочених карток ви хочете повторювати на день? Решта розподілиться на наступні дні. Введіть число від 1 до %d або \"off\".",
+		"%d overdue cards per day": "%d прострочених карток на день",
+		"Catch-up: %s":             "Надолуження: %s",
+		"interval should be \"off\" or a number of days between 1 and %d":                                          "інтервал має бути \"off\" або числом днів від 1 до %d",
+		"How many days at most should pass between reviews of a card? Enter a number between 1 and %d or \"off\".": "Скільки днів максимум може минути між повтореннями картки? Введіть число від 1 до %d або \"off\".",
+		"%d days":                                "%d днів",
+		"Maximum interval: %s":                   "Максимальний інтервал: %s",
+		"there should be between 1 and %d steps": "має бути від 1 до %d кроків",
+		"step %q should be a duration between 1m and 30d, e.g. 10m, 2h or 1d":                                                     "крок %q має бути тривалістю від 1m до 30d, наприклад 10m, 2h або 1d",
+		"Enter the intervals between reviews of new and forgotten cards before they are learned, e.g. \"1m 10m 1d\", or \"off\".": "Введіть інтервали між повтореннями нових і забутих карток, доки їх не вивчено, наприклад \"1m 10m 1d\", або \"off\".",
+		"Learning steps: %s": "Кроки вивчення: %s",
+		"answer should be \"added\", \"random\" or \"frequent\"": "відповідь має бути \"added\", \"random\" або \"frequent\"",
+		"In which order should new cards come to practice? Enter \"added\" for the order you added them in, \"random\" or \"frequent\" for the words that are more common in sentences first.": "У якому порядку нові картки мають потрапляти в практику? Введіть \"added\" для порядку додавання, \"random\" або \"frequent\", щоб спочатку йшли слова, які частіше трапляються в реченнях.",
+		"in the order added":                       "у порядку додавання",
+		"in random order":                          "у випадковому порядку",
+		"most frequent first":                      "спочатку найчастіші",
+		"New cards: %s":                            "Нові картки: %s",
+		"hour should be a number between 0 and 23": "година має бути числом від 0 до 23",
+		"At which hour should your day start for streaks, daily goals and forecasts? Enter a number between 0 and 23, e.g. 4 to count practice until 4 in the morning to the previous day.": "О котрій годині має починатися ваш день для серій, цілей на день і прогнозів? Введіть число від 0 до 23, наприклад 4, щоб практика до 4 ранку зараховувалася до попереднього дня.",
+		"Day starts at: %02d:00": "День починається о: %02d:00",
+		"Your %d-day streak ends at %02d:00, practice a word to keep it!": "Ваша серія в %d дн. перерветься о %02d:00, повторіть хоча б одне слово, щоб її зберегти!",
+		"unknown day %q, days are mon, tue, wed, thu, fri, sat and sun":   "невідомий день %q, дні: mon, tue, wed, thu, fri, sat і sun",
+		"Your first card is ready for practice!":                          "Ваша перша картка готова до практики!",
+		"Card in reminders: %s":                                           "Картка в нагадуваннях: %s",
+		"Do you want reminders to show the first card ready for practice, so that you can answer it right away? Enter \"on\" or \"off\".": "Показувати в нагадуваннях першу картку, готову до практики, щоб одразу на неї відповісти? Введіть \"on\" або \"off\".",
+		"Reminders to practice are now sent only if you choose when. Use /availability to set them up.":                                   "Нагадування про практику тепер надсилаються, лише якщо ви оберете, коли їх отримувати. Використайте /availability, щоб їх налаштувати.",
+		"%s looks like a form of %s":                   "%s схоже на форму слова %s",
+		"Send the photo with the word as its caption.": "Надішліть фото зі словом у підписі.",
+		"Enter the word to attach an image to.":        "Введіть слово, до якого прикріпити зображення.",
+		"Send the photo for the front of the card.":    "Надішліть фото для лицьового боку картки.",
+		"Send a photo, please.":                        "Будь ласка, надішліть фото.",
+		"Attached the image to %q.":                    "Зображення прикріплено до %q.",
+		"Attach image":                                 "Прикріпити зображення",
+		"Which word is it?":                            "Що це за слово?",
+		"Set up syncing with /anki first.":             "Спочатку налаштуйте синхронізацію за допомогою /anki.",
+		"Couldn't sync with Anki: %s. Make sure that Anki is running and AnkiConnect can be reached from the internet.": "Не вдалося синхронізуватися з Anki: %s. Переконайтеся, що Anki запущено і AnkiConnect доступний з інтернету.",
+		"Syncing with Anki isn't enabled.":                                                                                "Синхронізацію з Anki не увімкнено.",
+		"Synced with Anki: %d new cards sent, %d reviews received.":                                                       "Синхронізовано з Anki: надіслано нових карток: %d, отримано повторень: %d.",
+		"Your cards aren't synced with Anki.":                                                                             "Ваші картки не синхронізуються з Anki.",
+		"Stopped syncing with Anki, the cards in Anki are kept.":                                                          "Синхронізацію з Anki зупинено, картки в Anki збережено.",
+		"Enter the address of AnkiConnect, e.g. http://203.0.113.5:8765. It has to be reachable from the internet.":       "Введіть адресу AnkiConnect, наприклад http://203.0.113.5:8765. Вона має бути доступна з інтернету.",
+		"The address should start with http:// or https://. Please try again.":                                            "Адреса має починатися з http:// або https://. Спробуйте ще раз.",
+		"Enter the API key of AnkiConnect or \"none\" if it has none.":                                                    "Введіть API-ключ AnkiConnect або \"none\", якщо його немає.",
+		"Enter the name of the Anki deck for the cards.":                                                                  "Введіть назву колоди Anki для карток.",
+		"Which should schedule the reviews of the synced cards, \"bot\" or \"anki\"? The other one follows its schedule.": "Хто має планувати повторення синхронізованих карток, \"bot\" чи \"anki\"? Інша сторона дотримується його розкладу.",
+		"Answer should be \"bot\" or \"anki\". Please try again.":                                                         "Відповідь має бути \"bot\" або \"anki\". Спробуйте ще раз.",
+		"You've just looked up %q, the reply is above.":                                                                   "Ви щойно шукали %q, відповідь вище.",
+		"Already done.":            "Вже зроблено.",
+		"The message was deleted.": "Повідомлення було видалено.",
+	},
+	"hun": {
+		"Know":                       "Tudom",
+		"Reset progress":             "Haladás törlése",
+		"Don't know":                 "Nem tudom",
+		"Cancelled":                  "Megszakítva",
+		"Saved %q for learning":      "%q elmentve tanuláshoz",
+		"Learn":                      "Tanulás",
+		"Start practice":             "Gyakorlás indítása",
+		"%s is not a known command.": "%s nem ismert parancs.",
+		"Did you mean %s?":           "Erre gondoltál: %s?",
+		" or ":                       " vagy ",
+		"Nothing to cancel.":         "Nincs mit megszakítani.",
+		"Cancelled %s.":              "%s megszakítva.",
+		"No more rows to practice; exiting practice mode.": "Nincs több gyakorolnivaló; kilépés a gyakorló módból.",
+		"unlimited":                              "korlátlan",
+		"%d characters":                          "%d karakter",
+		"off":                                    "ki",
+		"%d per day":                             "naponta %d",
+		"between %s":                             "%s között",
+		"on":                                     "be",
+		"Input language: %q":                     "Beviteli nyelv: %q",
+		"Input language in ISO 639-3: %q":        "Beviteli nyelv ISO 639-3 szerint: %q",
+		"Translation languages in ISO 639-3: %s": "Fordítási nyelvek ISO 639-3 szerint: %s",
+		"Time Zone: %s":                          "Időzóna: %s",
+		"Max usage example length: %s":           "Példamondatok max. hossza: %s",
+		"Reminders: %s":                          "Emlékeztetők: %s",
+		"Weekly summary: %s":                     "Heti összefoglaló: %s",
+		"Bot language: %s":                       "A bot nyelve: %s",
+		"Current settings:":                      "Jelenlegi beállítások:",
+		"To modify settings use one of the commands below:":                  "A beállítások módosításához használd az alábbi parancsok egyikét:",
+		"Tap the language to toggle translations of usage examples into it.": "Koppints egy nyelvre a példamondatok fordításának be- vagy kikapcsolásához.",
+		"%s. Please try again.": "%s. Kérlek, próbáld újra.",
+		"Enter front of the card (word, expression, question) or send a photo with the word as its caption.": "Add meg a kártya elejét (szó, kifejezés, kérdés), vagy küldj egy fotót a szóval a képaláírásban.",
+		"Enter back of the card (definition, answer).":                                                       "Add meg a kártya hátulját (meghatározás, válasz).",
+		"Added %q for learning!":                              "%q hozzáadva tanuláshoz!",
+		"Enter the word you want to delete from learning!":    "Add meg a szót, amelyet törölni szeretnél a tanulásból!",
+		"Word %q isn't saved for learning!":                   "A(z) %q szó nincs elmentve tanuláshoz!",
+		"Delete %q from learning? All progress will be lost.": "Törlöd a(z) %q szót a tanulásból? Minden haladás elvész.",
+		"Deleted %q!":               "%q törölve!",
+		"Progress for %q was reset": "A(z) %q haladása törölve",
+		"For now this bot doesn't work with expressions. Try entering a single work without spaces.": "A bot egyelőre nem kezel kifejezéseket. Próbálj egyetlen szót megadni szóközök nélkül.",
+		"Couldn't find definitions.": "Nem találtam meghatározást.",
+		"Welcome to the language bot. Still in development. No instructions so far. All sentences and translations are from Tatoeba's (https://tatoeba.org) dataset, released under a CC-BY 2.0 FR.": "Üdvözöl a nyelvi bot. Még fejlesztés alatt áll. Használati útmutató még nincs. Minden mondat és fordítás a Tatoeba (https://tatoeba.org) adatkészletéből származik, amely CC-BY 2.0 FR licenc alatt érhető el.",
+		"Enter ISO 639-3 codes of languages into which to translate usage examples separated by comma (e.g. \"eng,deu\") or \"default\" to use the defaults for your input language.":                "Add meg vesszővel elválasztva azoknak a nyelveknek az ISO 639-3 kódját, amelyekre a példamondatokat fordítani kell (pl. \"eng,deu\"), vagy \"default\"-ot a beviteli nyelv alapértelmezéseihez.",
+		"When can I send you reminders to practice? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00, or \"off\" to not receive reminders.":                            "Mikor küldhetek emlékeztetőt a gyakorláshoz? Add meg az időszakot a saját időzónádban HH:MM-HH:MM formátumban, pl. 09:00-21:00, vagy \"off\"-ot, ha nem kérsz emlékeztetőt.",
+		"All set! Send me a word to get its definition.": "Minden kész! Küldj egy szót, és megmutatom a jelentését.",
+		"Let's set things up first.":                     "Először állítsunk be mindent.",
+		"Enter input language of your choice. Supported are %s or any other language by its ISO 639-3 code (e.g. \"fra\")": "Add meg a beviteli nyelvet. Támogatott: %s vagy bármely más nyelv ISO 639-3 kóddal (pl. \"fra\")",
+		"Input your timezone in one of the formats: UTC, UTC+X or UTC-X.":                                                  "Add meg az időzónádat a következő formátumok egyikében: UTC, UTC+X vagy UTC-X.",
+		"When can I send you reminders? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00. To limit it to some days, put them before the range, and separate several ranges with \";\", e.g. mon-fri 07:00-09:00; sat,sun 10:00-12:00.": "Mikor küldhetek emlékeztetőt? Add meg az időszakot a saját időzónádban HH:MM-HH:MM formátumban, pl. 09:00-21:00. Ha csak bizonyos napokra szeretnéd, írd a napokat az időszak elé, több időszakot pedig \";\" jellel válassz el, pl. mon-fri 07:00-09:00; sat,sun 10:00-12:00.",
+		"How many times a day should I remind you to practice? Enter a number between 1 and %d or \"off\" to stop reminders.":                                                                                                                                        "Naponta hányszor emlékeztesselek a gyakorlásra? Adj meg egy számot 1 és %d között, vagy \"off\"-ot az emlékeztetők kikapcsolásához.",
+		"Do you want to receive a weekly summary of your progress? Enter \"on\" or \"off\".":                                                                                                                                                                         "Szeretnél heti összefoglalót kapni a haladásodról? Írd be: \"on\" vagy \"off\".",
+		"Enter the language of the bot interface. Supported are %s.":                                                                                                                                                                                                 "Add meg a bot felületének nyelvét. Támogatott: %s.",
+		"Input maximum length of usage examples in characters (0 to show examples of any length).":                                                                                                                                                                   "Add meg a példamondatok maximális hosszát karakterben (0 esetén bármilyen hosszúságú példa megjelenik).",
+		"Stopped. Input the word to get it's definition.":                                                                                                                                                                                                            "Leállítva. Írj be egy szót a jelentéséhez.",
+		"#%d by %s":                             "#%d, szerző: %s",
+		"[truncated %d definitions]":            "[%d meghatározás elrejtve]",
+		"Usage examples:":                       "Példamondatok:",
+		"Didn't find usage examples.":           "Nem találtam példamondatot.",
+		"unsupported interface language %q":     "nem támogatott felületi nyelv: %q",
+		"unsupported language %q":               "nem támogatott nyelv: %q",
+		"You have %d words ready for practice!": "%d szó vár gyakorlásra!",
+		"You have 1 word ready for practice!":   "1 szó vár gyakorlásra!",
+		"Your week in review:":                  "A heted összefoglalója:",
+		"Reviews done: %d":                      "Ismétlések: %d",
+		"New words added: %d":                   "Új szavak: %d",
+		"Retention: %.0f%%":                     "Megjegyzési arány: %.0f%%",
+		"Longest streak: %d days":               "Leghosszabb sorozat: %d nap",
+		"Hardest words: %s":                     "Legnehezebb szavak: %s",
+		"availability window should be in the format HH:MM-HH:MM, e.g. 09:00-21:00": "az időszakot HH:MM-HH:MM formátumban kell megadni, pl. 09:00-21:00",
+		"unsupported time zone (format should be UTC, UTC+X or UTC-X)":              "nem támogatott időzóna (a formátum UTC, UTC+X vagy UTC-X)",
+		"length should be a number between 0 and %d":                                "a hossznak 0 és %d közötti számnak kell lennie",
+		"unsupported translation language %q":                                       "nem támogatott fordítási nyelv: %q",
+		"at least one translation language should stay enabled":                     "legalább egy fordítási nyelvnek bekapcsolva kell maradnia",
+		"no languages entered":                                                      "nem adtál meg nyelvet",
+		"%q is not an ISO 639-3 code":                                               "%q nem ISO 639-3 kód",
+		"frequency should be \"off\" or a number between 1 and %d":                  "a gyakoriság \"off\" vagy 1 és %d közötti szám lehet",
+		"answer should be \"on\" or \"off\"":                                        "a válasz \"on\" vagy \"off\" lehet",
+		"No":                                                                        "Nem",
+		"Yes":                                                                       "Igen",
+		"too many messages, please wait a bit":                                      "túl sok üzenet, kérlek, várj egy kicsit",
+		"%s is available only to admins":                                            "a(z) %s csak adminisztrátoroknak érhető el",
+		"No words are due for practice, nothing to quiz you on.":                    "Nincs esedékes szó, nincs miből kvízt összeállítani.",
+		"Quiz finished! Score: %d/%d.":                                              "Vége a kvíznek! Eredmény: %d/%d.",
+		"Time: %s.":                                                                 "Idő: %s.",
+		"Words to revisit: %s.":                                                     "Átismétlendő szavak: %s.",
+		"This quiz is over.":                                                        "Ez a kvíz már véget ért.",
+		"Enter the word to define.":                                                 "Add meg a szót, amelynek a jelentésére kíváncsi vagy.",
+		"Updated the card for %q":                                                   "A(z) %q kártyája frissítve",
+		"Update card":                                                               "Kártya frissítése",
+		"You have no saved words yet.":                                              "Még nincs elmentett szavad.",
+		"Show answer":                                                               "Válasz mutatása",
+		"Your review forecast:":                                                     "Ismétlési előrejelzés:",
+		"Due now: %d":                                                               "Most esedékes: %d",
+		"Later today: %d":                                                           "Ma később: %d",
+		"Tomorrow: %d":                                                              "Holnap: %d",
+		"Next 7 days: %d":                                                           "A következő 7 napban: %d",
+		"Delete all your data? Saved words, progress, settings and reminders will be lost for good.": "Törlöd az összes adatodat? A mentett szavak, a haladás, a beállítások és az emlékeztetők végleg elvesznek.",
+		"All your data was deleted. Send /start to set up the bot again.":                            "Minden adatod törölve. Küldd el a /start parancsot a bot újbóli beállításához.",
+		"Current streak: %d days":                                          "Jelenlegi sorozat: %d nap",
+		"Best streak: %d days":                                             "Legjobb sorozat: %d nap",
+		"Practice today to keep your streak!":                              "Gyakorolj ma, hogy ne szakadjon meg a sorozatod!",
+		"Your %d-day streak ends at midnight, practice a word to keep it!": "A %d napos sorozatod éjfélkor megszakad, gyakorolj legalább egy szót, hogy megtartsd!",
+		"Your share code is %s. Others can copy your words with /subscribe %s, /unshare stops sharing.": "A megosztási kódod: %s. Mások a /subscribe %s paranccsal másolhatják a szavaidat, a /unshare leállítja a megosztást.",
+		"Your words aren't shared anymore.": "A szavaid már nincsenek megosztva.",
+		"Your words aren't shared.":         "A szavaid nincsenek megosztva.",
+		"Enter the share code of the deck.": "Add meg a pakli megosztási kódját.",
+		"there is no deck with the code %q": "nincs pakli a(z) %q kóddal",
+		"this is your own deck":             "ez a saját paklid",
+		"Enter \"copy\" to copy the words once or \"follow\" to also get the words added later.": "Írd be: \"copy\", hogy egyszer átmásold a szavakat, vagy \"follow\", hogy a később hozzáadottakat is megkapd.",
+		"expected \"copy\" or \"follow\"":                                               "\"copy\" vagy \"follow\" a várt válasz",
+		"Copied %d words for learning.":                                                 "%d szó átmásolva tanuláshoz.",
+		"Words added to the deck later will be copied too, /unsubscribe %s stops that.": "A paklihoz később hozzáadott szavak is átmásolódnak, a /unsubscribe %s ezt leállítja.",
+		"you don't follow the deck %q":                                                  "nem követed a(z) %q paklit",
+		"New words of the deck won't be copied anymore, the copied ones stay.":          "A pakli új szavai már nem másolódnak át, az átmásoltak megmaradnak.",
+		"Imported %d words.":                                                            "%d szó importálva.",
+		"Already saved: %d.":                                                            "Már mentve: %d.",
+		"No definitions found for: %s.":                                                 "Nem található meghatározás: %s.",
+		"%d words were left out, import at most %d words at once.":                      "%d szó kimaradt, egyszerre legfeljebb %d szó importálható.",
+		"Send vocab.db from your Kindle (in the system folder) or its CSV export to create cards for the looked up words.": "Küldd el a Kindle vocab.db fájlját (a system mappában) vagy annak CSV exportját, hogy kártyák készüljenek a kikeresett szavakhoz.",
+		"send the file as a document or /cancel":                  "küldd el a fájlt dokumentumként vagy /cancel",
+		"the file is too large":                                   "a fájl túl nagy",
+		"couldn't read words from the file":                       "nem sikerült szavakat olvasni a fájlból",
+		"no words in the file":                                    "nincsenek szavak a fájlban",
+		"Importing %d words, it can take a while.":                "%d szó importálása, ez eltarthat egy ideig.",
+		"Importing %d words: %d done.":                            "%d szó importálása: %d kész.",
+		"The import failed, try again later.":                     "Az importálás nem sikerült, próbálja újra később.",
+		"another import is in progress, wait for it to finish":    "egy másik importálás folyamatban van, várja meg a végét",
+		"%q is already saved: stage %d of %d, next review on %s.": "%q már mentve van: %d/%d. szint, következő ismétlés: %s.",
+		"Added the definition to the card for %q":                 "A meghatározás hozzáadva a(z) %q kártyához",
+		"Append this definition":                                  "Meghatározás hozzáadása",
+		"Kept the card for %q as is":                              "A(z) %q kártya változatlan maradt",
+		"Keep as is":                                              "Maradjon így",
+		"Other studied languages in ISO 639-3: %s":                "További tanult nyelvek ISO 639-3 szerint: %s",
+		"none": "nincs",
+		"Enter the language to study in addition to the current ones. It becomes the input language, words not found in it are looked up in the others.": "Add meg a nyelvet, amelyet a jelenlegiek mellett tanulnál. Ez lesz a beviteli nyelv, a benne nem talált szavakat a többiben keresem.",
+		"Tap the language to look up words in it first. Add languages with /addlanguage, practice one of them with /practice <language>.":                "Koppints a nyelvre, hogy először abban keressem a szavakat. Nyelvet a /addlanguage paranccsal adhatsz hozzá, egyet közülük a /practice <nyelv> paranccsal gyakorolhatsz.",
+		"You study only %s, use /language to change it.":            "Csak a(z) %s nyelvet tanulod, a /language paranccsal változtathatod meg.",
+		"Tap the language to stop studying it. Its cards are kept.": "Koppints a nyelvre, hogy ne tanuld tovább. A kártyái megmaradnak.",
+		"%s is not studied": "a(z) %s nyelvet nem tanulod",
+		"can't remove the only studied language, use /language to change it": "az egyetlen tanult nyelv nem távolítható el, a /language paranccsal változtathatod meg",
+		"Session finished! Known: %d of %d (%d%%).":                          "Vége a gyakorlásnak! Tudtad: %d / %d (%d%%).",
+		"Still due: %d": "Még esedékes: %d",
+		"Continue":      "Folytatás",
+		"How many cards should a practice session have? Enter a number between 1 and %d.": "Hány kártya legyen egy gyakorlásban? Adj meg egy számot 1 és %d között.",
+		"length should be a number between 1 and %d":                                      "a hossznak 1 és %d közötti számnak kell lennie",
+		"Practice session: %d cards":                                                      "Gyakorlás: %d kártya",
+		"Study ahead":                                                                     "Tanulás előre",
+		"vacation should be \"off\" or a number of days between 1 and %d":                 "a szabadság \"off\" vagy 1 és %d közötti napszám lehet",
+		"paused until %s":                                                                 "szünetel eddig: %s",
+		"For how many days are you going away? Enter a number between 1 and %d or \"off\" to end the vacation.": "Hány napra mész el? Adj meg egy számot 1 és %d között, vagy \"off\"-ot a szabadság befejezéséhez.",
+		"Welcome back! Reminders are resumed.":                                                   "Üdv újra! Az emlékeztetők folytatódnak.",
+		"Enjoy your vacation! %d words are postponed by %d days, reminders are paused until %s.": "Jó pihenést! %d szó %d nappal elhalasztva, az emlékeztetők szünetelnek eddig: %s.",
+		"default":       "alapértelmezett",
+		"Leitner boxes": "Leitner-dobozok",
+		"Scheduler: %s": "Ismétlések ütemezése: %s",
+		"answer should be \"default\" or \"leitner\"": "a válasz \"default\" vagy \"leitner\" lehet",
+		"How should I schedule the reviews? Enter \"default\" to move the words through the stages of learning or \"leitner\" for 5 Leitner boxes reviewed every 1, 2, 4, 8 and 16 days.": "Hogyan ütemezzem az ismétléseket? Írd be, hogy \"default\", ha a szavak a tanulás szakaszain haladjanak végig, vagy \"leitner\" az 1, 2, 4, 8 és 16 naponta ismételt 5 Leitner-dobozhoz.",
+		"Show more definitions":              "További jelentések",
+		"Pick definition":                    "Jelentés kiválasztása",
+		"Enter the word to add a note to.":   "Add meg a szót, amelyhez jegyzetet fűzöl.",
+		"Enter the note to add to the card.": "Add meg a kártyához fűzendő jegyzetet.",
+		"Added the note to %q.":              "A jegyzet hozzáadva ehhez: %q.",
+		"Add note":                           "Jegyzet hozzáadása",
+		"Sentences in practice: %s":          "Mondatok gyakorláskor: %s",
+		"Do you want to practice with sentences in which the word is blanked out, when there are any? Enter \"on\" or \"off\".": "Szeretnél olyan mondatokkal gyakorolni, amelyekből a szó ki van hagyva, ha vannak ilyenek? Írd be: \"on\" vagy \"off\".",
+		"Save looked up words: %s": "Keresett szavak mentése: %s",
+		"Do you want to save every word you look up for learning without pressing Learn? Enter \"on\" or \"off\".": "Szeretnéd minden keresett szót tanulásra menteni a Learn megnyomása nélkül? Írd be: \"on\" vagy \"off\".",
+		"%s: %d%% (%d of %d)":                   "%s: %d%% (%d / %d)",
+		"You haven't practiced any words yet.":  "Még nem gyakoroltál egy szót sem.",
+		"Known answers by the previous answer:": "Tudott válaszok az előző válasz szerint:",
+		"After Know":                            "Tudom után",
+		"After Don't know":                      "Nem tudom után",
+		"First review":                          "Első ismétlés",
+		"Known answers by the time since the previous review:": "Tudott válaszok az előző ismétlés óta eltelt idő szerint:",
+		"Less than a day":                     "Kevesebb mint egy nap",
+		"1 to 7 days":                         "1-7 nap",
+		"7 to 30 days":                        "7-30 nap",
+		"More than 30 days":                   "Több mint 30 nap",
+		"plural %s":                           "többes szám: %s",
+		"Transliteration of translations: %s": "Fordítások átírása: %s",
+		"Do you want translations of usage examples in Russian and Ukrainian to be followed by their transliteration into latin letters? Enter \"on\" or \"off\".": "Szeretnéd, hogy a példamondatok orosz és ukrán fordításai után latin betűs átírásuk is megjelenjen? Írd be: \"on\" vagy \"off\".",
+		"no definitions found":               "nincs meghatározás",
+		"Learn %s":                           "%s tanulása",
+		"%q is already saved":                "%q már el van mentve",
+		"The API isn't enabled on this bot.": "Az API nincs bekapcsolva ennél a botnál.",
+		"Your API token, keep it secret:\n%s\n\nSend it in the Authorization: Bearer header. /token again replaces it.": "Az API tokened, tartsd titokban:\n%s\n\nAz Authorization: Bearer fejlécben küldd. Az újabb /token lecseréli.",
+		"The dashboard isn't enabled on this bot.":                        "Az irányítópult nincs bekapcsolva ennél a botnál.",
+		"Open your dashboard within %d minutes, the link works once:\n%s": "Nyisd meg az irányítópultot %d percen belül, a link egyszer működik:\n%s",
+		"Reviews in the last %d weeks":                                    "Ismétlések az elmúlt %d hétben",
+		"Cards: %d":                                                       "Kártyák: %d",
+		"Search":                                                          "Keresés",
+		"Word":                                                            "Szó",
+		"Definition":                                                      "Meghatározás",
+		"Next review":                                                     "Következő ismétlés",
+		"Later":                                                           "Később",
+		"%q will be back in %d minutes":                                   "%q %d perc múlva visszatér",
+		"Nothing else to practice now, the cards put off for later come back in a few minutes.": "Most nincs más gyakorolnivaló, a későbbre hagyott kártyák néhány perc múlva visszatérnek.",
+		"☆ Star":       "☆ Csillag",
+		"★ Unstar":     "★ Csillag törlése",
+		"Starred %q":   "%q csillagozva",
+		"Unstarred %q": "%q csillaga törölve",
+		"No starred cards yet. Star cards with the ☆ Star button under them.": "Még nincsenek csillagozott kártyák. Csillagozz a kártyák alatti ☆ Csillag gombbal.",
+		"Starred cards: %d":                          "Csillagozott kártyák: %d",
+		"… and %d more":                              "… és még %d",
+		"stage %d":                                   "%d. szint",
+		"Practice starred":                           "Csillagozottak gyakorlása",
+		"No more starred cards to practice.":         "Nincs több csillagozott kártya gyakorlásra.",
+		"Recognizing words on photos isn't enabled.": "A szavak felismerése fényképeken nincs bekapcsolva.",
+		"Couldn't recognize the text on the photo, try again later.": "Nem sikerült felismerni a szöveget a fényképen, próbáld újra később.",
+		"Couldn't find any words on the photo.":                      "Nem található szó a fényképen.",
+		"Tap a word to define it:":                                   "Koppints egy szóra a jelentéséért:",
+		"🔊 Listen":                                                   "🔊 Meghallgatás",
+		"Audio isn't available anymore.":                             "A hang már nem érhető el.",
+		"🔊 Which word did you hear?":                                 "🔊 Melyik szót hallottad?",
+		"Listening practice: %s":                                     "Hallás utáni gyakorlás: %s",
+		"Do you want to hear the words in practice instead of reading them, for the languages with a voice? Enter \"on\" or \"off\".": "Szeretnéd gyakorláskor meghallgatni a szavakat olvasás helyett, azokon a nyelveken, amelyekhez van hang? Írd be: \"on\" vagy \"off\".",
+		"Save at least 2 words to play.":                                           "Ments el legalább 2 szót a játékhoz.",
+		"Match the words with their definitions: tap a word, then its definition.": "Párosítsd a szavakat a jelentésükkel: koppints egy szóra, majd a jelentésére.",
+		"This game is over.":     "Ez a játék véget ért.",
+		"Tap a word first.":      "Először koppints egy szóra.",
+		"Not a pair, try again.": "Ez nem pár, próbáld újra.",
+		"All pairs matched! Right on the first try: %d of %d. Time: %s.":                            "Minden pár megvan! Elsőre sikerült: %d / %d. Idő: %s.",
+		"goal should be \"off\" or a number between 1 and %d":                                       "a cél \"off\" vagy egy szám legyen 1 és %d között",
+		"How many reviews do you want to do every day? Enter a number between 1 and %d or \"off\".": "Hány ismétlést szeretnél naponta? Adj meg egy számot 1 és %d között, vagy \"off\".",
+		"%d reviews":     "%d ismétlés",
+		"Daily goal: %s": "Napi cél: %s",
+		"Daily goal reached: %d of %d reviews today.":                        "Napi cél teljesítve: ma %d / %d ismétlés.",
+		"Daily goal: %d of %d reviews today.":                                "Napi cél: ma %d / %d ismétlés.",
+		"%d more reviews to reach your daily goal of %d!":                    "Még %d ismétlés a(z) %d ismétléses napi célig!",
+		"the number of cards should be \"off\" or a number between 1 and %d": "a kártyák száma \"off\" vagy egy szám legyen 1 és %d között",
+		"How many overdue cards do you want to practice a day? The rest are spread over the following days. Enter a number between 1 and %d or \"off\".": "Naponta hány lejárt kártyát szeretnél gyakorolni? A többi a következő napokra oszlik el. Adj meg egy számot 1 és %d között, vagy \"off\".",
+		"%d overdue cards per day": "napi %d lejárt kártya",
+		"Catch-up: %s":             "Felzárkózás: %s",
+		"interval should be \"off\" or a number of days between 1 and %d":                                          "az időköz \"off\" vagy napok száma legyen 1 és %d között",
+		"How many days at most should pass between reviews of a card? Enter a number between 1 and %d or \"off\".": "Legfeljebb hány nap teljen el egy kártya ismétlései között? Adj meg egy számot 1 és %d között, vagy \"off\".",
+		"%d days":                                "%d nap",
+		"Maximum interval: %s":                   "Leghosszabb időköz: %s",
+		"there should be between 1 and %d steps": "1 és %d közötti számú lépés legyen",
+		"step %q should be a duration between 1m and 30d, e.g. 10m, 2h or 1d":                                                     "a(z) %q lépés 1m és 30d közötti időtartam legyen, pl. 10m, 2h vagy 1d",
+		"Enter the intervals between reviews of new and forgotten cards before they are learned, e.g. \"1m 10m 1d\", or \"off\".": "Add meg az új és elfelejtett kártyák ismétlései közötti időközöket, amíg meg nem tanulod őket, pl. \"1m 10m 1d\", vagy \"off\".",
+		"Learning steps: %s": "Tanulási lépések: %s",
+		"answer should be \"added\", \"random\" or \"frequent\"": "a válasz \"added\", \"random\" vagy \"frequent\" legyen",
+		"In which order should new cards come to practice? Enter \"added\" for the order you added them in, \"random\" or \"frequent\" for the words that are more common in sentences first.": "Milyen sorrendben kerüljenek gyakorlásra az új kártyák? Írd be: \"added\" a hozzáadás sorrendjéhez, \"random\", vagy \"frequent\", hogy a mondatokban gyakoribb szavak jöjjenek előbb.",
+		"in the order added":                       "hozzáadás sorrendjében",
+		"in random order":                          "véletlen sorrendben",
+		"most frequent first":                      "a leggyakoribbak előbb",
+		"New cards: %s":                            "Új kártyák: %s",
+		"hour should be a number between 0 and 23": "az óra 0 és 23 közötti szám legyen",
+		"At which hour should your day start for streaks, daily goals and forecasts? Enter a number between 0 and 23, e.g. 4 to count practice until 4 in the morning to the previous day.": "Hány órakor kezdődjön a napod a sorozatok, napi célok és előrejelzések szempontjából? Adj meg egy számot 0 és 23 között, pl. 4-et, hogy a hajnali 4-ig tartó gyakorlás az előző naphoz számítson.",
+		"Day starts at: %02d:00": "A nap kezdete: %02d:00",
+		"Your %d-day streak ends at %02d:00, practice a word to keep it!": "A %d napos sorozatod %02d:00-kor megszakad, gyakorolj legalább egy szót, hogy megtartsd!",
+		"unknown day %q, days are mon, tue, wed, thu, fri, sat and sun":   "ismeretlen nap: %q, a napok: mon, tue, wed, thu, fri, sat és sun",
+		"Your first card is ready for practice!":                          "Az első kártyád gyakorlásra kész!",
+		"Card in reminders: %s":                                           "Kártya az emlékeztetőkben: %s",
+		"Do you want reminders to show the first card ready for practice, so that you can answer it right away? Enter \"on\" or \"off\".": "Szeretnéd, hogy az emlékeztetők megmutassák az első gyakorlásra kész kártyát, hogy rögtön válaszolhass? Írd be: \"on\" vagy \"off\".",
+		"Reminders to practice are now sent only if you choose when. Use /availability to set them up.":                                   "Gyakorlási emlékeztetőket mostantól csak akkor küldök, ha kiválasztod, mikor. Használd az /availability parancsot a beállításukhoz.",
+		"%s looks like a form of %s":                   "%s valószínűleg a(z) %s egyik alakja",
+		"Send the photo with the word as its caption.": "Küldd el a fotót a szóval a képaláírásban.",
+		"Enter the word to attach an image to.":        "Add meg a szót, amelyhez képet csatolsz.",
+		"Send the photo for the front of the card.":    "Küldd el a fotót a kártya elejére.",
+		"Send a photo, please.":                        "Kérlek, küldj egy fotót.",
+		"Attached the image to %q.":                    "A kép hozzá van csatolva ehhez: %q.",
+		"Attach image":                                 "Kép csatolása",
+		"Which word is it?":                            "Melyik szó ez?",
+		"Set up syncing with /anki first.":             "Előbb állítsd be a szinkronizálást az /anki paranccsal.",
+		"Couldn't sync with Anki: %s. Make sure that Anki is running and AnkiConnect can be reached from the internet.": "Nem sikerült szinkronizálni az Ankival: %s. Győződj meg róla, hogy az Anki fut, és az AnkiConnect elérhető az internetről.",
+		"Syncing with Anki isn't enabled.":                                                                                "Az Ankival való szinkronizálás nincs engedélyezve.",
+		"Synced with Anki: %d new cards sent, %d reviews received.":                                                       "Szinkronizálva az Ankival: %d új kártya elküldve, %d ismétlés érkezett.",
+		"Your cards aren't synced with Anki.":                                                                             "A kártyáid nincsenek szinkronizálva az Ankival.",
+		"Stopped syncing with Anki, the cards in Anki are kept.":                                                          "Az Ankival való szinkronizálás leállt, az Ankiban lévő kártyák megmaradnak.",
+		"Enter the address of AnkiConnect, e.g. http://203.0.113.5:8765. It has to be reachable from the internet.":       "Add meg az AnkiConnect címét, pl. http://203.0.113.5:8765. Elérhetőnek kell lennie az internetről.",
+		"The address should start with http:// or https://. Please try again.":                                            "A címnek http:// vagy https:// kezdetűnek kell lennie. Próbáld újra.",
+		"Enter the API key of AnkiConnect or \"none\" if it has none.":                                                    "Add meg az AnkiConnect API-kulcsát, vagy \"none\", ha nincs.",
+		"Enter the name of the Anki deck for the cards.":                                                                  "Add meg a kártyák Anki-paklijának nevét.",
+		"Which should schedule the reviews of the synced cards, \"bot\" or \"anki\"? The other one follows its schedule.": "Melyik ütemezze a szinkronizált kártyák ismétlését, \"bot\" vagy \"anki\"? A másik az ő ütemezését követi.",
+		"Answer should be \"bot\" or \"anki\". Please try again.":                                                         "A válasz \"bot\" vagy \"anki\" legyen. Próbáld újra.",
+		"You've just looked up %q, the reply is above.":                                                                   "Épp most kerested ezt: %q, a válasz fent van.",
+		"Already done.":            "Már megtörtént.",
+		"The message was deleted.": "Az üzenetet törölték.",
+	},
+	"deu": {
+		"Know":                       "Weiß ich",
+		"Reset progress":             "Fortschritt zurücksetzen",
+		"Don't know":                 "Weiß ich nicht",
+		"Cancelled":                  "Abgebrochen",
+		"Saved %q for learning":      "%q zum Lernen gespeichert",
+		"Learn":                      "Lernen",
+		"Start practice":             "Übung starten",
+		"%s is not a known command.": "%s ist kein bekannter Befehl.",
+		"Did you mean %s?":           "Meintest du %s?",
+		" or ":                       " oder ",
+		"Nothing to cancel.":         "Nichts abzubrechen.",
+		"Cancelled %s.":              "%s abgebrochen.",
+		"No more rows to practice; exiting practice mode.": "Nichts mehr zu üben; Übungsmodus wird beendet.",
+		"unlimited":                              "unbegrenzt",
+		"%d characters":                          "%d Zeichen",
+		"off":                                    "aus",
+		"%d per day":                             "%d pro Tag",
+		"between %s":                             "zwischen %s",
+		"on":                                     "an",
+		"Input language: %q":                     "Eingabesprache: %q",
+		"Input language in ISO 639-3: %q":        "Eingabesprache in ISO 639-3: %q",
+		"Translation languages in ISO 639-3: %s": "Übersetzungssprachen in ISO 639-3: %s",
+		"Time Zone: %s":                          "Zeitzone: %s",
+		"Max usage example length: %s":           "Max. Länge der Beispiele: %s",
+		"Reminders: %s":                          "Erinnerungen: %s",
+		"Weekly summary: %s":                     "Wochenübersicht: %s",
+		"Bot language: %s":                       "Sprache des Bots: %s",
+		"Current settings:":                      "Aktuelle Einstellungen:",
+		"To modify settings use one of the commands below:":                  "Um die Einstellungen zu ändern, verwende einen der folgenden Befehle:",
+		"Tap the language to toggle translations of usage examples into it.": "Tippe auf eine Sprache, um Übersetzungen der Beispiele in diese Sprache ein- oder auszuschalten.",
+		"%s. Please try again.": "%s. Bitte versuche es erneut.",
+		"Enter front of the card (word, expression, question) or send a photo with the word as its caption.": "Gib die Vorderseite der Karte ein (Wort, Ausdruck, Frage) oder sende ein Foto mit dem Wort als Bildunterschrift.",
+		"Enter back of the card (definition, answer).":                                                       "Gib die Rückseite der Karte ein (Definition, Antwort).",
+		"Added %q for learning!":                              "%q zum Lernen hinzugefügt!",
+		"Enter the word you want to delete from learning!":    "Gib das Wort ein, das du aus dem Lernen entfernen möchtest!",
+		"Word %q isn't saved for learning!":                   "Das Wort %q ist nicht zum Lernen gespeichert!",
+		"Delete %q from learning? All progress will be lost.": "%q aus dem Lernen entfernen? Der gesamte Fortschritt geht verloren.",
+		"Deleted %q!":               "%q gelöscht!",
+		"Progress for %q was reset": "Fortschritt für %q wurde zurückgesetzt",
+		"For now this bot doesn't work with expressions. Try entering a single work without spaces.": "Der Bot kann vorerst nicht mit Ausdrücken umgehen. Gib ein einzelnes Wort ohne Leerzeichen ein.",
+		"Couldn't find definitions.": "Keine Definitionen gefunden.",
+		"Welcome to the language bot. Still in development. No instructions so far. All sentences and translations are from Tatoeba's (https://tatoeba.org) dataset, released under a CC-BY 2.0 FR.": "Willkommen beim Sprach-Bot. Er befindet sich noch in Entwicklung. Anleitungen gibt es noch keine. Alle Sätze und Übersetzungen stammen aus dem Datensatz von Tatoeba (https://tatoeba.org), veröffentlicht unter CC-BY 2.0 FR.",
+		"Enter ISO 639-3 codes of languages into which to translate usage examples separated by comma (e.g. \"eng,deu\") or \"default\" to use the defaults for your input language.":                "Gib die ISO 639-3 Codes der Sprachen, in die Beispiele übersetzt werden sollen, durch Komma getrennt ein (z. B. \"eng,deu\") oder \"default\" für die Standardwerte deiner Eingabesprache.",
+		"When can I send you reminders to practice? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00, or \"off\" to not receive reminders.":                            "Wann darf ich dir Erinnerungen zum Üben schicken? Gib einen Zeitraum in deiner Zeitzone im Format HH:MM-HH:MM ein, z. B. 09:00-21:00, oder \"off\", um keine Erinnerungen zu erhalten.",
+		"All set! Send me a word to get its definition.": "Alles bereit! Schick mir ein Wort, um seine Definition zu erhalten.",
+		"Let's set things up first.":                     "Lass uns zuerst alles einrichten.",
+		"Enter input language of your choice. Supported are %s or any other language by its ISO 639-3 code (e.g. \"fra\")": "Gib die gewünschte Eingabesprache ein. Unterstützt werden %s oder jede andere Sprache über ihren ISO 639-3 Code (z. B. \"fra\")",
+		"Input your timezone in one of the formats: UTC, UTC+X or UTC-X.":                                                  "Gib deine Zeitzone in einem der Formate UTC, UTC+X oder UTC-X ein.",
+		"When can I send you reminders? Enter time range in your time zone in the format HH:MM-HH:MM, e.g. 09:00-21:00. To limit it to some days, put them before the range, and separate several ranges with \";\", e.g. mon-fri 07:00-09:00; sat,sun 10:00-12:00.": "Wann darf ich dir Erinnerungen schicken? Gib einen Zeitraum in deiner Zeitzone im Format HH:MM-HH:MM ein, z. B. 09:00-21:00. Um ihn auf bestimmte Tage zu beschränken, gib sie vor dem Zeitraum an, und trenne mehrere Zeiträume mit \";\", z. B. mon-fri 07:00-09:00; sat,sun 10:00-12:00.",
+		"How many times a day should I remind you to practice? Enter a number between 1 and %d or \"off\" to stop reminders.":                                                                                                                                        "Wie oft am Tag soll ich dich ans Üben erinnern? Gib eine Zahl zwischen 1 und %d ein oder \"off\", um Erinnerungen zu beenden.",
+		"Do you want to receive a weekly summary of your progress? Enter \"on\" or \"off\".":                                                                                                                                                                         "Möchtest du eine wöchentliche Übersicht über deinen Fortschritt erhalten? Gib \"on\" oder \"off\" ein.",
+		"Enter the language of the bot interface. Supported are %s.":                                                                                                                                                                                                 "Gib die Sprache der Bot-Oberfläche ein. Unterstützt werden %s.",
+		"Input maximum length of usage examples in characters (0 to show examples of any length).":                                                                                                                                                                   "Gib die maximale Länge der Beispiele in Zeichen ein (0, um Beispiele beliebiger Länge anzuzeigen).",
+		"Stopped. Input the word to get it's definition.":                                                                                                                                                                                                            "Beendet. Gib ein Wort ein, um seine Definition zu erhalten.",
+		"#%d by %s":                             "#%d von %s",
+		"[truncated %d definitions]":            "[%d Definitionen ausgeblendet]",
+		"Usage examples:":                       "Beispiele:",
+		"Didn't find usage examples.":           "Keine Beispiele gefunden.",
+		"unsupported interface language %q":     "nicht unterstützte Oberflächensprache %q",
+		"unsupported language %q":               "nicht unterstützte Sprache %q",
+		"You have %d words ready for practice!": "%d Wörter sind bereit zum Üben!",
+		"You have 1 word ready for practice!":   "1 Wort ist bereit zum Üben!",
+		"Your week in review:":                  "Deine Woche im Rückblick:",
+		"Reviews done: %d":                      "Wiederholungen: %d",
+		"New words added: %d":                   "Neue Wörter: %d",
+		"Retention: %.0f%%":                     "Behaltensquote: %.0f%%",
+		"Longest streak: %d days":               "Längste Serie: %d Tage",
+		"Hardest words: %s":                     "Schwierigste Wörter: %s",
+		"availability window should be in the format HH:MM-HH:MM, e.g. 09:00-21:00": "der Zeitraum muss im Format HH:MM-HH:MM angegeben werden, z. B. 09:00-21:00",
+		"unsupported time zone (format should be UTC, UTC+X or UTC-X)":              "nicht unterstützte Zeitzone (Format: UTC, UTC+X oder UTC-X)",
+		"length should be a number between 0 and %d":                                "die Länge muss eine Zahl zwischen 0 und %d sein",
+		"unsupported translation language %q":                                       "nicht unterstützte Übersetzungssprache %q",
+		"at least one translation language should stay enabled":                     "mindestens eine Übersetzungssprache muss aktiviert bleiben",
+		"no languages entered":                                                      "keine Sprachen eingegeben",
+		"%q is not an ISO 639-3 code":                                               "%q ist kein ISO 639-3 Code",
+		"frequency should be \"off\" or a number between 1 and %d":                  "die Häufigkeit muss \"off\" oder eine Zahl zwischen 1 und %d sein",
+		"answer should be \"on\" or \"off\"":                                        "die Antwort muss \"on\" oder \"off\" sein",
+		"No":                                                                        "Nein",
+		"Yes":                                                                       "Ja",
+		"too many messages, please wait a bit":                                      "zu viele Nachrichten, bitte warte kurz",
+		"%s is available only to admins":                                            "%s ist nur für Administratoren verfügbar",
+		"No words are due for practice, nothing to quiz you on.":                    "Keine Wörter sind fällig, es gibt nichts abzufragen.",
+		"Quiz finished! Score: %d/%d.":                                              "Quiz beendet! Ergebnis: %d/%d.",
+		"Time: %s.":                                                                 "Zeit: %s.",
+		"Words to revisit: %s.":                                                     "Zu wiederholende Wörter: %s.",
+		"This quiz is over.":                                                        "Dieses Quiz ist bereits beendet.",
+		"Enter the word to define.":                                                 "Gib das Wort ein, das definiert werden soll.",
+		"Updated the card for %q":                                                   "Karte für %q aktualisiert",
+		"Update card":                                                               "Karte aktualisieren",
+		"You have no saved words yet.":                                              "Du hast noch keine gespeicherten Wörter.",
+		"Show answer":                                                               "Antwort zeigen",
+		"Your review forecast:":                                                     "Deine Wiederholungsprognose:",
+		"Due now: %d":                                                               "Jetzt fällig: %d",
+		"Later today: %d":                                                           "Später heute: %d",
+		"Tomorrow: %d":                                                              "Morgen: %d",
+		"Next 7 days: %d":                                                           "Nächste 7 Tage: %d",
+		"Delete all your data? Saved words, progress, settings and reminders will be lost for good.": "Alle deine Daten löschen? Gespeicherte Wörter, Fortschritt, Einstellungen und Erinnerungen gehen endgültig verloren.",
+		"All your data was deleted. Send /start to set up the bot again.":                            "Alle deine Daten wurden gelöscht. Sende /start, um den Bot neu einzurichten.",
+		"Current streak: %d days":                                          "Aktuelle Serie: %d Tage",
+		"Best streak: %d days":                                             "Beste Serie: %d Tage",
+		"Practice today to keep your streak!":                              "Übe heute, um deine Serie zu halten!",
+		"Your %d-day streak ends at midnight, practice a word to keep it!": "Deine %d-Tage-Serie endet um Mitternacht, übe ein Wort, um sie zu halten!",
+		"Your share code is %s. Others can copy your words with /subscribe %s, /unshare stops sharing.": "Dein Freigabecode ist %s. Andere können deine Wörter mit /subscribe %s kopieren, /unshare beendet die Freigabe.",
+		"Your words aren't shared anymore.": "Deine Wörter sind nicht mehr freigegeben.",
+		"Your words aren't shared.":         "Deine Wörter sind nicht freigegeben.",
+		"Enter the share code of the deck.": "Gib den Freigabecode des Decks ein.",
+		"there is no deck with the code %q": "es gibt kein Deck mit dem Code %q",
+		"this is your own deck":             "das ist dein eigenes Deck",
+		"Enter \"copy\" to copy the words once or \"follow\" to also get the words added later.": "Gib \"copy\" ein, um die Wörter einmal zu kopieren, oder \"follow\", um auch später hinzugefügte Wörter zu bekommen.",
+		"expected \"copy\" or \"follow\"":                                               "\"copy\" oder \"follow\" erwartet",
+		"Copied %d words for learning.":                                                 "%d Wörter zum Lernen kopiert.",
+		"Words added to the deck later will be copied too, /unsubscribe %s stops that.": "Später zum Deck hinzugefügte Wörter werden auch kopiert, /unsubscribe %s beendet das.",
+		"you don't follow the deck %q":                                                  "du folgst dem Deck %q nicht",
+		"New words of the deck won't be copied anymore, the copied ones stay.":          "Neue Wörter des Decks werden nicht mehr kopiert, die kopierten bleiben.",
+		"Imported %d words.":                                                            "%d Wörter importiert.",
+		"Already saved: %d.":                                                            "Bereits gespeichert: %d.",
+		"No definitions found for: %s.":                                                 "Keine Definitionen gefunden für: %s.",
+		"%d words were left out, import at most %d words at once.":                      "%d Wörter wurden ausgelassen, es können höchstens %d Wörter auf einmal importiert werden.",
+		"Send vocab.db from your Kindle (in the system folder) or its CSV export to create cards for the looked up words.": "Sende vocab.db von deinem Kindle (im Ordner system) oder seinen CSV-Export, um Karten für die nachgeschlagenen Wörter zu erstellen.",
+		"send the file as a document or /cancel":                  "sende die Datei als Dokument oder /cancel",
+		"the file is too large":                                   "die Datei ist zu groß",
+		"couldn't read words from the file":                       "aus der Datei konnten keine Wörter gelesen werden",
+		"no words in the file":                                    "keine Wörter in der Datei",
+		"Importing %d words, it can take a while.":                "%d Wörter werden importiert, das kann eine Weile dauern.",
+		"Importing %d words: %d done.":                            "%d Wörter werden importiert: %d fertig.",
+		"The import failed, try again later.":                     "Der Import ist fehlgeschlagen, versuchen Sie es später erneut.",
+		"another import is in progress, wait for it to finish":    "ein anderer Import läuft noch, warten Sie, bis er fertig ist",
+		"%q is already saved: stage %d of %d, next review on %s.": "%q ist bereits gespeichert: Stufe %d von %d, nächste Wiederholung am %s.",
+		"Added the definition to the card for %q":                 "Die Definition wurde zur Karte für %q hinzugefügt",
+		"Append this definition":                                  "Diese Definition anhängen",
+		"Kept the card for %q as is":                              "Die Karte für %q bleibt unverändert",
+		"Keep as is":                                              "So lassen",
+		"Other studied languages in ISO 639-3: %s":                "Weitere gelernte Sprachen in ISO 639-3: %s",
+		"none": "keine",
+		"Enter the language to study in addition to the current ones. It becomes the input language, words not found in it are looked up in the others.": "Gib die Sprache ein, die du zusätzlich zu den aktuellen lernen möchtest. Sie wird zur Eingabesprache, Wörter, die darin nicht gefunden werden, werden in den anderen gesucht.",
+		"Tap the language to look up words in it first. Add languages with /addlanguage, practice one of them with /practice <language>.":                "Tippe auf eine Sprache, um Wörter zuerst in ihr nachzuschlagen. Füge Sprachen mit /addlanguage hinzu, übe eine davon mit /practice <Sprache>.",
+		"You study only %s, use /language to change it.":            "Du lernst nur %s, ändere sie mit /language.",
+		"Tap the language to stop studying it. Its cards are kept.": "Tippe auf eine Sprache, um sie nicht mehr zu lernen. Ihre Karten bleiben erhalten.",
+		"%s is not studied": "%s wird nicht gelernt",
+		"can't remove the only studied language, use /language to change it": "die einzige gelernte Sprache kann nicht entfernt werden, ändere sie mit /language",
+		"Session finished! Known: %d of %d (%d%%).":                          "Sitzung beendet! Gewusst: %d von %d (%d%%).",
+		"Still due: %d": "Noch fällig: %d",
+		"Continue":      "Weiter",
+		"How many cards should a practice session have? Enter a number between 1 and %d.": "Wie viele Karten soll eine Übungssitzung haben? Gib eine Zahl zwischen 1 und %d ein.",
+		"length should be a number between 1 and %d":                                      "die Länge muss eine Zahl zwischen 1 und %d sein",
+		"Practice session: %d cards":                                                      "Übungssitzung: %d Karten",
+		"Study ahead":                                                                     "Vorausarbeiten",
+		"vacation should be \"off\" or a number of days between 1 and %d":                 "Urlaub sollte \"off\" oder eine Anzahl von Tagen zwischen 1 und %d sein",
+		"paused until %s":                                                                 "pausiert bis %s",
+		"For how many days are you going away? Enter a number between 1 and %d or \"off\" to end the vacation.": "Für wie viele Tage bist du weg? Gib eine Zahl zwischen 1 und %d ein oder \"off\", um den Urlaub zu beenden.",
+		"Welcome back! Reminders are resumed.":                                                   "Willkommen zurück! Die Erinnerungen sind wieder aktiv.",
+		"Enjoy your vacation! %d words are postponed by %d days, reminders are paused until %s.": "Schönen Urlaub! %d Wörter sind um %d Tage verschoben, Erinnerungen pausieren bis %s.",
+		"default":       "Standard",
+		"Leitner boxes": "Leitner-Kästen",
+		"Scheduler: %s": "Wiederholungsplan: %s",
+		"answer should be \"default\" or \"leitner\"": "Antwort sollte \"default\" oder \"leitner\" sein",
+		"How should I schedule the reviews? Enter \"default\" to move the words through the stages of learning or \"leitner\" for 5 Leitner boxes reviewed every 1, 2, 4, 8 and 16 days.": "Wie soll ich die Wiederholungen planen? Gib \"default\" ein, damit die Wörter die Lernstufen durchlaufen, oder \"leitner\" für 5 Leitner-Kästen, die alle 1, 2, 4, 8 und 16 Tage wiederholt werden.",
+		"Show more definitions":              "Weitere Definitionen zeigen",
+		"Pick definition":                    "Definition wählen",
+		"Enter the word to add a note to.":   "Gib das Wort ein, zu dem eine Notiz hinzugefügt werden soll.",
+		"Enter the note to add to the card.": "Gib die Notiz für die Karte ein.",
+		"Added the note to %q.":              "Notiz zu %q hinzugefügt.",
+		"Add note":                           "Notiz hinzufügen",
+		"Sentences in practice: %s":          "Sätze beim Üben: %s",
+		"Do you want to practice with sentences in which the word is blanked out, when there are any? Enter \"on\" or \"off\".": "Möchtest du mit Sätzen üben, in denen das Wort ausgelassen ist, falls es welche gibt? Gib \"on\" oder \"off\" ein.",
+		"Save looked up words: %s": "Nachgeschlagene Wörter speichern: %s",
+		"Do you want to save every word you look up for learning without pressing Learn? Enter \"on\" or \"off\".": "Möchtest du jedes nachgeschlagene Wort zum Lernen speichern, ohne Learn zu drücken? Gib \"on\" oder \"off\" ein.",
+		"%s: %d%% (%d of %d)":                   "%s: %d%% (%d von %d)",
+		"You haven't practiced any words yet.":  "Du hast noch keine Wörter geübt.",
+		"Known answers by the previous answer:": "Gewusste Antworten nach der vorherigen Antwort:",
+		"After Know":                            "Nach „Weiß ich“",
+		"After Don't know":                      "Nach „Weiß ich nicht“",
+		"First review":                          "Erste Wiederholung",
+		"Known answers by the time since the previous review:": "Gewusste Antworten nach der Zeit seit der vorherigen Wiederholung:",
+		"Less than a day":                     "Weniger als ein Tag",
+		"1 to 7 days":                         "1 bis 7 Tage",
+		"7 to 30 days":                        "7 bis 30 Tage",
+		"More than 30 days":                   "Mehr als 30 Tage",
+		"plural %s":                           "Plural %s",
+		"Transliteration of translations: %s": "Transliteration der Übersetzungen: %s",
+		"Do you want translations of usage examples in Russian and Ukrainian to be followed by their transliteration into latin letters? Enter \"on\" or \"off\".": "Sollen Übersetzungen von Beispielsätzen ins Russische und Ukrainische von ihrer Transliteration in lateinische Buchstaben gefolgt werden? Gib \"on\" oder \"off\" ein.",
+		"no definitions found":               "keine Definitionen gefunden",
+		"Learn %s":                           "%s lernen",
+		"%q is already saved":                "%q ist bereits gespeichert",
+		"The API isn't enabled on this bot.": "Die API ist bei diesem Bot nicht aktiviert.",
+		"Your API token, keep it secret:\n%s\n\nSend it in the Authorization: Bearer header. /token again replaces it.": "Dein API-Token, halte es geheim:\n%s\n\nSende es im Header Authorization: Bearer. Ein erneutes /token ersetzt es.",
+		"The dashboard isn't enabled on this bot.":                        "Das Dashboard ist bei diesem Bot nicht aktiviert.",
+		"Open your dashboard within %d minutes, the link works once:\n%s": "Öffne dein Dashboard innerhalb von %d Minuten, der Link funktioniert einmal:\n%s",
+		"Reviews in the last %d weeks":                                    "Wiederholungen in den letzten %d Wochen",
+		"Cards: %d":                                                       "Karten: %d",
+		"Search":                                                          "Suchen",
+		"Word":                                                            "Wort",
+		"Definition":                                                      "Definition",
+		"Next review":                                                     "Nächste Wiederholung",
+		"Later":                                                           "Später",
+		"%q will be back in %d minutes":                                   "%q kommt in %d Minuten wieder",
+		"Nothing else to practice now, the cards put off for later come back in a few minutes.": "Gerade gibt es nichts mehr zu üben, die zurückgestellten Karten kommen in ein paar Minuten wieder.",
+		"☆ Star":       "☆ Markieren",
+		"★ Unstar":     "★ Markierung entfernen",
+		"Starred %q":   "%q markiert",
+		"Unstarred %q": "Markierung von %q entfernt",
+		"No starred cards yet. Star cards with the ☆ Star button under them.": "Noch keine markierten Karten. Markiere Karten mit der Schaltfläche ☆ Markieren darunter.",
+		"Starred cards: %d":                          "Markierte Karten: %d",
+		"… and %d more":                              "… und %d weitere",
+		"stage %d":                                   "Stufe %d",
+		"Practice starred":                           "Markierte üben",
+		"No more starred cards to practice.":         "Keine markierten Karten mehr zum Üben.",
+		"Recognizing words on photos isn't enabled.": "Die Erkennung von Wörtern auf Fotos ist nicht aktiviert.",
+		"Couldn't recognize the text on the photo, try again later.": "Der Text auf dem Foto konnte nicht erkannt werden, versuche es später noch einmal.",
+		"Couldn't find any words on the photo.":                      "Auf dem Foto wurden keine Wörter gefunden.",
+		"Tap a word to define it:":                                   "Tippe auf ein Wort, um es nachzuschlagen:",
+		"🔊 Listen":                                                   "🔊 Anhören",
+		"Audio isn't available anymore.":                             "Audio ist nicht mehr verfügbar.",
+		"🔊 Which word did you hear?":                                 "🔊 Welches Wort hast du gehört?",
+		"Listening practice: %s":                                     "Hörübung: %s",
+		"Do you want to hear the words in practice instead of reading them, for the languages with a voice? Enter \"on\" or \"off\".": "Möchtest du die Wörter beim Üben hören statt lesen, für Sprachen mit einer Stimme? Gib \"on\" oder \"off\" ein.",
+		"Save at least 2 words to play.":                                           "Speichere mindestens 2 Wörter, um zu spielen.",
+		"Match the words with their definitions: tap a word, then its definition.": "Ordne die Wörter ihren Bedeutungen zu: tippe auf ein Wort, dann auf seine Bedeutung.",
+		"This game is over.":     "Dieses Spiel ist vorbei.",
+		"Tap a word first.":      "Tippe zuerst auf ein Wort.",
+		"Not a pair, try again.": "Das ist kein Paar, versuche es noch einmal.",
+		"All pairs matched! Right on the first try: %d of %d. Time: %s.":                            "Alle Paare gefunden! Beim ersten Versuch richtig: %d von %d. Zeit: %s.",
+		"goal should be \"off\" or a number between 1 and %d":                                       "das Ziel sollte \"off\" oder eine Zahl zwischen 1 und %d sein",
+		"How many reviews do you want to do every day? Enter a number between 1 and %d or \"off\".": "Wie viele Wiederholungen möchtest du jeden Tag machen? Gib eine Zahl zwischen 1 und %d oder \"off\" ein.",
+		"%d reviews":     "%d Wiederholungen",
+		"Daily goal: %s": "Tagesziel: %s",
+		"Daily goal reached: %d of %d reviews today.":                        "Tagesziel erreicht: heute %d von %d Wiederholungen.",
+		"Daily goal: %d of %d reviews today.":                                "Tagesziel: heute %d von %d Wiederholungen.",
+		"%d more reviews to reach your daily goal of %d!":                    "Noch %d Wiederholungen bis zu deinem Tagesziel von %d!",
+		"the number of cards should be \"off\" or a number between 1 and %d": "die Anzahl der Karten sollte \"off\" oder eine Zahl zwischen 1 und %d sein",
+		"How many overdue cards do you want to practice a day? The rest are spread over the following days. Enter a number between 1 and %d or \"off\".": "Wie viele überfällige Karten möchtest du pro Tag üben? Der Rest wird auf die folgenden Tage verteilt. Gib eine Zahl zwischen 1 und %d oder \"off\" ein.",
+		"%d overdue cards per day": "%d überfällige Karten pro Tag",
+		"Catch-up: %s":             "Aufholen: %s",
+		"interval should be \"off\" or a number of days between 1 and %d":                                          "das Intervall sollte \"off\" oder eine Anzahl von Tagen zwischen 1 und %d sein",
+		"How many days at most should pass between reviews of a card? Enter a number between 1 and %d or \"off\".": "Wie viele Tage sollen höchstens zwischen den Wiederholungen einer Karte liegen? Gib eine Zahl zwischen 1 und %d oder \"off\" ein.",
+		"%d days":                                "%d Tage",
+		"Maximum interval: %s":                   "Maximales Intervall: %s",
+		"there should be between 1 and %d steps": "es sollten zwischen 1 und %d Schritte sein",
+		"step %q should be a duration between 1m and 30d, e.g. 10m, 2h or 1d":                                                     "Schritt %q sollte eine Dauer zwischen 1m und 30d sein, z. B. 10m, 2h oder 1d",
+		"Enter the intervals between reviews of new and forgotten cards before they are learned, e.g. \"1m 10m 1d\", or \"off\".": "Gib die Intervalle zwischen den Wiederholungen neuer und vergessener Karten ein, bis sie gelernt sind, z. B. \"1m 10m 1d\", oder \"off\".",
+		"Learning steps: %s": "Lernschritte: %s",
+		"answer should be \"added\", \"random\" or \"frequent\"": "die Antwort sollte \"added\", \"random\" oder \"frequent\" sein",
+		"In which order should new cards come to practice? Enter \"added\" for the order you added them in, \"random\" or \"frequent\" for the words that are more common in sentences first.": "In welcher Reihenfolge sollen neue Karten geübt werden? Gib \"added\" für die Reihenfolge des Hinzufügens ein, \"random\" oder \"frequent\", damit in Sätzen häufigere Wörter zuerst kommen.",
+		"in the order added":                       "in der Reihenfolge des Hinzufügens",
+		"in random order":                          "in zufälliger Reihenfolge",
+		"most frequent first":                      "häufigste zuerst",
+		"New cards: %s":                            "Neue Karten: %s",
+		"hour should be a number between 0 and 23": "die Stunde sollte eine Zahl zwischen 0 und 23 sein",
+		"At which hour should your day start for streaks, daily goals and forecasts? Enter a number between 0 and 23, e.g. 4 to count practice until 4 in the morning to the previous day.": "Zu welcher Stunde soll dein Tag für Serien, Tagesziele und Prognosen beginnen? Gib eine Zahl zwischen 0 und 23 ein, z. B. 4, damit Üben bis 4 Uhr morgens zum Vortag zählt.",
+		"Day starts at: %02d:00": "Tagesbeginn: %02d:00",
+		"Your %d-day streak ends at %02d:00, practice a word to keep it!": "Deine %d-Tage-Serie endet um %02d:00, übe ein Wort, um sie zu halten!",
+		"unknown day %q, days are mon, tue, wed, thu, fri, sat and sun":   "unbekannter Tag %q, Tage sind mon, tue, wed, thu, fri, sat und sun",
+		"Your first card is ready for practice!":                          "Deine erste Karte ist bereit zum Üben!",
+		"Card in reminders: %s":                                           "Karte in Erinnerungen: %s",
+		"Do you want reminders to show the first card ready for practice, so that you can answer it right away? Enter \"on\" or \"off\".": "Sollen Erinnerungen die erste Karte zeigen, die zum Üben bereit ist, damit du sie gleich beantworten kannst? Gib \"on\" oder \"off\" ein.",
+		"Reminders to practice are now sent only if you choose when. Use /availability to set them up.":                                   "Erinnerungen zum Üben werden jetzt nur gesendet, wenn du auswählst, wann. Verwende /availability, um sie einzurichten.",
+		"%s looks like a form of %s":                   "%s scheint eine Form von %s zu sein",
+		"Send the photo with the word as its caption.": "Sende das Foto mit dem Wort als Bildunterschrift.",
+		"Enter the word to attach an image to.":        "Gib das Wort ein, an das ein Bild angehängt werden soll.",
+		"Send the photo for the front of the card.":    "Sende das Foto für die Vorderseite der Karte.",
+		"Send a photo, please.":                        "Bitte sende ein Foto.",
+		"Attached the image to %q.":                    "Das Bild wurde an %q angehängt.",
+		"Attach image":                                 "Bild anhängen",
+		"Which word is it?":                            "Welches Wort ist das?",
+		"Set up syncing with /anki first.":             "Richte die Synchronisierung zuerst mit /anki ein.",
+		"Couldn't sync with Anki: %s. Make sure that Anki is running and AnkiConnect can be reached from the internet.": "Synchronisierung mit Anki fehlgeschlagen: %s. Stelle sicher, dass Anki läuft und AnkiConnect aus dem Internet erreichbar ist.",
+		"Syncing with Anki isn't enabled.":                                                                                "Die Synchronisierung mit Anki ist nicht aktiviert.",
+		"Synced with Anki: %d new cards sent, %d reviews received.":                                                       "Mit Anki synchronisiert: %d neue Karten gesendet, %d Wiederholungen empfangen.",
+		"Your cards aren't synced with Anki.":                                                                             "Deine Karten werden nicht mit Anki synchronisiert.",
+		"Stopped syncing with Anki, the cards in Anki are kept.":                                                          "Die Synchronisierung mit Anki wurde beendet, die Karten in Anki bleiben erhalten.",
+		"Enter the address of AnkiConnect, e.g. http://203.0.113.5:8765. It has to be reachable from the internet.":       "Gib die Adresse von AnkiConnect ein, z. B. http://203.0.113.5:8765. Sie muss aus dem Internet erreichbar sein.",
+		"The address should start with http:// or https://. Please try again.":                                            "Die Adresse sollte mit http:// oder https:// beginnen. Bitte versuche es erneut.",
+		"Enter the API key of AnkiConnect or \"none\" if it has none.":                                                    "Gib den API-Schlüssel von AnkiConnect ein oder \"none\", wenn es keinen gibt.",
+		"Enter the name of the Anki deck for the cards.":                                                                  "Gib den Namen des Anki-Stapels für die Karten ein.",
+		"Which should schedule the reviews of the synced cards, \"bot\" or \"anki\"? The other one follows its schedule.": "Wer soll die Wiederholungen der synchronisierten Karten planen, \"bot\" oder \"anki\"? Die andere Seite folgt diesem Zeitplan.",
+		"Answer should be \"bot\" or \"anki\". Please try again.":                                                         "Die Antwort sollte \"bot\" oder \"anki\" sein. Bitte versuche es erneut.",
+		"You've just looked up %q, the reply is above.":                                                                   "Du hast gerade %q nachgeschlagen, die Antwort steht oben.",
+		"Already done.":            "Bereits erledigt.",
+		"The message was deleted.": "Die Nachricht wurde gelöscht.",
+	},
+}
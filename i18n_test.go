@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var verbRe = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalog(t *testing.T) {
+	ref := catalog["rus"]
+	for lang, messages := range catalog {
+		if _, ok := BotLanguages[lang]; !ok {
+			t.Errorf("catalog has unknown language %q", lang)
+		}
+		for k, v := range messages {
+			if got, want := verbRe.FindAllString(v, -1), verbRe.FindAllString(k, -1); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s translation of %q has verbs %q, want %q", lang, k, got, want)
+			}
+		}
+		// All catalogs should translate the same messages.
+		for k := range ref {
+			if _, ok := messages[k]; !ok {
+				t.Errorf("%s is missing translation of %q", lang, k)
+			}
+		}
+	}
+	for lang := range BotLanguages {
+		if _, ok := catalog[lang]; !ok && lang != defaultBotLanguage {
+			t.Errorf("no catalog for %q", lang)
+		}
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	l := NewLocalizer("deu")
+	if got, want := l.T("Deleted %q!", "fekete"), `"fekete" gelöscht!`; got != want {
+		t.Errorf("T(Deleted): got %q want %q", got, want)
+	}
+	if got, want := l.T("not translated %d", 1), "not translated 1"; got != want {
+		t.Errorf("T(not translated): got %q want %q", got, want)
+	}
+	if got, want := NewLocalizer("xyz").T("Deleted %q!", "a"), `Deleted "a"!`; got != want {
+		t.Errorf("T for unknown language: got %q want %q", got, want)
+	}
+
+	err := fmt.Errorf("wrapped: %w", localizedErrorf("unsupported language %q", "Klingon"))
+	if got, want := l.Error(err), `nicht unterstützte Sprache "Klingon"`; got != want {
+		t.Errorf("Error(wrapped): got %q want %q", got, want)
+	}
+	if got, want := err.Error(), `wrapped: unsupported language "Klingon"`; got != want {
+		t.Errorf("err.Error(): got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Chats that blocked the bot. Reminders and broadcasts to them would only
+// fail, so they aren't sent until the user writes to the bot again.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// InactiveChats keeps the chats to which telegram refuses to deliver
+// messages, see errChatInactive. They are also kept in memory, since every
+// update checks whether its chat is back.
+type InactiveChats struct {
+	db    *sql.DB
+	clock Clock
+
+	mu    sync.Mutex
+	chats map[int64]bool
+}
+
+// NewInactiveChats loads the inactive chats from the database.
+func NewInactiveChats(db *sql.DB, clock Clock) (*InactiveChats, error) {
+	rows, err := db.Query(`SELECT chat_id FROM InactiveChats;`)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: loading inactive chats: %w", err)
+	}
+	defer rows.Close()
+	chats := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("INTERNAL: loading inactive chats: %w", err)
+		}
+		chats[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("INTERNAL: loading inactive chats: %w", err)
+	}
+	return &InactiveChats{db: db, clock: clock, chats: chats}, nil
+}
+
+// Has returns whether the chat is inactive.
+func (c *InactiveChats) Has(chatID int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chats[chatID]
+}
+
+// Count returns the number of inactive chats.
+func (c *InactiveChats) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.chats)
+}
+
+// Mark records that messages to the chat aren't delivered anymore.
+func (c *InactiveChats) Mark(chatID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.chats[chatID] {
+		return nil
+	}
+	if _, err := c.db.Exec(`
+		INSERT OR REPLACE INTO InactiveChats(chat_id, inactive_seconds)
+		VALUES($0, $1)`, chatID, c.clock.Now().Unix()); err != nil {
+		return fmt.Errorf("INTERNAL: marking chat %d inactive: %w", chatID, err)
+	}
+	c.chats[chatID] = true
+	return nil
+}
+
+// Clear makes the chat active again. It's cheap for active chats, which
+// aren't written to the database.
+func (c *InactiveChats) Clear(chatID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.chats[chatID] {
+		return nil
+	}
+	if _, err := c.db.Exec(`DELETE FROM InactiveChats WHERE chat_id = $0`, chatID); err != nil {
+		return fmt.Errorf("INTERNAL: marking chat %d active: %w", chatID, err)
+	}
+	delete(c.chats, chatID)
+	return nil
+}
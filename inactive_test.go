@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInactiveChats(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	settings, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inactive, err := NewInactiveChats(db, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{Settings: settings, Inactive: inactive}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const blocked, active int64 = 1, 2
+	for _, id := range []int64{blocked, active} {
+		if err := settings.Set(id, remindedSettings()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := make(map[int64]int)
+	r.sendNofication = func(n *Notification) error {
+		sent[n.ChatID]++
+		if n.ChatID == blocked {
+			return fmt.Errorf("sending: %w", &StatusError{Code: http.StatusForbidden, Description: "Forbidden: bot was blocked by the user"})
+		}
+		return nil
+	}
+	r.dueCount = func(int64) (int, error) { return 1, nil }
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r.clock = newFakeClock(now)
+	r.nextReview = func(int64) (time.Time, error) { return now.Add(-time.Hour), nil }
+
+	// Reminders are sent on the start of the loop and on every tick.
+	ticker := make(chan time.Time)
+	cancel := make(chan struct{})
+	go func() {
+		// The blocked chat would be reminded again right away.
+		ticker <- now
+		cancel <- struct{}{}
+	}()
+	r.Loop(ticker, cancel)
+	if sent[blocked] != 1 || sent[active] != 1 {
+		t.Errorf("Reminders sent: got %v want 1 to each chat", sent)
+	}
+	if !inactive.Has(blocked) || inactive.Has(active) {
+		t.Errorf("Has() = %t, %t want only chat %d inactive", inactive.Has(blocked), inactive.Has(active), blocked)
+	}
+
+	// The flag survives restarts.
+	loaded, err := NewInactiveChats(db, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Has(blocked) || loaded.Count() != 1 {
+		t.Errorf("Loaded inactive chats: got %d want chat %d", loaded.Count(), blocked)
+	}
+
+	// Any update from the chat makes it active again.
+	h := Reactivate(inactive)(func(*Update) error { return nil })
+	if err := h(messageUpdate(blocked, "/practice")); err != nil {
+		t.Fatal(err)
+	}
+	if inactive.Has(blocked) {
+		t.Error("Has() after an update = true want false")
+	}
+	if loaded, err = NewInactiveChats(db, SystemClock{}); err != nil || loaded.Count() != 0 {
+		t.Errorf("Loaded inactive chats after an update: got %v, %v want none", loaded, err)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Import of words looked up on Kindle, so that they can be practiced.
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxImportWords limits the number of words imported at once, every word is
+// looked up in the sources.
+const maxImportWords = 100
+
+// importProgressEvery is how often, in words, the progress of an import is
+// shown.
+const importProgressEvery = 20
+
+// sqliteMagic starts every SQLite database, e.g. Kindle's vocab.db.
+const sqliteMagic = "SQLite format 3\x00"
+
+// parseKindleVocab returns words from Kindle's vocab.db or its CSV export in
+// the order they were looked up, without duplicates. Stems are preferred, so
+// that the card is for "run" rather than for "running".
+func parseKindleVocab(data []byte) ([]string, error) {
+	var ws []string
+	var err error
+	if bytes.HasPrefix(data, []byte(sqliteMagic)) {
+		ws, err = parseVocabDB(data)
+	} else {
+		ws, err = parseVocabCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var res []string
+	for _, w := range ws {
+		w = strings.TrimSpace(w)
+		if w == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		res = append(res, w)
+	}
+	return res, nil
+}
+
+func parseVocabDB(data []byte) ([]string, error) {
+	dir, err := ioutil.TempDir("", "vocab")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "vocab.db")
+	if err := ioutil.WriteFile(p, data, 0600); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+p+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`
+		SELECT COALESCE(NULLIF(stem, ''), word)
+		FROM WORDS
+		ORDER BY timestamp`)
+	if err != nil {
+		return nil, fmt.Errorf("reading vocab.db: %w", err)
+	}
+	defer rows.Close()
+	var ws []string
+	for rows.Next() {
+		var w sql.NullString
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
+		}
+		ws = append(ws, w.String)
+	}
+	return ws, rows.Err()
+}
+
+// parseVocabCSV takes words from the "stem" or the "word" column if the file
+// has a header and from the first column otherwise.
+func parseVocabCSV(data []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	col := 0
+	var ws []string
+	for first := true; ; first = false {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		if first {
+			if c := headerColumn(rec); c >= 0 {
+				col = c
+				continue
+			}
+		}
+		if col < len(rec) {
+			ws = append(ws, rec[col])
+		}
+	}
+	return ws, nil
+}
+
+// headerColumn returns the column with words if rec is a header, -1 otherwise.
+func headerColumn(rec []string) int {
+	for _, name := range []string{"stem", "word"} {
+		for i, h := range rec {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// ImportResult is what happened to the words of an import.
+type ImportResult struct {
+	Imported int
+	// Words that were saved before.
+	Existing int
+	// Words without definitions in the sources.
+	NotFound []string
+	// Words over maxImportWords.
+	Skipped int
+}
+
+// importWords saves cards for the words with the first definition from the
+// sources as the back. progress is called with the number of words done after
+// each of them.
+func importWords(s *State, chatID int64, words []string, progress func(done int)) (*ImportResult, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return nil, err
+	}
+	res := &ImportResult{}
+	if len(words) > maxImportWords {
+		res.Skipped = len(words) - maxImportWords
+		words = words[:maxImportWords]
+	}
+	for i, w := range words {
+		if i > 0 {
+			progress(i)
+		}
+		ok, err := s.Repetitions.Exists(chatID, w)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res.Existing++
+			continue
+		}
+		ds, _, err := s.Definer.Define(w, settings)
+		if err != nil || len(ds) == 0 {
+			s.Log.Debug("No definition to import", "word", w, "err", err)
+			res.NotFound = append(res.NotFound, w)
+			continue
+		}
+		if err := saveCard(s, chatID, w, ds[0].Text, settings.InputLanguageISO639_3); err != nil {
+			return nil, err
+		}
+		res.Imported++
+	}
+	return res, nil
+}
+
+func importSummary(l *Localizer, r *ImportResult) string {
+	lines := []string{l.T("Imported %d words.", r.Imported)}
+	if r.Existing > 0 {
+		lines = append(lines, l.T("Already saved: %d.", r.Existing))
+	}
+	if len(r.NotFound) > 0 {
+		lines = append(lines, l.T("No definitions found for: %s.", strings.Join(r.NotFound, ", ")))
+	}
+	if r.Skipped > 0 {
+		lines = append(lines, l.T("%d words were left out, import at most %d words at once.", r.Skipped, maxImportWords))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runImport imports the words, showing the progress in a message, and sends
+// the summary.
+func runImport(s *State, chatID int64, words []string) error {
+	l := s.L(chatID)
+	n := min(len(words), maxImportWords)
+	pm, err := s.Telegram.Send(&MessageReply{ChatId: chatID, Text: l.T("Importing %d words, it can take a while.", n)})
+	if err != nil {
+		return err
+	}
+	res, err := importWords(s, chatID, words, func(done int) {
+		if done%importProgressEvery != 0 {
+			return
+		}
+		r := &EditMessageText{ChatId: chatID, MessageId: pm.Id, Text: l.T("Importing %d words: %d done.", n, done)}
+		var rm Message
+		if err := s.Telegram.Call("editMessageText", r, &rm); err != nil {
+			s.Log.Warn("Showing import progress", "err", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return s.Telegram.SendTextMessage(chatID, importSummary(l, res))
+}
+
+// Imports runs imports in the background, so that looking up the words doesn't
+// hold up the updates. A chat runs one import at a time.
+type Imports struct {
+	mu      sync.Mutex
+	running map[int64]bool
+}
+
+func NewImports() *Imports {
+	return &Imports{running: make(map[int64]bool)}
+}
+
+// Start runs f in the background and returns true unless the chat has an
+// import in progress.
+func (im *Imports) Start(chatID int64, f func()) bool {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if im.running[chatID] {
+		return false
+	}
+	im.running[chatID] = true
+	go func() {
+		defer func() {
+			im.mu.Lock()
+			delete(im.running, chatID)
+			im.mu.Unlock()
+		}()
+		f()
+	}()
+	return true
+}
+
+// importCommand waits for the file with words to import.
+type importCommand struct {
+	name string
+}
+
+func ImportCommandFactory() CommandFactory {
+	return func(name string) Command {
+		return &importCommand{name: name}
+	}
+}
+
+func (c *importCommand) Serialize() *SerializedCommand {
+	return &SerializedCommand{Name: c.name}
+}
+
+func (c *importCommand) Init(*SerializedCommand) error {
+	return nil
+}
+
+func (c *importCommand) OnCommand(s *State, m *Message) (Command, error) {
+	return c, s.Telegram.SendTextMessage(m.Chat.Id, s.L(m.Chat.Id).T(
+		"Send vocab.db from your Kindle (in the system folder) or its CSV export to create cards for the looked up words."))
+}
+
+func (c *importCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	if m.Document == nil {
+		return c, UserError{ChatID: chatID, Err: localizedErrorf("send the file as a document or /cancel")}
+	}
+	if m.Document.FileSize > maxDownloadSize {
+		return c, UserError{ChatID: chatID, Err: localizedErrorf("the file is too large")}
+	}
+	data, err := s.Telegram.DownloadFile(m.Document.FileId)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: downloading %q: %w", m.Document.FileName, err)
+	}
+	ws, err := parseKindleVocab(data)
+	if err != nil {
+		s.Log.Warn("Parsing vocabulary", "file", m.Document.FileName, "err", err)
+		return c, UserError{ChatID: chatID, Err: localizedErrorf("couldn't read words from the file")}
+	}
+	if len(ws) == 0 {
+		return c, UserError{ChatID: chatID, Err: localizedErrorf("no words in the file")}
+	}
+	// Errors can't fail the update anymore, so they are reported here.
+	started := s.Imports.Start(chatID, func() {
+		if err := runImport(s, chatID, ws); err != nil {
+			s.Log.Error("Importing words", "err", err)
+			if err := s.Telegram.SendTextMessage(chatID, s.L(chatID).T("The import failed, try again later.")); err != nil {
+				s.Log.Error("Reporting failed import", "err", err)
+			}
+		}
+	})
+	if !started {
+		return nil, UserError{ChatID: chatID, Err: localizedErrorf("another import is in progress, wait for it to finish")}
+	}
+	return nil, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// vocabDB returns a vocab.db with the schema of Kindle's vocabulary builder.
+func vocabDB(t *testing.T) []byte {
+	dir, err := ioutil.TempDir("", "vocab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "vocab.db")
+	db, err := sql.Open("sqlite3", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE WORDS (id TEXT PRIMARY KEY NOT NULL, word TEXT, stem TEXT, lang TEXT, category INTEGER DEFAULT 0, timestamp INTEGER DEFAULT 0, profileid TEXT);
+		INSERT INTO WORDS VALUES
+			('hu:falvak', 'falvak', 'falu', 'hu', 0, 3, ''),
+			('hu:fekete', 'fekete', 'fekete', 'hu', 0, 1, ''),
+			('hu:falu', 'falu', 'falu', 'hu', 0, 2, ''),
+			('hu:kutya', 'kutya', '', 'hu', 0, 4, '');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseKindleVocab(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"vocab.db", vocabDB(t), []string{"fekete", "falu", "kutya"}},
+		{"CSV with header", []byte("Word,Stem,Usage\nfalvak,falu,\"A \"\"falvak\"\" szép\"\nfekete,fekete,\n,,\nfalu,falu,\n"), []string{"falu", "fekete"}},
+		{"CSV without header", []byte("fekete\n falu \nfekete\n"), []string{"fekete", "falu"}},
+		{"empty", nil, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseKindleVocab(tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestImports(t *testing.T) {
+	im := NewImports()
+	release := make(chan struct{})
+	done := make(chan struct{})
+	if !im.Start(1, func() {
+		<-release
+		close(done)
+	}) {
+		t.Fatal("Start: got false want true")
+	}
+	if im.Start(1, func() {}) {
+		t.Error("Start with an import in progress: got true want false")
+	}
+	// Other chats aren't held up.
+	other := make(chan struct{})
+	if !im.Start(2, func() { close(other) }) {
+		t.Error("Start in another chat: got false want true")
+	}
+	<-other
+	close(release)
+	<-done
+	for !im.Start(1, func() {}) {
+		// The chat is released right after the import returns.
+		runtime.Gosched()
+	}
+}
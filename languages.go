@@ -0,0 +1,175 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Registry of the input languages supported by the bot. Languages are stored
+// in the database, so that new ones can be added without rebuilding the bot:
+//
+//   INSERT INTO Languages(name, iso639_3, translation_languages)
+//   VALUES("French", "fra", "eng,deu");
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+type Language struct {
+	// English name of the language, the same as used in wiktionary.
+	Name string
+	// ISO 639-3 code of the language.
+	ISO639_3 string
+	// ISO 639-3 codes of languages into which usage examples are translated
+	// by default.
+	TranslationLanguages []string
+}
+
+// Settings returns settings with the language as an input language.
+func (l *Language) Settings() Settings {
+	tl := make(map[string]bool)
+	for _, t := range l.TranslationLanguages {
+		tl[t] = true
+	}
+	return Settings{
+		InputLanguage:         l.Name,
+		InputLanguageISO639_3: l.ISO639_3,
+		TranslationLanguages:  tl,
+	}
+}
+
+// Languages with which the registry is populated on creation.
+var defaultLanguages = []*Language{
+	{
+		Name:                 "Hungarian",
+		ISO639_3:             "hun",
+		TranslationLanguages: []string{"eng", "rus", "ukr"},
+	},
+	{
+		Name:                 "English",
+		ISO639_3:             "eng",
+		TranslationLanguages: []string{"rus", "ukr"},
+	},
+	{
+		Name:                 "German",
+		ISO639_3:             "deu",
+		TranslationLanguages: []string{"eng", "rus", "ukr"},
+	},
+}
+
+type LanguageRegistry struct {
+	db *sql.DB
+}
+
+func NewLanguageRegistry(db *sql.DB) (*LanguageRegistry, error) {
+	for _, l := range defaultLanguages {
+		if _, err := db.Exec(`
+			INSERT OR IGNORE INTO Languages(name, iso639_3, translation_languages)
+			VALUES($0, $1, $2)`,
+			l.Name, l.ISO639_3, strings.Join(l.TranslationLanguages, ",")); err != nil {
+			return nil, fmt.Errorf("populating languages: %w", err)
+		}
+	}
+	return &LanguageRegistry{db}, nil
+}
+
+func splitList(s string) []string {
+	var r []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			r = append(r, e)
+		}
+	}
+	return r
+}
+
+// All returns all registered languages sorted by name.
+func (r *LanguageRegistry) All() ([]*Language, error) {
+	rows, err := r.db.Query(`
+		SELECT name, iso639_3, translation_languages
+		FROM Languages
+		ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ls []*Language
+	for rows.Next() {
+		var name, code, tl string
+		if err := rows.Scan(&name, &code, &tl); err != nil {
+			return nil, err
+		}
+		ls = append(ls, &Language{
+			Name:                 name,
+			ISO639_3:             code,
+			TranslationLanguages: splitList(tl),
+		})
+	}
+	return ls, rows.Err()
+}
+
+// Find returns the language by its name or ISO 639-3 code. Languages that are
+// not registered are accepted as well if a valid ISO 639-3 code is passed.
+func (r *LanguageRegistry) Find(nameOrCode string) (*Language, error) {
+	ls, err := r.All()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range ls {
+		if strings.EqualFold(l.Name, nameOrCode) || strings.EqualFold(l.ISO639_3, nameOrCode) {
+			return l, nil
+		}
+	}
+	code := strings.ToLower(nameOrCode)
+	b, err := language.ParseBase(code)
+	if len(code) != 3 || err != nil || b.ISO3() != code {
+		return nil, localizedErrorf("unsupported language %q", nameOrCode)
+	}
+	// Unregistered languages get translations into all registered ones.
+	var tl []string
+	for _, l := range ls {
+		tl = append(tl, l.ISO639_3)
+	}
+	return &Language{
+		Name:                 display.English.Languages().Name(b),
+		ISO639_3:             code,
+		TranslationLanguages: tl,
+	}, nil
+}
+
+// Codes returns sorted ISO 639-3 codes of all registered languages and their
+// translation languages.
+func (r *LanguageRegistry) Codes() ([]string, error) {
+	ls, err := r.All()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]bool)
+	for _, l := range ls {
+		m[l.ISO639_3] = true
+		for _, t := range l.TranslationLanguages {
+			m[t] = true
+		}
+	}
+	var cs []string
+	for c := range m {
+		cs = append(cs, c)
+	}
+	sort.Strings(cs)
+	return cs, nil
+}
@@ -0,0 +1,472 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Loading of usage examples from the tatoeba dumps into the database, run as
+// the load subcommand of the bot.
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"words/tokenize"
+)
+
+type LoadOptions struct {
+	// Path to the file in csv with links between files, <id><TAB><translation>
+	LinksPath string
+	// Path to the file in csv with all the sentences. <id><TAB><lang><TAB><text>
+	// <lang> is an ISO 639-3 language code.
+	// Detailed version of the file is supported as well, in this case authors
+	// of the sentences are loaded for the attribution:
+	// <id><TAB><lang><TAB><text><TAB><username><TAB><date added><TAB><date modified>
+	SentencesPath string
+	// Languages are ISO 639-3 codes of sentences to load, all sentences are
+	// loaded if empty. Only links between loaded sentences are kept.
+	Languages []string
+}
+
+// loads returns true if sentences in lang should be loaded.
+func (o *LoadOptions) loads(lang string) bool {
+	if len(o.Languages) == 0 {
+		return true
+	}
+	for _, l := range o.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Loader) ReadAndLoad(conn *sql.Conn, opts LoadOptions) error {
+	// Single proc is used so that tx is single. Rows are committed together
+	// with the high-water mark of the file every 100k rows, so that load can be
+	// resumed after it is interrupted.
+	p, err := newProc(conn)
+	if err != nil {
+		return err
+	}
+	defer p.cleanup()
+
+	if err := p.loadFile(opts.SentencesPath, func(row string) error {
+		s := strings.Split(row, "\t")
+		if len(s) != 3 && len(s) != 6 {
+			return fmt.Errorf("reading %q: wrond format for row %s", opts.SentencesPath, s)
+		}
+		id, err := strconv.ParseInt(s[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("reading %q: parsing id %q: %v", opts.SentencesPath, s[0], err)
+		}
+		if !opts.loads(s[1]) {
+			return nil
+		}
+		// Sentence might be already loaded if the file was replaced with a
+		// newer dump, its words don't need to be inserted again then.
+		loaded, err := p.exists(SentencesTable, id)
+		if err != nil || loaded {
+			return err
+		}
+		lang, text := s[1], s[2]
+		var author sql.NullString
+		// \N is used for unknown authors.
+		if len(s) == 6 && s[3] != "\\N" {
+			author = sql.NullString{String: s[3], Valid: true}
+		}
+		if err := p.sentence(id, lang, text, author); err != nil {
+			return err
+		}
+		// Words are normalized the same way they are when usage examples are
+		// fetched.
+		for _, word := range tokenize.Words(text) {
+			if err := p.word(word, lang, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return p.loadFile(opts.LinksPath, func(row string) error {
+		var ids []interface{}
+		for _, i := range strings.Split(row, "\t") {
+			id, err := strconv.ParseInt(i, 10, 64)
+			if err != nil {
+				return fmt.Errorf("reading %q: parsing id %q: %v", opts.LinksPath, i, err)
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) != 2 {
+			return fmt.Errorf("reading %q: wrond format for row %s", opts.LinksPath, row)
+		}
+		if len(opts.Languages) > 0 {
+			// Either of the sentences might have been filtered out.
+			for _, id := range ids {
+				if ok, err := p.exists(SentencesTable, id); err != nil || !ok {
+					return err
+				}
+			}
+		}
+		loaded, err := p.exists(TranslationsTable, ids...)
+		if err != nil || loaded {
+			return err
+		}
+		return p.translation(ids[0].(int64), ids[1].(int64))
+	})
+}
+
+type Loader struct {
+	db   *sql.DB
+	opts LoadOptions
+}
+
+// NewLoader creates a loader writing into db, which should be opened with
+// OpenDB so that the schema is up to date.
+func NewLoader(db *sql.DB, opts LoadOptions) *Loader {
+	return &Loader{db, opts}
+}
+
+type proc struct {
+	conn  *sql.Conn
+	tx    *sql.Tx
+	ins   map[TableType]*sql.Stmt
+	exist map[TableType]*sql.Stmt
+	// rows has arguments of the rows which weren't inserted yet.
+	rows map[TableType][]interface{}
+	cnt  map[TableType]int
+
+	// processed is the number of rows written since the last commit.
+	processed int64
+	// file that is currently being loaded.
+	file string
+	// offset up to which the file has been processed.
+	offset int64
+	// size of the file that is currently being loaded.
+	size int64
+}
+
+type TableType int
+
+const (
+	SentencesTable TableType = iota
+	WordsTable
+	WordSentencesTable
+	TranslationsTable
+)
+
+// insert describes how rows are inserted into a table.
+type insert struct {
+	table string
+	// format of the statement, rows are inserted as VALUES in place of %s.
+	format  string
+	columns int
+}
+
+var inserts = map[TableType]insert{
+	SentencesTable: {"Sentences", `INSERT OR REPLACE INTO Sentences(id, lang, text, author) VALUES %s`, 4},
+	// Words are never replaced, as that would change their ids.
+	WordsTable: {"Words", `INSERT OR IGNORE INTO Words(word, lang) VALUES %s`, 2},
+	// Rows are (word, lang, sentence_id), words have to be inserted first.
+	WordSentencesTable: {"WordSentences", `
+		INSERT OR IGNORE INTO WordSentences(word_id, sentence_id)
+		SELECT w.word_id, v.column3
+		FROM (VALUES %s) v
+		INNER JOIN Words w ON w.word = v.column1 AND w.lang = v.column2`, 3},
+	TranslationsTable: {"Translations", `INSERT OR REPLACE INTO Translations(id, translation_id) VALUES %s`, 2},
+}
+
+// query returns a statement inserting n rows.
+func (i insert) query(n int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", i.columns), ", ") + ")"
+	return fmt.Sprintf(i.format, strings.TrimSuffix(strings.Repeat(row+", ", n), ", "))
+}
+
+const (
+	// Number of rows after which data is flushed and progress is reported.
+	flushRows = 100_000
+	// Number of rows inserted by a single statement. It keeps the number of
+	// arguments below 999, the limit of older versions of sqlite.
+	batchRows = 200
+)
+
+func newProc(conn *sql.Conn) (*proc, error) {
+	p := &proc{
+		conn: conn,
+		rows: make(map[TableType][]interface{}),
+		cnt:  make(map[TableType]int),
+	}
+	return p, p.begin()
+}
+
+// begin starts a new transaction and prepares statements in it.
+func (p *proc) begin() (err error) {
+	p.tx, err = p.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	p.ins = make(map[TableType]*sql.Stmt)
+	for t, i := range inserts {
+		if p.ins[t], err = p.tx.Prepare(i.query(batchRows)); err != nil {
+			return err
+		}
+	}
+	p.exist = make(map[TableType]*sql.Stmt)
+	for t, q := range map[TableType]string{
+		SentencesTable:    `SELECT COUNT(*) FROM Sentences WHERE id = ?`,
+		TranslationsTable: `SELECT COUNT(*) FROM Translations WHERE id = ? AND translation_id = ?`,
+	} {
+		if p.exist[t], err = p.tx.Prepare(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *proc) sentence(id int64, lang, text string, author sql.NullString) error {
+	return p.row(SentencesTable, id, lang, text, author)
+}
+func (p *proc) word(word, lang string, sid int64) error {
+	if err := p.row(WordsTable, word, lang); err != nil {
+		return err
+	}
+	return p.row(WordSentencesTable, word, lang, sid)
+}
+func (p *proc) translation(id, tid int64) error {
+	return p.row(TranslationsTable, id, tid)
+}
+
+// row queues the row to be inserted with the next batch.
+func (p *proc) row(table TableType, args ...interface{}) error {
+	p.cnt[table] += 1
+	p.processed += 1
+	p.rows[table] = append(p.rows[table], args...)
+	if len(p.rows[table]) < batchRows*inserts[table].columns {
+		return nil
+	}
+	return p.flush(table)
+}
+
+// flush inserts the queued rows of the table.
+func (p *proc) flush(table TableType) error {
+	if table == WordSentencesTable {
+		if err := p.flush(WordsTable); err != nil {
+			return err
+		}
+	}
+	i, args := inserts[table], p.rows[table]
+	n := len(args) / i.columns
+	if n == 0 {
+		return nil
+	}
+	var err error
+	if n == batchRows {
+		_, err = p.ins[table].Exec(args...)
+	} else {
+		_, err = p.tx.Exec(i.query(n), args...)
+	}
+	if err != nil {
+		return fmt.Errorf("inserting %d rows into %s: %w", n, i.table, err)
+	}
+	p.rows[table] = args[:0]
+	return nil
+}
+
+// exists returns true if the row identified by args is already loaded into
+// table. Rows which are queued aren't visible yet.
+func (p *proc) exists(table TableType, args ...interface{}) (bool, error) {
+	var n int
+	if err := p.exist[table].QueryRow(args...).Scan(&n); err != nil {
+		return false, fmt.Errorf("exists(%d, %v): %v", table, args, err)
+	}
+	return n > 0, nil
+}
+
+// highWaterMark returns the offset in bytes up to which path was loaded.
+func (p *proc) highWaterMark(path string) (int64, error) {
+	var o int64
+	err := p.tx.QueryRow(`SELECT offset FROM LoadProgress WHERE path = ?`, path).Scan(&o)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return o, err
+}
+
+// loadFile calls load for each line of the file starting from the high-water
+// mark, so that lines loaded by previous runs are skipped.
+func (p *proc) loadFile(path string, load func(row string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	o, err := p.highWaterMark(path)
+	if err != nil {
+		return fmt.Errorf("reading high-water mark for %q: %w", path, err)
+	}
+	if o > fi.Size() {
+		slog.Warn("File is smaller than at the last load, loading from the beginning", "path", path)
+		o = 0
+	}
+	if _, err := f.Seek(o, io.SeekStart); err != nil {
+		return err
+	}
+	p.file, p.offset, p.size = path, o, fi.Size()
+	if o > 0 {
+		slog.Info("Resuming loading", "path", path, "progress", p.progress())
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading %q: %w", path, err)
+		}
+		if row := strings.TrimRight(line, "\r\n"); row != "" {
+			if err := load(row); err != nil {
+				return err
+			}
+		}
+		// Offset is updated only after the whole line is processed, so that
+		// partially processed lines are processed again on resume.
+		p.offset += int64(len(line))
+		if p.processed >= flushRows {
+			if err := p.commit(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if err := p.commit(); err != nil {
+		return err
+	}
+	slog.Info("Finished loading", "path", path)
+	return nil
+}
+
+func (p *proc) progress() string {
+	if p.size == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(p.offset)/float64(p.size))
+}
+
+// commit inserts the queued rows and commits the transaction together with
+// the high-water mark of the file being loaded, and creates a new transaction.
+func (p *proc) commit() error {
+	for _, t := range []TableType{SentencesTable, WordsTable, WordSentencesTable, TranslationsTable} {
+		if err := p.flush(t); err != nil {
+			return err
+		}
+	}
+	if p.file != "" {
+		if _, err := p.tx.Exec(`INSERT OR REPLACE INTO LoadProgress(path, offset) VALUES(?, ?)`, p.file, p.offset); err != nil {
+			return fmt.Errorf("saving high-water mark for %q: %w", p.file, err)
+		}
+	}
+	if err := p.tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("Loaded", "path", p.file, "progress", p.progress(), "written", p.cnt)
+	p.processed = 0
+	return p.begin()
+}
+
+func (p *proc) cleanup() {
+	// Prepared statements are closed together with the transaction.
+	p.tx.Rollback()
+}
+
+func (l *Loader) Load() error {
+	ctx := context.Background()
+	// Pragmas are set per connection, so the whole load goes through one.
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF;`); err != nil {
+		return err
+	}
+	restore, err := bulkMode(conn)
+	if err != nil {
+		return err
+	}
+	return errors.Join(l.ReadAndLoad(conn, l.opts), restore())
+}
+
+// bulkMode speeds up loading through conn until the returned restore is
+// called: nothing is synced to disk, so a power loss can lose the last
+// transactions. The journal mode is kept, so that a crash doesn't corrupt the
+// database the bot may be using.
+func bulkMode(conn *sql.Conn) (restore func() error, err error) {
+	ctx := context.Background()
+	var sync int
+	if err := conn.QueryRowContext(ctx, `PRAGMA synchronous`).Scan(&sync); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `PRAGMA synchronous = OFF`); err != nil {
+		return nil, err
+	}
+	return func() error {
+		s := fmt.Sprintf("PRAGMA synchronous = %d", sync)
+		if _, err := conn.ExecContext(ctx, s); err != nil {
+			return fmt.Errorf("%s: %w", s, err)
+		}
+		return nil
+	}, nil
+}
+
+// RunLoad runs the load subcommand: it loads the sentences and links given
+// with -sentences and -links into the database configured the same way as for
+// the bot.
+func RunLoad(name string, args []string, getenv func(string) string) error {
+	fs := flag.NewFlagSet(name+" load", flag.ContinueOnError)
+	var opts LoadOptions
+	fs.StringVar(&opts.SentencesPath, "sentences", "data/sentences.csv", "Path to the sentences in csv format. Use sentences_detailed.csv to include authors for the attribution.")
+	fs.StringVar(&opts.LinksPath, "links", "data/links.csv", "Path to the links between sentences and their translations in csv format.")
+	fs.Func("languages", "Comma separated ISO 639-3 codes of languages to load, e.g. eng,hun. All languages are loaded if empty.", func(s string) error {
+		return setOption(reflect.ValueOf(&opts.Languages).Elem(), s)
+	})
+	cfg, err := parseConfig(fs, args, getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	db, err := OpenDB(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return NewLoader(db, opts).Load()
+}
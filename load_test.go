@@ -0,0 +1,222 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "load_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "tmpdb")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	count := func(table string) int32 {
+		t.Helper()
+		r := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s;", table))
+		var n int32
+		if err := r.Scan(&n); err != nil {
+			t.Fatalf("count(%s): %v", table, err)
+		}
+		return n
+	}
+
+	ldb, err := OpenDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+	l := NewLoader(ldb, LoadOptions{SentencesPath: "testdata/sentences.csv", LinksPath: "testdata/links.csv"})
+	if err := l.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode after Load: got %q, %v want wal, nil", mode, err)
+	}
+	tables := []string{"Sentences", "Translations", "Words", "WordSentences"}
+	got := make(map[string]int32)
+	for _, tb := range tables {
+		n := count(tb)
+		if n == 0 {
+			t.Errorf("%s: got %d want > 0", tb, n)
+		}
+		got[tb] = n
+	}
+
+	// Concecutive calls to Load should result in no errors and be noops.
+	if err := l.Load(); err != nil {
+		t.Fatal(err)
+	}
+	want := got
+	got = make(map[string]int32)
+	for _, tb := range tables {
+		got[tb] = count(tb)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	log.Printf("want: %v", want)
+
+	t.Run("Resume", func(t *testing.T) {
+		dbPath := filepath.Join(dir, "resumedb")
+		files := map[string]string{
+			"testdata/sentences.csv": filepath.Join(dir, "sentences.csv"),
+			"testdata/links.csv":     filepath.Join(dir, "links.csv"),
+		}
+		// copy copies first n-th part of the lines of each file.
+		copy := func(n int) {
+			t.Helper()
+			for src, dst := range files {
+				b, err := ioutil.ReadFile(src)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines := strings.SplitAfter(string(b), "\n")
+				if err := ioutil.WriteFile(dst, []byte(strings.Join(lines[:len(lines)/n], "")), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		ldb, err := OpenDB(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ldb.Close()
+		l := NewLoader(ldb, LoadOptions{SentencesPath: files["testdata/sentences.csv"], LinksPath: files["testdata/links.csv"]})
+		// Simulate interrupted load by loading only the first half of the
+		// files and then resuming it with the complete files.
+		copy(2)
+		if err := l.Load(); err != nil {
+			t.Fatal(err)
+		}
+		copy(1)
+		if err := l.Load(); err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		defer db.Close()
+		for _, tb := range tables {
+			var n int32
+			if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s;", tb)).Scan(&n); err != nil {
+				t.Fatalf("count(%s): %v", tb, err)
+			}
+			if n != want[tb] {
+				t.Errorf("%s: got %d rows after resuming want %d", tb, n, want[tb])
+			}
+		}
+	})
+}
+
+func TestRunLoad(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "db.sql")
+	getenv := func(k string) string {
+		if k == "WORDS_DB_PATH" {
+			return dbPath
+		}
+		return ""
+	}
+	if err := RunLoad("words", []string{"-sentences", "testdata/sentences.csv", "-links", "testdata/links.csv"}, getenv); err != nil {
+		t.Fatalf("RunLoad: %v", err)
+	}
+	db, err := OpenDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Sentences`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Errorf("got no sentences in %s loaded by RunLoad", dbPath)
+	}
+}
+
+func TestLoadLanguages(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "db.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	l := NewLoader(db, LoadOptions{
+		SentencesPath: "testdata/sentences.csv",
+		LinksPath:     "testdata/links.csv",
+		Languages:     []string{"hun", "eng"},
+	})
+	if err := l.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`SELECT COUNT(*) FROM Sentences WHERE lang NOT IN ('hun', 'eng')`,
+		`SELECT COUNT(*) FROM Words WHERE lang NOT IN ('hun', 'eng')`,
+		`SELECT COUNT(*) FROM Translations t
+		LEFT JOIN Sentences s ON s.id = t.id
+		LEFT JOIN Sentences ts ON ts.id = t.translation_id
+		WHERE s.id IS NULL OR ts.id IS NULL`,
+	} {
+		var n int
+		if err := db.QueryRow(q).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		if n != 0 {
+			t.Errorf("%s: got %d want 0", q, n)
+		}
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Translations`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Error("got no translations between hun and eng")
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db, err := OpenDB(filepath.Join(b.TempDir(), "db.sql"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		l := NewLoader(db, LoadOptions{SentencesPath: "testdata/sentences.csv", LinksPath: "testdata/links.csv"})
+		b.StartTimer()
+		if err := l.Load(); err != nil {
+			b.Fatal(err)
+		}
+		db.Close()
+	}
+}
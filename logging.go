@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Structured logging. Lines logged while processing an update are tagged with
+// its update_id, chat_id and command, see Update.Logger.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+)
+
+// SetupLogging makes the default logger write to w lines of the given level
+// or above: debug, info, warn or error.
+func SetupLogging(w io.Writer, level string) error {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: l})))
+	return nil
+}
+
+func parseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("log level %q: %w", level, err)
+	}
+	return l, nil
+}
+
+// Logger returns the logger for lines about the update, see Bot.tagUpdate.
+func (u *Update) Logger() *slog.Logger {
+	if u.log == nil {
+		return slog.Default()
+	}
+	return u.log
+}
+
+// tagUpdate sets the logger of the update. The command is the one sent in the
+// message, or the one in progress that is going to process the message.
+// Callbacks are tagged with their action instead.
+func (b *Bot) tagUpdate(u *Update) {
+	attrs := []any{slog.Int64("update_id", u.UpdateId)}
+	if chatID, err := u.ChatId(); err == nil {
+		attrs = append(attrs, slog.Int64("chat_id", chatID))
+		if u.Message != nil {
+			name, _ := splitCommand(u.Message.Text)
+			if name == "" {
+				if cmd := b.getCommand(chatID); cmd != nil {
+					if s := cmd.Serialize(); s != nil {
+						name = s.Name
+					}
+				}
+			}
+			attrs = append(attrs, slog.String("command", name))
+		}
+	}
+	if q := u.CallbackQuery; q != nil {
+		action := "invalid"
+		if c, err := decodeCallbackInfo(q.Data); err == nil {
+			action = strconv.Itoa(int(c.Action))
+		}
+		attrs = append(attrs, slog.String("callback_action", action))
+	}
+	u.log = slog.Default().With(attrs...)
+}
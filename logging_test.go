@@ -0,0 +1,66 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestTagUpdate(t *testing.T) {
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	var buf bytes.Buffer
+	if err := SetupLogging(&buf, "info"); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &Bot{state: &State{Clients: &Clients{}}, command: make(map[int64]Command)}
+	b.setCommand(2, CommandsTemplate.Commands["/add"]("/add"))
+	msg := func(chatID int64, text string) *Update {
+		m := &Message{Text: text}
+		m.Chat.Id = chatID
+		return &Update{UpdateId: 7, Message: m}
+	}
+	cb := &Update{UpdateId: 8, CallbackQuery: &CallbackQuery{
+		Message: msg(3, "card").Message,
+		Data:    CallbackInfo{Action: StartPracticeAction}.String(),
+	}}
+	for _, tc := range []struct {
+		u    *Update
+		want string
+	}{
+		{msg(1, "/quiz"), "update_id=7 chat_id=1 command=/quiz"},
+		{msg(1, "word"), "update_id=7 chat_id=1 command=\"\""},
+		{msg(2, "word"), "update_id=7 chat_id=2 command=/add"},
+		{cb, "update_id=8 chat_id=3 callback_action=5"},
+	} {
+		buf.Reset()
+		b.tagUpdate(tc.u)
+		tc.u.Logger().Info("hello")
+		if got := buf.String(); !strings.Contains(got, "msg=hello "+tc.want+"\n") {
+			t.Errorf("Got line %q want it tagged with %q", got, tc.want)
+		}
+	}
+
+	buf.Reset()
+	slog.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("Got %q logged at info level want nothing", buf.String())
+	}
+	if err := SetupLogging(&buf, "verbose"); err == nil {
+		t.Error("SetupLogging(\"verbose\") succeeded want error")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Recent lookups of words, so that a word sent twice in a row, e.g. by a
+// double send, isn't fetched and replied to again.
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// recentLookupWindow is how long a lookup stays recent. Variable for tests.
+var recentLookupWindow = 3 * time.Minute
+
+// maxRecentLookups is the number of the latest lookups kept for a chat.
+const maxRecentLookups = 5
+
+type recentLookup struct {
+	word string
+	// key tells apart replies to the same word, e.g. the definitions from
+	// the sources and the saved card.
+	key string
+	// messageID is the first message of the reply.
+	messageID int64
+	at        time.Time
+}
+
+// RecentLookups keeps the latest lookups of every chat for
+// recentLookupWindow.
+type RecentLookups struct {
+	mu    sync.Mutex
+	chats map[int64][]recentLookup
+}
+
+func NewRecentLookups() *RecentLookups {
+	return &RecentLookups{chats: make(map[int64][]recentLookup)}
+}
+
+// expire drops the lookups of the chat that aren't recent at now.
+func (r *RecentLookups) expire(chatID int64, now time.Time) {
+	var ls []recentLookup
+	for _, l := range r.chats[chatID] {
+		if now.Sub(l.at) < recentLookupWindow {
+			ls = append(ls, l)
+		}
+	}
+	if len(ls) == 0 {
+		delete(r.chats, chatID)
+		return
+	}
+	r.chats[chatID] = ls
+}
+
+// Find returns the first message of the reply to the recent lookup of the word
+// with the key, false if there is none.
+func (r *RecentLookups) Find(chatID int64, word, key string, now time.Time) (int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(chatID, now)
+	for _, l := range r.chats[chatID] {
+		if l.word == word && l.key == key {
+			return l.messageID, true
+		}
+	}
+	return 0, false
+}
+
+// Add records the lookup of the word replied to with the message.
+func (r *RecentLookups) Add(chatID int64, word, key string, messageID int64, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(chatID, now)
+	var ls []recentLookup
+	for _, l := range r.chats[chatID] {
+		if l.word != word {
+			ls = append(ls, l)
+		}
+	}
+	ls = append(ls, recentLookup{word: word, key: key, messageID: messageID, at: now})
+	if len(ls) > maxRecentLookups {
+		ls = ls[len(ls)-maxRecentLookups:]
+	}
+	r.chats[chatID] = ls
+}
+
+// recentLookupReply points to the reply sent to the same lookup a moment ago
+// instead of replying again.
+func recentLookupReply(s *State, chatID int64, word string, messageID int64) error {
+	return s.Telegram.SendMessage(&MessageReply{
+		ChatId: chatID,
+		Text:   s.L(chatID).T("You've just looked up %q, the reply is above.", word),
+		ReplyParameters: &ReplyParameters{
+			MessageId:                messageID,
+			AllowSendingWithoutReply: true,
+		},
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecentLookups(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRecentLookups()
+	const chatID, other int64 = 1, 2
+	r.Add(chatID, "alma", "", 10, now)
+	if id, ok := r.Find(chatID, "alma", "", now.Add(time.Minute)); !ok || id != 10 {
+		t.Errorf("Find: got %d, %t want 10, true", id, ok)
+	}
+	// Another reply to the same word, e.g. after saving it, isn't recent.
+	if _, ok := r.Find(chatID, "alma", "apple", now.Add(time.Minute)); ok {
+		t.Error("Find with another key: got true want false")
+	}
+	if _, ok := r.Find(other, "alma", "", now.Add(time.Minute)); ok {
+		t.Error("Find in another chat: got true want false")
+	}
+	if _, ok := r.Find(chatID, "alma", "", now.Add(recentLookupWindow)); ok {
+		t.Error("Find after the window: got true want false")
+	}
+
+	// The latest lookup of a word replaces the earlier one, only the latest
+	// lookups are kept.
+	r.Add(chatID, "alma", "", 11, now)
+	r.Add(chatID, "alma", "apple", 12, now)
+	if _, ok := r.Find(chatID, "alma", "", now); ok {
+		t.Error("Find replaced lookup: got true want false")
+	}
+	for i := 0; i < maxRecentLookups; i++ {
+		r.Add(chatID, fmt.Sprint(i), "", int64(i), now)
+	}
+	if _, ok := r.Find(chatID, "alma", "apple", now); ok {
+		t.Error("Find the earliest of too many lookups: got true want false")
+	}
+	if id, ok := r.Find(chatID, "0", "", now); !ok || id != 0 {
+		t.Errorf("Find the latest lookups: got %d, %t want 0, true", id, ok)
+	}
+}
@@ -15,70 +15,66 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"log"
-	"net/http"
-	"time"
+	"log/slog"
+	"os"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // TODO: Start and PollAndProcess should be part of the commander
 func Start(ctx context.Context, opts *CommanderOptions) error {
-	// TODO: Move telegram building into NewCommander, NewCommander will accept
-	// only http.Client
-	t := &Telegram{hc: http.Client{}}
-	c, err := NewCommander(t, opts)
+	c, err := NewCommander(NewTelegram(BotToken), opts)
 	if err != nil {
 		return err
 	}
+	others, err := NewBots(c, opts)
+	if err != nil {
+		return err
+	}
+	bots := append([]*Commander{c}, others...)
+	for _, b := range bots {
+		b.StartReminders(ctx, opts.reminderInterval)
+		b.StartBackups(ctx, opts.backupInterval)
+		b.StartMaintenance(ctx, opts.maintenanceInterval)
+	}
 	if opts.push {
-		return c.StartPush(opts)
-	} else {
-		return c.StartPoll()
+		return c.StartPush(opts, others)
 	}
+	// Bots are polled independently, the first one to fail stops all.
+	errs := make(chan error, len(bots))
+	for _, b := range bots {
+		go func(b *Commander) { errs <- b.StartPoll() }(b)
+	}
+	return <-errs
 }
 
 func main() {
-	log.SetFlags(log.Flags() | log.Lshortfile)
-
-	db := flag.String("db_path", "./db.sql", "Path to the persistent sqlite3 database.")
-
-	push := flag.Bool("push", false, "If true will register webhook, otherwise will rely on polling to get updates.")
-	ip := flag.String("ip", "", "IP address of the server. Needed only if push is set to true.")
-	port := flag.Int("port", 8443, "Port of which webhook should listen. Needed only if push is set to true.")
-	cert := flag.String("cert_path", "webhook.crt", "TLS certificate. Needed only if push is set to true.")
-	key := flag.String("key_path", "webhook.key", "Private key for TLS. Needed only if push is set to true.")
-
-	flag.Parse()
-	log.Printf("db_path: %q", *db)
-	ctx := context.Background()
-	opts := &CommanderOptions{
-		useCache: false,
-		dbPath:   *db,
-		port:     *port,
-		certPath: *cert,
-		keyPath:  *key,
-		ip:       *ip,
-		push:     *push,
-		stages: []time.Duration{
-			20 * time.Second,
-			1 * time.Hour * 23,
-			2 * time.Hour * 23,
-			3 * time.Hour * 23,
-			5 * time.Hour * 23,
-			8 * time.Hour * 24,
-			13 * time.Hour * 24,
-			21 * time.Hour * 24,
-			34 * time.Hour * 24,
-			55 * time.Hour * 24,
-			89 * time.Hour * 24,
-			144 * time.Hour * 24,
-			233 * time.Hour * 24,
-			377 * time.Hour * 24,
-		},
+	if len(os.Args) > 1 && os.Args[1] == "load" {
+		if err := RunLoad(os.Args[0], os.Args[2:], os.Getenv); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	cfg, err := ParseConfig(os.Args[0], os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := SetupLogging(os.Stderr, cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Starting", "db_path", cfg.DBPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := StartDebug(ctx, cfg.DebugPort); err != nil {
+		log.Fatal(err)
 	}
-	if err := Start(ctx, opts); err != nil {
+	if err := Start(ctx, cfg.CommanderOptions()); err != nil {
 		log.Fatal(err)
 	}
 }
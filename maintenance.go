@@ -0,0 +1,168 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Scheduled maintenance of the database: stale cached definitions and expired
+// web logins are removed, the file is compacted when much of it is free and
+// query statistics are refreshed.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Maintenance keeps the database small and its query plans up to date.
+type Maintenance struct {
+	db    *sql.DB
+	clock Clock
+	// cacheTTL is how long cached definitions are kept, they are kept
+	// forever if it's 0.
+	cacheTTL time.Duration
+
+	// mu serializes runs, VACUUM of a large database takes a while.
+	mu sync.Mutex
+}
+
+// MaintenanceReport is the health of the database after a run.
+type MaintenanceReport struct {
+	// Rows removed by table.
+	Definitions, WebLogins, WebSessions int64
+	// Cached definitions and cards left.
+	Cached, Cards int
+	// Size of the database file in bytes before and after VACUUM.
+	SizeBefore, SizeAfter int64
+	// Vacuumed is true if the database was compacted, see maxFreePages.
+	Vacuumed bool
+	Took     time.Duration
+}
+
+func (r *MaintenanceReport) String() string {
+	return fmt.Sprintf("Removed %d cached definitions, %d web logins and %d web sessions.\nCached definitions: %d\nCards: %d\nDatabase size: %d KiB, was %d KiB\nTook %s",
+		r.Definitions, r.WebLogins, r.WebSessions, r.Cached, r.Cards,
+		r.SizeAfter/1024, r.SizeBefore/1024, r.Took.Round(time.Millisecond))
+}
+
+// maxFreePages is the fraction of free pages of the database above which it's
+// compacted. VACUUM rewrites the whole file and blocks writers for longer than
+// busyTimeoutMillis on a large corpus, so it's only worth it when a lot was
+// removed.
+const maxFreePages = 0.25
+
+// Run removes expired rows, compacts the database if much of it is free and
+// refreshes its statistics.
+func (m *Maintenance) Run() (*MaintenanceReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start := m.clock.Now()
+	r := &MaintenanceReport{}
+	var err error
+	if m.cacheTTL > 0 {
+		r.Definitions, err = m.delete(`DELETE FROM Definitions WHERE cached_seconds <= $0`, start.Add(-m.cacheTTL).Unix())
+		if err != nil {
+			return nil, fmt.Errorf("INTERNAL: expiring cached definitions: %w", err)
+		}
+	}
+	// Expired ones are otherwise removed only on the next login.
+	if r.WebLogins, err = m.delete(`DELETE FROM WebLogins WHERE expires_seconds <= $0`, start.Unix()); err != nil {
+		return nil, fmt.Errorf("INTERNAL: expiring web logins: %w", err)
+	}
+	if r.WebSessions, err = m.delete(`DELETE FROM WebSessions WHERE expires_seconds <= $0`, start.Unix()); err != nil {
+		return nil, fmt.Errorf("INTERNAL: expiring web sessions: %w", err)
+	}
+	if r.SizeBefore, err = m.size(); err != nil {
+		return nil, err
+	}
+	var free, pages int64
+	if err := m.db.QueryRow(`PRAGMA freelist_count;`).Scan(&free); err != nil {
+		return nil, fmt.Errorf("INTERNAL: getting free page count: %w", err)
+	}
+	if err := m.db.QueryRow(`PRAGMA page_count;`).Scan(&pages); err != nil {
+		return nil, fmt.Errorf("INTERNAL: getting page count: %w", err)
+	}
+	if r.Vacuumed = float64(free) > maxFreePages*float64(pages); r.Vacuumed {
+		if _, err := m.db.Exec(`VACUUM;`); err != nil {
+			return nil, fmt.Errorf("INTERNAL: VACUUM: %w", err)
+		}
+	}
+	// Unlike ANALYZE, only analyzes the tables whose statistics are stale.
+	if _, err := m.db.Exec(`PRAGMA optimize;`); err != nil {
+		return nil, fmt.Errorf("INTERNAL: PRAGMA optimize: %w", err)
+	}
+	if r.SizeAfter, err = m.size(); err != nil {
+		return nil, err
+	}
+	for _, c := range []struct {
+		q string
+		n *int
+	}{
+		{`SELECT COUNT(*) FROM Definitions;`, &r.Cached},
+		{`SELECT COUNT(*) FROM Repetition;`, &r.Cards},
+	} {
+		if err := m.db.QueryRow(c.q).Scan(c.n); err != nil {
+			return nil, fmt.Errorf("INTERNAL: counting rows: %w", err)
+		}
+	}
+	r.Took = m.clock.Now().Sub(start)
+	return r, nil
+}
+
+func (m *Maintenance) delete(q string, args ...any) (int64, error) {
+	res, err := m.db.Exec(q, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// size returns the size of the database in bytes.
+func (m *Maintenance) size() (int64, error) {
+	var pages, pageSize int64
+	if err := m.db.QueryRow(`PRAGMA page_count;`).Scan(&pages); err != nil {
+		return 0, fmt.Errorf("INTERNAL: getting page count: %w", err)
+	}
+	if err := m.db.QueryRow(`PRAGMA page_size;`).Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("INTERNAL: getting page size: %w", err)
+	}
+	return pages * pageSize, nil
+}
+
+// Loop runs maintenance on every tick and logs the health of the database.
+func (m *Maintenance) Loop(ticker <-chan time.Time, cancel <-chan struct{}) {
+	for {
+		select {
+		case <-ticker:
+		case <-cancel:
+			return
+		}
+		r, err := m.Run()
+		if err != nil {
+			slog.Error("Scheduled maintenance", "err", err)
+			continue
+		}
+		slog.Info("Maintenance done",
+			"expired_definitions", r.Definitions,
+			"expired_web_logins", r.WebLogins,
+			"expired_web_sessions", r.WebSessions,
+			"cached_definitions", r.Cached,
+			"cards", r.Cards,
+			"size_before", r.SizeBefore,
+			"size_after", r.SizeAfter,
+			"vacuumed", r.Vacuumed,
+			"took", r.Took)
+	}
+}
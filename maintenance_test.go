@@ -0,0 +1,96 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	clock := newFakeClock(time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC))
+	cache, err := NewDefCache(db, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save("alma", "alma", "apple"); err != nil {
+		t.Fatal(err)
+	}
+	web := &WebSessions{db: db, clock: clock}
+	if _, err := web.LoginURL(1); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRepetition(db, []time.Duration{0}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(1, "alma", "apple", "hun"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(48 * time.Hour)
+	if err := cache.Save("körte", "körte", "pear"); err != nil {
+		t.Fatal(err)
+	}
+	m := &Maintenance{db: db, clock: clock, cacheTTL: 24 * time.Hour}
+	got, err := m.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Definitions != 1 || got.WebLogins != 1 || got.Cached != 1 || got.Cards != 1 {
+		t.Errorf("Run() = %+v want 1 definition and 1 login removed, 1 definition and 1 card left", got)
+	}
+	// A single removed definition doesn't free enough to compact.
+	if got.SizeBefore <= 0 || got.SizeAfter <= 0 || got.Vacuumed {
+		t.Errorf("Run() = %+v want positive sizes and no VACUUM", got)
+	}
+	if _, _, err := cache.Lookup("alma"); err == nil {
+		t.Error("Lookup(alma) after expiring: got nil error")
+	}
+	if w, _, err := cache.Lookup("körte"); err != nil || w != "körte" {
+		t.Errorf("Lookup(körte) = %q, %v want körte", w, err)
+	}
+
+	// Nothing expires if the cache is kept forever.
+	clock.Advance(48 * time.Hour)
+	m.cacheTTL = 0
+	if got, err := m.Run(); err != nil || got.Definitions != 0 || got.Cached != 1 {
+		t.Errorf("Run() without TTL = %+v, %v want nothing removed", got, err)
+	}
+
+	// Expiring most of the database compacts it.
+	for i := 0; i < 100; i++ {
+		w := fmt.Sprintf("szó%d", i)
+		if err := cache.Save(w, w, strings.Repeat("definition ", 1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(48 * time.Hour)
+	m.cacheTTL = 24 * time.Hour
+	got, err = m.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Vacuumed || got.SizeAfter >= got.SizeBefore {
+		t.Errorf("Run() after expiring most definitions = %+v want a smaller database after VACUUM", got)
+	}
+}
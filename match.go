@@ -0,0 +1,343 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Matching game: the words and their definitions are shuffled into two columns
+// of buttons and the user pairs them up.
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// matchSize is the maximum number of pairs in a game.
+const matchSize = 5
+
+// maxMatchHint is the maximum length of a definition on a button in runes.
+const maxMatchHint = 40
+
+type matchSession struct {
+	// Words left to match in the order of their buttons, and the words
+	// whose definitions are left in the order of the definition buttons.
+	words, defs []string
+	hints       map[string]string
+	// The word tapped last, empty if none.
+	selected string
+	// Words that were paired with a wrong definition.
+	missed  map[string]bool
+	total   int
+	started time.Time
+}
+
+// matchOutcome is the result of tapping a button in the game.
+type matchOutcome int
+
+const (
+	// The game is over or was replaced by another one.
+	matchStale matchOutcome = iota
+	matchSelected
+	// A definition was tapped before a word.
+	matchNoWord
+	matchWrong
+	matchPaired
+	// The last pair was matched.
+	matchDone
+)
+
+// matchView is the state of the game to show after a tap.
+type matchView struct {
+	words, defs []string
+	hints       map[string]string
+	selected    string
+	// When the game is done: the words matched on the first try out of
+	// total and the time it took.
+	firstTry []string
+	total    int
+	duration time.Duration
+}
+
+// Matches keeps games in progress. Like commands in progress they are lost on
+// restart.
+type Matches struct {
+	mu       sync.Mutex
+	sessions map[int64]*matchSession
+}
+
+func NewMatches() *Matches {
+	return &Matches{sessions: make(map[int64]*matchSession)}
+}
+
+// Start starts a new game with the hints of the words replacing the one in
+// progress. The words and the definitions are shown in the given orders.
+func (m *Matches) Start(chatID int64, hints map[string]string, words, defs []string, now time.Time) matchView {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := &matchSession{
+		words:   words,
+		defs:    defs,
+		hints:   hints,
+		missed:  make(map[string]bool),
+		total:   len(words),
+		started: now,
+	}
+	m.sessions[chatID] = s
+	return s.view()
+}
+
+// Stop drops the game in progress, if any.
+func (m *Matches) Stop(chatID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, chatID)
+}
+
+// Tap selects the word or, for a definition, pairs it with the selected word.
+// Definitions that are the same for several words match any of them.
+func (m *Matches) Tap(chatID int64, word string, definition bool, now time.Time) (matchView, matchOutcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[chatID]
+	if s == nil {
+		return matchView{}, matchStale
+	}
+	left := s.words
+	if definition {
+		left = s.defs
+	}
+	if !slices.Contains(left, word) {
+		return matchView{}, matchStale
+	}
+	if !definition {
+		s.selected = word
+		return s.view(), matchSelected
+	}
+	if s.selected == "" {
+		return s.view(), matchNoWord
+	}
+	selected := s.selected
+	s.selected = ""
+	if s.hints[selected] != s.hints[word] {
+		s.missed[selected] = true
+		return s.view(), matchWrong
+	}
+	s.words = slices.DeleteFunc(s.words, func(w string) bool { return w == selected })
+	s.defs = slices.DeleteFunc(s.defs, func(w string) bool { return w == word })
+	if len(s.words) > 0 {
+		return s.view(), matchPaired
+	}
+	delete(m.sessions, chatID)
+	v := s.view()
+	for w := range s.hints {
+		if !s.missed[w] {
+			v.firstTry = append(v.firstTry, w)
+		}
+	}
+	v.total = s.total
+	v.duration = now.Sub(s.started)
+	return v, matchDone
+}
+
+func (s *matchSession) view() matchView {
+	return matchView{
+		words:    slices.Clone(s.words),
+		defs:     slices.Clone(s.defs),
+		hints:    s.hints,
+		selected: s.selected,
+	}
+}
+
+// shuffle shuffles the words in place with randIntn.
+func shuffle(ws []string) {
+	for i := len(ws) - 1; i > 0; i-- {
+		j := randIntn(i + 1)
+		ws[i], ws[j] = ws[j], ws[i]
+	}
+}
+
+// matchHint returns the first line of the definition without the word, short
+// enough for a button.
+func matchHint(word, definition string) string {
+	// The first paragraph of definitions from the sources is the word
+	// itself.
+	if ps := strings.Split(definition, "\n\n"); len(ps) > 1 && strings.Contains(ps[0], word) {
+		definition = strings.Join(ps[1:], "\n\n")
+	}
+	line := strings.TrimSpace(definition)
+	for _, l := range strings.Split(definition, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+	return truncate(strings.ReplaceAll(line, word, "…"), maxMatchHint)
+}
+
+// matchReply starts a game with the due cards in the input language, filled up
+// with the recently reviewed ones.
+func matchReply(s *State, chatID int64) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	language := settings.InputLanguageISO639_3
+	ws, err := s.Repetitions.DueWords(chatID, language, matchSize)
+	if err != nil {
+		return err
+	}
+	if len(ws) < matchSize {
+		recent, err := s.Repetitions.RecentWords(chatID, language, matchSize-len(ws), ws...)
+		if err != nil {
+			return err
+		}
+		ws = append(ws, recent...)
+	}
+	l := s.L(chatID)
+	if len(ws) < 2 {
+		return s.Telegram.SendTextMessage(chatID, l.T("Save at least 2 words to play."))
+	}
+	hints := make(map[string]string)
+	for _, w := range ws {
+		d, err := s.Repetitions.GetDefinition(chatID, w)
+		if err != nil {
+			return err
+		}
+		hints[w] = matchHint(w, d)
+	}
+	words := slices.Clone(ws)
+	shuffle(words)
+	defs := slices.Clone(ws)
+	shuffle(defs)
+	// Pairs shouldn't end up on the same row.
+	for i := range defs {
+		if defs[i] == words[i] {
+			j := (i + 1) % len(defs)
+			defs[i], defs[j] = defs[j], defs[i]
+		}
+	}
+	v := s.Matches.Start(chatID, hints, words, defs, s.Clock.Now())
+	return s.Telegram.SendMessage(&MessageReply{
+		ChatId:      chatID,
+		Text:        l.T("Match the words with their definitions: tap a word, then its definition."),
+		ReplyMarkup: &ReplyMarkup{InlineKeyboard: matchKeyboard(l, v)},
+	})
+}
+
+// matchKeyboard returns the words and the definitions left as two columns.
+func matchKeyboard(l *Localizer, v matchView) [][]*InlineKeyboard {
+	var ks [][]*InlineKeyboard
+	for i, w := range v.words {
+		text := w
+		if w == v.selected {
+			text = "👉 " + w
+		}
+		d := v.defs[i]
+		ks = append(ks, []*InlineKeyboard{
+			MatchCallback{w, false, text}.AsInlineKeyboard(l),
+			MatchCallback{d, true, v.hints[d]}.AsInlineKeyboard(l),
+		})
+	}
+	return ks
+}
+
+// MatchCallback taps a word or a definition in the game.
+type MatchCallback struct {
+	Word string
+	// Whether it's the definition of the word.
+	Definition bool
+	// Text of the button.
+	Text string
+}
+
+func (MatchCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	l := s.L(chatID)
+	v, out := s.Matches.Tap(chatID, info.Word, info.Index == "d", s.Clock.Now())
+	switch out {
+	case matchStale:
+		s.AnswerCallback(q, l.T("This game is over."))
+		return editKeyboard(s, q.Message, nil)
+	case matchNoWord:
+		s.AnswerCallback(q, l.T("Tap a word first."))
+		return nil
+	case matchWrong:
+		s.AnswerCallback(q, l.T("Not a pair, try again."))
+	case matchDone:
+		// The matched words are credited first.
+		s.AnswerCallback(q, "")
+		return finishMatch(s, q.Message, v)
+	}
+	r := &EditMessageText{
+		ChatId:      chatID,
+		MessageId:   q.Message.Id,
+		ReplyMarkup: ReplyMarkup{InlineKeyboard: matchKeyboard(l, v)},
+	}
+	var rm Message
+	if err := s.Telegram.Call("editMessageReplyMarkup", r, &rm); err != nil {
+		return fmt.Errorf("editing message reply markup: %w", err)
+	}
+	return nil
+}
+
+// finishMatch credits the due words matched on the first try and replaces the
+// game with its result.
+func finishMatch(s *State, m *Message, v matchView) error {
+	chatID := m.Chat.Id
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	for _, w := range v.firstTry {
+		if err := s.Repetitions.CreditCard(chatID, w, settings.Scheduling()); err != nil {
+			return err
+		}
+	}
+	l := s.L(chatID)
+	r := &EditMessageText{
+		ChatId:    chatID,
+		MessageId: m.Id,
+		Text: l.T("All pairs matched! Right on the first try: %d of %d. Time: %s.",
+			len(v.firstTry), v.total, v.duration.Round(time.Second)),
+		ReplyMarkup: ReplyMarkup{InlineKeyboard: [][]*InlineKeyboard{}},
+	}
+	var rm Message
+	if err := s.Telegram.Call("editMessageText", r, &rm); err != nil {
+		return fmt.Errorf("editing message: %w", err)
+	}
+	return nil
+}
+
+func (MatchCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == MatchAction
+}
+
+func (c MatchCallback) AsInlineKeyboard(*Localizer) *InlineKeyboard {
+	index := ""
+	if c.Definition {
+		index = "d"
+	}
+	return &InlineKeyboard{
+		Text: c.Text,
+		CallbackData: CallbackInfo{
+			Action: MatchAction,
+			Word:   c.Word,
+			Index:  index,
+		}.String(),
+	}
+}
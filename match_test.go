@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMatches(t *testing.T) {
+	m := NewMatches()
+	const chatID int64 = 1
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	if _, out := m.Tap(chatID, "Haus", false, start); out != matchStale {
+		t.Errorf("Tap without a game: got %v want %v", out, matchStale)
+	}
+	hints := map[string]string{"Haus": "house", "Baum": "tree", "Heim": "house"}
+	m.Start(chatID, hints, []string{"Haus", "Baum", "Heim"}, []string{"Heim", "Haus", "Baum"}, start)
+	for i, tc := range []struct {
+		word       string
+		definition bool
+		want       matchOutcome
+	}{
+		{"Baum", true, matchNoWord},
+		{"Haus", false, matchSelected},
+		{"Baum", true, matchWrong},
+		{"Baum", false, matchSelected},
+		{"Baum", true, matchPaired},
+		{"Baum", false, matchStale},
+		// The same definitions match any of the words.
+		{"Haus", false, matchSelected},
+		{"Heim", true, matchPaired},
+	} {
+		if _, out := m.Tap(chatID, tc.word, tc.definition, start); out != tc.want {
+			t.Errorf("tap #%d on %s: got %v want %v", i+1, tc.word, out, tc.want)
+		}
+	}
+	m.Tap(chatID, "Heim", false, start)
+	v, out := m.Tap(chatID, "Haus", true, start.Add(time.Minute))
+	if out != matchDone {
+		t.Fatalf("last tap: got %v want %v", out, matchDone)
+	}
+	sort.Strings(v.firstTry)
+	if !reflect.DeepEqual(v.firstTry, []string{"Baum", "Heim"}) || v.total != 3 || v.duration != time.Minute {
+		t.Errorf("result: got %+v want Baum and Heim of 3 in a minute", v)
+	}
+	if _, out := m.Tap(chatID, "Haus", false, start); out != matchStale {
+		t.Errorf("Tap after the game: got %v want %v", out, matchStale)
+	}
+}
+
+func TestMatchHint(t *testing.T) {
+	for _, tc := range []struct{ word, def, want string }{
+		{"falu", "falu\nfalu, plural falvak\n\n1. [noun] village\nA világ egy falu.", "1. [noun] village"},
+		{"cardfront", "cardback\n\nmnemonic: card", "cardback"},
+		{"ház", "a ház is a house where the ház stands", "a … is a house where the … stands"},
+	} {
+		if got := matchHint(tc.word, tc.def); got != tc.want {
+			t.Errorf("matchHint(%q): got %q want %q", tc.word, got, tc.want)
+		}
+	}
+}
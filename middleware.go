@@ -0,0 +1,234 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Middleware for concerns shared by all updates, so that they don't have to be
+// handled in every command and callback.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Handler processes a single update.
+type Handler func(u *Update) error
+
+// Middleware wraps a handler with additional processing of the update.
+// Returned UserErrors are surfaced to the user as for commands.
+type Middleware func(next Handler) Handler
+
+// chain wraps h into mws, so that mws[0] is the first to see the update.
+func chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// updateMetrics counts processed updates, see Metrics.
+var updateMetrics = expvar.NewMap("updates")
+
+// Metrics counts updates, errors and total processing time in m.
+func Metrics(m *expvar.Map) Middleware {
+	return func(next Handler) Handler {
+		return func(u *Update) error {
+			start := time.Now()
+			err := next(u)
+			m.Add("total", 1)
+			m.Add("latency_us", time.Since(start).Microseconds())
+			var ue UserError
+			switch {
+			case errors.As(err, &ue):
+				m.Add("user_errors", 1)
+			case err != nil:
+				m.Add("errors", 1)
+			}
+			return err
+		}
+	}
+}
+
+// Logging logs the time it took to process every update.
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(u *Update) error {
+			start := time.Now()
+			err := next(u)
+			u.Logger().Info("Processed update", "duration", time.Since(start))
+			return err
+		}
+	}
+}
+
+// Recover turns panics into internal errors, so that a bug in a single command
+// doesn't bring down the whole bot.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(u *Update) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("INTERNAL: panic while processing update %d: %v\n%s", u.UpdateId, r, debug.Stack())
+				}
+			}()
+			return next(u)
+		}
+	}
+}
+
+// rateWindow counts updates from a chat starting at start.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit allows at most n updates from a chat per interval. The first
+// update over the limit gets a reply, the rest are dropped silently so that
+// flooding the bot doesn't make it flood telegram in turn.
+func RateLimit(n int, interval time.Duration, clock Clock) Middleware {
+	var mu sync.Mutex
+	windows := make(map[int64]*rateWindow)
+	var lastCleanup time.Time
+	// allow returns whether the update should be processed and whether the
+	// user should be told about the limit.
+	allow := func(chatID int64) (bool, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := clock.Now()
+		if now.Sub(lastCleanup) >= interval {
+			for id, w := range windows {
+				if now.Sub(w.start) >= interval {
+					delete(windows, id)
+				}
+			}
+			lastCleanup = now
+		}
+		w := windows[chatID]
+		if w == nil || now.Sub(w.start) >= interval {
+			w = &rateWindow{start: now}
+			windows[chatID] = w
+		}
+		w.count++
+		return w.count <= n, w.count == n+1
+	}
+	return func(next Handler) Handler {
+		return func(u *Update) error {
+			chatID, err := u.ChatId()
+			if err != nil {
+				return next(u)
+			}
+			ok, notify := allow(chatID)
+			if ok {
+				return next(u)
+			}
+			if notify {
+				return UserError{localizedErrorf("too many messages, please wait a bit"), chatID}
+			}
+			u.Logger().Warn("Dropped update: rate limited")
+			return nil
+		}
+	}
+}
+
+// RecordErrors adds internal errors to el.
+func RecordErrors(el *ErrorLog) Middleware {
+	return func(next Handler) Handler {
+		return func(u *Update) error {
+			err := next(u)
+			var ue UserError
+			if err != nil && !errors.As(err, &ue) {
+				if chatID, idErr := u.ChatId(); idErr == nil {
+					el.Add(chatID, err)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// relayedUpdateLength limits the size of the update in relayed errors, the
+// whole message has to fit into telegram's limit of 4096 characters.
+const relayedUpdateLength = 1000
+
+// RelayErrors sends internal errors together with the offending update to the
+// chat, so that the operator notices them without reading the logs.
+func RelayErrors(chatID int64, send func(chatID int64, text string) error) Middleware {
+	return func(next Handler) Handler {
+		return func(u *Update) error {
+			err := next(u)
+			var ue UserError
+			if err == nil || errors.As(err, &ue) {
+				return err
+			}
+			msg := truncate(fmt.Sprintf("INTERNAL ERROR: %v", err), 2000)
+			if b, jerr := json.Marshal(u); jerr == nil {
+				msg += "\n\nUpdate: " + truncate(string(b), relayedUpdateLength)
+			}
+			if serr := send(chatID, msg); serr != nil {
+				u.Logger().Error("Relaying error", "relay_chat_id", chatID, "err", serr)
+			}
+			return err
+		}
+	}
+}
+
+// truncate cuts s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
+
+// AdminOnly rejects the commands from chats that are not in admins.
+func AdminOnly(admins map[int64]bool, commands ...string) Middleware {
+	guarded := make(map[string]bool)
+	for _, c := range commands {
+		guarded[c] = true
+	}
+	return func(next Handler) Handler {
+		return func(u *Update) error {
+			if u.Message == nil {
+				return next(u)
+			}
+			chatID, _ := u.ChatId()
+			if name, _ := splitCommand(u.Message.Text); guarded[name] && !admins[chatID] {
+				u.Logger().Warn("Not an admin")
+				return UserError{localizedErrorf("%s is available only to admins", name), chatID}
+			}
+			return next(u)
+		}
+	}
+}
+
+// Reactivate clears the inactive flag of the chat of every update, since the
+// user evidently unblocked the bot.
+func Reactivate(c *InactiveChats) Middleware {
+	return func(next Handler) Handler {
+		return func(u *Update) error {
+			if chatID, err := u.ChatId(); err == nil {
+				if err := c.Clear(chatID); err != nil {
+					u.Logger().Error("Reactivating chat", "err", err)
+				}
+			}
+			return next(u)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func messageUpdate(chatID int64, text string) *Update {
+	m := &Message{Text: text}
+	m.Chat.Id = chatID
+	return &Update{Message: m}
+}
+
+func TestChain(t *testing.T) {
+	var got []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(u *Update) error {
+				got = append(got, name)
+				return next(u)
+			}
+		}
+	}
+	h := chain(func(*Update) error {
+		got = append(got, "handler")
+		return nil
+	}, mw("first"), mw("second"))
+	if err := h(messageUpdate(1, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"first", "second", "handler"}, got); diff != "" {
+		t.Errorf("chain order (-want +got):\n%s", diff)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Recover()(func(*Update) error { panic("oops") })
+	if err := h(messageUpdate(1, "a")); err == nil {
+		t.Errorf("Recover: got nil error after panic")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := new(expvar.Map).Init()
+	errs := []error{nil, UserError{errors.New("user"), 1}, errors.New("internal")}
+	for _, err := range errs {
+		err := err
+		Metrics(m)(func(*Update) error { return err })(messageUpdate(1, "a"))
+	}
+	for k, want := range map[string]string{"total": "3", "user_errors": "1", "errors": "1"} {
+		if got := m.Get(k); got == nil || got.String() != want {
+			t.Errorf("metric %q: got %v want %s", k, got, want)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	processed := 0
+	h := RateLimit(2, time.Minute, clock)(func(*Update) error {
+		processed++
+		return nil
+	})
+	var userErrors int
+	for i := 0; i < 5; i++ {
+		var ue UserError
+		if err := h(messageUpdate(1, "a")); errors.As(err, &ue) {
+			userErrors++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if processed != 2 || userErrors != 1 {
+		t.Errorf("5 updates with limit 2: processed %d, replied %d times; want 2, 1", processed, userErrors)
+	}
+	// Other chats are not affected.
+	if err := h(messageUpdate(2, "a")); err != nil || processed != 3 {
+		t.Errorf("update from another chat: processed %d, err %v; want 3, nil", processed, err)
+	}
+	clock.Advance(time.Minute)
+	if err := h(messageUpdate(1, "a")); err != nil || processed != 4 {
+		t.Errorf("update after interval: processed %d, err %v; want 4, nil", processed, err)
+	}
+}
+
+func TestRelayErrors(t *testing.T) {
+	var sent []string
+	send := func(chatID int64, text string) error {
+		if chatID != 42 {
+			t.Errorf("relayed to chat %d want 42", chatID)
+		}
+		sent = append(sent, text)
+		return nil
+	}
+	for _, err := range []error{
+		nil,
+		UserError{errors.New("bad input"), 1},
+		errors.New("INTERNAL: database is locked"),
+	} {
+		h := RelayErrors(42, send)(func(*Update) error { return err })
+		if got := h(messageUpdate(1, strings.Repeat("a", 5000))); got != err {
+			t.Errorf("RelayErrors changed error %v to %v", err, got)
+		}
+	}
+	if len(sent) != 1 {
+		t.Fatalf("relayed %d messages want 1: %q", len(sent), sent)
+	}
+	if !strings.HasPrefix(sent[0], "INTERNAL ERROR: INTERNAL: database is locked\n\nUpdate: {") {
+		t.Errorf("relayed message %q", sent[0])
+	}
+	if n := len([]rune(sent[0])); n > 4096 {
+		t.Errorf("relayed message has %d characters, more than telegram allows", n)
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	h := AdminOnly(map[int64]bool{1: true}, "/secret")(func(*Update) error { return nil })
+	for _, tc := range []struct {
+		chatID  int64
+		text    string
+		wantErr bool
+	}{
+		{1, "/secret", false},
+		{1, "/secret now", false},
+		{2, "/secret", true},
+		{2, "/secret now", true},
+		{2, "/settings", false},
+		{2, "secret", false},
+	} {
+		err := h(messageUpdate(tc.chatID, tc.text))
+		if got := err != nil; got != tc.wantErr {
+			t.Errorf("chat %d sending %q: got error %v, want error %t", tc.chatID, tc.text, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Defining words seen on photos: the text is recognized by an OCR backend and
+// the user picks a word to look it up.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"words/tokenize"
+)
+
+// OCR recognizes text in images.
+type OCR interface {
+	// Recognize returns the text in the image. language is the ISO 639-3
+	// code of the expected language.
+	Recognize(image []byte, language string) (string, error)
+}
+
+// maxPhotoWords limits the number of recognized words offered as buttons.
+const maxPhotoWords = 30
+
+// photoWordsPerRow is the number of word buttons in a row of the keyboard.
+const photoWordsPerRow = 3
+
+// ocrTimeout limits recognition of a single photo.
+const ocrTimeout = time.Minute
+
+// TesseractServer is an OCR backend running tesseract behind HTTP, see
+// https://github.com/hertzg/tesseract-server. Its language codes match ISO
+// 639-3 for most languages, the language data has to be installed on the
+// server.
+type TesseractServer struct {
+	hc  *http.Client
+	url string
+}
+
+func NewTesseractServer(url string) *TesseractServer {
+	return &TesseractServer{hc: &http.Client{Timeout: ocrTimeout}, url: strings.TrimSuffix(url, "/")}
+}
+
+func (t *TesseractServer) Recognize(image []byte, language string) (string, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	opts, err := json.Marshal(map[string]any{"languages": []string{language}})
+	if err != nil {
+		return "", err
+	}
+	if err := w.WriteField("options", string(opts)); err != nil {
+		return "", err
+	}
+	fw, err := w.CreateFormFile("file", "photo.jpg")
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(image); err != nil {
+		return "", err
+	}
+	w.Close()
+
+	req, err := http.NewRequest("POST", t.url+"/tesseract", &b)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	res, err := t.hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1000))
+		return "", fmt.Errorf("tesseract server: %w", &StatusError{Code: res.StatusCode, Body: string(msg)})
+	}
+	var r struct {
+		Data struct {
+			Stdout string `json:"stdout"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("decoding tesseract server response: %w", err)
+	}
+	return r.Data.Stdout, nil
+}
+
+// photoWords returns the distinct words of the recognized text in order, at
+// most maxPhotoWords.
+func photoWords(text string) []string {
+	var ws []string
+	seen := make(map[string]bool)
+	for _, w := range tokenize.Words(text) {
+		// Single letters are mostly noise of recognition.
+		if seen[w] || len([]rune(w)) < 2 {
+			continue
+		}
+		seen[w] = true
+		ws = append(ws, w)
+		if len(ws) == maxPhotoWords {
+			break
+		}
+	}
+	return ws
+}
+
+// photoReply recognizes the words on the largest size of the photo and sends
+// them as buttons defining each of them.
+func photoReply(s *State, m *Message) error {
+	chatID := m.Chat.Id
+	if s.OCR == nil {
+		return UserError{ChatID: chatID, Err: localizedErrorf("Recognizing words on photos isn't enabled.")}
+	}
+	p := m.LargestPhoto()
+	if p.FileSize > maxDownloadSize {
+		return UserError{ChatID: chatID, Err: localizedErrorf("the file is too large")}
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	data, err := s.Telegram.DownloadFile(p.FileId)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: downloading photo: %w", err)
+	}
+	text, err := s.OCR.Recognize(data, settings.InputLanguageISO639_3)
+	if err != nil {
+		s.Log.Warn("Recognizing photo", "language", settings.InputLanguageISO639_3, "err", err)
+		return UserError{ChatID: chatID, Err: localizedErrorf("Couldn't recognize the text on the photo, try again later.")}
+	}
+	ws := photoWords(text)
+	if len(ws) == 0 {
+		return UserError{ChatID: chatID, Err: localizedErrorf("Couldn't find any words on the photo.")}
+	}
+	l := s.L(chatID)
+	var ks [][]*InlineKeyboard
+	for i, w := range ws {
+		if i%photoWordsPerRow == 0 {
+			ks = append(ks, nil)
+		}
+		ks[len(ks)-1] = append(ks[len(ks)-1], DefineWordCallback{w}.AsInlineKeyboard(l))
+	}
+	return s.Telegram.SendMessage(&MessageReply{
+		ChatId:      chatID,
+		Text:        l.T("Tap a word to define it:"),
+		ReplyMarkup: &ReplyMarkup{InlineKeyboard: ks},
+	})
+}
+
+// DefineWordCallback looks up the word as if the user sent it.
+type DefineWordCallback struct {
+	Word string
+}
+
+func (DefineWordCallback) Call(s *State, q *CallbackQuery) error {
+	s.AnswerCallback(q, "")
+	return defineReply(s, q.Message.Chat.Id, CallbackInfoFromString(q.Data).Word)
+}
+
+func (DefineWordCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == DefineWordAction
+}
+
+func (c DefineWordCallback) AsInlineKeyboard(*Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: c.Word,
+		CallbackData: CallbackInfo{
+			Action: DefineWordAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
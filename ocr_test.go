@@ -0,0 +1,64 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTesseractServer(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tesseract" {
+			http.NotFound(w, r)
+			return
+		}
+		var opts struct{ Languages []string }
+		if err := json.Unmarshal([]byte(r.FormValue("options")), &opts); err != nil {
+			t.Errorf("decoding options: %v", err)
+		}
+		if !reflect.DeepEqual(opts.Languages, []string{"hun"}) {
+			t.Errorf("languages: got %q want [hun]", opts.Languages)
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("reading file: %v", err)
+		}
+		if b, _ := io.ReadAll(f); string(b) != "image" {
+			t.Errorf("file: got %q want image", b)
+		}
+		io.WriteString(w, `{"data":{"exit":{"code":0},"stderr":"","stdout":"Fekete falu\n"}}`)
+	}))
+	defer s.Close()
+
+	text, err := NewTesseractServer(s.URL+"/").Recognize([]byte("image"), "hun")
+	if err != nil || text != "Fekete falu\n" {
+		t.Errorf("Recognize: got %q, %v want %q", text, err, "Fekete falu\n")
+	}
+	if _, err := NewTesseractServer(s.URL+"/missing").Recognize([]byte("image"), "hun"); err == nil {
+		t.Error("Recognize succeeded with a missing server")
+	}
+}
+
+func TestPhotoWords(t *testing.T) {
+	got := photoWords("A világ egy falu.\nA világ, a falu; x")
+	want := []string{"világ", "egy", "falu"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("photoWords: got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,629 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Practice sessions, in which cards can be answered either with the buttons or
+// with text shortcuts.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// practiceAnswers are the text shortcuts for the buttons of the practice card,
+// true for Know. There are only two answers, so "hard", "good" and "easy" are
+// all Know.
+var practiceAnswers = map[string]bool{
+	"1": false, "again": false,
+	"2": true, "hard": true,
+	"3": true, "good": true,
+	"4": true, "easy": true,
+}
+
+// defaultPracticeSessionLength is the number of cards in a practice session
+// if the user didn't set it.
+const defaultPracticeSessionLength = 20
+
+// snoozeInterval is how long a card put off with the Later button stays out of
+// the practice session the first time. It doubles every time the same card is
+// put off again, up to maxSnoozeInterval.
+const (
+	snoozeInterval    = 10 * time.Minute
+	maxSnoozeInterval = time.Hour
+)
+
+// studyAheadWindow limits studying ahead to the words that would become ready
+// for repetition soon anyway.
+const studyAheadWindow = 48 * time.Hour
+
+// practiceSession is a practice in progress.
+type practiceSession struct {
+	// Language to which the practice is limited, empty for all.
+	Language string
+	// Whether the words that aren't ready for repetition yet are practiced,
+	// see studyAheadWindow.
+	Ahead bool
+	// Whether only the starred cards are practiced, each once regardless of
+	// their schedule.
+	Starred  bool
+	Started  time.Time
+	Answered int
+	Known    int
+	// The card waiting for an answer, empty if none.
+	Word      string
+	MessageID int64
+	// Cards put off with the Later button: when they can be shown again and
+	// how many times they were put off.
+	Later   map[string]time.Time
+	Snoozes map[string]int
+	// Cards shown in the session, except the ones put off.
+	Shown map[string]bool
+}
+
+// PracticeSessions keeps practice sessions of the chats. Like commands in
+// progress they are lost on restart.
+type PracticeSessions struct {
+	mu       sync.Mutex
+	sessions map[int64]*practiceSession
+}
+
+func NewPracticeSessions() *PracticeSessions {
+	return &PracticeSessions{sessions: make(map[int64]*practiceSession)}
+}
+
+// Start starts a new session replacing the one in progress.
+func (p *PracticeSessions) Start(chatID int64, language string, ahead bool, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions[chatID] = &practiceSession{Language: language, Ahead: ahead, Started: now}
+}
+
+// StartStarred starts a new session with the starred cards, which are answered
+// as when studying ahead.
+func (p *PracticeSessions) StartStarred(chatID int64, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions[chatID] = &practiceSession{Ahead: true, Starred: true, Started: now}
+}
+
+// Starred returns whether the chat is practicing the starred cards.
+func (p *PracticeSessions) Starred(chatID int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.sessions[chatID]
+	return ps != nil && ps.Starred
+}
+
+// Shown returns the cards shown in the session, except the ones put off.
+func (p *PracticeSessions) Shown(chatID int64) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.sessions[chatID]
+	if ps == nil {
+		return nil
+	}
+	var ws []string
+	for w := range ps.Shown {
+		ws = append(ws, w)
+	}
+	sort.Strings(ws)
+	return ws
+}
+
+// Ahead returns whether the chat is studying ahead.
+func (p *PracticeSessions) Ahead(chatID int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.sessions[chatID]
+	return ps != nil && ps.Ahead
+}
+
+// SetCard records the card waiting for an answer. It starts a session if there
+// is none, e.g. for buttons sent before a restart.
+func (p *PracticeSessions) SetCard(chatID int64, language, word string, messageID int64, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.sessions[chatID]
+	if ps == nil {
+		ps = &practiceSession{Language: language, Started: now}
+		p.sessions[chatID] = ps
+	}
+	ps.Word, ps.MessageID = word, messageID
+	if ps.Shown == nil {
+		ps.Shown = make(map[string]bool)
+	}
+	ps.Shown[word] = true
+}
+
+// TakeCard returns the session if a card is waiting for an answer and forgets
+// the card, so that it's answered only once.
+func (p *PracticeSessions) TakeCard(chatID int64) (practiceSession, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.sessions[chatID]
+	if ps == nil || ps.Word == "" {
+		return practiceSession{}, false
+	}
+	r := *ps
+	ps.Word, ps.MessageID = "", 0
+	return r, true
+}
+
+// Answer counts the answer and returns the session. The session is over after
+// length answers, in which case it's dropped.
+func (p *PracticeSessions) Answer(chatID int64, known bool, length int) (ps practiceSession, over bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.sessions[chatID]
+	if s == nil {
+		return practiceSession{}, false
+	}
+	s.Answered++
+	if known {
+		s.Known++
+	}
+	if s.Answered < length {
+		return *s, false
+	}
+	delete(p.sessions, chatID)
+	return *s, true
+}
+
+// Snooze puts off the word for the rest of the session and returns for how
+// long. It starts a session if there is none, like SetCard.
+func (p *PracticeSessions) Snooze(chatID int64, language, word string, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.sessions[chatID]
+	if ps == nil {
+		ps = &practiceSession{Language: language, Started: now}
+		p.sessions[chatID] = ps
+	}
+	if ps.Later == nil {
+		ps.Later, ps.Snoozes = make(map[string]time.Time), make(map[string]int)
+	}
+	if ps.Word == word {
+		ps.Word, ps.MessageID = "", 0
+	}
+	delete(ps.Shown, word)
+	d := min(snoozeInterval<<ps.Snoozes[word], maxSnoozeInterval)
+	ps.Snoozes[word]++
+	ps.Later[word] = now.Add(d)
+	return d
+}
+
+// Later returns the words that are still put off at now.
+func (p *PracticeSessions) Later(chatID int64, now time.Time) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ps := p.sessions[chatID]
+	if ps == nil {
+		return nil
+	}
+	var ws []string
+	for w, t := range ps.Later {
+		if now.Before(t) {
+			ws = append(ws, w)
+		}
+	}
+	sort.Strings(ws)
+	return ws
+}
+
+// Stop drops the session in progress, if any.
+func (p *PracticeSessions) Stop(chatID int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sessions, chatID)
+}
+
+// practiceCommand starts practice. The optional argument limits it to the
+// words in one language, e.g. "/practice German".
+type practiceCommand struct {
+	name string
+}
+
+func PracticeCommandFactory() CommandFactory {
+	return func(name string) Command {
+		return &practiceCommand{name: name}
+	}
+}
+
+func (c *practiceCommand) Serialize() *SerializedCommand {
+	return &SerializedCommand{Name: c.name}
+}
+
+func (c *practiceCommand) Init(*SerializedCommand) error {
+	return nil
+}
+
+func (c *practiceCommand) OnCommand(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	_, arg := splitCommand(m.Text)
+	language := ""
+	if arg != "" {
+		l, err := s.Settings.Languages.Find(arg)
+		if err != nil {
+			return nil, UserError{ChatID: chatID, Err: err}
+		}
+		language = l.ISO639_3
+	}
+	// Cards are answered by the default command, see practiceAnswer.
+	return nil, startPractice(s, chatID, language, false)
+}
+
+func (c *practiceCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	return defaultCommand{}.ProcessMessage(s, m)
+}
+
+// practiceAnswer answers the card waiting in the practice session of the chat
+// if the message is one of practiceAnswers, however the session was started.
+// It returns false if there is no such card or it's another message, which is
+// processed as usual without ending the session.
+func practiceAnswer(s *State, m *Message) (bool, error) {
+	chatID := m.Chat.Id
+	known, ok := practiceAnswers[strings.ToLower(strings.TrimSpace(m.Text))]
+	if !ok {
+		return false, nil
+	}
+	ps, ok := s.Practice.TakeCard(chatID)
+	if !ok {
+		return false, nil
+	}
+	l := s.L(chatID)
+	star, err := starCallback(s, chatID, ps.Word)
+	if err != nil {
+		return true, err
+	}
+	ks := []*InlineKeyboard{AddNoteCallback{ps.Word}.AsInlineKeyboard(l), star.AsInlineKeyboard(l)}
+	if known {
+		ks = append([]*InlineKeyboard{DontKnowCallback{ps.Word, false, ""}.AsInlineKeyboard(l)}, ks...)
+	}
+	if err := answerCard(s, chatID, ps.Word, known, ps.Ahead); err != nil {
+		return true, err
+	}
+	cm := &Message{Id: ps.MessageID}
+	cm.Chat.Id = chatID
+	image, err := s.Repetitions.Image(chatID, ps.Word)
+	if err != nil {
+		return true, err
+	}
+	if image != "" {
+		// The card was sent as a photo, so its caption is flipped.
+		cm.Photo = []PhotoSize{{FileId: image}}
+	}
+	// The practice goes on if the card was deleted meanwhile.
+	if err := flipWordCard(s.Clients, ps.Word, cm, ks); err != nil && editFailure(s, chatID, err) == "" {
+		return true, err
+	}
+	return true, continuePractice(s, chatID, ps.Language, known)
+}
+
+// spreadBacklog spreads the overdue cards over the following days if the chat
+// is in the catch-up mode, see Repetition.SpreadBacklog.
+func spreadBacklog(s *State, chatID int64) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	if settings.CatchUp == 0 {
+		return nil
+	}
+	n, err := s.Repetitions.SpreadBacklog(chatID, settings.CatchUp, s.Clock.Now(), settings.DayLocation())
+	if n > 0 {
+		slog.Debug("Spread backlog", "chat_id", chatID, "moved", n)
+	}
+	return err
+}
+
+// startPractice starts a new session and sends its first card.
+func startPractice(s *State, chatID int64, language string, ahead bool) error {
+	if err := spreadBacklog(s, chatID); err != nil {
+		return err
+	}
+	s.Practice.Start(chatID, language, ahead, s.Clock.Now())
+	return practiceReply(s, chatID, language)
+}
+
+// continuePractice counts the answer and sends the next card or the summary if
+// the session is over.
+func continuePractice(s *State, chatID int64, language string, known bool) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	ps, over := s.Practice.Answer(chatID, known, settings.PracticeLength())
+	if !over {
+		return practiceReply(s, chatID, language)
+	}
+	due, err := s.Repetitions.DueCount(chatID)
+	if err != nil {
+		return err
+	}
+	l := s.L(chatID)
+	lines := []string{
+		l.T("Session finished! Known: %d of %d (%d%%).", ps.Known, ps.Answered, ps.Known*100/ps.Answered),
+		l.T("Time: %s.", s.Clock.Now().Sub(ps.Started).Round(time.Second)),
+		l.T("Still due: %d", due),
+	}
+	goal, err := goalLine(s, l, chatID, settings)
+	if err != nil {
+		return err
+	}
+	if goal != "" {
+		lines = append(lines, goal)
+	}
+	msg := strings.Join(lines, "\n")
+	var cs []Callback
+	if due > 0 {
+		cs = append(cs, ContinuePracticeCallback{language})
+	}
+	return s.Telegram.SendMessage(NewMessageReply(l, chatID, msg, cs))
+}
+
+// practiceReply sends the next practice card of the session to the user. If language isn't empty, only
+// the words in it are practiced.
+func practiceReply(s *State, chatID int64, language string) error {
+	switch UsePractice {
+	case PracticeKnowledge:
+	default:
+		panic(fmt.Sprintf("INTERNAL: Unimplemented practice type: %v", UsePractice))
+	}
+	ahead := s.Practice.Ahead(chatID)
+	until := s.Clock.Now()
+	if ahead {
+		until = until.Add(studyAheadWindow)
+	}
+	later := s.Practice.Later(chatID, s.Clock.Now())
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	var word string
+	if s.Practice.Starred(chatID) {
+		word, err = s.Repetitions.StarredWord(chatID, append(later, s.Practice.Shown(chatID)...)...)
+		if err == sql.ErrNoRows && len(later) == 0 {
+			return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("No more starred cards to practice."))
+		}
+		if err == sql.ErrNoRows {
+			return noPracticeReply(s, chatID, language, ahead)
+		}
+	} else {
+		word, err = s.Repetitions.RepeatWordOrdered(chatID, language, until, settings.NewCardOrder, later...)
+		if err == sql.ErrNoRows {
+			return noPracticeReply(s, chatID, language, ahead)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("retrieving word for repetition: %w", err)
+	}
+	cs := []Callback{
+		KnowCallback{word, true, language},
+		DontKnowCallback{word, true, language},
+		SnoozeCallback{word, language},
+	}
+	image, err := s.Repetitions.Image(chatID, word)
+	if err != nil {
+		return err
+	}
+	if image != "" {
+		return practiceImage(s, chatID, language, word, image, cs)
+	}
+	question, err := practiceQuestion(s, chatID, word)
+	if err != nil {
+		return err
+	}
+	if s.TTS != nil {
+		if question, cs, err = practiceAudio(s, chatID, word, question, cs); err != nil {
+			return err
+		}
+	}
+	m, err := s.Telegram.Send(NewMessageReply(s.L(chatID), chatID, question, cs))
+	if err != nil {
+		return err
+	}
+	s.Practice.SetCard(chatID, language, word, m.Id, s.Clock.Now())
+	return nil
+}
+
+// practiceImage sends the practice card with the photo on its front instead of
+// the word, which is then the answer.
+func practiceImage(s *State, chatID int64, language, word, image string, cs []Callback) error {
+	l := s.L(chatID)
+	r := NewMessageReply(l, chatID, "", cs)
+	m, err := s.Telegram.SendPhoto(&PhotoReply{
+		ChatId:      chatID,
+		Photo:       image,
+		Caption:     l.T("Which word is it?"),
+		ReplyMarkup: r.ReplyMarkup,
+	})
+	if err != nil {
+		return err
+	}
+	s.Practice.SetCard(chatID, language, word, m.Id, s.Clock.Now())
+	return nil
+}
+
+// practiceQuestion returns the front of the practice card: the word or, if
+// the user chose so, a sentence with the word blanked out.
+func practiceQuestion(s *State, chatID int64, word string) (string, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return "", err
+	}
+	if !settings.ClozePractice {
+		return word, nil
+	}
+	language, err := s.Repetitions.Language(chatID, word)
+	if err != nil {
+		return "", err
+	}
+	if language == "" {
+		language = settings.InputLanguageISO639_3
+	}
+	c, err := s.Usage.ClozeSentence(word, language)
+	if err == sql.ErrNoRows {
+		return word, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("INTERNAL: retrieving sentence with %q: %w", word, err)
+	}
+	return c, nil
+}
+
+// practiceAudio adds the Listen button to the practice card if there is a
+// voice for the word. In listening practice the word is sent spoken instead and
+// the question only refers to it.
+func practiceAudio(s *State, chatID int64, word, question string, cs []Callback) (string, []Callback, error) {
+	language, err := cardLanguage(s, chatID, word)
+	if err != nil {
+		return "", nil, err
+	}
+	if !s.TTS.Speaks(language) {
+		return question, cs, nil
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !settings.ListeningPractice {
+		return question, append(cs, ListenCallback{word, language}), nil
+	}
+	if err := sendSpeech(s, chatID, word, language); err != nil {
+		return "", nil, err
+	}
+	return s.L(chatID).T("🔊 Which word did you hear?"), cs, nil
+}
+
+// noPracticeReply tells that there is nothing to practice and offers to study
+// ahead if some words become ready for repetition soon.
+func noPracticeReply(s *State, chatID int64, language string, ahead bool) error {
+	l := s.L(chatID)
+	// FIXME: Make this user error instead.
+	msg := l.T("No more rows to practice; exiting practice mode.")
+	if len(s.Practice.Later(chatID, s.Clock.Now())) > 0 {
+		return s.Telegram.SendTextMessage(chatID, l.T("Nothing else to practice now, the cards put off for later come back in a few minutes."))
+	}
+	if ahead {
+		return s.Telegram.SendTextMessage(chatID, msg)
+	}
+	_, err := s.Repetitions.RepeatWordBefore(chatID, language, s.Clock.Now().Add(studyAheadWindow))
+	if err == sql.ErrNoRows {
+		return s.Telegram.SendTextMessage(chatID, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("retrieving word for studying ahead: %w", err)
+	}
+	return s.Telegram.SendMessage(NewMessageReply(l, chatID, msg, []Callback{StudyAheadCallback{language}}))
+}
+
+// SnoozeCallback puts off the practice card for later in the session without
+// answering it, see PracticeSessions.Snooze.
+type SnoozeCallback struct {
+	Word string
+	// Language to which the practice is limited, empty for all.
+	Language string
+}
+
+func (SnoozeCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	d := s.Practice.Snooze(chatID, info.Setting, info.Word, s.Clock.Now())
+	s.AnswerCallback(q, s.L(chatID).T("%q will be back in %d minutes", info.Word, int(d.Minutes())))
+	if err := editKeyboard(s, q.Message, nil); err != nil {
+		return err
+	}
+	return practiceReply(s, chatID, info.Setting)
+}
+
+func (SnoozeCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == SnoozeAction
+}
+
+func (c SnoozeCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Later"),
+		CallbackData: CallbackInfo{
+			Action:  SnoozeAction,
+			Word:    c.Word,
+			Setting: c.Language,
+		}.String(),
+	}
+}
+
+// ContinuePracticeCallback starts a new session after the previous one is over.
+type ContinuePracticeCallback struct {
+	// Language to which the practice is limited, empty for all.
+	Language string
+}
+
+func (ContinuePracticeCallback) Call(s *State, q *CallbackQuery) error {
+	s.AnswerCallback(q, "")
+	if err := editKeyboard(s, q.Message, nil); err != nil {
+		return err
+	}
+	return startPractice(s, q.Message.Chat.Id, CallbackInfoFromString(q.Data).Setting, false)
+}
+
+func (ContinuePracticeCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == ContinuePracticeAction
+}
+
+func (c ContinuePracticeCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Continue"),
+		CallbackData: CallbackInfo{
+			Action:  ContinuePracticeAction,
+			Setting: c.Language,
+		}.String(),
+	}
+}
+
+// StudyAheadCallback starts a session with the words that become ready for
+// repetition within studyAheadWindow.
+type StudyAheadCallback struct {
+	// Language to which the practice is limited, empty for all.
+	Language string
+}
+
+func (StudyAheadCallback) Call(s *State, q *CallbackQuery) error {
+	s.AnswerCallback(q, "")
+	if err := editKeyboard(s, q.Message, nil); err != nil {
+		return err
+	}
+	return startPractice(s, q.Message.Chat.Id, CallbackInfoFromString(q.Data).Setting, true)
+}
+
+func (StudyAheadCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == StudyAheadAction
+}
+
+func (c StudyAheadCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Study ahead"),
+		CallbackData: CallbackInfo{
+			Action:  StudyAheadAction,
+			Setting: c.Language,
+		}.String(),
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPracticeSessions(t *testing.T) {
+	p := NewPracticeSessions()
+	const chatID int64 = 1
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	if _, over := p.Answer(chatID, true, 2); over {
+		t.Error("Answer without a session ended it")
+	}
+	p.Start(chatID, "deu", false, start)
+	p.SetCard(chatID, "deu", "Haus", 7, start)
+	ps, ok := p.TakeCard(chatID)
+	if !ok || ps.Word != "Haus" || ps.MessageID != 7 || ps.Language != "deu" {
+		t.Errorf("TakeCard: got %+v, %t", ps, ok)
+	}
+	if _, ok := p.TakeCard(chatID); ok {
+		t.Error("the card was taken twice")
+	}
+	if ps, over := p.Answer(chatID, true, 2); over || ps.Answered != 1 {
+		t.Errorf("first Answer: got %+v, %t want 1 answer, not over", ps, over)
+	}
+	ps, over := p.Answer(chatID, false, 2)
+	if !over || ps.Answered != 2 || ps.Known != 1 || !ps.Started.Equal(start) {
+		t.Errorf("second Answer: got %+v, %t want 1 of 2 known, over", ps, over)
+	}
+	if _, ok := p.TakeCard(chatID); ok {
+		t.Error("the session is still there after it's over")
+	}
+}
+
+func TestPracticeSnooze(t *testing.T) {
+	p := NewPracticeSessions()
+	const chatID int64 = 1
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	if ws := p.Later(chatID, now); ws != nil {
+		t.Errorf("Later without a session: got %q", ws)
+	}
+	p.Start(chatID, "", false, now)
+	p.SetCard(chatID, "", "Haus", 7, now)
+	for i, want := range []time.Duration{10 * time.Minute, 20 * time.Minute, 40 * time.Minute, time.Hour, time.Hour} {
+		if d := p.Snooze(chatID, "", "Haus", now); d != want {
+			t.Errorf("snooze #%d: got %v want %v", i+1, d, want)
+		}
+	}
+	if _, ok := p.TakeCard(chatID); ok {
+		t.Error("the snoozed card is still waiting for an answer")
+	}
+	p.Snooze(chatID, "", "Baum", now)
+	if ws := p.Later(chatID, now.Add(30*time.Minute)); len(ws) != 1 || ws[0] != "Haus" {
+		t.Errorf("Later after 30 minutes: got %q want [Haus]", ws)
+	}
+	if ws := p.Later(chatID, now.Add(time.Hour)); len(ws) != 0 {
+		t.Errorf("Later after an hour: got %q want none", ws)
+	}
+	if ps, _ := p.Answer(chatID, true, 20); ps.Answered != 1 {
+		t.Errorf("snoozes were counted as answers: %+v", ps)
+	}
+	p.SetCard(chatID, "", "Baum", 8, now)
+	p.SetCard(chatID, "", "Tisch", 9, now)
+	p.Snooze(chatID, "", "Tisch", now)
+	if ws := p.Shown(chatID); len(ws) != 1 || ws[0] != "Baum" {
+		t.Errorf("Shown: got %q want [Baum]", ws)
+	}
+}
+
+func TestPracticeStarred(t *testing.T) {
+	p := NewPracticeSessions()
+	const chatID int64 = 1
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	p.Start(chatID, "", false, now)
+	if p.Starred(chatID) {
+		t.Error("a usual session is starred")
+	}
+	p.StartStarred(chatID, now)
+	if !p.Starred(chatID) || !p.Ahead(chatID) {
+		t.Error("starred session isn't practiced ahead of the schedule")
+	}
+}
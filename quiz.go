@@ -0,0 +1,185 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Quiz is a practice session of a fixed number of cards which ends with a
+// summary, unlike the open-ended /practice.
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// quizLength is the maximum number of cards in a quiz.
+const quizLength = 10
+
+type quizSession struct {
+	words []string
+	// Index of the word that is asked now.
+	current int
+	known   int
+	missed  []string
+	started time.Time
+}
+
+// QuizResult is the outcome of a finished quiz.
+type QuizResult struct {
+	Known    int
+	Total    int
+	Duration time.Duration
+	// Words that weren't known, in the order they were asked.
+	Missed []string
+}
+
+// Quizzes keeps quizzes in progress. Like commands in progress they are lost
+// on restart.
+type Quizzes struct {
+	clock Clock
+
+	mu       sync.Mutex
+	sessions map[int64]*quizSession
+}
+
+func NewQuizzes(clock Clock) *Quizzes {
+	return &Quizzes{clock: clock, sessions: make(map[int64]*quizSession)}
+}
+
+// Start starts a new quiz for the chat with the given words replacing the one
+// in progress and returns the first word.
+func (q *Quizzes) Start(chatID int64, words []string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sessions[chatID] = &quizSession{words: words, started: q.clock.Now()}
+	return words[0]
+}
+
+// Stop drops the quiz in progress, if any.
+func (q *Quizzes) Stop(chatID int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.sessions, chatID)
+}
+
+// Answer records the answer for the word and returns the next word to ask or
+// the result if the quiz is over. ok is false if the word isn't the one asked
+// in the current quiz, e.g. on a repeated tap or for a card from an old quiz.
+func (q *Quizzes) Answer(chatID int64, word string, known bool) (next string, res *QuizResult, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := q.sessions[chatID]
+	if s == nil || s.words[s.current] != word {
+		return "", nil, false
+	}
+	if known {
+		s.known++
+	} else {
+		s.missed = append(s.missed, word)
+	}
+	s.current++
+	if s.current < len(s.words) {
+		return s.words[s.current], nil, true
+	}
+	delete(q.sessions, chatID)
+	return "", &QuizResult{
+		Known:    s.known,
+		Total:    len(s.words),
+		Duration: q.clock.Now().Sub(s.started),
+		Missed:   s.missed,
+	}, true
+}
+
+// quizReply starts a quiz with the cards in the input language that are due.
+func quizReply(s *State, chatID int64) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	ws, err := s.Repetitions.DueWords(chatID, settings.InputLanguageISO639_3, quizLength)
+	if err != nil {
+		return err
+	}
+	if len(ws) == 0 {
+		return s.Telegram.SendTextMessage(chatID, s.L(chatID).T("No words are due for practice, nothing to quiz you on."))
+	}
+	return askQuizCard(s, chatID, s.Quizzes.Start(chatID, ws))
+}
+
+func askQuizCard(s *State, chatID int64, word string) error {
+	return s.Telegram.SendMessage(NewMessageReply(s.L(chatID), chatID, word,
+		[]Callback{QuizAnswerCallback{word, true}, QuizAnswerCallback{word, false}}))
+}
+
+// quizSummary describes the result of the quiz.
+func quizSummary(l *Localizer, r *QuizResult) string {
+	lines := []string{
+		l.T("Quiz finished! Score: %d/%d.", r.Known, r.Total),
+		l.T("Time: %s.", r.Duration.Round(time.Second)),
+	}
+	if len(r.Missed) > 0 {
+		lines = append(lines, l.T("Words to revisit: %s.", strings.Join(r.Missed, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// QuizAnswerCallback answers the card in the quiz.
+type QuizAnswerCallback struct {
+	Word  string
+	Known bool
+}
+
+func (QuizAnswerCallback) Call(s *State, q *CallbackQuery) error {
+	info := CallbackInfoFromString(q.Data)
+	chatID := q.Message.Chat.Id
+	known := info.Action == QuizKnowAction
+	next, res, ok := s.Quizzes.Answer(chatID, info.Word, known)
+	if !ok {
+		s.AnswerCallback(q, s.L(chatID).T("This quiz is over."))
+		return editKeyboard(s, q.Message, nil)
+	}
+	if err := answerCard(s, chatID, info.Word, known, false); err != nil {
+		return err
+	}
+	toast := ""
+	if err := flipWordCard(s.Clients, info.Word, q.Message, nil); err != nil {
+		if toast = editFailure(s, chatID, err); toast == "" {
+			return err
+		}
+	}
+	s.AnswerCallback(q, toast)
+	if res != nil {
+		return s.Telegram.SendTextMessage(chatID, quizSummary(s.L(chatID), res))
+	}
+	return askQuizCard(s, chatID, next)
+}
+
+func (QuizAnswerCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == QuizKnowAction || info.Action == QuizDontKnowAction
+}
+
+func (c QuizAnswerCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	t, a := "Don't know", QuizDontKnowAction
+	if c.Known {
+		t, a = "Know", QuizKnowAction
+	}
+	return &InlineKeyboard{
+		Text: l.T(t),
+		CallbackData: CallbackInfo{
+			Action: a,
+			Word:   c.Word,
+		}.String(),
+	}
+}
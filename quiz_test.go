@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQuizzes(t *testing.T) {
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	const chatID int64 = 1
+	q := NewQuizzes(clock)
+	if _, _, ok := q.Answer(chatID, "foo", true); ok {
+		t.Errorf("Answer without a quiz: got ok")
+	}
+	if w := q.Start(chatID, []string{"foo", "bar", "baz"}); w != "foo" {
+		t.Errorf("Start: got %q want foo", w)
+	}
+	if _, _, ok := q.Answer(chatID, "bar", true); ok {
+		t.Errorf("Answer for a word that isn't asked: got ok")
+	}
+	for _, a := range []struct {
+		word  string
+		known bool
+		next  string
+	}{
+		{"foo", false, "bar"},
+		{"bar", true, "baz"},
+	} {
+		next, res, ok := q.Answer(chatID, a.word, a.known)
+		if !ok || res != nil || next != a.next {
+			t.Errorf("Answer(%q): got %q, %v, %t want %q, nil, true", a.word, next, res, ok, a.next)
+		}
+	}
+	// Repeated tap on the same card is ignored.
+	if _, _, ok := q.Answer(chatID, "bar", true); ok {
+		t.Errorf("repeated Answer: got ok")
+	}
+
+	clock.Advance(90 * time.Second)
+	_, res, ok := q.Answer(chatID, "baz", false)
+	if !ok {
+		t.Fatalf("Answer(baz): got not ok")
+	}
+	want := &QuizResult{
+		Known:    1,
+		Total:    3,
+		Duration: 90 * time.Second,
+		Missed:   []string{"foo", "baz"},
+	}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Errorf("QuizResult (-want +got):\n%s", diff)
+	}
+	if got, want := quizSummary(nil, res), "Quiz finished! Score: 1/3.\nTime: 1m30s.\nWords to revisit: foo, baz."; got != want {
+		t.Errorf("quizSummary: got %q want %q", got, want)
+	}
+	if _, _, ok := q.Answer(chatID, "foo", true); ok {
+		t.Errorf("Answer after the quiz is over: got ok")
+	}
+}
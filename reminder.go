@@ -15,42 +15,104 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 )
 
 type Notification struct {
 	ChatID int64
+	// Number of words ready for practice.
+	DueCount int
+	// Word is the first card ready for practice to show in the notification,
+	// empty if the user didn't opt in.
+	Word string
 }
 
 // reminder
 type Reminder struct {
 	sendNofication func(*Notification) error
 	fetchSettings  func() (map[int64]*Settings, error)
+	dueCount       func(chatID int64) (int, error)
+	// firstCard returns the card that practice would start with.
+	firstCard func(chatID int64, now time.Time, s *Settings) (string, error)
+	// nextReview returns the time when the earliest word of the chat becomes
+	// ready for practice.
+	nextReview func(chatID int64) (time.Time, error)
+	// stats returns review statistics since the given time.
+	stats       func(chatID int64, since time.Time, loc *time.Location) (*ReviewStats, error)
+	sendSummary func(chatID int64, summary string) error
+	// streak returns streaks of the chat as of the given time.
+	streak            func(chatID int64, now time.Time, loc *time.Location) (*Streak, error)
+	sendStreakWarning func(chatID int64, warning string) error
+	// reviewsToday returns the number of reviews since the local midnight.
+	reviewsToday  func(chatID int64, now time.Time, loc *time.Location) (int, error)
+	sendGoalNudge func(chatID int64, nudge string) error
+	// sendCardReady notifies that the review queue isn't empty anymore.
+	sendCardReady func(chatID int64, msg string) error
+	sendPrompt    func(chatID int64, prompt string) error
+	// spreadBacklog moves the overdue cards that don't fit into the
+	// catch-up limit to the following days.
+	spreadBacklog func(chatID int64, perDay int, now time.Time, loc *time.Location) (int, error)
+	// inactive returns whether the chat blocked the bot, nothing is sent to
+	// it then. markInactive records that it did. Both are nil if blocked
+	// chats aren't tracked.
+	inactive     func(chatID int64) bool
+	markInactive func(chatID int64) error
 
 	// db stores last reminder time for each chat ID.
-	db *sql.DB
+	db    *sql.DB
+	clock Clock
 }
 
 func NewReminder(c *Clients, db *sql.DB) (*Reminder, error) {
-
-	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS Reminders (
-			chat_id INTEGER PRIMARY KEY,
-			last_reminder_time_seconds INTEGER -- seconds since UNIX epoch
-		);`); err != nil {
-		return nil, err
-	}
-
-	return &Reminder{
-		db: db,
+	r := &Reminder{
+		db:    db,
+		clock: c.Clock,
 		sendNofication: func(n *Notification) error {
-			log.Printf("Notification: %v", n)
-			return nil
+			slog.Debug("Sending notification", "chat_id", n.ChatID, "due", n.DueCount)
+			l := c.L(n.ChatID)
+			msg := l.T("You have %d words ready for practice!", n.DueCount)
+			if n.DueCount == 1 {
+				msg = l.T("You have 1 word ready for practice!")
+			}
+			if n.Word != "" {
+				return c.Telegram.SendMessage(NewMessageReply(l, n.ChatID, msg+"\n\n"+n.Word, []Callback{ShowAnswerCallback{n.Word, true}}))
+			}
+			return c.Telegram.SendMessage(NewMessageReply(l, n.ChatID, msg, []Callback{StartPracticeCallback{}}))
 		},
 		fetchSettings: c.Settings.GetAll,
-	}, nil
+		dueCount:      c.Repetitions.DueCount,
+		firstCard: func(chatID int64, now time.Time, s *Settings) (string, error) {
+			return c.Repetitions.RepeatWordOrdered(chatID, "", now, s.NewCardOrder)
+		},
+		nextReview: c.Repetitions.NextReview,
+		stats:      c.Repetitions.Stats,
+		sendSummary: func(chatID int64, summary string) error {
+			return c.Telegram.SendTextMessage(chatID, summary)
+		},
+		streak: c.Repetitions.Streak,
+		sendStreakWarning: func(chatID int64, warning string) error {
+			return c.Telegram.SendMessage(NewMessageReply(c.L(chatID), chatID, warning, []Callback{StartPracticeCallback{}}))
+		},
+		reviewsToday:  c.Repetitions.ReviewsToday,
+		spreadBacklog: c.Repetitions.SpreadBacklog,
+		sendGoalNudge: func(chatID int64, nudge string) error {
+			return c.Telegram.SendMessage(NewMessageReply(c.L(chatID), chatID, nudge, []Callback{StartPracticeCallback{}}))
+		},
+		sendCardReady: func(chatID int64, msg string) error {
+			return c.Telegram.SendMessage(NewMessageReply(c.L(chatID), chatID, msg, []Callback{StartPracticeCallback{}}))
+		},
+		sendPrompt: func(chatID int64, prompt string) error {
+			return c.Telegram.SendTextMessage(chatID, prompt)
+		},
+	}
+	if c.Inactive != nil {
+		r.inactive, r.markInactive = c.Inactive.Has, c.Inactive.Mark
+	}
+	return r, nil
 }
 
 func (r *Reminder) LastReminderTime(chatID int64) (time.Time, error) {
@@ -74,36 +136,383 @@ func (r *Reminder) UpdateLastReminderTime(chatID int64) error {
 	_, err := r.db.Exec(`
 		INSERT OR REPLACE INTO Reminders(chat_id, last_reminder_time_seconds) VALUES
 		($0, $1);`,
-		chatID, time.Now().Unix())
+		chatID, r.clock.Now().Unix())
 	if err != nil {
 		return fmt.Errorf("INTERNAL: Failed updating reminder_time: %w", err)
 	}
 	return nil
 }
 
+// NextReminderTime returns the earliest time when the chat can be reminded:
+// when its first word becomes ready for practice, but not sooner than the
+// reminder frequency allows and only within the availability window.
+// sql.ErrNoRows is returned if there is nothing to practice.
+func (r *Reminder) NextReminderTime(chatID int64, s *Settings) (time.Time, error) {
+	lt, err := r.LastReminderTime(chatID)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, err
+	}
+	t, err := r.nextReview(chatID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if minT := lt.Add(24 * time.Hour / time.Duration(s.RemindersPerDay())); t.Before(minT) {
+		t = minT
+	}
+	w := s.Windows()
+	return w.Clamp(t.In(s.Location())), nil
+}
+
+// TrySendNotification sends a notification to the chat if some words are ready
+// for practice and it's time to remind according to NextReminderTime.
+func (r *Reminder) TrySendNotification(chatID int64, s *Settings) error {
+	if s.NoReminders(r.clock.Now()) {
+		return nil
+	}
+	rt, err := r.NextReminderTime(chatID, s)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r.clock.Now().Before(rt) {
+		return nil
+	}
+	// In the catch-up mode, only the cards left for today are counted.
+	if s.CatchUp > 0 {
+		if _, err := r.spreadBacklog(chatID, s.CatchUp, r.clock.Now(), s.DayLocation()); err != nil {
+			return err
+		}
+	}
+	n, err := r.dueCount(chatID)
+	if err != nil {
+		return err
+	}
+	// Nothing to practice, no reason to bother the user.
+	if n == 0 {
+		return nil
+	}
+	nt := &Notification{ChatID: chatID, DueCount: n}
+	if s.ReminderCard {
+		nt.Word, err = r.firstCard(chatID, r.clock.Now(), s)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	if err := r.sendNofication(nt); err != nil {
+		return err
+	}
+	return r.UpdateLastReminderTime(chatID)
+}
+
+const summaryPeriod = 7 * 24 * time.Hour
+
+// WeeklySummary formats the summary of the week.
+func WeeklySummary(l *Localizer, st *ReviewStats) string {
+	var b strings.Builder
+	b.WriteString(l.T("Your week in review:") + "\n\n")
+	b.WriteString(l.T("Reviews done: %d", st.Reviews) + "\n")
+	b.WriteString(l.T("New words added: %d", st.Added) + "\n")
+	if st.Reviews > 0 {
+		b.WriteString(l.T("Retention: %.0f%%", st.Retention*100) + "\n")
+	}
+	b.WriteString(l.T("Longest streak: %d days", st.LongestStreak) + "\n")
+	if len(st.Hardest) > 0 {
+		b.WriteString(l.T("Hardest words: %s", strings.Join(st.Hardest, ", ")) + "\n")
+	}
+	return b.String()
+}
+
+func (r *Reminder) lastSummaryTime(chatID int64) (time.Time, error) {
+	row := r.db.QueryRow(`
+		SELECT last_summary_time_seconds
+		FROM Summaries
+		WHERE chat_id = $0`,
+		chatID)
+	var u int64
+	if err := row.Scan(&u); err != nil && err != sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("INTERNAL: retrieving last_summary_time_seconds for chat id %d: %w", chatID, err)
+	}
+	return time.Unix(u, 0), nil
+}
+
+// TrySendSummary sends a weekly summary to the chat if user opted in and a
+// week has passed since the last one.
+func (r *Reminder) TrySendSummary(chatID int64, s *Settings) error {
+	if !s.WeeklySummary {
+		return nil
+	}
+	lt, err := r.lastSummaryTime(chatID)
+	if err != nil {
+		return err
+	}
+	w := s.Windows()
+	now := r.clock.Now()
+	if now.Before(w.Clamp(lt.Add(summaryPeriod).In(s.Location()))) {
+		return nil
+	}
+	st, err := r.stats(chatID, now.Add(-summaryPeriod), s.DayLocation())
+	if err != nil {
+		return err
+	}
+	if err := r.sendSummary(chatID, WeeklySummary(NewLocalizer(s.BotLanguage), st)); err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT OR REPLACE INTO Summaries(chat_id, last_summary_time_seconds) VALUES
+		($0, $1);`,
+		chatID, now.Unix())
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating summary time: %w", err)
+	}
+	return nil
+}
+
+// streakWarningHour is the local hour after which users who didn't practice
+// today are warned that their streak is about to break.
+const streakWarningHour = 20
+
+// TrySendStreakWarning warns the chat in the evening, once a day, if its streak
+// breaks when the day ends unless the user practices today. Like reminders,
+// the warning is sent only within the availability window.
+func (r *Reminder) TrySendStreakWarning(chatID int64, s *Settings) error {
+	if s.NoReminders(r.clock.Now()) {
+		return nil
+	}
+	loc := s.DayLocation()
+	now := r.clock.Now().In(s.Location())
+	w := s.Windows()
+	if now.Hour() < streakWarningHour || !w.Clamp(now).Equal(now) {
+		return nil
+	}
+	row := r.db.QueryRow(`
+		SELECT last_warning_seconds
+		FROM StreakWarnings
+		WHERE chat_id = $0`,
+		chatID)
+	var last int64
+	if err := row.Scan(&last); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("INTERNAL: retrieving last_warning_seconds for chat id %d: %w", chatID, err)
+	}
+	if localDay(time.Unix(last, 0), loc).Equal(localDay(now, loc)) {
+		return nil
+	}
+	st, err := r.streak(chatID, now, loc)
+	if err != nil {
+		return err
+	}
+	if st.Current == 0 || st.ReviewedToday {
+		return nil
+	}
+	l := NewLocalizer(s.BotLanguage)
+	warning := l.T("Your %d-day streak ends at midnight, practice a word to keep it!", st.Current)
+	if s.DayStartHour > 0 {
+		warning = l.T("Your %d-day streak ends at %02d:00, practice a word to keep it!", st.Current, s.DayStartHour)
+	}
+	if err := r.sendStreakWarning(chatID, warning); err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT OR REPLACE INTO StreakWarnings(chat_id, last_warning_seconds) VALUES
+		($0, $1);`,
+		chatID, now.Unix())
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating streak warning time: %w", err)
+	}
+	return nil
+}
+
+// goalNudgeHour is the local hour after which users who haven't reached their
+// daily goal are nudged. It's before streakWarningHour, so that both don't
+// arrive at once.
+const goalNudgeHour = 18
+
+// TrySendGoalNudge reminds the chat in the evening, once a day, how many
+// reviews are left to reach the daily goal. Like reminders, the nudge is sent
+// only within the availability window.
+func (r *Reminder) TrySendGoalNudge(chatID int64, s *Settings) error {
+	if s.DailyGoal == 0 || s.NoReminders(r.clock.Now()) {
+		return nil
+	}
+	loc := s.DayLocation()
+	now := r.clock.Now().In(s.Location())
+	w := s.Windows()
+	if now.Hour() < goalNudgeHour || !w.Clamp(now).Equal(now) {
+		return nil
+	}
+	row := r.db.QueryRow(`
+		SELECT last_nudge_seconds
+		FROM GoalNudges
+		WHERE chat_id = $0`,
+		chatID)
+	var last int64
+	if err := row.Scan(&last); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("INTERNAL: retrieving last_nudge_seconds for chat id %d: %w", chatID, err)
+	}
+	if localDay(time.Unix(last, 0), loc).Equal(localDay(now, loc)) {
+		return nil
+	}
+	n, err := r.reviewsToday(chatID, now, loc)
+	if err != nil {
+		return err
+	}
+	if n >= s.DailyGoal {
+		return nil
+	}
+	l := NewLocalizer(s.BotLanguage)
+	if err := r.sendGoalNudge(chatID, l.T("%d more reviews to reach your daily goal of %d!", s.DailyGoal-n, s.DailyGoal)); err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT OR REPLACE INTO GoalNudges(chat_id, last_nudge_seconds) VALUES
+		($0, $1);`,
+		chatID, now.Unix())
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating goal nudge time: %w", err)
+	}
+	return nil
+}
+
+// TrySendCardReady notifies the chat once when its review queue goes from
+// empty to non-empty, without waiting for the reminder frequency. The first
+// check of a chat only records the state of its queue. If the queue fills up
+// outside the availability window, the notification waits for the window.
+func (r *Reminder) TrySendCardReady(chatID int64, s *Settings) error {
+	if s.NoReminders(r.clock.Now()) {
+		return nil
+	}
+	now := r.clock.Now()
+	t, err := r.nextReview(chatID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	empty := err == sql.ErrNoRows || now.Before(t)
+	row := r.db.QueryRow(`
+		SELECT empty
+		FROM ReviewQueues
+		WHERE chat_id = $0`,
+		chatID)
+	var wasEmpty bool
+	switch err := row.Scan(&wasEmpty); {
+	case err == sql.ErrNoRows:
+		return r.updateReviewQueue(chatID, empty)
+	case err != nil:
+		return fmt.Errorf("INTERNAL: retrieving review queue for chat id %d: %w", chatID, err)
+	}
+	switch {
+	case empty && !wasEmpty:
+		return r.updateReviewQueue(chatID, true)
+	case empty || !wasEmpty:
+		return nil
+	}
+	w := s.Windows()
+	if local := now.In(s.Location()); !w.Clamp(local).Equal(local) {
+		return nil
+	}
+	if err := r.sendCardReady(chatID, NewLocalizer(s.BotLanguage).T("Your first card is ready for practice!")); err != nil {
+		return err
+	}
+	// Regular reminders count from this one, so that they don't follow
+	// right away.
+	if err := r.UpdateLastReminderTime(chatID); err != nil {
+		return err
+	}
+	return r.updateReviewQueue(chatID, false)
+}
+
+func (r *Reminder) updateReviewQueue(chatID int64, empty bool) error {
+	_, err := r.db.Exec(`
+		INSERT OR REPLACE INTO ReviewQueues(chat_id, empty) VALUES
+		($0, $1);`,
+		chatID, empty)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating review queue: %w", err)
+	}
+	return nil
+}
+
+// promptWindow is the time of the day, in the time zone of the chat, when the
+// chats that never chose when to receive reminders are asked to do so.
+var promptWindow = &AvailabilityWindow{Start: 9 * 60 * 60, End: 21 * 60 * 60}
+
+// TrySendReminderPrompt asks the chats marked in ReminderPrompts, which got
+// reminders before they became opt-in, once to choose when to receive them.
+func (r *Reminder) TrySendReminderPrompt(chatID int64, s *Settings) error {
+	if s.Muted || len(s.Windows()) > 0 {
+		return nil
+	}
+	now := r.clock.Now().In(s.Location())
+	if !promptWindow.Clamp(now).Equal(now) {
+		return nil
+	}
+	row := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM ReminderPrompts
+		WHERE chat_id = $0 AND prompted_seconds IS NULL`,
+		chatID)
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return fmt.Errorf("INTERNAL: retrieving reminder prompt for chat id %d: %w", chatID, err)
+	}
+	if n == 0 {
+		return nil
+	}
+	l := NewLocalizer(s.BotLanguage)
+	if err := r.sendPrompt(chatID, l.T("Reminders to practice are now sent only if you choose when. Use /availability to set them up.")); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`
+		UPDATE ReminderPrompts SET prompted_seconds = $0
+		WHERE chat_id = $1;`,
+		now.Unix(), chatID)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating reminder prompt time: %w", err)
+	}
+	return nil
+}
+
+// trySend sends the chat all messages that are due. It stops at the first
+// one refused because the user blocked the bot, and marks the chat inactive.
+func (r *Reminder) trySend(chatID int64, s *Settings) {
+	for _, m := range []struct {
+		try  func(int64, *Settings) error
+		what string
+	}{
+		{r.TrySendCardReady, "card ready notification"},
+		{r.TrySendNotification, "reminder"},
+		{r.TrySendSummary, "summary"},
+		{r.TrySendStreakWarning, "streak warning"},
+		{r.TrySendGoalNudge, "goal nudge"},
+		{r.TrySendReminderPrompt, "reminder prompt"},
+	} {
+		err := m.try(chatID, s)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, errChatInactive) || r.markInactive == nil {
+			slog.Error("Sending "+m.what, "chat_id", chatID, "err", err)
+			continue
+		}
+		slog.Info("Chat blocked the bot, marking it inactive", "chat_id", chatID, "err", err)
+		if err := r.markInactive(chatID); err != nil {
+			slog.Error("Marking chat inactive", "chat_id", chatID, "err", err)
+		}
+		return
+	}
+}
+
 func (r *Reminder) Loop(ticker <-chan time.Time, cancel <-chan struct{}) {
 	for {
 		cs, err := r.fetchSettings()
 		if err != nil {
-			log.Printf("ERROR: fetchSettings: %v", err)
+			slog.Error("Fetching settings", "err", err)
 		}
-		// TODO: Take into account availability window, reminder frequency and timezone.
-		// newReminderTime = lastReminder + (aval window size)/Frequency
-		for chatID, _ := range cs {
-			const frequency = 1
-			rt, err := r.LastReminderTime(chatID)
-			if err != nil {
-				log.Print(err)
-			}
-			newRT := rt.Add(24 / frequency * time.Hour)
-			if time.Now().After(newRT) {
-				if err := r.sendNofication(&Notification{chatID}); err != nil {
-					log.Print(err)
-				}
-				if err := r.UpdateLastReminderTime(chatID); err != nil {
-					log.Print(err)
-				}
+		for chatID, s := range cs {
+			if r.inactive != nil && r.inactive(chatID) {
+				continue
 			}
+			r.trySend(chatID, s)
 		}
 		select {
 		case <-ticker:
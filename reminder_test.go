@@ -21,9 +21,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// remindedSettings returns the default settings of a user who chose to receive
+// reminders from 09:00 till 21:00.
+func remindedSettings() *Settings {
+	s := DefaultSettings()
+	s.AvailabilityWindow = &AvailabilityWindow{Start: 9 * 60 * 60, End: 21 * 60 * 60}
+	return s
+}
+
 func TestReminders(t *testing.T) {
 	dir, err := ioutil.TempDir("", "repetition")
 	if err != nil {
@@ -32,14 +41,11 @@ func TestReminders(t *testing.T) {
 	t.Logf("Temp dir: %q", dir)
 	defer os.RemoveAll(dir)
 
-	dbPath := filepath.Join(dir, "tmpdb")
-
-	settings, err := NewSettingsConfig(dbPath)
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	db, err := sql.Open("sqlite3", dbPath)
+	settings, err := NewSettingsConfig(db)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -52,7 +58,7 @@ func TestReminders(t *testing.T) {
 	}
 
 	const chatID int64 = 0
-	if err := settings.Set(chatID, DefaultSettings()); err != nil {
+	if err := settings.Set(chatID, remindedSettings()); err != nil {
 		t.Fatal(err)
 	}
 
@@ -69,6 +75,9 @@ func TestReminders(t *testing.T) {
 		sent = append(sent, n)
 		return nil
 	}
+	r.dueCount = func(int64) (int, error) { return 1, nil }
+	r.nextReview = func(int64) (time.Time, error) { return time.Unix(0, 0), nil }
+	r.clock = newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
 
 	r.Loop(c, cancel)
 
@@ -76,3 +85,523 @@ func TestReminders(t *testing.T) {
 		t.Errorf("got %d notifications (%v), want 1", len(sent), sent)
 	}
 }
+
+func TestRemindersFrequency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings: &SettingsConfig{},
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []*Notification
+	r.sendNofication = func(n *Notification) error {
+		sent = append(sent, n)
+		return nil
+	}
+	due := 0
+	r.dueCount = func(int64) (int, error) { return due, nil }
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r.clock = newFakeClock(now)
+	r.nextReview = func(int64) (time.Time, error) { return now.Add(-time.Hour), nil }
+
+	if err := r.TrySendNotification(1, remindedSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("nothing due: got %d notifications (%v), want 0", len(sent), sent)
+	}
+	due = 3
+
+	// Reminders are opt-in.
+	if err := r.TrySendNotification(1, DefaultSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("no window: got %d notifications (%v), want 0", len(sent), sent)
+	}
+
+	muted := remindedSettings()
+	muted.Muted = true
+	if err := r.TrySendNotification(1, muted); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("muted: got %d notifications (%v), want 0", len(sent), sent)
+	}
+
+	away := remindedSettings()
+	until := now.Add(time.Minute)
+	away.VacationUntil = &until
+	if err := r.TrySendNotification(1, away); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("on vacation: got %d notifications (%v), want 0", len(sent), sent)
+	}
+
+	s := remindedSettings()
+	s.ReminderFrequency = 24
+	if err := r.TrySendNotification(2, s); err != nil {
+		t.Fatal(err)
+	}
+	// Shouldn't be sent again right away.
+	if err := r.TrySendNotification(2, s); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("got %d notifications (%v), want 1", len(sent), sent)
+	}
+	// An hour later reminder is due.
+	if _, err := db.Exec(`UPDATE Reminders SET last_reminder_time_seconds = last_reminder_time_seconds - 3601`); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.TrySendNotification(2, s); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("got %d notifications (%v), want 2", len(sent), sent)
+	}
+	if len(sent) > 0 && sent[0].DueCount != 3 {
+		t.Errorf("got DueCount %d, want 3", sent[0].DueCount)
+	}
+
+	// In the catch-up mode the backlog is spread before counting.
+	catchUp := remindedSettings()
+	catchUp.CatchUp = 2
+	r.spreadBacklog = func(_ int64, perDay int, _ time.Time, _ *time.Location) (int, error) {
+		due = perDay
+		return 1, nil
+	}
+	if err := r.TrySendNotification(4, catchUp); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 3 || sent[2].DueCount != 2 {
+		t.Errorf("catch-up: got notifications %v, want the third with DueCount 2", sent)
+	}
+	due = 3
+
+	// The first card is shown only to those who opted in.
+	withCard := remindedSettings()
+	withCard.ReminderCard = true
+	r.firstCard = func(int64, time.Time, *Settings) (string, error) { return "alma", nil }
+	if err := r.TrySendNotification(5, withCard); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 4 || sent[3].Word != "alma" || sent[0].Word != "" {
+		t.Errorf("reminder card: got notifications %v, want only the fourth with Word \"alma\"", sent)
+	}
+
+	// Only words that are not yet ready.
+	r.nextReview = func(int64) (time.Time, error) { return now.Add(time.Hour), nil }
+	if err := r.TrySendNotification(3, remindedSettings()); err != nil {
+		t.Fatal(err)
+	}
+	// No words at all.
+	r.nextReview = func(int64) (time.Time, error) { return time.Time{}, sql.ErrNoRows }
+	if err := r.TrySendNotification(3, remindedSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 4 {
+		t.Errorf("words not ready: got %d notifications (%v), want 4", len(sent), sent)
+	}
+}
+
+func TestNextReminderTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings: &SettingsConfig{},
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name       string
+		nextReview time.Time
+		timeZone   string
+		window     *AvailabilityWindow
+		want       time.Time
+	}{
+		{
+			name:       "within window",
+			nextReview: day.Add(15 * time.Hour),
+			want:       day.Add(15 * time.Hour),
+		},
+		{
+			name:       "before window",
+			nextReview: day.Add(3 * time.Hour),
+			want:       day.Add(9 * time.Hour),
+		},
+		{
+			name:       "after window",
+			nextReview: day.Add(22 * time.Hour),
+			want:       day.Add(33 * time.Hour),
+		},
+		{
+			name:       "time zone",
+			nextReview: day.Add(3 * time.Hour),
+			timeZone:   "UTC+3",
+			want:       day.Add(6 * time.Hour),
+		},
+		{
+			name:       "window over midnight",
+			nextReview: day.Add(12 * time.Hour),
+			window:     &AvailabilityWindow{Start: 22 * 60 * 60, End: 2 * 60 * 60},
+			want:       day.Add(22 * time.Hour),
+		},
+		{
+			name:       "weekends only",
+			nextReview: day.Add(12 * time.Hour),
+			window:     &AvailabilityWindow{Start: 9 * 60 * 60, End: 21 * 60 * 60, Weekdays: 1<<time.Saturday | 1<<time.Sunday},
+			want:       day.AddDate(0, 0, 5).Add(9 * time.Hour),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := remindedSettings()
+			if tc.timeZone != "" {
+				s.TimeZone = tc.timeZone
+			}
+			if tc.window != nil {
+				s.AvailabilityWindow = tc.window
+			}
+			r.nextReview = func(int64) (time.Time, error) { return tc.nextReview, nil }
+			got, err := r.NextReminderTime(1, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("NextReminderTime = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWeeklySummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings: &SettingsConfig{},
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []string
+	r.sendSummary = func(_ int64, summary string) error {
+		sent = append(sent, summary)
+		return nil
+	}
+	r.stats = func(int64, time.Time, *time.Location) (*ReviewStats, error) {
+		return &ReviewStats{
+			Reviews:       4,
+			Added:         2,
+			Retention:     0.75,
+			LongestStreak: 3,
+			Hardest:       []string{"foo", "bar"},
+		}, nil
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r.clock = clock
+
+	// Not opted in.
+	if err := r.TrySendSummary(1, DefaultSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("got %d summaries (%v), want 0", len(sent), sent)
+	}
+
+	s := DefaultSettings()
+	s.WeeklySummary = true
+	if err := r.TrySendSummary(1, s); err != nil {
+		t.Fatal(err)
+	}
+	// A day later it's too early for the next one.
+	clock.Advance(24 * time.Hour)
+	if err := r.TrySendSummary(1, s); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`Your week in review:
+
+Reviews done: 4
+New words added: 2
+Retention: 75%
+Longest streak: 3 days
+Hardest words: foo, bar
+`}
+	if diff := cmp.Diff(sent, want); diff != "" {
+		t.Errorf("summaries: (-got +want):\n%s", diff)
+	}
+	clock.Advance(6 * 24 * time.Hour)
+	if err := r.TrySendSummary(1, s); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("got %d summaries, want 2", len(sent))
+	}
+}
+
+func TestStreakWarning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings: &SettingsConfig{},
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []string
+	r.sendStreakWarning = func(_ int64, warning string) error {
+		sent = append(sent, warning)
+		return nil
+	}
+	streak := &Streak{Current: 4, Longest: 4}
+	r.streak = func(int64, time.Time, *time.Location) (*Streak, error) { return streak, nil }
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r.clock = clock
+	s := DefaultSettings()
+	s.AvailabilityWindow = &AvailabilityWindow{Start: 9 * 60 * 60, End: 22 * 60 * 60}
+
+	check := func(step string, want int) {
+		t.Helper()
+		if err := r.TrySendStreakWarning(1, s); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != want {
+			t.Errorf("%s: got %d warnings (%q), want %d", step, len(sent), sent, want)
+		}
+	}
+	check("afternoon", 0)
+	clock.Advance(8 * time.Hour)
+	check("evening", 1)
+	check("again in the evening", 1)
+	if len(sent) > 0 && sent[0] != "Your 4-day streak ends at midnight, practice a word to keep it!" {
+		t.Errorf("got warning %q", sent[0])
+	}
+	clock.Advance(3 * time.Hour)
+	check("outside of the availability window", 1)
+
+	clock.Advance(21 * time.Hour)
+	streak = &Streak{Current: 5, Longest: 5, ReviewedToday: true}
+	check("practiced today", 1)
+	streak = &Streak{Longest: 5}
+	check("no streak", 1)
+	streak = &Streak{Current: 5, Longest: 5}
+	s.Muted = true
+	check("muted", 1)
+	s.Muted = false
+	check("next evening", 2)
+
+	clock.Advance(24 * time.Hour)
+	s.DayStartHour = 4
+	check("day starting at 4", 3)
+	if len(sent) > 2 && sent[2] != "Your 5-day streak ends at 04:00, practice a word to keep it!" {
+		t.Errorf("got warning %q", sent[2])
+	}
+}
+
+func TestGoalNudge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings: &SettingsConfig{},
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []string
+	r.sendGoalNudge = func(_ int64, nudge string) error {
+		sent = append(sent, nudge)
+		return nil
+	}
+	reviews := 12
+	r.reviewsToday = func(int64, time.Time, *time.Location) (int, error) { return reviews, nil }
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r.clock = clock
+	s := DefaultSettings()
+	s.AvailabilityWindow = &AvailabilityWindow{Start: 9 * 60 * 60, End: 22 * 60 * 60}
+
+	check := func(step string, want int) {
+		t.Helper()
+		if err := r.TrySendGoalNudge(1, s); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != want {
+			t.Errorf("%s: got %d nudges (%q), want %d", step, len(sent), sent, want)
+		}
+	}
+	s.DailyGoal = 30
+	check("afternoon", 0)
+	clock.Advance(7 * time.Hour)
+	s.DailyGoal = 0
+	check("no goal", 0)
+	s.DailyGoal = 30
+	check("evening", 1)
+	check("again in the evening", 1)
+	if len(sent) > 0 && sent[0] != "18 more reviews to reach your daily goal of 30!" {
+		t.Errorf("got nudge %q", sent[0])
+	}
+
+	clock.Advance(24 * time.Hour)
+	reviews = 30
+	check("goal reached", 1)
+	reviews = 0
+	s.Muted = true
+	check("muted", 1)
+	s.Muted = false
+	check("next evening", 2)
+}
+
+func TestCardReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings: &SettingsConfig{},
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []string
+	r.sendCardReady = func(_ int64, msg string) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r.clock = clock
+	next := clock.Now().Add(time.Hour)
+	nextErr := error(nil)
+	r.nextReview = func(int64) (time.Time, error) { return next, nextErr }
+	s := DefaultSettings()
+	s.AvailabilityWindow = &AvailabilityWindow{Start: 9 * 60 * 60, End: 22 * 60 * 60}
+
+	check := func(step string, want int) {
+		t.Helper()
+		if err := r.TrySendCardReady(1, s); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != want {
+			t.Errorf("%s: got %d notifications (%q), want %d", step, len(sent), sent, want)
+		}
+	}
+	check("first check", 0)
+	clock.Advance(2 * time.Hour)
+	check("card ready", 1)
+	if len(sent) > 0 && sent[0] != "Your first card is ready for practice!" {
+		t.Errorf("got notification %q", sent[0])
+	}
+	if lt, err := r.LastReminderTime(1); err != nil || !lt.Equal(clock.Now()) {
+		t.Errorf("LastReminderTime() = %v, %v want %v", lt, err, clock.Now())
+	}
+	check("still ready", 1)
+
+	next = clock.Now().Add(10 * time.Hour)
+	check("practiced", 1)
+	clock.Advance(11 * time.Hour)
+	check("ready at night", 1)
+	clock.Advance(9 * time.Hour)
+	check("ready in the morning", 2)
+
+	nextErr = sql.ErrNoRows
+	check("no cards", 2)
+	nextErr = nil
+	next = clock.Now()
+	s.Muted = true
+	check("muted", 2)
+	s.Muted = false
+	check("unmuted", 3)
+}
+
+func TestReminderPrompt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings: &SettingsConfig{},
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []int64
+	r.sendPrompt = func(chatID int64, _ string) error {
+		sent = append(sent, chatID)
+		return nil
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 7, 0, 0, 0, time.UTC))
+	r.clock = clock
+	if _, err := db.Exec(`INSERT INTO ReminderPrompts(chat_id) VALUES (1), (2)`); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(step string, chatID int64, s *Settings, want []int64) {
+		t.Helper()
+		if err := r.TrySendReminderPrompt(chatID, s); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, sent); diff != "" {
+			t.Errorf("%s: prompted chats differ (-want +got):\n%s", step, diff)
+		}
+	}
+	check("early morning", 1, DefaultSettings(), nil)
+	clock.Advance(3 * time.Hour)
+	check("morning", 1, DefaultSettings(), []int64{1})
+	check("again", 1, DefaultSettings(), []int64{1})
+	check("configured", 2, remindedSettings(), []int64{1})
+	check("not marked", 3, DefaultSettings(), []int64{1})
+}
@@ -16,18 +16,41 @@ package main
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/rand"
 	"strings"
 	"time"
+
+	"words/tokenize"
 )
 
 type Repetition struct {
-	db *sql.DB
+	db    *sql.DB
+	clock Clock
 	// FIXME: Probably not needed here. Maybe only the number of stages.
 	stages []time.Duration
+
+	// Statements of the queries run for every update or for every chat by
+	// the reminder, see prepare.
+	repeatWord    *sql.Stmt
+	dueCount      *sql.Stmt
+	nextReview    *sql.Stmt
+	answerKnow    *sql.Stmt
+	resetProgress *sql.Stmt
+	getDefinition *sql.Stmt
+	exists        *sql.Stmt
+	insertReview  *sql.Stmt
 }
 
-func NewRepetition(dbPath string, stages []time.Duration) (*Repetition, error) {
+// stageDuration is a subquery for the duration of the Repetition's stage. If
+// the number of stages shrinks, the duration of the last stage is used.
+const stageDuration = `
+	SELECT duration FROM Stages
+	WHERE Stages.id >= Repetition.stage
+	ORDER BY Stages.id
+	LIMIT 1`
+
+func NewRepetition(db *sql.DB, stages []time.Duration, clock Clock) (*Repetition, error) {
 	// this is arbitrary big number
 	const maxStages = 1_000_000
 	if len(stages) == 0 {
@@ -43,20 +66,12 @@ func NewRepetition(dbPath string, stages []time.Duration) (*Repetition, error) {
 	// insert large last id so that words with stages > len(stages) can still
 	// be queried (This can happen if number of stages shrinks)
 	sv = append(sv, fmt.Sprintf("(%d, %d)", maxStages, int64(stages[len(stages)-1].Seconds())))
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, err
-	}
 	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS Repetition (
-			chat_id INTEGER,
-			word STRING,
-			definition STRING,
-			stage INTEGER,
-			last_updated_seconds INTEGER -- seconds since UNIX epoch
-		);
-		CREATE TEMP TABLE IF NOT EXISTS Stages (
-			id INTEGER,
+		-- Stages aren't TEMP, so that they are visible to all connections
+		-- in the pool. They are replaced on every start.
+		DROP TABLE IF EXISTS Stages;
+		CREATE TABLE Stages (
+			id INTEGER PRIMARY KEY,
 			duration INTEGER
 		);
 		INSERT INTO Stages(id, duration)
@@ -67,6 +82,13 @@ func NewRepetition(dbPath string, stages []time.Duration) (*Repetition, error) {
 	); err != nil {
 		return nil, err
 	}
+	// Cards added before next_review_seconds was introduced.
+	if _, err := db.Exec(`
+		UPDATE Repetition
+		SET next_review_seconds = last_updated_seconds + (` + stageDuration + `)
+		WHERE next_review_seconds IS NULL;`); err != nil {
+		return nil, fmt.Errorf("populating next_review_seconds: %w", err)
+	}
 	row := db.QueryRow(`
 		SELECT COUNT(*)
 		FROM Repetition;`)
@@ -74,16 +96,47 @@ func NewRepetition(dbPath string, stages []time.Duration) (*Repetition, error) {
 	if err := row.Scan(&d); err != nil {
 		return nil, err
 	}
-	log.Printf("DEBUG: Repetition database initially contains %d rows!", d)
-	return &Repetition{db, stages}, nil
+	slog.Debug("Repetition database initially", "rows", d)
+	r := &Repetition{db: db, clock: clock, stages: stages}
+	if err := r.prepare(); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
-func (r *Repetition) Save(chatID int64, word, definition string) error {
+// prepare prepares the hot statements once instead of on every call.
+func (r *Repetition) prepare() error {
+	for _, s := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&r.repeatWord, repeatWordQuery},
+		{&r.dueCount, dueCountQuery},
+		{&r.nextReview, nextReviewQuery},
+		{&r.answerKnow, answerKnowQuery},
+		{&r.resetProgress, resetProgressQuery},
+		{&r.getDefinition, getDefinitionQuery},
+		{&r.exists, existsQuery},
+		{&r.insertReview, insertReviewQuery},
+	} {
+		stmt, err := r.db.Prepare(s.query)
+		if err != nil {
+			return fmt.Errorf("preparing %q: %w", s.query, err)
+		}
+		*s.stmt = stmt
+	}
+	return nil
+}
+
+// Save adds the word as a new card. language is the ISO 639-3 code of the
+// word's language.
+func (r *Repetition) Save(chatID int64, word, definition, language string) error {
 	// FIXME: Don't insert duplicates!!!
+	now := r.clock.Now().Unix()
 	_, err := r.db.Exec(`
-		INSERT INTO Repetition(chat_id, word, definition, stage, last_updated_seconds)
-		VALUES($0, $1, $2, $3, $4)`,
-		chatID, word, definition, 0, time.Now().Unix())
+		INSERT INTO Repetition(chat_id, word, definition, stage, last_updated_seconds, next_review_seconds, added_seconds, language, normalized_word)
+		VALUES($0, $1, $2, $3, $4, $5, $4, $6, $7)`,
+		chatID, word, definition, 0, now, now+int64(r.stages[0].Seconds()), language, tokenize.Normalize(word))
 	return err
 }
 
@@ -96,10 +149,9 @@ func (r *Repetition) Repeat(chatID int64) (string, error) {
 	row := r.db.QueryRow(`
 		SELECT word, definition
 		FROM Repetition
-		INNER JOIN Stages ON Repetition.stage <= Stages.id
-		WHERE Repetition.last_updated_seconds + Stages.duration <= $0
-		  AND Repetition.chat_id = $1;`,
-		time.Now().Unix(), chatID)
+		WHERE next_review_seconds <= $0
+		  AND chat_id = $1;`,
+		r.clock.Now().Unix(), chatID)
 	var w, d string
 	err := row.Scan(&w, &d)
 	if err != nil {
@@ -113,21 +165,402 @@ func (r *Repetition) Repeat(chatID int64) (string, error) {
 	return strings.ReplaceAll(d, w, "********"), nil
 }
 
-// Repeat retrieves a word ready for repetition.
+const repeatWordQuery = `
+	SELECT word
+	FROM Repetition
+	WHERE next_review_seconds <= $0
+	  AND chat_id = $1
+	  AND ($2 = '' OR language = $3);`
+
+// Repeat retrieves a word ready for repetition. If language isn't empty, only
+// the words in it are considered.
 // TODO: Deduplicate with Repeat?
-func (r *Repetition) RepeatWord(chatID int64) (string, error) {
-	row := r.db.QueryRow(`
-		SELECT word
-		FROM Repetition
-		INNER JOIN Stages ON Repetition.stage <= Stages.id
-		WHERE Repetition.last_updated_seconds + Stages.duration <= $0
-		  AND Repetition.chat_id = $1;`,
-		time.Now().Unix(), chatID)
+func (r *Repetition) RepeatWord(chatID int64, language string) (string, error) {
+	return r.RepeatWordBefore(chatID, language, r.clock.Now())
+}
+
+// RepeatWordBefore is like RepeatWord, but also considers the words that
+// become ready for repetition until t, for studying ahead. The words in except
+// are skipped, e.g. the ones put off in the practice session.
+func (r *Repetition) RepeatWordBefore(chatID int64, language string, t time.Time, except ...string) (string, error) {
+	return r.RepeatWordOrdered(chatID, language, t, NewCardsAdded, except...)
+}
+
+// newCard is true for the cards that were never reviewed.
+const newCard = `last_updated_seconds = added_seconds`
+
+// newCardOrders are the expressions ordering the new cards, see
+// Settings.NewCardOrder. The frequency is the number of sentences in the
+// corpus with the normalized word, negated to have the frequent words first. Extra bots
+// don't keep the corpus in their database, so there all words are equally
+// frequent.
+var newCardOrders = map[string]string{
+	NewCardsRandom: `RANDOM()`,
+	NewCardsFrequent: `-(
+		SELECT COUNT(*)
+		FROM Words w
+		INNER JOIN WordSentences ws ON ws.word_id = w.word_id
+		WHERE w.word = Repetition.normalized_word
+		  AND w.lang = Repetition.language)`,
+}
+
+// RepeatWordOrdered is RepeatWordBefore with the new cards coming in the given
+// order, see Settings.NewCardOrder. Unless they come in the order they were
+// added, the cards that were reviewed come before the new ones.
+func (r *Repetition) RepeatWordOrdered(chatID int64, language string, t time.Time, order string, except ...string) (string, error) {
+	args := []any{t.Unix(), chatID, language, language}
+	o, ordered := newCardOrders[order]
+	if len(except) == 0 && !ordered {
+		return scanWord(r.repeatWord.QueryRow(args...))
+	}
+	q := strings.TrimSuffix(strings.TrimSpace(repeatWordQuery), ";")
+	if len(except) > 0 {
+		var ps []string
+		for _, w := range except {
+			args = append(args, w)
+			ps = append(ps, fmt.Sprintf("$%d", len(args)-1))
+		}
+		q += "\n\t  AND word NOT IN (" + strings.Join(ps, ", ") + ")"
+	}
+	if ordered {
+		q += "\n\tORDER BY " + newCard + ", CASE WHEN " + newCard + " THEN " + o + " END, next_review_seconds"
+	}
+	return scanWord(r.db.QueryRow(q+";", args...))
+}
+
+func scanWord(row *sql.Row) (string, error) {
 	var w string
 	err := row.Scan(&w)
 	return w, err
 }
 
+// randIntn is rand.Intn, replaced in tests.
+var randIntn = rand.Intn
+
+// RandomWord returns a random saved word regardless of its schedule.
+// sql.ErrNoRows is returned if there are no words.
+func (r *Repetition) RandomWord(chatID int64) (string, error) {
+	var n int
+	row := r.db.QueryRow(`SELECT COUNT(*) FROM Repetition WHERE chat_id = $0;`, chatID)
+	if err := row.Scan(&n); err != nil {
+		return "", fmt.Errorf("INTERNAL: counting words for chat %d: %w", chatID, err)
+	}
+	if n == 0 {
+		return "", sql.ErrNoRows
+	}
+	row = r.db.QueryRow(`
+		SELECT word
+		FROM Repetition
+		WHERE chat_id = $0
+		ORDER BY word
+		LIMIT 1 OFFSET $1;`,
+		chatID, randIntn(n))
+	var w string
+	if err := row.Scan(&w); err != nil {
+		return "", fmt.Errorf("INTERNAL: retrieving random word for chat %d: %w", chatID, err)
+	}
+	return w, nil
+}
+
+// DueWords returns up to limit words ready for repetition, the longest
+// overdue first. If language isn't empty, only the words in it are returned.
+func (r *Repetition) DueWords(chatID int64, language string, limit int) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT word
+		FROM Repetition
+		WHERE next_review_seconds <= $0
+		  AND chat_id = $1
+		  AND ($2 = '' OR language = $3)
+		ORDER BY next_review_seconds, word
+		LIMIT $4;`,
+		r.clock.Now().Unix(), chatID, language, language, limit)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving due words for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var ws []string
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
+		}
+		ws = append(ws, w)
+	}
+	return ws, rows.Err()
+}
+
+// RecentWords returns up to limit words reviewed or added most recently,
+// skipping the words in except. If language isn't empty, only the words in it
+// are returned.
+func (r *Repetition) RecentWords(chatID int64, language string, limit int, except ...string) ([]string, error) {
+	args := []any{chatID, language, language}
+	var ps []string
+	for _, w := range except {
+		args = append(args, w)
+		ps = append(ps, fmt.Sprintf("$%d", len(args)-1))
+	}
+	q := `
+		SELECT word
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND ($1 = '' OR language = $2)`
+	if len(ps) > 0 {
+		q += `
+		  AND word NOT IN (` + strings.Join(ps, ", ") + `)`
+	}
+	args = append(args, limit)
+	q += fmt.Sprintf(`
+		ORDER BY last_updated_seconds DESC, word
+		LIMIT $%d;`, len(args)-1)
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving recent words for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var ws []string
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
+		}
+		ws = append(ws, w)
+	}
+	return ws, rows.Err()
+}
+
+// Scheduling is how the answers of a chat reschedule its cards, see Settings.
+type Scheduling struct {
+	// Leitner is true for LeitnerScheduler.
+	Leitner bool
+	// See Settings.LearningSteps.
+	LearningSteps []time.Duration
+	// MaxInterval is the longest interval between reviews, 0 if unlimited.
+	MaxInterval time.Duration
+}
+
+// CreditCard reschedules the word if it's ready for repetition as if it was
+// reviewed again at its stage or box, without moving it further. It's a
+// lighter reward than AnswerCard for recognizing the word rather than recalling
+// it, and isn't logged as a review.
+func (r *Repetition) CreditCard(chatID int64, word string, sc Scheduling) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("INTERNAL: crediting %q for chat %d: %w", word, chatID, err)
+	}
+	defer tx.Rollback()
+	now := r.clock.Now().Unix()
+	if _, err := tx.Exec(`
+		UPDATE Repetition
+		SET last_updated_seconds = $0,
+		    next_review_seconds = $1 + CASE WHEN $2 THEN `+leitnerInterval("MAX(Repetition.scheduler_state, 1)")+` ELSE (`+stageDuration+`) END
+		WHERE chat_id = $3
+		  AND word = $4
+		  AND next_review_seconds <= $5;`,
+		now, now, sc.Leitner, chatID, word, now); err != nil {
+		return fmt.Errorf("INTERNAL: crediting %q for chat %d: %w", word, chatID, err)
+	}
+	if err := capInterval(tx, chatID, word, sc.MaxInterval); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("INTERNAL: crediting %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+const capIntervalQuery = `
+	UPDATE Repetition
+	SET next_review_seconds = last_updated_seconds + $0
+	WHERE chat_id = $1
+	  AND ($2 = '' OR word = $3)
+	  AND next_review_seconds > last_updated_seconds + $0;`
+
+// CapInterval moves the next review of the word closer, so that it's at most
+// max after the last one. If word is empty, all words of the chat are capped.
+func (r *Repetition) CapInterval(chatID int64, word string, max time.Duration) error {
+	_, err := r.db.Exec(capIntervalQuery, int64(max.Seconds()), chatID, word, word)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: capping interval of %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+// capInterval is CapInterval for the word just rescheduled in tx, so that the
+// uncapped interval is never visible. 0 means unlimited.
+func capInterval(tx *sql.Tx, chatID int64, word string, max time.Duration) error {
+	if max == 0 {
+		return nil
+	}
+	_, err := tx.Exec(capIntervalQuery, int64(max.Seconds()), chatID, word, word)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: capping interval of %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+const dueCountQuery = `
+	SELECT COUNT(*)
+	FROM Repetition
+	WHERE next_review_seconds <= $0
+	  AND chat_id = $1;`
+
+// DueCount returns the number of words ready for repetition.
+func (r *Repetition) DueCount(chatID int64) (int, error) {
+	row := r.dueCount.QueryRow(r.clock.Now().Unix(), chatID)
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, fmt.Errorf("INTERNAL: counting due words for chat %d: %w", chatID, err)
+	}
+	return n, nil
+}
+
+// DueForecast is the number of words ready for repetition over the upcoming
+// periods.
+type DueForecast struct {
+	Now        int
+	LaterToday int
+	Tomorrow   int
+	// Words that become ready after now within 7 days, including the ones
+	// later today and tomorrow.
+	Week int
+}
+
+// Forecast returns the number of words that are ready or will become ready
+// for repetition. Days are counted in the given location.
+func (r *Repetition) Forecast(chatID int64, now time.Time, loc *time.Location) (*DueForecast, error) {
+	t := now.In(loc)
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+	row := r.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(next_review_seconds <= $0), 0),
+			COALESCE(SUM(next_review_seconds > $0 AND next_review_seconds < $1), 0),
+			COALESCE(SUM(next_review_seconds >= $1 AND next_review_seconds < $2), 0),
+			COALESCE(SUM(next_review_seconds > $0 AND next_review_seconds <= $3), 0)
+		FROM Repetition
+		WHERE chat_id = $4;`,
+		now.Unix(), tomorrow.Unix(), tomorrow.AddDate(0, 0, 1).Unix(), now.AddDate(0, 0, 7).Unix(), chatID)
+	f := &DueForecast{}
+	if err := row.Scan(&f.Now, &f.LaterToday, &f.Tomorrow, &f.Week); err != nil {
+		return nil, fmt.Errorf("INTERNAL: forecasting due words for chat %d: %w", chatID, err)
+	}
+	return f, nil
+}
+
+// CountCards returns the number of words saved for learning by all chats.
+func (r *Repetition) CountCards() (int, error) {
+	var n int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM Repetition;`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("INTERNAL: counting cards: %w", err)
+	}
+	return n, nil
+}
+
+const nextReviewQuery = `
+	SELECT MIN(next_review_seconds)
+	FROM Repetition
+	WHERE chat_id = $0;`
+
+// NextReview returns the time when the earliest word becomes ready for
+// repetition. sql.ErrNoRows is returned if there are no words.
+func (r *Repetition) NextReview(chatID int64) (time.Time, error) {
+	row := r.nextReview.QueryRow(chatID)
+	var n sql.NullInt64
+	if err := row.Scan(&n); err != nil {
+		return time.Time{}, fmt.Errorf("INTERNAL: retrieving next review for chat %d: %w", chatID, err)
+	}
+	if !n.Valid {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return time.Unix(n.Int64, 0), nil
+}
+
+// Postpone moves the next review of all words of the chat by d and returns the
+// number of the moved words.
+func (r *Repetition) Postpone(chatID int64, d time.Duration) (int, error) {
+	res, err := r.db.Exec(`
+		UPDATE Repetition
+		SET next_review_seconds = next_review_seconds + $0
+		WHERE chat_id = $1;`,
+		int64(d.Seconds()), chatID)
+	if err != nil {
+		return 0, fmt.Errorf("INTERNAL: postponing words for chat %d: %w", chatID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("INTERNAL: postponing words for chat %d: %w", chatID, err)
+	}
+	return int(n), nil
+}
+
+// Reschedule sets the next review of the saved word, keeping its stage.
+func (r *Repetition) Reschedule(chatID int64, word string, next time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE Repetition
+		SET next_review_seconds = $0
+		WHERE chat_id = $1
+		  AND word = $2`,
+		next.Unix(), chatID, word)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: rescheduling %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+// SpreadBacklog keeps at most perDay of the words that were ready for
+// repetition before today and moves the rest to the start of the following
+// days, perDay a day, the longest waiting first. Days start at midnight in loc.
+// The number of the moved words is returned.
+func (r *Repetition) SpreadBacklog(chatID int64, perDay int, now time.Time, loc *time.Location) (int, error) {
+	today := localDay(now, loc)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("INTERNAL: spreading backlog for chat %d: %w", chatID, err)
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(`
+		SELECT word
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND next_review_seconds < $1
+		ORDER BY next_review_seconds, word`,
+		chatID, today.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("INTERNAL: spreading backlog for chat %d: %w", chatID, err)
+	}
+	var ws []string
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ws = append(ws, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ws) <= perDay {
+		return 0, nil
+	}
+	for i, w := range ws[perDay:] {
+		day := today.AddDate(0, 0, i/perDay+1)
+		if _, err := tx.Exec(`
+			UPDATE Repetition
+			SET next_review_seconds = $0
+			WHERE chat_id = $1
+			  AND word = $2`,
+			day.Unix(), chatID, w); err != nil {
+			return 0, fmt.Errorf("INTERNAL: spreading backlog for chat %d: %w", chatID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("INTERNAL: spreading backlog for chat %d: %w", chatID, err)
+	}
+	return len(ws) - perDay, nil
+}
+
 // looks up definition and compares it to the word
 // FIXME: FIXME: FIXME: FIXME: This doesn't work!!!!!!!!
 //  cannot save obfuscated - cannot check.
@@ -136,72 +569,257 @@ func (r *Repetition) RepeatWord(chatID int64) (string, error) {
 //  a way to fix is to move obfuscation into commander, save into Asking
 //    not-obfuscated message, but send to user obfuscated one.
 // Maybe this is already fixed, just not tested?
+//
+// The stage is computed in the UPDATE itself, so that concurrent answers for the
+// same card don't overwrite each other's progress. Updating first takes the
+// write lock for the whole transaction.
 func (r *Repetition) Answer(chatID int64, definition, word string) (string, error) {
-	panic("This logic is broken, fix it!")
-	row := r.db.QueryRow(`
-		SELECT word, stage
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("INTERNAL: answering: %w", err)
+	}
+	defer tx.Rollback()
+	// Expressions in SET refer to the old values of the row.
+	res, err := tx.Exec(`
+		UPDATE Repetition
+		SET stage = CASE WHEN word = $0 THEN MIN(stage + 1, $1) ELSE 0 END,
+		    last_updated_seconds = $2,
+		    next_review_seconds = $2 + (
+		    	SELECT duration FROM Stages
+		    	WHERE Stages.id = CASE WHEN Repetition.word = $0 THEN MIN(Repetition.stage + 1, $1) ELSE 0 END)
+		WHERE definition = $3
+		  AND chat_id = $4;`,
+		word, len(r.stages)-1, r.clock.Now().Unix(), definition, chatID)
+	if err != nil {
+		return "", fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return "", fmt.Errorf("INTERNAL: Did not find definition %q: %w", definition, sql.ErrNoRows)
+	}
+	var correct string
+	row := tx.QueryRow(`
+		SELECT word
 		FROM Repetition
 		WHERE definition = $0
 		  AND chat_id = $1`,
 		definition, chatID)
-	var correct string
-	var stage int
-	if err := row.Scan(&correct, &stage); err != nil {
+	if err := row.Scan(&correct); err != nil {
 		return "", fmt.Errorf("INTERNAL: Did not find definition %q: %w", definition, err)
 	}
-	if correct != word {
-		stage = 0
-	} else {
-		stage += 1
-		if stage >= len(r.stages) {
-			stage = len(r.stages) - 1
-		}
-	}
-	_, err := r.db.Exec(`
-		UPDATE Repetition
-		SET stage = $0, last_updated_seconds = $1
-		WHERE definition = $2
-		  AND chat_id = $3;`,
-		stage, time.Now().Unix(), definition, chatID)
-	if err != nil {
-		return "", fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("INTERNAL: answering: %w", err)
 	}
 	return correct, nil
 }
 
+// Expressions in SET refer to the old values of the row, so the stage is
+// incremented in the subquery for the duration as well.
+const answerKnowQuery = `
+	UPDATE Repetition
+	SET stage = MIN(stage + 1, $0),
+	    last_updated_seconds = $1,
+	    next_review_seconds = $1 + (
+	    	SELECT duration FROM Stages
+	    	WHERE Stages.id = MIN(Repetition.stage + 1, $0))
+	WHERE word = $2
+	  AND chat_id = $3;`
+
+// AnswerKnow is AnswerCard for the recalled word with the default scheduler.
 func (r *Repetition) AnswerKnow(chatID int64, word string) error {
-	_, err := r.db.Exec(`
-		UPDATE Repetition
-		SET stage = MIN(stage + 1, $0), last_updated_seconds = $1
-		WHERE word = $2
-		  AND chat_id = $3;`,
-		len(r.stages)-1, time.Now().Unix(), word, chatID)
+	return r.AnswerCard(chatID, word, true, false, Scheduling{})
+}
+
+// AnswerDontKnow is AnswerCard for the forgotten word with the default
+// scheduler.
+func (r *Repetition) AnswerDontKnow(chatID int64, word string) error {
+	return r.AnswerCard(chatID, word, false, false, Scheduling{})
+}
+
+// AnswerCard records whether the word was known and reschedules it with the
+// scheduler of sc. ahead is true when studying ahead, see RepeatWordBefore.
+// The interval is capped and the answer is logged in the same transaction, so
+// the uncapped interval is never visible.
+func (r *Repetition) AnswerCard(chatID int64, word string, known, ahead bool, sc Scheduling) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("INTERNAL: answering %q for chat %d: %w", word, chatID, err)
+	}
+	defer tx.Rollback()
+	now := r.clock.Now().Unix()
+	switch {
+	case sc.Leitner:
+		err = r.answerLeitner(tx, chatID, word, known, now)
+	case len(sc.LearningSteps) > 0:
+		err = r.answerLearning(tx, chatID, word, known, ahead, sc.LearningSteps, now)
+	default:
+		err = r.answerStages(tx, chatID, word, known, ahead, now)
+	}
+	if err != nil {
+		return err
+	}
+	if err := capInterval(tx, chatID, word, sc.MaxInterval); err != nil {
+		return err
+	}
+	if err := r.logReview(tx, chatID, word, known); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("INTERNAL: answering %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+// answerKnowAheadQuery is answerKnowQuery for the words that may be not ready
+// for repetition yet. Recalling such a word says less about how well it's
+// remembered, so the stage is kept and only its interval starts over.
+const answerKnowAheadQuery = `
+	UPDATE Repetition
+	SET stage = CASE WHEN next_review_seconds <= $0 THEN MIN(stage + 1, $1) ELSE stage END,
+	    last_updated_seconds = $0,
+	    next_review_seconds = $0 + (
+	    	SELECT duration FROM Stages
+	    	WHERE Stages.id >= CASE WHEN Repetition.next_review_seconds <= $0 THEN MIN(Repetition.stage + 1, $1) ELSE Repetition.stage END
+	    	ORDER BY Stages.id
+	    	LIMIT 1)
+	WHERE word = $2
+	  AND chat_id = $3;`
+
+// answerStages moves the known word to the next stage and the forgotten one
+// back to the first stage.
+func (r *Repetition) answerStages(tx *sql.Tx, chatID int64, word string, known, ahead bool, now int64) error {
+	var err error
+	switch {
+	case !known:
+		_, err = tx.Stmt(r.resetProgress).Exec(now, now+int64(r.stages[0].Seconds()), word, chatID)
+	case ahead:
+		_, err = tx.Exec(answerKnowAheadQuery, now, len(r.stages)-1, word, chatID)
+	default:
+		_, err = tx.Stmt(r.answerKnow).Exec(len(r.stages)-1, now, word, chatID)
+	}
 	if err != nil {
 		return fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
 	}
 	return nil
 }
 
-func (r *Repetition) AnswerDontKnow(chatID int64, word string) error {
-	_, err := r.db.Exec(`
+// leitnerIntervals are the intervals between reviews of the cards in the boxes
+// of the Leitner system, the first box first. New cards are in the first box.
+var leitnerIntervals = []time.Duration{
+	24 * time.Hour,
+	2 * 24 * time.Hour,
+	4 * 24 * time.Hour,
+	8 * 24 * time.Hour,
+	16 * 24 * time.Hour,
+}
+
+// leitnerBox is an expression for the box of the answered card: known cards
+// move to the next box, the rest go back to the first one. Like with
+// answerKnowAheadQuery, cards known before they are ready stay in their box.
+const leitnerBox = `
+	CASE
+		WHEN NOT $0 THEN 1
+		WHEN Repetition.next_review_seconds <= $1 THEN MIN(MAX(Repetition.scheduler_state, 1) + 1, $2)
+		ELSE MAX(Repetition.scheduler_state, 1)
+	END`
+
+// leitnerInterval returns an expression for the interval of the box.
+func leitnerInterval(box string) string {
+	var b strings.Builder
+	b.WriteString("CASE " + box)
+	for i, d := range leitnerIntervals {
+		fmt.Fprintf(&b, " WHEN %d THEN %d", i+1, int64(d.Seconds()))
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+// answerLeitner is answerStages for the chats using LeitnerScheduler. The box
+// is kept in scheduler_state.
+func (r *Repetition) answerLeitner(tx *sql.Tx, chatID int64, word string, known bool, now int64) error {
+	// Expressions in SET refer to the old values of the row.
+	_, err := tx.Exec(`
+		UPDATE Repetition
+		SET scheduler_state = `+leitnerBox+`,
+		    last_updated_seconds = $1,
+		    next_review_seconds = $1 + (`+leitnerInterval(leitnerBox)+`)
+		WHERE word = $3
+		  AND chat_id = $4;`,
+		known, now, len(leitnerIntervals), word, chatID)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating box: %w", err)
+	}
+	return nil
+}
+
+// learningStepInterval returns an expression for the interval of the
+// learning step.
+func learningStepInterval(step string, steps []time.Duration) string {
+	var b strings.Builder
+	b.WriteString("CASE " + step)
+	for i, d := range steps {
+		fmt.Fprintf(&b, " WHEN %d THEN %d", i, int64(d.Seconds()))
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+// answerLearning is answerStages for the chats with learning steps, see
+// Settings.LearningSteps. New cards and the ones that weren't known are at
+// stage 0 and go through the steps, each known answer moves a card to the
+// next step. A card known at the last step graduates to the stages.
+func (r *Repetition) answerLearning(tx *sql.Tx, chatID int64, word string, known, ahead bool, steps []time.Duration, now int64) error {
+	// Expressions in SET refer to the old values of the row. The step is
+	// checked in the UPDATE itself, which takes the write lock before
+	// anything is read.
+	step := `CASE WHEN $0 THEN Repetition.learning_step + 1 ELSE 0 END`
+	res, err := tx.Exec(`
 		UPDATE Repetition
-		SET stage = 0, last_updated_seconds = $0
-		WHERE word = $1
-		  AND chat_id = $2;`,
-		time.Now().Unix(), word, chatID)
+		SET stage = 0,
+		    scheduler_state = 0,
+		    learning_step = `+step+`,
+		    last_updated_seconds = $1,
+		    next_review_seconds = $1 + (`+learningStepInterval(step, steps)+`)
+		WHERE chat_id = $2
+		  AND word = $3
+		  AND (NOT $0 OR (stage = 0 AND learning_step + 1 < $4));`,
+		known, now, chatID, word, len(steps))
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating learning step: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed updating learning step: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	return r.answerStages(tx, chatID, word, known, ahead, now)
+}
+
+const resetProgressQuery = `
+	UPDATE Repetition
+	SET stage = 0, scheduler_state = 0, learning_step = 0, last_updated_seconds = $0, next_review_seconds = $1
+	WHERE word = $2
+	  AND chat_id = $3;`
+
+// ResetProgress makes the word practiced as if it was just added.
+func (r *Repetition) ResetProgress(chatID int64, word string) error {
+	now := r.clock.Now().Unix()
+	_, err := r.resetProgress.Exec(now, now+int64(r.stages[0].Seconds()), word, chatID)
 	if err != nil {
 		return fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
 	}
 	return nil
 }
 
+const getDefinitionQuery = `
+	SELECT definition
+	FROM Repetition
+	WHERE word = $0
+	  AND chat_id = $1`
+
 func (r *Repetition) GetDefinition(chatID int64, word string) (string, error) {
-	row := r.db.QueryRow(`
-		SELECT definition
-		FROM Repetition
-		WHERE word = $0
-		  AND chat_id = $1`,
-		word, chatID)
+	row := r.getDefinition.QueryRow(word, chatID)
 	var d string
 	if err := row.Scan(&d); err != nil {
 		return "", fmt.Errorf("INTERNAL: Did not find definition: %w", err)
@@ -209,12 +827,208 @@ func (r *Repetition) GetDefinition(chatID int64, word string) (string, error) {
 	return d, nil
 }
 
-func (r *Repetition) Exists(chatID int64, word string) (bool, error) {
+// UpdateDefinition replaces the definition of the saved word keeping its
+// progress.
+func (r *Repetition) UpdateDefinition(chatID int64, word, definition string) error {
+	_, err := r.db.Exec(`
+		UPDATE Repetition
+		SET definition = $0
+		WHERE chat_id = $1
+		  AND word = $2`,
+		definition, chatID, word)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: updating definition of %q: %w", word, err)
+	}
+	return nil
+}
+
+// AppendDefinition adds the definition to the one of the saved word keeping its
+// progress.
+func (r *Repetition) AppendDefinition(chatID int64, word, definition string) error {
+	_, err := r.db.Exec(`
+		UPDATE Repetition
+		SET definition = definition || $0
+		WHERE chat_id = $1
+		  AND word = $2`,
+		"\n\n"+definition, chatID, word)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: appending definition of %q: %w", word, err)
+	}
+	return nil
+}
+
+// CardProgress is how far the saved word got in practice.
+type CardProgress struct {
+	// Stage is 0 for new cards and up to Stages-1 for learned ones.
+	Stage      int
+	Stages     int
+	NextReview time.Time
+}
+
+// Progress returns the progress of the saved word, sql.ErrNoRows if it isn't
+// saved.
+func (r *Repetition) Progress(chatID int64, word string) (*CardProgress, error) {
 	row := r.db.QueryRow(`
-			SELECT COUNT(*) FROM Repetition
-			WHERE chat_id = $1
-				AND word = $2`,
+		SELECT stage, next_review_seconds
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND word = $1`,
+		chatID, word)
+	var stage int
+	var next int64
+	if err := row.Scan(&stage, &next); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("INTERNAL: retrieving progress of %q: %w", word, err)
+	}
+	return &CardProgress{
+		Stage:      min(stage, len(r.stages)-1),
+		Stages:     len(r.stages),
+		NextReview: time.Unix(next, 0),
+	}, nil
+}
+
+// Card is a saved word with its schedule.
+type Card struct {
+	Word       string
+	Definition string
+	Language   string
+	// Stage is 0 for new cards and up to the number of stages-1 for learned
+	// ones.
+	Stage      int
+	NextReview time.Time
+	Starred    bool
+}
+
+// Cards returns all cards of the chat in alphabetical order.
+func (r *Repetition) Cards(chatID int64) ([]*Card, error) {
+	return r.cards(chatID, false)
+}
+
+// StarredCards returns the starred cards of the chat in alphabetical order.
+func (r *Repetition) StarredCards(chatID int64) ([]*Card, error) {
+	return r.cards(chatID, true)
+}
+
+func (r *Repetition) cards(chatID int64, starred bool) ([]*Card, error) {
+	rows, err := r.db.Query(`
+		SELECT word, definition, language, stage, next_review_seconds, starred
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND ($1 = 0 OR starred = 1)
+		ORDER BY word`,
+		chatID, starred)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving cards of chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var cs []*Card
+	for rows.Next() {
+		c := &Card{}
+		var next int64
+		if err := rows.Scan(&c.Word, &c.Definition, &c.Language, &c.Stage, &next, &c.Starred); err != nil {
+			return nil, err
+		}
+		c.Stage = min(c.Stage, len(r.stages)-1)
+		c.NextReview = time.Unix(next, 0)
+		cs = append(cs, c)
+	}
+	return cs, rows.Err()
+}
+
+// Starred returns whether the saved word is starred.
+func (r *Repetition) Starred(chatID int64, word string) (bool, error) {
+	row := r.db.QueryRow(`
+		SELECT starred
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND word = $1`,
 		chatID, word)
+	var starred bool
+	if err := row.Scan(&starred); err != nil {
+		return false, fmt.Errorf("INTERNAL: retrieving star of %q for chat %d: %w", word, chatID, err)
+	}
+	return starred, nil
+}
+
+// SetStarred stars or unstars the saved word. Its schedule isn't affected.
+func (r *Repetition) SetStarred(chatID int64, word string, starred bool) error {
+	_, err := r.db.Exec(`
+		UPDATE Repetition
+		SET starred = $0
+		WHERE chat_id = $1
+		  AND word = $2`,
+		starred, chatID, word)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: starring %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+// Image returns the file_id of the photo on the front of the saved word's card,
+// empty if there is none.
+func (r *Repetition) Image(chatID int64, word string) (string, error) {
+	row := r.db.QueryRow(`
+		SELECT image
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND word = $1`,
+		chatID, word)
+	var image string
+	if err := row.Scan(&image); err != nil {
+		return "", fmt.Errorf("INTERNAL: retrieving image of %q for chat %d: %w", word, chatID, err)
+	}
+	return image, nil
+}
+
+// SetImage puts the photo with the file_id on the front of the saved word's
+// card, an empty fileID removes it.
+func (r *Repetition) SetImage(chatID int64, word, fileID string) error {
+	_, err := r.db.Exec(`
+		UPDATE Repetition
+		SET image = $0
+		WHERE chat_id = $1
+		  AND word = $2`,
+		fileID, chatID, word)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: setting image of %q for chat %d: %w", word, chatID, err)
+	}
+	return nil
+}
+
+// StarredWord returns the starred word with the earliest review regardless of
+// its schedule, skipping the words in except. sql.ErrNoRows is returned if
+// there is none.
+func (r *Repetition) StarredWord(chatID int64, except ...string) (string, error) {
+	args := []any{chatID}
+	var ps []string
+	for _, w := range except {
+		args = append(args, w)
+		ps = append(ps, fmt.Sprintf("$%d", len(args)-1))
+	}
+	q := `
+		SELECT word
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND starred = 1`
+	if len(ps) > 0 {
+		q += `
+		  AND word NOT IN (` + strings.Join(ps, ", ") + `)`
+	}
+	q += `
+		ORDER BY next_review_seconds
+		LIMIT 1`
+	return scanWord(r.db.QueryRow(q, args...))
+}
+
+const existsQuery = `
+	SELECT COUNT(*) FROM Repetition
+	WHERE chat_id = $1
+	  AND word = $2`
+
+func (r *Repetition) Exists(chatID int64, word string) (bool, error) {
+	row := r.exists.QueryRow(chatID, word)
 	var d int32
 	if err := row.Scan(&d); err != nil {
 		return false, fmt.Errorf("INTERNAL: Counting %q for chat %d: %w", word, chatID, err)
@@ -222,6 +1036,22 @@ func (r *Repetition) Exists(chatID int64, word string) (bool, error) {
 	return d > 0, nil
 }
 
+// Language returns the ISO 639-3 code of the word's language, empty if it's
+// unknown.
+func (r *Repetition) Language(chatID int64, word string) (string, error) {
+	row := r.db.QueryRow(`
+		SELECT language
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND word = $1`,
+		chatID, word)
+	var l string
+	if err := row.Scan(&l); err != nil {
+		return "", fmt.Errorf("INTERNAL: retrieving language of %q for chat %d: %w", word, chatID, err)
+	}
+	return l, nil
+}
+
 func (r *Repetition) Delete(chatID int64, word string) error {
 	_, err := r.db.Exec(`
 		DELETE
@@ -235,6 +1065,29 @@ func (r *Repetition) Delete(chatID int64, word string) error {
 	return nil
 }
 
+// CopyCards adds the cards of chat from to chat to as new ones, skipping the
+// words the chat already has. If word isn't empty, only its card is copied.
+// Returns the number of copied cards.
+func (r *Repetition) CopyCards(from, to int64, word string) (int, error) {
+	now := r.clock.Now().Unix()
+	res, err := r.db.Exec(`
+		INSERT INTO Repetition(chat_id, word, definition, stage, last_updated_seconds, next_review_seconds, added_seconds, language, image, normalized_word)
+		SELECT $0, src.word, src.definition, 0, $1, $2, $3, src.language, src.image, src.normalized_word
+		FROM Repetition src
+		WHERE src.chat_id = $4
+		  AND ($5 = '' OR src.word = $6)
+		  AND NOT EXISTS (
+			SELECT 1 FROM Repetition dst
+			WHERE dst.chat_id = $7
+			  AND dst.word = src.word)`,
+		to, now, now+int64(r.stages[0].Seconds()), now, from, word, word, to)
+	if err != nil {
+		return 0, fmt.Errorf("INTERNAL: copying cards of chat %d to chat %d: %w", from, to, err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
 // TODO later editing should be helpful.
 // func (r *Repetition) Edit(chatID int64, word, newDefinition string) {
 // }
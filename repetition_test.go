@@ -14,9 +14,14 @@
 package main
 
 import (
+	"database/sql"
+	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -29,9 +34,12 @@ func TestRepetition(t *testing.T) {
 	t.Logf("Temp dir: %q", dir)
 	defer os.RemoveAll(dir)
 
-	db := filepath.Join(dir, "tmpdb")
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	stages := []time.Duration{0, 0, 0, 0}
-	r, err := NewRepetition(db, stages)
+	r, err := NewRepetition(db, stages, SystemClock{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -65,11 +73,27 @@ func TestRepetition(t *testing.T) {
 	}
 
 	const chatId int64 = 1
-	if err := r.Save(chatId, "foo", "foo is bar"); err != nil {
+	if _, err := r.RandomWord(chatId); err != sql.ErrNoRows {
+		t.Errorf("r.RandomWord without words: %v want %v", err, sql.ErrNoRows)
+	}
+	if err := r.Save(chatId, "foo", "foo is bar", "hun"); err != nil {
 		t.Fatal(err)
 	}
 	check(&row{chatId: chatId, word: "foo", definition: "foo is bar", stage: 0})
 
+	if n, err := r.DueCount(chatId); err != nil || n != 1 {
+		t.Errorf("r.DueCount: %d, %v want 1, nil", n, err)
+	}
+	if w, err := r.RandomWord(chatId); err != nil || w != "foo" {
+		t.Errorf("r.RandomWord: %q, %v want foo, nil", w, err)
+	}
+	if ws, err := r.DueWords(chatId, "", 10); err != nil || len(ws) != 1 || ws[0] != "foo" {
+		t.Errorf("r.DueWords: %q, %v want [foo], nil", ws, err)
+	}
+	if ws, err := r.DueWords(chatId, "deu", 10); err != nil || len(ws) != 0 {
+		t.Errorf("r.DueWords in deu: %q, %v want [], nil", ws, err)
+	}
+
 	d, err := r.Repeat(chatId)
 	if err != nil {
 		t.Fatal(err)
@@ -120,6 +144,10 @@ func TestRepetition(t *testing.T) {
 	if e, err := r.Exists(chatId, "foo"); err != nil || !e {
 		t.Errorf("r.Exists: %t, %v want true, nil", e, err)
 	}
+	if err := r.UpdateDefinition(chatId, "foo", "foo is baz"); err != nil {
+		t.Fatal(err)
+	}
+	check(&row{chatId: chatId, word: "foo", definition: "foo is baz", stage: 3})
 	if err := r.Delete(chatId, "foo"); err != nil {
 		t.Fatal(err)
 	}
@@ -134,3 +162,923 @@ func TestRepetition(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// Concurrent answers for the same card must all count.
+func TestRepetitionConcurrentAnswers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const answers = 50
+	r, err := NewRepetition(db, make([]time.Duration, 2*answers), SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	if err := r.Save(chatID, "spelled", "spelled definition", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(chatID, "known", "known definition", "hun"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*answers)
+	for i := 0; i < answers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := r.Answer(chatID, "spelled definition", "spelled")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- r.AnswerKnow(chatID, "known")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, w := range []string{"spelled", "known"} {
+		var stage int
+		if err := db.QueryRow(`SELECT stage FROM Repetition WHERE word = $0`, w).Scan(&stage); err != nil {
+			t.Fatal(err)
+		}
+		if stage != answers {
+			t.Errorf("%s: got stage %d after %d concurrent answers want %d", w, stage, answers, answers)
+		}
+	}
+}
+
+func TestRepetitionIntervals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	checkDue := func(step string, want int, next time.Duration) {
+		t.Helper()
+		if n, err := r.DueCount(chatID); err != nil || n != want {
+			t.Errorf("%s: DueCount = %d, %v want %d, nil", step, n, err, want)
+		}
+		if nr, err := r.NextReview(chatID); err != nil || !nr.Equal(clock.Now().Add(next)) {
+			t.Errorf("%s: NextReview = %v, %v want %v, nil", step, nr, err, clock.Now().Add(next))
+		}
+	}
+
+	if err := r.Save(chatID, "foo", "foo is bar", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	checkDue("saved", 1, 0)
+
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	checkDue("known once", 0, time.Hour)
+	clock.Advance(time.Hour - time.Minute)
+	checkDue("a minute before the review", 0, time.Minute)
+	clock.Advance(time.Minute)
+	checkDue("an hour later", 1, 0)
+
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	checkDue("known twice", 0, 24*time.Hour)
+	// The last stage repeats.
+	clock.Advance(24 * time.Hour)
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	checkDue("known three times", 0, 24*time.Hour)
+
+	clock.Advance(24 * time.Hour)
+	if err := r.AnswerDontKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	checkDue("forgotten", 1, 0)
+
+	if n, err := r.Postpone(chatID, 48*time.Hour); err != nil || n != 1 {
+		t.Errorf("Postpone: got %d, %v want 1, nil", n, err)
+	}
+	checkDue("postponed", 0, 48*time.Hour)
+}
+
+func TestForecast(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRepetition(db, []time.Duration{0}, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2020, 6, 1, 20, 0, 0, 0, loc)
+	const chatID int64 = 1
+	for w, next := range map[string]time.Time{
+		"overdue":    now.Add(-time.Hour),
+		"now":        now,
+		"tonight":    now.Add(3 * time.Hour),
+		"tomorrow":   now.Add(5 * time.Hour),
+		"in 5 days":  now.AddDate(0, 0, 5),
+		"in 10 days": now.AddDate(0, 0, 10),
+	} {
+		if err := r.Save(chatID, w, w, "hun"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.db.Exec(`UPDATE Repetition SET next_review_seconds = $0 WHERE word = $1`, next.Unix(), w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := r.Forecast(chatID, now, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DueForecast{Now: 2, LaterToday: 1, Tomorrow: 1, Week: 3}
+	if *got != *want {
+		t.Errorf("Forecast: got %+v want %+v", got, want)
+	}
+	if got, err := r.Forecast(chatID+1, now, loc); err != nil || *got != (DueForecast{}) {
+		t.Errorf("Forecast for chat without words: got %+v, %v want zeroes", got, err)
+	}
+}
+
+// benchmarkCards is the number of cards in the database for benchmarks, spread
+// over benchmarkChats chats.
+const (
+	benchmarkCards = 100_000
+	benchmarkChats = 1000
+)
+
+// BenchmarkRepetition measures the queries run for every update. The
+// unindexed variant drops the indexes to show their effect.
+func BenchmarkRepetition(b *testing.B) {
+	for _, indexed := range []bool{true, false} {
+		name := "indexed"
+		if !indexed {
+			name = "unindexed"
+		}
+		b.Run(name, func(b *testing.B) {
+			dir, err := ioutil.TempDir("", "repetition")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+			if !indexed {
+				if _, err := db.Exec(`
+					DROP INDEX RepetitionChatWordIndex;
+					DROP INDEX RepetitionChatReviewIndex;`); err != nil {
+					b.Fatal(err)
+				}
+			}
+			r, err := NewRepetition(db, []time.Duration{0, time.Hour}, SystemClock{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			tx, err := db.Begin()
+			if err != nil {
+				b.Fatal(err)
+			}
+			insert, err := tx.Prepare(`
+				INSERT INTO Repetition(chat_id, word, definition, stage, last_updated_seconds, next_review_seconds, added_seconds)
+				VALUES($0, $1, $2, 0, 0, $3, 0)`)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < benchmarkCards; i++ {
+				// Every other card is due.
+				if _, err := insert.Exec(i%benchmarkChats, fmt.Sprintf("word%d", i), "definition", (i/benchmarkChats)%2*2_000_000_000); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				b.Fatal(err)
+			}
+
+			for _, bm := range []struct {
+				name string
+				f    func(chatID int64, word string) error
+			}{
+				{"RepeatWord", func(chatID int64, _ string) error {
+					_, err := r.RepeatWord(chatID, "")
+					return err
+				}},
+				{"DueCount", func(chatID int64, _ string) error {
+					_, err := r.DueCount(chatID)
+					return err
+				}},
+				{"Exists", func(chatID int64, word string) error {
+					_, err := r.Exists(chatID, word)
+					return err
+				}},
+				{"AnswerKnow", r.AnswerKnow},
+			} {
+				b.Run(bm.name, func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						c := i % benchmarkCards
+						if err := bm.f(int64(c%benchmarkChats), fmt.Sprintf("word%d", c)); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestCardProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	if _, err := r.Progress(chatID, "foo"); err != sql.ErrNoRows {
+		t.Errorf("Progress of unsaved word: got %v want %v", err, sql.ErrNoRows)
+	}
+	if err := r.Save(chatID, "foo", "foo is bar", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress := func(step string) {
+		t.Helper()
+		p, err := r.Progress(chatID, "foo")
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		if p.Stage != 1 || p.Stages != 2 || !p.NextReview.Equal(clock.Now().Add(24*time.Hour)) {
+			t.Errorf("%s: Progress = %+v want stage 1 of 2, next review in a day", step, p)
+		}
+	}
+	checkProgress("known once")
+
+	if err := r.AppendDefinition(chatID, "foo", "foo is baz"); err != nil {
+		t.Fatal(err)
+	}
+	if def, err := r.GetDefinition(chatID, "foo"); err != nil || def != "foo is bar\n\nfoo is baz" {
+		t.Errorf("GetDefinition after append: got %q, %v", def, err)
+	}
+	checkProgress("appended")
+}
+
+func TestStudyAhead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	checkProgress := func(step string, stage int, next time.Duration) {
+		t.Helper()
+		p, err := r.Progress(chatID, "foo")
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		if p.Stage != stage || !p.NextReview.Equal(clock.Now().Add(next)) {
+			t.Errorf("%s: Progress = %+v want stage %d, next review in %v", step, p, stage, next)
+		}
+	}
+	if err := r.Save(chatID, "foo", "foo is bar", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	// Ready words are answered as usual.
+	if err := r.AnswerCard(chatID, "foo", true, true, Scheduling{}); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress("known when ready", 1, time.Hour)
+
+	clock.Advance(30 * time.Minute)
+	if _, err := r.RepeatWord(chatID, ""); err != sql.ErrNoRows {
+		t.Errorf("RepeatWord before the review: got %v want %v", err, sql.ErrNoRows)
+	}
+	if w, err := r.RepeatWordBefore(chatID, "", clock.Now().Add(time.Hour)); err != nil || w != "foo" {
+		t.Errorf("RepeatWordBefore: got %q, %v want foo", w, err)
+	}
+	if _, err := r.RepeatWordBefore(chatID, "", clock.Now().Add(time.Hour), "foo"); err != sql.ErrNoRows {
+		t.Errorf("RepeatWordBefore except foo: got %v want %v", err, sql.ErrNoRows)
+	}
+	if err := r.AnswerCard(chatID, "foo", true, true, Scheduling{}); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress("known ahead", 1, time.Hour)
+}
+
+func TestLeitner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	const day = 24 * time.Hour
+	check := func(step string, box int, next time.Duration) {
+		t.Helper()
+		var b int
+		if err := db.QueryRow(`SELECT scheduler_state FROM Repetition WHERE word = 'foo'`).Scan(&b); err != nil {
+			t.Fatal(err)
+		}
+		nr, err := r.NextReview(chatID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != box || !nr.Equal(clock.Now().Add(next)) {
+			t.Errorf("%s: got box %d, next review %v want box %d, next review %v", step, b, nr, box, clock.Now().Add(next))
+		}
+	}
+	if err := r.Save(chatID, "foo", "foo is bar", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []time.Duration{2 * day, 4 * day, 8 * day, 16 * day, 16 * day} {
+		if err := r.AnswerCard(chatID, "foo", true, false, Scheduling{Leitner: true}); err != nil {
+			t.Fatal(err)
+		}
+		check(fmt.Sprintf("known %d times", i+1), min(i+2, 5), want)
+		clock.Advance(want)
+	}
+	clock.Advance(-day)
+	if err := r.AnswerCard(chatID, "foo", true, false, Scheduling{Leitner: true}); err != nil {
+		t.Fatal(err)
+	}
+	check("known before ready", 5, 16*day)
+	if err := r.AnswerCard(chatID, "foo", false, false, Scheduling{Leitner: true}); err != nil {
+		t.Fatal(err)
+	}
+	check("forgotten", 1, day)
+}
+
+func TestStarred(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"foo", "bar", "baz"} {
+		if err := r.Save(chatID, w, w+" is a word", "hun"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+	if _, err := r.StarredWord(chatID); err != sql.ErrNoRows {
+		t.Errorf("StarredWord without stars: got %v want %v", err, sql.ErrNoRows)
+	}
+	for _, w := range []string{"foo", "baz"} {
+		if err := r.SetStarred(chatID, w, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if starred, err := r.Starred(chatID, "bar"); err != nil || starred {
+		t.Errorf("Starred(bar): got %t, %v want false", starred, err)
+	}
+	cs, err := r.StarredCards(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 2 || cs[0].Word != "baz" || cs[1].Word != "foo" || !cs[0].Starred {
+		t.Errorf("StarredCards: got %+v want baz and foo", cs)
+	}
+	// Starred words are practiced regardless of the schedule, the earliest
+	// review first.
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if w, err := r.StarredWord(chatID); err != nil || w != "baz" {
+		t.Errorf("StarredWord: got %q, %v want baz", w, err)
+	}
+	if w, err := r.StarredWord(chatID, "baz"); err != nil || w != "foo" {
+		t.Errorf("StarredWord except baz: got %q, %v want foo", w, err)
+	}
+	if err := r.SetStarred(chatID, "baz", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.StarredWord(chatID, "foo"); err != sql.ErrNoRows {
+		t.Errorf("StarredWord after unstarring: got %v want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour}, newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID, other int64 = 1, 2
+	if err := r.Save(chatID, "alma", "apple", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	if image, err := r.Image(chatID, "alma"); err != nil || image != "" {
+		t.Errorf("Image before setting: got %q, %v want empty", image, err)
+	}
+	if err := r.SetImage(chatID, "alma", "file-1"); err != nil {
+		t.Fatal(err)
+	}
+	if image, err := r.Image(chatID, "alma"); err != nil || image != "file-1" {
+		t.Errorf("Image: got %q, %v want file-1", image, err)
+	}
+	// Images are copied with the cards.
+	if _, err := r.CopyCards(chatID, other, ""); err != nil {
+		t.Fatal(err)
+	}
+	if image, err := r.Image(other, "alma"); err != nil || image != "file-1" {
+		t.Errorf("Image of the copied card: got %q, %v want file-1", image, err)
+	}
+	if err := r.SetImage(chatID, "alma", ""); err != nil {
+		t.Fatal(err)
+	}
+	if image, err := r.Image(chatID, "alma"); err != nil || image != "" {
+		t.Errorf("Image after removing: got %q, %v want empty", image, err)
+	}
+}
+
+func TestCreditCard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"foo", "bar", "baz"} {
+		if err := r.Save(chatID, w, w+" is a word", "hun"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+	if ws, err := r.RecentWords(chatID, "", 2, "baz"); err != nil || !reflect.DeepEqual(ws, []string{"bar", "foo"}) {
+		t.Errorf("RecentWords: got %q, %v want [bar foo]", ws, err)
+	}
+	if ws, err := r.RecentWords(chatID, "deu", 2); err != nil || len(ws) != 0 {
+		t.Errorf("RecentWords in deu: got %q, %v want []", ws, err)
+	}
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	// CreditCard keeps the stage of ready cards and doesn't touch the rest.
+	if err := r.CreditCard(chatID, "foo", Scheduling{}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := r.Progress(chatID, "foo"); err != nil || p.Stage != 1 || !p.NextReview.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("Progress after CreditCard: got %+v, %v want stage 1 in an hour", p, err)
+	}
+	if err := r.CreditCard(chatID, "foo", Scheduling{}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := r.Progress(chatID, "foo"); !p.NextReview.Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("CreditCard moved a card that isn't ready: %+v", p)
+	}
+	if err := r.CreditCard(chatID, "bar", Scheduling{Leitner: true}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := r.Progress(chatID, "bar"); !p.NextReview.Equal(clock.Now().Add(leitnerIntervals[0])) {
+		t.Errorf("CreditCard with leitner: got %+v want the interval of the first box", p)
+	}
+	if n, err := r.CountReviews(time.Time{}); err != nil || n != 1 {
+		t.Errorf("CountReviews: got %d, %v want only the answer", n, err)
+	}
+}
+
+func TestSpreadBacklog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"a", "b", "c", "d", "e"} {
+		if err := r.Save(chatID, w, w+" is a letter", "hun"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+	clock.Advance(2 * 24 * time.Hour)
+	// Ready today, it isn't a part of the backlog.
+	if err := r.Save(chatID, "f", "f is a letter", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.SpreadBacklog(chatID, 2, clock.Now(), time.UTC); err != nil || n != 3 {
+		t.Errorf("SpreadBacklog: got %d, %v want 3", n, err)
+	}
+	today := time.Date(2020, 6, 3, 0, 0, 0, 0, time.UTC)
+	for w, want := range map[string]time.Time{
+		"a": time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		"b": time.Date(2020, 6, 1, 12, 1, 0, 0, time.UTC),
+		"c": today.AddDate(0, 0, 1),
+		"d": today.AddDate(0, 0, 1),
+		"e": today.AddDate(0, 0, 2),
+		"f": clock.Now(),
+	} {
+		if p, err := r.Progress(chatID, w); err != nil || !p.NextReview.Equal(want) {
+			t.Errorf("Progress(%q): got %+v, %v want next review at %v", w, p, err, want)
+		}
+	}
+	if n, err := r.SpreadBacklog(chatID, 2, clock.Now(), time.UTC); err != nil || n != 0 {
+		t.Errorf("SpreadBacklog again: got %d, %v want 0", n, err)
+	}
+}
+
+func TestCapInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, 365 * 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"foo", "bar", "baz"} {
+		if err := r.Save(chatID, w, w+" is a word", "hun"); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.AnswerKnow(chatID, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const max = 180 * 24 * time.Hour
+	if err := r.CapInterval(chatID, "foo", max); err != nil {
+		t.Fatal(err)
+	}
+	for w, want := range map[string]time.Duration{"foo": max, "bar": 365 * 24 * time.Hour} {
+		if p, err := r.Progress(chatID, w); err != nil || !p.NextReview.Equal(clock.Now().Add(want)) {
+			t.Errorf("Progress(%q): got %+v, %v want next review in %v", w, p, err, want)
+		}
+	}
+	if err := r.CapInterval(chatID, "", max); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{"foo", "bar", "baz"} {
+		if p, err := r.Progress(chatID, w); err != nil || !p.NextReview.Equal(clock.Now().Add(max)) {
+			t.Errorf("Progress(%q) after capping all: got %+v, %v want next review in %v", w, p, err, max)
+		}
+	}
+	// Shorter intervals aren't extended.
+	if err := r.CapInterval(chatID, "", 365*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := r.Progress(chatID, "foo"); !p.NextReview.Equal(clock.Now().Add(max)) {
+		t.Errorf("CapInterval extended the interval: %+v", p)
+	}
+	// Answers are capped and logged with the capped interval.
+	const shorter = 30 * 24 * time.Hour
+	if err := r.AnswerCard(chatID, "bar", true, false, Scheduling{MaxInterval: shorter}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := r.Progress(chatID, "bar"); err != nil || !p.NextReview.Equal(clock.Now().Add(shorter)) {
+		t.Errorf("Progress after AnswerCard: got %+v, %v want next review in %v", p, err, shorter)
+	}
+	rs, err := r.Reviews(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rs[len(rs)-1]; got.Word != "bar" || got.Interval != shorter {
+		t.Errorf("last review: got %+v want bar with interval %v", got, shorter)
+	}
+}
+
+func TestAnswerLearning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{20 * time.Second, 24 * time.Hour, 48 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	if err := r.Save(chatID, "foo", "foo is a word", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	steps := []time.Duration{time.Minute, 10 * time.Minute, 24 * time.Hour}
+	check := func(step string, known bool, wantStage int, wantNext time.Duration) {
+		t.Helper()
+		if err := r.AnswerCard(chatID, "foo", known, false, Scheduling{LearningSteps: steps}); err != nil {
+			t.Fatal(err)
+		}
+		p, err := r.Progress(chatID, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Stage != wantStage || !p.NextReview.Equal(clock.Now().Add(wantNext)) {
+			t.Errorf("%s: got stage %d, next review in %v want stage %d in %v", step, p.Stage, p.NextReview.Sub(clock.Now()), wantStage, wantNext)
+		}
+	}
+	check("known new card", true, 0, 10*time.Minute)
+	check("forgotten", false, 0, time.Minute)
+	check("known again", true, 0, 10*time.Minute)
+	check("known at the second step", true, 0, 24*time.Hour)
+	check("graduated", true, 1, 24*time.Hour)
+	check("learned", true, 2, 48*time.Hour)
+	check("forgotten after learning", false, 0, time.Minute)
+	if n, err := r.CountReviews(time.Time{}); err != nil || n != 7 {
+		t.Errorf("CountReviews: got %d, %v want 7", n, err)
+	}
+}
+
+func TestRepeatWordOrdered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"old", "rare", "Common", "mid", "Élet"} {
+		if err := r.Save(chatID, w, w+" is a word", "hun"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+	if err := r.AnswerDontKnow(chatID, "old"); err != nil {
+		t.Fatal(err)
+	}
+	// LOWER() of SQLite wouldn't fold "Élet".
+	for word, n := range map[string]int{"common": 3, "élet": 2, "mid": 1} {
+		res, err := db.Exec(`INSERT INTO Words(word, lang) VALUES($0, 'hun')`, word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			if _, err := db.Exec(`INSERT INTO WordSentences(word_id, sentence_id) VALUES($0, $1)`, id, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check := func(order string, want string, except ...string) {
+		t.Helper()
+		if w, err := r.RepeatWordOrdered(chatID, "", clock.Now(), order, except...); err != nil || w != want {
+			t.Errorf("RepeatWordOrdered(%q, except %q): got %q, %v want %q", order, except, w, err, want)
+		}
+	}
+	check(NewCardsFrequent, "old")
+	check(NewCardsFrequent, "Common", "old")
+	check(NewCardsFrequent, "Élet", "old", "Common")
+	check(NewCardsFrequent, "mid", "old", "Common", "Élet")
+	check(NewCardsFrequent, "rare", "old", "Common", "Élet", "mid")
+	check(NewCardsRandom, "old")
+	check(NewCardsAdded, "rare", "old")
+	if w, err := r.RepeatWordOrdered(chatID, "", clock.Now(), NewCardsRandom, "old"); err != nil || w == "old" {
+		t.Errorf("RepeatWordOrdered(random, except old): got %q, %v", w, err)
+	}
+}
+
+// TestSchedulerProperties answers a card at random times with random settings
+// and checks its schedule after every answer against a model of the
+// schedulers: intervals are never negative or longer than the cap, stages and
+// boxes stay in range, known answers of ready cards never shorten the interval
+// and unknown ones start over. Answers go through answerCard as in practice,
+// after spreading the backlog in the catch-up mode.
+func TestSchedulerProperties(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stages := []time.Duration{0, time.Hour, 24 * time.Hour, 72 * time.Hour, 240 * time.Hour}
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, stages, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{Clients: &Clients{Repetitions: r, Settings: sc, Clock: clock}}
+	const (
+		sequences = 100
+		answers   = 40
+	)
+	for seed := int64(0); seed < sequences; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		chatID := seed
+		// The other cards are a backlog for the catch-up mode.
+		for _, w := range []string{"foo", "bar", "baz"} {
+			if err := r.Save(chatID, w, w+" is a word", "hun"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		settings := DefaultSettings()
+		if rnd.Intn(3) == 0 {
+			settings.Scheduler = LeitnerScheduler
+		}
+		if rnd.Intn(2) == 0 {
+			settings.LearningSteps = []time.Duration{time.Minute, 10 * time.Minute}
+		}
+		if rnd.Intn(2) == 0 {
+			settings.MaxIntervalDays = 1 + rnd.Intn(5)
+		}
+		if rnd.Intn(2) == 0 {
+			settings.CatchUp = 1
+		}
+		if err := sc.Set(chatID, settings); err != nil {
+			t.Fatal(err)
+		}
+		leitner := settings.Scheduler == LeitnerScheduler
+		learning := !leitner && len(settings.LearningSteps) > 0
+		// The model of the card: its stage and learning step, or its box
+		// for leitner.
+		stage, step, box := 0, 0, 1
+		// The interval set by the previous answer.
+		var last time.Duration
+		history := []string{fmt.Sprintf("%+v", settings.Scheduling())}
+		for i := 0; i < answers; i++ {
+			// Answers come up to a few days late, or early when studying
+			// ahead.
+			clock.Advance(time.Duration(rnd.Int63n(int64(96 * time.Hour))).Truncate(time.Second))
+			now := clock.Now()
+			if err := spreadBacklog(s, chatID); err != nil {
+				t.Fatal(err)
+			}
+			p, err := r.Progress(chatID, "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			due := !p.NextReview.After(now)
+			known := rnd.Intn(3) > 0
+			history = append(history, fmt.Sprintf("known=%t due=%t", known, due))
+			if err := answerCard(s, chatID, "foo", known, !due); err != nil {
+				t.Fatal(err)
+			}
+
+			var want time.Duration
+			switch {
+			case leitner:
+				switch {
+				case !known:
+					box = 1
+				case due:
+					box = min(box+1, len(leitnerIntervals))
+				}
+				want = leitnerIntervals[box-1]
+			case !known && learning:
+				stage, step = 0, 0
+				want = settings.LearningSteps[0]
+			case !known:
+				stage = 0
+				want = stages[0]
+			case learning && stage == 0 && step+1 < len(settings.LearningSteps):
+				step++
+				want = settings.LearningSteps[step]
+			default:
+				if due {
+					stage = min(stage+1, len(stages)-1)
+				}
+				want = stages[stage]
+			}
+			if max := settings.MaxInterval(); max > 0 {
+				want = min(want, max)
+			}
+
+			got, err := r.Progress(chatID, "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			interval := got.NextReview.Sub(now)
+			switch {
+			case interval < 0:
+				t.Errorf("seed %d after %q: negative interval %v", seed, history, interval)
+			case settings.MaxInterval() > 0 && interval > settings.MaxInterval():
+				t.Errorf("seed %d after %q: interval %v over the cap", seed, history, interval)
+			case interval != want:
+				t.Errorf("seed %d after %q: got interval %v want %v", seed, history, interval, want)
+			case got.Stage < 0 || got.Stage >= len(stages):
+				t.Errorf("seed %d after %q: stage %d out of [0, %d)", seed, history, got.Stage, len(stages))
+			case !leitner && got.Stage != stage:
+				t.Errorf("seed %d after %q: got stage %d want %d", seed, history, got.Stage, stage)
+			}
+			if known && due && interval < last {
+				t.Errorf("seed %d after %q: known answer shortened the interval from %v to %v", seed, history, last, interval)
+			}
+			last = interval
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ReviewStats summarizes user's practice over a period of time.
+type ReviewStats struct {
+	// Number of answers given.
+	Reviews int
+	// Number of words added for practice.
+	Added int
+	// Fraction of answers where the word was known.
+	Retention float64
+	// Longest number of consecutive days with at least one review, over the
+	// whole history.
+	LongestStreak int
+	// Words that were not known the most times.
+	Hardest []string
+}
+
+// insertReviewQuery logs the answer after the card was rescheduled, so the
+// interval is the one the answer scheduled.
+const insertReviewQuery = `
+	INSERT INTO ReviewLog(chat_id, word, known, reviewed_seconds, interval_seconds)
+	VALUES($0, $1, $2, $3, (
+		SELECT next_review_seconds - last_updated_seconds
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND word = $1))`
+
+func (r *Repetition) logReview(tx *sql.Tx, chatID int64, word string, known bool) error {
+	_, err := tx.Stmt(r.insertReview).Exec(chatID, word, known, r.clock.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("INTERNAL: logging review of %q: %w", word, err)
+	}
+	return nil
+}
+
+// CountReviews returns the number of answers given by all chats since the given
+// time.
+func (r *Repetition) CountReviews(since time.Time) (int, error) {
+	var n int
+	row := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM ReviewLog
+		WHERE reviewed_seconds >= $0`,
+		since.Unix())
+	if err := row.Scan(&n); err != nil {
+		return 0, fmt.Errorf("INTERNAL: counting reviews: %w", err)
+	}
+	return n, nil
+}
+
+// maxHardest is the maximum number of words in ReviewStats.Hardest.
+const maxHardest = 3
+
+// Stats returns statistics of the reviews since the given time. Days for
+// streaks are counted in the given location.
+func (r *Repetition) Stats(chatID int64, since time.Time, loc *time.Location) (*ReviewStats, error) {
+	st := &ReviewStats{}
+	var known sql.NullInt64
+	row := r.db.QueryRow(`
+		SELECT COUNT(*), SUM(known)
+		FROM ReviewLog
+		WHERE chat_id = $0
+		  AND reviewed_seconds >= $1`,
+		chatID, since.Unix())
+	if err := row.Scan(&st.Reviews, &known); err != nil {
+		return nil, fmt.Errorf("INTERNAL: counting reviews for chat %d: %w", chatID, err)
+	}
+	if st.Reviews > 0 {
+		st.Retention = float64(known.Int64) / float64(st.Reviews)
+	}
+
+	row = r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND added_seconds >= $1`,
+		chatID, since.Unix())
+	if err := row.Scan(&st.Added); err != nil {
+		return nil, fmt.Errorf("INTERNAL: counting added words for chat %d: %w", chatID, err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT word
+		FROM ReviewLog
+		WHERE chat_id = $0
+		  AND reviewed_seconds >= $1
+		  AND NOT known
+		GROUP BY word
+		ORDER BY COUNT(*) DESC, word
+		LIMIT $2`,
+		chatID, since.Unix(), maxHardest)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving hardest words for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
+		}
+		st.Hardest = append(st.Hardest, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	streak, err := r.Streak(chatID, r.clock.Now(), loc)
+	if err != nil {
+		return nil, err
+	}
+	st.LongestStreak = streak.Longest
+	return st, nil
+}
+
+// Streak counts consecutive days with at least one review.
+type Streak struct {
+	// Current is the streak that ends today, or yesterday if there were no
+	// reviews today yet. It's 0 if the streak is broken.
+	Current int
+	// Longest over the whole history.
+	Longest int
+	// ReviewedToday is true if today already counts towards Current.
+	ReviewedToday bool
+}
+
+// localDay returns the midnight of t's day in loc.
+func localDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// Streak returns streaks of the chat as of now. Days are counted in loc.
+func (r *Repetition) Streak(chatID int64, now time.Time, loc *time.Location) (*Streak, error) {
+	rows, err := r.db.Query(`
+		SELECT reviewed_seconds
+		FROM ReviewLog
+		WHERE chat_id = $0
+		ORDER BY reviewed_seconds`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving reviews for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	st := &Streak{}
+	var streak int
+	var last time.Time
+	for rows.Next() {
+		var s int64
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		day := localDay(time.Unix(s, 0), loc)
+		switch {
+		case day.Equal(last):
+			continue
+		case day.Equal(last.AddDate(0, 0, 1)):
+			streak++
+		default:
+			streak = 1
+		}
+		last = day
+		if streak > st.Longest {
+			st.Longest = streak
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	today := localDay(now, loc)
+	st.ReviewedToday = last.Equal(today)
+	if st.ReviewedToday || last.Equal(today.AddDate(0, 0, -1)) {
+		st.Current = streak
+	}
+	return st, nil
+}
+
+// ReviewsByDay returns the number of answers given on each day since the
+// given time. Days are midnights in loc, days without reviews are missing.
+func (r *Repetition) ReviewsByDay(chatID int64, since time.Time, loc *time.Location) (map[time.Time]int, error) {
+	rows, err := r.db.Query(`
+		SELECT reviewed_seconds
+		FROM ReviewLog
+		WHERE chat_id = $0
+		  AND reviewed_seconds >= $1`,
+		chatID, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving reviews for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	days := make(map[time.Time]int)
+	for rows.Next() {
+		var s int64
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		days[localDay(time.Unix(s, 0), loc)]++
+	}
+	return days, rows.Err()
+}
+
+// ReviewsToday returns the number of answers given since the midnight of now
+// in loc.
+func (r *Repetition) ReviewsToday(chatID int64, now time.Time, loc *time.Location) (int, error) {
+	var n int
+	row := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM ReviewLog
+		WHERE chat_id = $0
+		  AND reviewed_seconds >= $1`,
+		chatID, localDay(now, loc).Unix())
+	if err := row.Scan(&n); err != nil {
+		return 0, fmt.Errorf("INTERNAL: counting today's reviews for chat %d: %w", chatID, err)
+	}
+	return n, nil
+}
+
+// Review is an answer in the review log.
+type Review struct {
+	Time  time.Time
+	Word  string
+	Known bool
+	// Since is the time since the previous review of the word, 0 for the
+	// first one.
+	Since time.Duration
+	// Interval until the next review scheduled by the answer, 0 if it wasn't
+	// logged.
+	Interval time.Duration
+}
+
+// Reviews returns all answers of the chat, the earliest first.
+func (r *Repetition) Reviews(chatID int64) ([]*Review, error) {
+	rows, err := r.db.Query(`
+		SELECT word, known, reviewed_seconds, COALESCE(interval_seconds, 0)
+		FROM ReviewLog
+		WHERE chat_id = $0
+		ORDER BY reviewed_seconds`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving reviews for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var rs []*Review
+	last := make(map[string]time.Time)
+	for rows.Next() {
+		rv := &Review{}
+		var reviewed, interval int64
+		if err := rows.Scan(&rv.Word, &rv.Known, &reviewed, &interval); err != nil {
+			return nil, err
+		}
+		rv.Time = time.Unix(reviewed, 0)
+		rv.Interval = time.Duration(interval) * time.Second
+		if t, ok := last[rv.Word]; ok {
+			rv.Since = rv.Time.Sub(t)
+		}
+		last[rv.Word] = rv.Time
+		rs = append(rs, rv)
+	}
+	return rs, rows.Err()
+}
+
+// RetentionBucket counts the reviews of a group in RetentionReport.
+type RetentionBucket struct {
+	Known int
+	Total int
+}
+
+func (b *RetentionBucket) add(known bool) {
+	b.Total++
+	if known {
+		b.Known++
+	}
+}
+
+// retentionIntervals are the upper bounds of the intervals since the previous
+// review in RetentionReport.ByInterval, the last bucket has no bound.
+var retentionIntervals = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+}
+
+// RetentionReport breaks down the answers of a chat to tell how well the
+// words are remembered depending on their schedule.
+type RetentionReport struct {
+	// Reviews grouped by the previous answer for the same card. First
+	// reviews after the card was added have no previous answer.
+	AfterKnow     RetentionBucket
+	AfterDontKnow RetentionBucket
+	FirstReview   RetentionBucket
+	// Reviews grouped by the time since the previous review or since the
+	// card was added, see retentionIntervals. Cards reviewed more rarely
+	// are more mature.
+	ByInterval []RetentionBucket
+}
+
+// Retention returns the retention report over the whole review log of the
+// chat.
+func (r *Repetition) Retention(chatID int64) (*RetentionReport, error) {
+	// Cards that were deleted since have no added_seconds, their first
+	// reviews aren't counted by interval.
+	rows, err := r.db.Query(`
+		SELECT l.word, l.known, l.reviewed_seconds, r.added_seconds
+		FROM ReviewLog l
+		LEFT JOIN Repetition r
+		  ON r.chat_id = l.chat_id
+		  AND r.word = l.word
+		WHERE l.chat_id = $0
+		ORDER BY l.word, l.reviewed_seconds`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving reviews for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	rep := &RetentionReport{ByInterval: make([]RetentionBucket, len(retentionIntervals)+1)}
+	var prevWord string
+	var prevKnown bool
+	var prevTime sql.NullInt64
+	for rows.Next() {
+		var word string
+		var known bool
+		var reviewed int64
+		var added sql.NullInt64
+		if err := rows.Scan(&word, &known, &reviewed, &added); err != nil {
+			return nil, err
+		}
+		switch {
+		case word != prevWord:
+			rep.FirstReview.add(known)
+			prevTime = added
+		case prevKnown:
+			rep.AfterKnow.add(known)
+		default:
+			rep.AfterDontKnow.add(known)
+		}
+		if prevTime.Valid {
+			d := time.Duration(reviewed-prevTime.Int64) * time.Second
+			i := 0
+			for i < len(retentionIntervals) && d >= retentionIntervals[i] {
+				i++
+			}
+			rep.ByInterval[i].add(known)
+		}
+		prevWord, prevKnown, prevTime = word, known, sql.NullInt64{Int64: reviewed, Valid: true}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
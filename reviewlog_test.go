@@ -0,0 +1,293 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reviewlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRepetition(db, []time.Duration{0}, SystemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"foo", "bar", "baz"} {
+		if err := r.Save(chatID, w, w+" definition", "hun"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range []struct {
+		word  string
+		known bool
+	}{
+		{"foo", false},
+		{"foo", false},
+		{"bar", false},
+		{"bar", true},
+		{"baz", true},
+		{"baz", true},
+	} {
+		f := r.AnswerDontKnow
+		if a.known {
+			f = r.AnswerKnow
+		}
+		if err := f(chatID, a.word); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Move some reviews into the past to form a streak of 3 days: 2 days
+	// ago, yesterday and today.
+	now := time.Now()
+	for _, u := range []struct {
+		word string
+		days int
+	}{
+		{"baz", 2},
+		{"bar", 1},
+	} {
+		if _, err := r.db.Exec(`
+			UPDATE ReviewLog
+			SET reviewed_seconds = $0
+			WHERE word = $1`,
+			now.AddDate(0, 0, -u.days).Unix(), u.word); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := r.Stats(chatID, now.Add(-time.Hour), time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ReviewStats{
+		Reviews:       2,
+		Added:         3,
+		Retention:     0,
+		LongestStreak: 3,
+		Hardest:       []string{"foo"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Stats: (-got +want):\n%s", diff)
+	}
+
+	got, err = r.Stats(chatID, now.AddDate(0, 0, -7), time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &ReviewStats{
+		Reviews:       6,
+		Added:         3,
+		Retention:     0.5,
+		LongestStreak: 3,
+		Hardest:       []string{"foo", "bar"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Stats: (-got +want):\n%s", diff)
+	}
+
+	if n, err := r.CountReviews(now.Add(-time.Hour)); err != nil || n != 2 {
+		t.Errorf("CountReviews(last hour): got %d, %v want 2, nil", n, err)
+	}
+	if n, err := r.CountCards(); err != nil || n != 3 {
+		t.Errorf("CountCards: got %d, %v want 3, nil", n, err)
+	}
+}
+
+func TestStreak(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reviewlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	r, err := NewRepetition(db, []time.Duration{0}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	if err := r.Save(chatID, "foo", "foo definition", "hun"); err != nil {
+		t.Fatal(err)
+	}
+	// Reviews on days 0-2 and 4-5, twice on day 1.
+	for _, d := range []int{0, 1, 1, 2, 4, 5} {
+		clock.Set(start.AddDate(0, 0, d))
+		if err := r.AnswerKnow(chatID, "foo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want Streak
+	}{
+		{"reviewed today", start.AddDate(0, 0, 5).Add(time.Hour), time.UTC, Streak{Current: 2, Longest: 3, ReviewedToday: true}},
+		{"not yet today", start.AddDate(0, 0, 6), time.UTC, Streak{Current: 2, Longest: 3}},
+		{"broken", start.AddDate(0, 0, 7), time.UTC, Streak{Current: 0, Longest: 3}},
+		// 10:00 UTC is the next day at UTC+14.
+		{"other time zone", start.AddDate(0, 0, 5).Add(time.Hour), time.FixedZone("UTC+14", 14*60*60), Streak{Current: 2, Longest: 3, ReviewedToday: true}},
+		{"day before the reviews", start.AddDate(0, 0, -1), time.UTC, Streak{Longest: 3}},
+	} {
+		got, err := r.Streak(chatID, tc.now, tc.loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(&tc.want, got); diff != "" {
+			t.Errorf("%s: Streak (-want +got):\n%s", tc.name, diff)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reviewlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{0}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"foo", "bar"} {
+		if err := r.Save(chatID, w, w+" definition", "hun"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range []struct {
+		after time.Duration
+		word  string
+		known bool
+	}{
+		{time.Hour, "foo", false},
+		{time.Hour, "bar", true},
+		{2 * 24 * time.Hour, "foo", true},
+		{10 * 24 * time.Hour, "foo", true},
+		{40 * 24 * time.Hour, "bar", false},
+	} {
+		clock.Advance(a.after)
+		f := r.AnswerDontKnow
+		if a.known {
+			f = r.AnswerKnow
+		}
+		if err := f(chatID, a.word); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := r.Retention(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RetentionReport{
+		AfterKnow:     RetentionBucket{Known: 1, Total: 2},
+		AfterDontKnow: RetentionBucket{Known: 1, Total: 1},
+		FirstReview:   RetentionBucket{Known: 1, Total: 2},
+		ByInterval: []RetentionBucket{
+			{Known: 1, Total: 2},
+			{Known: 1, Total: 1},
+			{Known: 1, Total: 1},
+			{Known: 0, Total: 1},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Retention() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReviews(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reviewlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	r, err := NewRepetition(db, []time.Duration{time.Minute, time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"foo", "bar"} {
+		if err := r.Save(chatID, w, w+" definition", "hun"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := r.AnswerDontKnow(chatID, "bar"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := r.AnswerKnow(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := r.Reviews(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Review{
+		{Time: start, Word: "foo", Known: true, Interval: time.Hour},
+		{Time: start.Add(time.Hour), Word: "bar", Interval: time.Minute},
+		{Time: start.Add(2 * time.Hour), Word: "foo", Known: true, Since: 2 * time.Hour, Interval: 24 * time.Hour},
+	}
+	if diff := cmp.Diff(want, rs); diff != "" {
+		t.Errorf("Reviews (-want +got):\n%s", diff)
+	}
+	got, err := historyCSV(rs, time.FixedZone("UTC+1", 60*60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "time,word,known,interval_before_seconds,interval_after_seconds\n" +
+		"2020-06-01T11:00:00+01:00,foo,1,,3600\n" +
+		"2020-06-01T12:00:00+01:00,bar,0,,60\n" +
+		"2020-06-01T13:00:00+01:00,foo,1,7200,86400\n"
+	if diff := cmp.Diff(wantCSV, string(got)); diff != "" {
+		t.Errorf("historyCSV (-want +got):\n%s", diff)
+	}
+}
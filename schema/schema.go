@@ -0,0 +1,513 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Package schema keeps the schema of the database up to date. It's used both
+// by the bot and by the corpus loader, since they share the database.
+//
+// Migrations are applied in order and each of them only once, the applied
+// versions are recorded in the Migrations table. To change the schema append
+// a new migration to the list, never edit the ones that could have been
+// applied already.
+package schema
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"words/tokenize"
+)
+
+// Migration changes the schema from the previous version to the next one.
+type Migration struct {
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// execSQL returns a migration step that executes the statements.
+func execSQL(stmts string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmts)
+		return err
+	}
+}
+
+// addColumn adds the column unless it's there already. Databases created before
+// migrations were introduced could have got the column without recording it.
+// Comments are not allowed in typ, they would end up in the table definition.
+func addColumn(table, column, typ string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				cid        int
+				name, ctyp string
+				notNull    bool
+				dflt       sql.NullString
+				pk         int
+			)
+			if err := rows.Scan(&cid, &name, &ctyp, &notNull, &dflt, &pk); err != nil {
+				return err
+			}
+			if name == column {
+				return nil
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, typ))
+		return err
+	}
+}
+
+// Migrations is the ordered list of all migrations, version of a migration is
+// its index + 1.
+//
+// The tables of the first migrations were created by the components
+// themselves, so they use IF NOT EXISTS to adopt existing databases.
+var Migrations = []Migration{
+	{"Create corpus tables", execSQL(`
+		CREATE TABLE IF NOT EXISTS Sentences (
+			id INTEGER PRIMARY KEY,
+			lang STRING,
+			text STRING
+		);
+
+		CREATE TABLE IF NOT EXISTS Translations (
+			id INTEGER,
+			translation_id INTEGER,
+			FOREIGN KEY(id) REFERENCES Sentences(id),
+			FOREIGN KEY(translation_id) REFERENCES Sentences(id)
+		);
+		CREATE INDEX IF NOT EXISTS TranslationsIdIndex
+		ON Translations (id);
+
+		CREATE TABLE IF NOT EXISTS Words (
+			word STRING,
+			lang STRING,
+			sentence_id INTEGER,
+			FOREIGN KEY(sentence_id) REFERENCES Sentences(id)
+		);
+		CREATE INDEX IF NOT EXISTS WordLangIndex
+		ON Words (word, lang);`)},
+	{"Create definitions cache", execSQL(`
+		CREATE TABLE IF NOT EXISTS Definitions (
+			query string UNIQUE NOT NULL, -- user's query
+			word string, -- the corresponding word (can be different from query in case of typos)
+			definition string
+		);`)},
+	{"Create repetition", execSQL(`
+		CREATE TABLE IF NOT EXISTS Repetition (
+			chat_id INTEGER,
+			word STRING,
+			definition STRING,
+			stage INTEGER,
+			last_updated_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	{"Create settings", execSQL(`
+		CREATE TABLE IF NOT EXISTS Settings (
+			chat_id INTEGER PRIMARY KEY,
+			settings STRING -- json serialized settings
+		);`)},
+	{"Create reminders", execSQL(`
+		CREATE TABLE IF NOT EXISTS Reminders (
+			chat_id INTEGER PRIMARY KEY,
+			last_reminder_time_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	{"Add sentence authors", addColumn("Sentences", "author", "STRING")},
+	{"Track loading progress of the corpus", execSQL(`
+		-- High-water mark for each of the loaded files.
+		CREATE TABLE IF NOT EXISTS LoadProgress (
+			path STRING PRIMARY KEY,
+			offset INTEGER -- in bytes
+		);`)},
+	{"Create languages", execSQL(`
+		CREATE TABLE IF NOT EXISTS Languages (
+			name STRING PRIMARY KEY,
+			iso639_3 STRING UNIQUE,
+			translation_languages STRING, -- comma separated ISO 639-3 codes
+			sources STRING -- comma separated
+		);`)},
+	// Existing cards are populated by NewRepetition, since it depends on
+	// the configured stages.
+	{"Add next review time", addColumn("Repetition", "next_review_seconds", "INTEGER")},
+	{"Create review log", execSQL(`
+		CREATE TABLE IF NOT EXISTS ReviewLog (
+			chat_id INTEGER,
+			word STRING,
+			known BOOLEAN,
+			reviewed_seconds INTEGER -- seconds since UNIX epoch
+		);
+		CREATE INDEX IF NOT EXISTS ReviewLogChatIndex ON ReviewLog(chat_id, reviewed_seconds);`)},
+	// Words added before added_seconds was introduced are never counted as
+	// new.
+	{"Add time when cards were added", addColumn("Repetition", "added_seconds", "INTEGER")},
+	{"Create summaries", execSQL(`
+		CREATE TABLE IF NOT EXISTS Summaries (
+			chat_id INTEGER PRIMARY KEY,
+			last_summary_time_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	// Cards are always looked up by chat, the second index covers the
+	// queries for due cards.
+	{"Index repetition", execSQL(`
+		CREATE INDEX IF NOT EXISTS RepetitionChatWordIndex
+		ON Repetition (chat_id, word);
+		CREATE INDEX IF NOT EXISTS RepetitionChatReviewIndex
+		ON Repetition (chat_id, next_review_seconds, word);`)},
+	{"Create streak warnings", execSQL(`
+		CREATE TABLE IF NOT EXISTS StreakWarnings (
+			chat_id INTEGER PRIMARY KEY,
+			last_warning_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	// A chat shares all its cards as one deck, chat_id is the owner.
+	{"Create decks", execSQL(`
+		CREATE TABLE IF NOT EXISTS Decks (
+			code STRING PRIMARY KEY,
+			chat_id INTEGER UNIQUE,
+			created_seconds INTEGER -- seconds since UNIX epoch
+		);
+		CREATE TABLE IF NOT EXISTS DeckFollowers (
+			code STRING,
+			chat_id INTEGER,
+			PRIMARY KEY (code, chat_id)
+		);`)},
+	{"Add language to cards", steps(
+		addColumn("Repetition", "language", "STRING NOT NULL DEFAULT ''"),
+		setCardLanguages,
+	)},
+	// The state of the scheduler chosen by the user, e.g. the box of the
+	// Leitner system.
+	{"Add scheduler state to cards", addColumn("Repetition", "scheduler_state", "INTEGER NOT NULL DEFAULT 0")},
+	// Words had a row for every occurrence of a word, now every word is
+	// stored once and linked to the sentences it occurs in.
+	{"Normalize words", execSQL(`
+		ALTER TABLE Words RENAME TO WordOccurrences;
+		CREATE TABLE Words (
+			word_id INTEGER PRIMARY KEY,
+			word STRING,
+			lang STRING,
+			UNIQUE (word, lang)
+		);
+		INSERT INTO Words(word, lang)
+		SELECT DISTINCT word, lang FROM WordOccurrences;
+		CREATE TABLE WordSentences (
+			word_id INTEGER,
+			sentence_id INTEGER,
+			PRIMARY KEY (word_id, sentence_id),
+			FOREIGN KEY(word_id) REFERENCES Words(word_id),
+			FOREIGN KEY(sentence_id) REFERENCES Sentences(id)
+		) WITHOUT ROWID;
+		INSERT OR IGNORE INTO WordSentences(word_id, sentence_id)
+		SELECT w.word_id, o.sentence_id
+		FROM WordOccurrences o
+		INNER JOIN Words w ON w.word = o.word AND w.lang = o.lang;
+		DROP TABLE WordOccurrences;`)},
+	{"Create API tokens", execSQL(`
+		-- Only hashes of the tokens are stored, a chat has at most one.
+		CREATE TABLE IF NOT EXISTS APITokens (
+			token_hash STRING PRIMARY KEY,
+			chat_id INTEGER UNIQUE,
+			created_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	// Links sent by /dashboard are exchanged for a session once.
+	{"Create web sessions", execSQL(`
+		CREATE TABLE IF NOT EXISTS WebLogins (
+			code_hash STRING PRIMARY KEY,
+			chat_id INTEGER,
+			expires_seconds INTEGER -- seconds since UNIX epoch
+		);
+		CREATE TABLE IF NOT EXISTS WebSessions (
+			session_hash STRING PRIMARY KEY,
+			chat_id INTEGER,
+			expires_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	{"Add stars to cards", addColumn("Repetition", "starred", "INTEGER NOT NULL DEFAULT 0")},
+	{"Create goal nudges", execSQL(`
+		CREATE TABLE IF NOT EXISTS GoalNudges (
+			chat_id INTEGER PRIMARY KEY,
+			last_nudge_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	{"Add learning steps to cards", addColumn("Repetition", "learning_step", "INTEGER NOT NULL DEFAULT 0")},
+	// The interval until the next review scheduled by the answer, NULL for
+	// the answers logged before.
+	{"Log intervals of reviews", addColumn("ReviewLog", "interval_seconds", "INTEGER")},
+	{"Create review queues", execSQL(`
+		CREATE TABLE IF NOT EXISTS ReviewQueues (
+			chat_id INTEGER PRIMARY KEY,
+			empty INTEGER NOT NULL DEFAULT 0 -- whether no card was due at the last check
+		);`)},
+	{"Mark chats without reminder windows", steps(
+		execSQL(`
+			CREATE TABLE IF NOT EXISTS ReminderPrompts (
+				chat_id INTEGER PRIMARY KEY,
+				prompted_seconds INTEGER -- seconds since UNIX epoch, NULL until prompted
+			);`),
+		markUnconfiguredReminders)},
+	// The Telegram file_id of the photo on the front of the card, empty if
+	// there is none.
+	{"Add images to cards", addColumn("Repetition", "image", "STRING NOT NULL DEFAULT ''")},
+	// Chats syncing their cards with Anki through AnkiConnect and which
+	// cards in Anki their words are.
+	{"Create Anki sync", execSQL(`
+		CREATE TABLE IF NOT EXISTS AnkiConfigs (
+			chat_id INTEGER PRIMARY KEY,
+			url STRING,
+			key STRING, -- API key of AnkiConnect, empty if there is none
+			deck STRING,
+			scheduler STRING, -- "bot" or "anki"
+			last_review_id INTEGER NOT NULL DEFAULT 0, -- the latest synced review in Anki
+			synced_seconds INTEGER NOT NULL DEFAULT 0 -- seconds since UNIX epoch
+		);
+		CREATE TABLE IF NOT EXISTS AnkiCards (
+			chat_id INTEGER,
+			word STRING,
+			note_id INTEGER,
+			card_id INTEGER,
+			PRIMARY KEY (chat_id, word)
+		);`)},
+	// When the definitions were cached, so that stale ones expire. Those
+	// cached before count from now.
+	{"Add times to cached definitions", steps(
+		addColumn("Definitions", "cached_seconds", "INTEGER NOT NULL DEFAULT 0"),
+		execSQL(`UPDATE Definitions SET cached_seconds = CAST(strftime('%s', 'now') AS INTEGER);`))},
+	// Chats that blocked the bot, nothing is sent to them until they write
+	// again.
+	{"Create inactive chats", execSQL(`
+		CREATE TABLE IF NOT EXISTS InactiveChats (
+			chat_id INTEGER PRIMARY KEY,
+			inactive_seconds INTEGER -- seconds since UNIX epoch
+		);`)},
+	// Definitions are now cached as JSON of the text with its entities
+	// instead of MarkdownV2, the old ones are fetched again.
+	{"Clear definitions cached as MarkdownV2", execSQL(`DELETE FROM Definitions;`)},
+	// Definitions always come from wiktionary, sources of languages were
+	// never read. The table is rebuilt, since SQLite can't drop columns.
+	{"Drop sources of languages", execSQL(`
+		CREATE TABLE LanguagesWithoutSources (
+			name STRING PRIMARY KEY,
+			iso639_3 STRING UNIQUE,
+			translation_languages STRING -- comma separated ISO 639-3 codes
+		);
+		INSERT INTO LanguagesWithoutSources(name, iso639_3, translation_languages)
+		SELECT name, iso639_3, translation_languages FROM Languages;
+		DROP TABLE Languages;
+		ALTER TABLE LanguagesWithoutSources RENAME TO Languages;`)},
+	// The word of the card in the form the corpus stores it, see
+	// tokenize.Normalize. SQLite can only fold ASCII.
+	{"Add normalized words to cards", steps(
+		addColumn("Repetition", "normalized_word", "STRING NOT NULL DEFAULT ''"),
+		normalizeCardWords)},
+}
+
+// steps returns a migration step that runs the steps in order.
+func steps(ss ...func(tx *sql.Tx) error) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, s := range ss {
+			if err := s(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// defaultCardLanguage is the input language of chats that never changed
+// settings.
+const defaultCardLanguage = "hun"
+
+// setCardLanguages sets the language of existing cards to the input language
+// of their chat. Settings are JSON, which SQLite can't query here.
+func setCardLanguages(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT chat_id, settings FROM Settings;`)
+	if err != nil {
+		return err
+	}
+	languages := make(map[int64]string)
+	for rows.Next() {
+		var (
+			chatID   int64
+			settings string
+		)
+		if err := rows.Scan(&chatID, &settings); err != nil {
+			rows.Close()
+			return err
+		}
+		var s struct{ InputLanguageISO639_3 string }
+		if err := json.Unmarshal([]byte(settings), &s); err != nil {
+			rows.Close()
+			return fmt.Errorf("settings of chat %d: %w", chatID, err)
+		}
+		languages[chatID] = s.InputLanguageISO639_3
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for chatID, l := range languages {
+		if _, err := tx.Exec(`
+			UPDATE Repetition SET language = $0
+			WHERE chat_id = $1 AND language = '';`,
+			l, chatID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`
+		UPDATE Repetition SET language = $0
+		WHERE language = '';`,
+		defaultCardLanguage)
+	return err
+}
+
+// normalizeCardWords sets the normalized words of existing cards.
+func normalizeCardWords(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT DISTINCT word FROM Repetition;`)
+	if err != nil {
+		return err
+	}
+	var words []string
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			rows.Close()
+			return err
+		}
+		words = append(words, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, w := range words {
+		if _, err := tx.Exec(`
+			UPDATE Repetition SET normalized_word = $0
+			WHERE word = $1;`,
+			tokenize.Normalize(w), w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markUnconfiguredReminders adds the chats that never chose when reminders can
+// be sent to ReminderPrompts. They used to get reminders in the default
+// window, now they are asked to opt in.
+func markUnconfiguredReminders(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT chat_id, settings FROM Settings;`)
+	if err != nil {
+		return err
+	}
+	var chatIDs []int64
+	for rows.Next() {
+		var (
+			chatID   int64
+			settings string
+		)
+		if err := rows.Scan(&chatID, &settings); err != nil {
+			rows.Close()
+			return err
+		}
+		var s struct {
+			Muted               bool
+			AvailabilityWindow  *struct{}
+			AvailabilityWindows []struct{}
+		}
+		if err := json.Unmarshal([]byte(settings), &s); err != nil {
+			rows.Close()
+			return fmt.Errorf("settings of chat %d: %w", chatID, err)
+		}
+		if !s.Muted && s.AvailabilityWindow == nil && len(s.AvailabilityWindows) == 0 {
+			chatIDs = append(chatIDs, chatID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, chatID := range chatIDs {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO ReminderPrompts(chat_id) VALUES ($0);`,
+			chatID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Version returns the latest applied migration, 0 if none.
+func Version(db *sql.DB) (int, error) {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS Migrations (
+			version INTEGER PRIMARY KEY,
+			description STRING,
+			applied_seconds INTEGER -- seconds since UNIX epoch
+		);`); err != nil {
+		return 0, fmt.Errorf("creating Migrations: %w", err)
+	}
+	var v int
+	if err := db.QueryRow(`SELECT IFNULL(MAX(version), 0) FROM Migrations;`).Scan(&v); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+// Migrate applies all migrations that weren't applied yet.
+func Migrate(db *sql.DB) error {
+	return migrate(db, Migrations)
+}
+
+func migrate(db *sql.DB, ms []Migration) error {
+	v, err := Version(db)
+	if err != nil {
+		return err
+	}
+	if v > len(ms) {
+		return fmt.Errorf("database schema version %d is newer than the latest known %d", v, len(ms))
+	}
+	for i := v; i < len(ms); i++ {
+		if err := apply(db, i+1, ms[i]); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", i+1, ms[i].Description, err)
+		}
+		slog.Info("Applied migration", "number", i+1, "description", ms[i].Description)
+	}
+	return nil
+}
+
+// apply runs the migration and records it in a single transaction, so that
+// a failed migration can be retried.
+func apply(db *sql.DB, version int, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+	// Fails on the primary key if another process has applied it meanwhile.
+	if _, err := tx.Exec(`
+		INSERT INTO Migrations(version, description, applied_seconds)
+		VALUES($0, $1, $2)`,
+		version, m.Description, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,365 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package schema
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openDB opens an empty database, cleanup removes it.
+func openDB(t *testing.T) (db *sql.DB, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = sql.Open("sqlite3", filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func version(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	v, err := Version(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMigrate(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := version(t, db), len(Migrations); got != want {
+		t.Errorf("Version() = %d want %d", got, want)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO Repetition(chat_id, word, next_review_seconds, added_seconds)
+		VALUES(1, "word", 2, 3);
+		INSERT INTO Sentences(id, lang, text, author) VALUES(1, "hun", "szó", "alice");`); err != nil {
+		t.Fatalf("Using migrated tables: %v", err)
+	}
+	// Nothing to do on the second run.
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := version(t, db), len(Migrations); got != want {
+		t.Errorf("Version() after second run = %d want %d", got, want)
+	}
+}
+
+func TestMigrateExistingDB(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	// Tables as created by components before migrations were introduced,
+	// including columns that were added later on.
+	if _, err := db.Exec(`
+		CREATE TABLE Sentences (
+			id INTEGER PRIMARY KEY,
+			lang STRING,
+			text STRING,
+			author STRING
+		);
+		CREATE TABLE Repetition (
+			chat_id INTEGER,
+			word STRING,
+			definition STRING,
+			stage INTEGER,
+			last_updated_seconds INTEGER,
+			next_review_seconds INTEGER
+		);
+		INSERT INTO Repetition(chat_id, word, stage, next_review_seconds)
+		VALUES(1, "word", 2, 3);`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	var word string
+	var next, added sql.NullInt64
+	if err := db.QueryRow(`SELECT word, next_review_seconds, added_seconds FROM Repetition`).Scan(&word, &next, &added); err != nil {
+		t.Fatal(err)
+	}
+	if word != "word" || next.Int64 != 3 || added.Valid {
+		t.Errorf("Got card (%q, %v, %v) want (\"word\", 3, NULL)", word, next, added)
+	}
+}
+
+func TestMigrateCardLanguages(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	n := -1
+	for i, m := range Migrations {
+		if m.Description == "Add language to cards" {
+			n = i
+		}
+	}
+	if n < 0 {
+		t.Fatal("migration adding the language to cards not found, update the test")
+	}
+	if err := migrate(db, Migrations[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO Settings(chat_id, settings)
+		VALUES(1, '{"InputLanguage":"German","InputLanguageISO639_3":"deu"}');
+		INSERT INTO Repetition(chat_id, word) VALUES(1, "Haus"), (2, "ház");`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(`SELECT word, language FROM Repetition ORDER BY chat_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var w, l string
+		if err := rows.Scan(&w, &l); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, w+":"+l)
+	}
+	if want := []string{"Haus:deu", "ház:hun"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got cards %q want %q", got, want)
+	}
+}
+
+func TestMigrateReminderPrompts(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	n := -1
+	for i, m := range Migrations {
+		if m.Description == "Mark chats without reminder windows" {
+			n = i
+		}
+	}
+	if n < 0 {
+		t.Fatal("migration marking chats without reminder windows not found, update the test")
+	}
+	if err := migrate(db, Migrations[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO Settings(chat_id, settings) VALUES
+			(1, '{"AvailabilityWindow":null}'),
+			(2, '{"AvailabilityWindow":{"Start":0,"End":3600}}'),
+			(3, '{"AvailabilityWindows":[{"Start":0,"End":3600,"Weekdays":2}]}'),
+			(4, '{"Muted":true}'),
+			(5, '{}');`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(`SELECT chat_id FROM ReminderPrompts WHERE prompted_seconds IS NULL ORDER BY chat_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, chatID)
+	}
+	if want := []int64{1, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got chats %v want %v", got, want)
+	}
+}
+
+func TestMigrateWords(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	n := -1
+	for i, m := range Migrations {
+		if m.Description == "Normalize words" {
+			n = i
+		}
+	}
+	if n < 0 {
+		t.Fatal("migration normalizing words not found, update the test")
+	}
+	if err := migrate(db, Migrations[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO Words(word, lang, sentence_id) VALUES
+			("ház", "hun", 1), ("ház", "hun", 1), ("ház", "hun", 2),
+			("a", "hun", 1), ("a", "eng", 3);`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(`
+		SELECT w.word, w.lang, ws.sentence_id
+		FROM Words w INNER JOIN WordSentences ws ON ws.word_id = w.word_id
+		ORDER BY w.word, w.lang, ws.sentence_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var w, l string
+		var id int
+		if err := rows.Scan(&w, &l, &id); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%s:%d", w, l, id))
+	}
+	if want := []string{"a:eng:3", "a:hun:1", "ház:hun:1", "ház:hun:2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got words %q want %q", got, want)
+	}
+	var words int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Words`).Scan(&words); err != nil {
+		t.Fatal(err)
+	}
+	if words != 3 {
+		t.Errorf("Got %d words want 3", words)
+	}
+}
+
+func TestMigrateLanguageSources(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	n := -1
+	for i, m := range Migrations {
+		if m.Description == "Drop sources of languages" {
+			n = i
+		}
+	}
+	if n < 0 {
+		t.Fatal("migration dropping sources of languages not found, update the test")
+	}
+	if err := migrate(db, Migrations[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO Languages(name, iso639_3, translation_languages, sources)
+		VALUES("French", "fra", "eng,deu", "wiktionary");`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	var name, tl string
+	if err := db.QueryRow(`SELECT name, translation_languages FROM Languages WHERE iso639_3 = "fra"`).Scan(&name, &tl); err != nil {
+		t.Fatal(err)
+	}
+	if name != "French" || tl != "eng,deu" {
+		t.Errorf("Got language %q with translations %q want French with eng,deu", name, tl)
+	}
+	if _, err := db.Exec(`INSERT INTO Languages(name, iso639_3, translation_languages, sources) VALUES("Polish", "pol", "eng", "")`); err == nil {
+		t.Error("Languages still has sources")
+	}
+}
+
+func TestMigrateNormalizedWords(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	n := -1
+	for i, m := range Migrations {
+		if m.Description == "Add normalized words to cards" {
+			n = i
+		}
+	}
+	if n < 0 {
+		t.Fatal("migration adding normalized words to cards not found, update the test")
+	}
+	if err := migrate(db, Migrations[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO Repetition(chat_id, word) VALUES(1, "Élet"), (2, "Élet"), (1, "ház");`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(`SELECT word, normalized_word FROM Repetition ORDER BY chat_id, word`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var w, nw string
+		if err := rows.Scan(&w, &nw); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, w+":"+nw)
+	}
+	if want := []string{"ház:ház", "Élet:élet", "Élet:élet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got cards %q want %q", got, want)
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db, cleanup := openDB(t)
+	defer cleanup()
+	fail := true
+	ms := []Migration{
+		{"First", execSQL(`CREATE TABLE First (id INTEGER);`)},
+		{"Second", func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE Second (id INTEGER);`); err != nil {
+				return err
+			}
+			if fail {
+				return errors.New("failed")
+			}
+			return nil
+		}},
+	}
+	if err := migrate(db, ms); err == nil {
+		t.Fatal("migrate() succeeded want error")
+	}
+	if got := version(t, db); got != 1 {
+		t.Errorf("Version() after failure = %d want 1", got)
+	}
+	// The failed migration is rolled back and can be retried.
+	fail = false
+	if err := migrate(db, ms); err != nil {
+		t.Fatal(err)
+	}
+	if got := version(t, db); got != 2 {
+		t.Errorf("Version() after retry = %d want 2", got)
+	}
+	if err := migrate(db, ms[:1]); err == nil {
+		t.Error("migrate() of an older version succeeded want error")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Sentence mode defines every word of a message with several words at once,
+// so that a sentence can be pasted as is.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"words/tokenize"
+)
+
+// maxGlossWords limits the number of words defined in a sentence, since every
+// word not in the cache is fetched from wiktionary.
+const maxGlossWords = 10
+
+// maxGlossLength is the maximum length of a single definition in runes.
+const maxGlossLength = 60
+
+// gloss returns the first definition of the word on a single line, empty if
+// there are none.
+func gloss(s *State, word string, settings *Settings) string {
+	ds, _, err := s.Definer.Define(word, settings)
+	if err != nil {
+		s.Log.Warn("Fetching definition", "word", word, "err", err)
+		return ""
+	}
+	ss := senses(ds[0])
+	if len(ss) == 0 {
+		return ""
+	}
+	line, _, _ := strings.Cut(ss[0], "\n")
+	return truncate(line, maxGlossLength)
+}
+
+// sentenceReply sends a short definition of every word in the text with a
+// button to learn each of them.
+func sentenceReply(s *State, chatID int64, text string) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	var ws []string
+	seen := make(map[string]bool)
+	for _, w := range tokenize.Words(text) {
+		if !seen[w] && len(ws) < maxGlossWords {
+			seen[w] = true
+			ws = append(ws, w)
+		}
+	}
+	if len(ws) == 0 {
+		return UserError{ChatID: chatID, Err: localizedErrorf("Couldn't find definitions.")}
+	}
+	// Definitions are fetched in parallel, most of the time is spent
+	// waiting for wiktionary.
+	gs := make([]string, len(ws))
+	var wg sync.WaitGroup
+	for i, w := range ws {
+		wg.Add(1)
+		go func(i int, w string) {
+			defer wg.Done()
+			gs[i] = gloss(s, w, settings)
+		}(i, w)
+	}
+	wg.Wait()
+
+	l := s.L(chatID)
+	var lines []string
+	var ks [][]*InlineKeyboard
+	for i, w := range ws {
+		if gs[i] == "" {
+			lines = append(lines, fmt.Sprintf("%s — %s", w, l.T("no definitions found")))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s — %s", w, gs[i]))
+		saved, err := s.Repetitions.Exists(chatID, w)
+		if err != nil {
+			return err
+		}
+		if !saved {
+			ks = append(ks, []*InlineKeyboard{LearnWordCallback{w, settings.InputLanguageISO639_3}.AsInlineKeyboard(l)})
+		}
+	}
+	r := &MessageReply{
+		ChatId: chatID,
+		Text:   strings.Join(lines, "\n"),
+	}
+	if len(ks) > 0 {
+		r.ReplyMarkup = &ReplyMarkup{InlineKeyboard: ks}
+	}
+	return s.Telegram.SendMessage(r)
+}
+
+// LearnWordCallback saves one of the words from the sentence with all its
+// definitions, as LearnCallback does for a single word.
+type LearnWordCallback struct {
+	Word string
+	// ISO 639-3 code of the language in which the word was looked up.
+	Language string
+}
+
+func (LearnWordCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	word := info.Word
+	l := s.L(chatID)
+	_, err := s.Repetitions.Progress(chatID, word)
+	if err == nil {
+		s.AnswerCallback(q, l.T("%q is already saved", word))
+		return removeButton(s, q)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	// Most likely cached by sentenceReply.
+	ds, _, err := defineAgain(s, chatID, word, info.Setting)
+	if err != nil {
+		return err
+	}
+	if err := saveCard(s, chatID, word, ds[0].Text, info.Setting); err != nil {
+		return err
+	}
+	s.AnswerCallback(q, l.T("Saved %q for learning", word))
+	return removeButton(s, q)
+}
+
+func (LearnWordCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == LearnWordAction
+}
+
+func (c LearnWordCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("Learn %s", c.Word),
+		CallbackData: CallbackInfo{
+			Action:  LearnWordAction,
+			Word:    c.Word,
+			Setting: c.Language,
+		}.String(),
+	}
+}
@@ -17,6 +17,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 )
 
 type Settings struct {
@@ -31,6 +36,376 @@ type Settings struct {
 	// true if translation is accepted
 	TranslationLanguages map[string]bool
 	TimeZone             string
+	// MaxExampleLength is the maximum length of the usage example in
+	// characters. 0 means that the length is not limited.
+	MaxExampleLength int
+	// ReminderFrequency is the number of reminders per day. 0 means default.
+	ReminderFrequency int
+	// Muted is true if user doesn't want to receive reminders.
+	Muted bool
+	// AvailabilityWindow is the time of the day when reminders can be sent.
+	AvailabilityWindow *AvailabilityWindow
+	// AvailabilityWindows are used instead of AvailabilityWindow if the user
+	// limited the window to some days of the week or entered several ones.
+	AvailabilityWindows AvailabilityWindows `json:",omitempty"`
+	// WeeklySummary is true if user wants to receive weekly progress summary.
+	WeeklySummary bool
+	// BotLanguage is an ISO 639-3 code of the language of the bot interface.
+	// Empty means English.
+	BotLanguage string
+	// StudiedLanguages are ISO 639-3 codes of the languages studied besides
+	// the input language. Words not found in the input language are looked up
+	// in them.
+	StudiedLanguages []string `json:",omitempty"`
+	// PracticeSessionLength is the number of cards in a practice session. 0
+	// means default.
+	PracticeSessionLength int `json:",omitempty"`
+	// VacationUntil is the end of the vacation during which reminders are
+	// paused, nil if the user isn't on vacation.
+	VacationUntil *time.Time `json:",omitempty"`
+	// Scheduler of the reviews, StagesScheduler or LeitnerScheduler.
+	Scheduler string `json:",omitempty"`
+	// ClozePractice is true if practice cards show a sentence with the word
+	// blanked out instead of the word.
+	ClozePractice bool `json:",omitempty"`
+	// AutoSave is true if every word that was looked up is saved for learning
+	// without pressing Learn.
+	AutoSave bool `json:",omitempty"`
+	// ListeningPractice is true if practice cards are spoken instead of
+	// shown, when there is a voice for the language.
+	ListeningPractice bool `json:",omitempty"`
+	// Transliterate is true if translations of usage examples in Cyrillic
+	// are followed by their romanization.
+	Transliterate bool `json:",omitempty"`
+	// DailyGoal is the number of reviews the user wants to do every day, 0
+	// means no goal.
+	DailyGoal int `json:",omitempty"`
+	// CatchUp is the number of the cards overdue since before today that are
+	// kept for practice each day, the rest are spread over the following
+	// days. 0 means all of them are kept.
+	CatchUp int `json:",omitempty"`
+	// MaxIntervalDays is the longest interval between reviews of a card in
+	// days. 0 means unlimited.
+	MaxIntervalDays int `json:",omitempty"`
+	// LearningSteps are the intervals between reviews of new and forgotten
+	// cards before they graduate to the stages, see
+	// Repetition.answerLearning. Empty means cards start right at the stages.
+	// Not used by LeitnerScheduler.
+	LearningSteps []time.Duration `json:",omitempty"`
+	// NewCardOrder is the order in which never reviewed cards come to
+	// practice: NewCardsAdded, NewCardsRandom or NewCardsFrequent.
+	NewCardOrder string `json:",omitempty"`
+	// DayStartHour is the local hour at which the day starts for streaks,
+	// goals and forecasts, so that practice after midnight can count for the
+	// previous day.
+	DayStartHour int `json:",omitempty"`
+	// ReminderCard is true if reminders show the first card ready for
+	// practice, so that it can be answered right away.
+	ReminderCard bool `json:",omitempty"`
+}
+
+// Orders of the new cards in practice, see Settings.NewCardOrder.
+const (
+	// NewCardsAdded shows the new cards in the order they were added.
+	NewCardsAdded = ""
+	// NewCardsRandom shows the new cards in random order.
+	NewCardsRandom = "random"
+	// NewCardsFrequent shows the words that are more common in the corpus
+	// first.
+	NewCardsFrequent = "frequent"
+)
+
+// Schedulers of the reviews, see Settings.Scheduler.
+const (
+	// StagesScheduler uses the stages from the config, each known answer
+	// moves the card to the next one.
+	StagesScheduler = ""
+	// LeitnerScheduler moves cards between the boxes with fixed intervals,
+	// see leitnerIntervals.
+	LeitnerScheduler = "leitner"
+)
+
+// OnVacation returns whether reminders are paused at now for the vacation.
+func (s *Settings) OnVacation(now time.Time) bool {
+	return s.VacationUntil != nil && now.Before(*s.VacationUntil)
+}
+
+// PracticeLength returns the number of cards in a practice session.
+func (s *Settings) PracticeLength() int {
+	if s.PracticeSessionLength <= 0 {
+		return defaultPracticeSessionLength
+	}
+	return s.PracticeSessionLength
+}
+
+// Languages returns ISO 639-3 codes of all studied languages, the input
+// language first.
+func (s *Settings) Languages() []string {
+	ls := []string{s.InputLanguageISO639_3}
+	for _, l := range s.StudiedLanguages {
+		if l != s.InputLanguageISO639_3 {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// AvailabilityWindow is a daily time range in the user's time zone. End can be
+// less than Start, in which case the window spans midnight.
+type AvailabilityWindow struct {
+	// Seconds since local midnight.
+	Start, End int
+	// Weekdays on which the window starts, every day if empty.
+	Weekdays Weekdays `json:",omitempty"`
+}
+
+// Weekdays is a set of days of the week, bit 1<<time.Weekday for each day.
+// The empty set is every day.
+type Weekdays uint8
+
+// weekdayNames are the names of the days accepted in the windows, Monday
+// first.
+var weekdayNames = []struct {
+	name string
+	day  time.Weekday
+}{
+	{"mon", time.Monday},
+	{"tue", time.Tuesday},
+	{"wed", time.Wednesday},
+	{"thu", time.Thursday},
+	{"fri", time.Friday},
+	{"sat", time.Saturday},
+	{"sun", time.Sunday},
+}
+
+// Has reports whether the day is in the set.
+func (d Weekdays) Has(day time.Weekday) bool {
+	return d == 0 || d&(1<<day) != 0
+}
+
+// parseWeekdays parses comma separated days or ranges of days, e.g.
+// "mon-fri" or "sat,sun".
+func parseWeekdays(v string) (Weekdays, error) {
+	index := func(name string) (int, error) {
+		for i, n := range weekdayNames {
+			if n.name == strings.ToLower(strings.TrimSpace(name)) {
+				return i, nil
+			}
+		}
+		return 0, localizedErrorf("unknown day %q, days are mon, tue, wed, thu, fri, sat and sun", name)
+	}
+	var d Weekdays
+	for _, r := range strings.Split(v, ",") {
+		from, to, isRange := strings.Cut(r, "-")
+		i, err := index(from)
+		if err != nil {
+			return 0, err
+		}
+		j := i
+		if isRange {
+			if j, err = index(to); err != nil {
+				return 0, err
+			}
+		}
+		for ; ; i = (i + 1) % len(weekdayNames) {
+			d |= 1 << weekdayNames[i].day
+			if i == j {
+				break
+			}
+		}
+	}
+	return d, nil
+}
+
+// String formats the days like parseWeekdays expects them, with runs of 3
+// and more days as ranges.
+func (d Weekdays) String() string {
+	var parts []string
+	for i := 0; i < len(weekdayNames); {
+		if d&(1<<weekdayNames[i].day) == 0 {
+			i++
+			continue
+		}
+		j := i
+		for j+1 < len(weekdayNames) && d&(1<<weekdayNames[j+1].day) != 0 {
+			j++
+		}
+		if j-i >= 2 {
+			parts = append(parts, weekdayNames[i].name+"-"+weekdayNames[j].name)
+		} else {
+			for k := i; k <= j; k++ {
+				parts = append(parts, weekdayNames[k].name)
+			}
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+const secondsPerDay = 24 * 60 * 60
+
+func ParseAvailabilityWindow(w string) (*AvailabilityWindow, error) {
+	parse := func(t string) (int, error) {
+		p, err := time.Parse("15:04", strings.TrimSpace(t))
+		if err != nil {
+			return 0, err
+		}
+		return p.Hour()*60*60 + p.Minute()*60, nil
+	}
+	err := localizedErrorf("availability window should be in the format HH:MM-HH:MM, e.g. 09:00-21:00")
+	ts := strings.Split(w, "-")
+	if len(ts) != 2 {
+		return nil, err
+	}
+	start, serr := parse(ts[0])
+	end, eerr := parse(ts[1])
+	if serr != nil || eerr != nil || start == end {
+		return nil, err
+	}
+	return &AvailabilityWindow{Start: start, End: end}, nil
+}
+
+func (w *AvailabilityWindow) String() string {
+	f := func(s int) string {
+		return fmt.Sprintf("%02d:%02d", s/3600, s%3600/60)
+	}
+	if w.Weekdays != 0 {
+		return w.Weekdays.String() + " " + f(w.Start) + "-" + f(w.End)
+	}
+	return f(w.Start) + "-" + f(w.End)
+}
+
+func (w *AvailabilityWindow) contains(s int) bool {
+	if w.Start <= w.End {
+		return w.Start <= s && s < w.End
+	}
+	return s >= w.Start || s < w.End
+}
+
+// Clamp returns t if it's within the window and the start of the next window
+// otherwise.
+func (w *AvailabilityWindow) Clamp(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	s := int(t.Sub(midnight).Seconds())
+	if w.contains(s) {
+		// The part of the window after midnight started the day before.
+		started := midnight
+		if s < w.Start {
+			started = midnight.AddDate(0, 0, -1)
+		}
+		if w.Weekdays.Has(started.Weekday()) {
+			return t
+		}
+	}
+	start := midnight.Add(time.Duration(w.Start) * time.Second)
+	if s >= w.Start {
+		start = start.AddDate(0, 0, 1)
+	}
+	for !w.Weekdays.Has(start.Weekday()) {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start
+}
+
+// AvailabilityWindows are the windows in which reminders can be sent.
+type AvailabilityWindows []*AvailabilityWindow
+
+// ParseAvailabilityWindows parses windows separated by ";", each optionally
+// preceded by the days, e.g. "mon-fri 07:00-09:00; sat,sun 10:00-12:00".
+func ParseAvailabilityWindows(v string) (AvailabilityWindows, error) {
+	var ws AvailabilityWindows
+	for _, p := range strings.Split(v, ";") {
+		var days string
+		if p = strings.TrimSpace(p); p != "" && unicode.IsLetter(rune(p[0])) {
+			days, p, _ = strings.Cut(p, " ")
+		}
+		w, err := ParseAvailabilityWindow(p)
+		if err != nil {
+			return nil, err
+		}
+		if days != "" {
+			if w.Weekdays, err = parseWeekdays(days); err != nil {
+				return nil, err
+			}
+		}
+		ws = append(ws, w)
+	}
+	return ws, nil
+}
+
+func (ws AvailabilityWindows) String() string {
+	var parts []string
+	for _, w := range ws {
+		parts = append(parts, w.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Clamp returns t if it's within one of the windows and the earliest start of
+// the next window otherwise. Without windows t is returned as is.
+func (ws AvailabilityWindows) Clamp(t time.Time) time.Time {
+	next := t
+	for i, w := range ws {
+		if c := w.Clamp(t); i == 0 || c.Before(next) {
+			next = c
+		}
+	}
+	return next
+}
+
+// Windows returns the windows in which reminders can be sent, none if the
+// user never chose them.
+func (s *Settings) Windows() AvailabilityWindows {
+	if len(s.AvailabilityWindows) > 0 {
+		return s.AvailabilityWindows
+	}
+	if s.AvailabilityWindow == nil {
+		return nil
+	}
+	return AvailabilityWindows{s.AvailabilityWindow}
+}
+
+// NoReminders returns whether reminders can't be sent at now: the user muted
+// them, is on vacation or never chose when they can be sent. Reminders are
+// opt-in.
+func (s *Settings) NoReminders(now time.Time) bool {
+	return s.Muted || s.OnVacation(now) || len(s.Windows()) == 0
+}
+
+// Location returns the location corresponding to the TimeZone.
+func (s *Settings) Location() *time.Location {
+	if !strings.HasPrefix(s.TimeZone, "UTC") || s.TimeZone == "UTC" {
+		return time.UTC
+	}
+	h, err := strconv.Atoi(strings.TrimPrefix(s.TimeZone, "UTC"))
+	if err != nil {
+		return time.UTC
+	}
+	return time.FixedZone(s.TimeZone, h*60*60)
+}
+
+// DayLocation returns Location shifted back by DayStartHour. Days counted in
+// it, e.g. with localDay, start at that hour of the user's time.
+func (s *Settings) DayLocation() *time.Location {
+	loc := s.Location()
+	if s.DayStartHour == 0 {
+		return loc
+	}
+	name, offset := time.Time{}.In(loc).Zone()
+	return time.FixedZone(fmt.Sprintf("%s-%dh", name, s.DayStartHour), offset-s.DayStartHour*60*60)
+}
+
+const (
+	defaultReminderFrequency = 1
+	maxReminderFrequency     = 24
+)
+
+// RemindersPerDay returns the number of reminders user should get per day.
+func (s *Settings) RemindersPerDay() int {
+	if s.ReminderFrequency <= 0 {
+		return defaultReminderFrequency
+	}
+	return s.ReminderFrequency
 }
 
 func SettingsFromString(s string) *Settings {
@@ -64,21 +439,16 @@ func (s Settings) String() string {
 
 type SettingsConfig struct {
 	db *sql.DB
+	// Languages supported as input languages.
+	Languages *LanguageRegistry
 }
 
-func NewSettingsConfig(dbPath string) (*SettingsConfig, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+func NewSettingsConfig(db *sql.DB) (*SettingsConfig, error) {
+	l, err := NewLanguageRegistry(db)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS Settings (
-			chat_id INTEGER PRIMARY KEY,
-			settings STRING -- json serialized settings
-		);`); err != nil {
-		return nil, err
-	}
-	return &SettingsConfig{db}, nil
+	return &SettingsConfig{db, l}, nil
 }
 
 func (c *SettingsConfig) GetAll() (map[int64]*Settings, error) {
@@ -124,6 +494,20 @@ func (c *SettingsConfig) Get(chatID int64) (*Settings, error) {
 	return SettingsFromString(s), nil
 }
 
+// Exists returns true if settings were ever saved for the chat.
+func (c *SettingsConfig) Exists(chatID int64) (bool, error) {
+	row := c.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM Settings
+		WHERE chat_id = $0`,
+		chatID)
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return false, fmt.Errorf("INTERNAL: checking settings for chat id %d: %w", chatID, err)
+	}
+	return n > 0, nil
+}
+
 func (c *SettingsConfig) Set(chatID int64, s *Settings) error {
 	_, err := c.db.Exec(`
 		INSERT OR REPLACE INTO Settings(chat_id, settings) VALUES
@@ -136,11 +520,8 @@ func (c *SettingsConfig) Set(chatID int64, s *Settings) error {
 }
 
 func (c *SettingsConfig) ValidateLanguage(language string) error {
-	_, ok := SupportedInputLanguages[language]
-	if !ok {
-		return fmt.Errorf("unsupported language %q", language)
-	}
-	return nil
+	_, err := c.Languages.Find(language)
+	return err
 }
 
 func (c *SettingsConfig) SetLanguage(chatid int64, language string) error {
@@ -148,20 +529,87 @@ func (c *SettingsConfig) SetLanguage(chatid int64, language string) error {
 	if err != nil {
 		return err
 	}
-	if err := c.ValidateLanguage(language); err != nil {
+	l, err := c.Languages.Find(language)
+	if err != nil {
 		return err
 	}
-	languageSettings := SupportedInputLanguages[language]
+	languageSettings := l.Settings()
 	currentSettings.InputLanguage = languageSettings.InputLanguage
 	currentSettings.InputLanguageISO639_3 = languageSettings.InputLanguageISO639_3
 	currentSettings.TranslationLanguages = languageSettings.TranslationLanguages
 	return c.Set(chatid, currentSettings)
 }
 
+// ForLanguage returns a copy of the settings with the language as the input
+// language, for looking up words in one of the studied languages.
+func (c *SettingsConfig) ForLanguage(s *Settings, language string) (*Settings, error) {
+	if language == s.InputLanguageISO639_3 {
+		return s, nil
+	}
+	l, err := c.Languages.Find(language)
+	if err != nil {
+		return nil, err
+	}
+	ls := l.Settings()
+	r := *s
+	r.InputLanguage = ls.InputLanguage
+	r.InputLanguageISO639_3 = ls.InputLanguageISO639_3
+	r.TranslationLanguages = ls.TranslationLanguages
+	return &r, nil
+}
+
+// AddLanguage makes the language the input language keeping the previous one
+// studied. It's also used to switch between the studied languages.
+func (c *SettingsConfig) AddLanguage(chatid int64, language string) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.StudiedLanguages = currentSettings.Languages()
+	// Saved at once, so that the language isn't studied without being the
+	// input language if saving fails.
+	newSettings, err := c.ForLanguage(currentSettings, language)
+	if err != nil {
+		return err
+	}
+	return c.Set(chatid, newSettings)
+}
+
+// RemoveLanguage stops studying the language. If it's the input language, the
+// next studied one becomes the input language. Cards in the language are kept.
+func (c *SettingsConfig) RemoveLanguage(chatid int64, language string) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	l, err := c.Languages.Find(language)
+	if err != nil {
+		return err
+	}
+	var rest []string
+	for _, code := range currentSettings.Languages() {
+		if code != l.ISO639_3 {
+			rest = append(rest, code)
+		}
+	}
+	switch {
+	case len(rest) == len(currentSettings.Languages()):
+		return localizedErrorf("%s is not studied", l.Name)
+	case len(rest) == 0:
+		return localizedErrorf("can't remove the only studied language, use /language to change it")
+	}
+	currentSettings.StudiedLanguages = rest[1:]
+	newSettings, err := c.ForLanguage(currentSettings, rest[0])
+	if err != nil {
+		return err
+	}
+	return c.Set(chatid, newSettings)
+}
+
 func (c *SettingsConfig) ValidateTimeZone(tz string) error {
 	set := TimeZones[tz]
 	if !set {
-		return fmt.Errorf("unsupported time zone (format should be UTC, UTC+X or UTC-X)")
+		return localizedErrorf("unsupported time zone (format should be UTC, UTC+X or UTC-X)")
 	}
 	return nil
 }
@@ -177,3 +625,516 @@ func (c *SettingsConfig) SetTimeZone(chatid int64, tz string) error {
 	currentSettings.TimeZone = tz
 	return c.Set(chatid, currentSettings)
 }
+
+// Upper bound for the max example length, longer examples are never shown
+// anyway.
+const maxExampleLengthLimit = 1000
+
+func (c *SettingsConfig) ValidateMaxExampleLength(l string) error {
+	n, err := strconv.Atoi(l)
+	if err != nil || n < 0 || n > maxExampleLengthLimit {
+		return localizedErrorf("length should be a number between 0 and %d", maxExampleLengthLimit)
+	}
+	return nil
+}
+
+func (c *SettingsConfig) SetMaxExampleLength(chatid int64, l string) error {
+	if err := c.ValidateMaxExampleLength(l); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	// Already validated.
+	currentSettings.MaxExampleLength, _ = strconv.Atoi(l)
+	return c.Set(chatid, currentSettings)
+}
+
+// AvailableTranslationLanguages returns ISO 639-3 codes of all languages
+// known to the bot apart from the input language in settings.
+func (c *SettingsConfig) AvailableTranslationLanguages(s *Settings) ([]string, error) {
+	cs, err := c.Languages.Codes()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]bool)
+	for _, l := range cs {
+		m[l] = true
+	}
+	// Input language might not be registered, in which case its translation
+	// languages are not known to the registry.
+	for l := range s.TranslationLanguages {
+		m[l] = true
+	}
+	delete(m, s.InputLanguageISO639_3)
+	var ls []string
+	for l := range m {
+		ls = append(ls, l)
+	}
+	sort.Strings(ls)
+	return ls, nil
+}
+
+func (c *SettingsConfig) ValidateTranslationLanguage(s *Settings, language string) error {
+	ls, err := c.AvailableTranslationLanguages(s)
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		if l == language {
+			return nil
+		}
+	}
+	return localizedErrorf("unsupported translation language %q", language)
+}
+
+// ToggleTranslationLanguage enables translations into the language if it's
+// disabled and disables them otherwise. The last enabled language can't be
+// disabled.
+func (c *SettingsConfig) ToggleTranslationLanguage(chatid int64, language string) (*Settings, error) {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ValidateTranslationLanguage(currentSettings, language); err != nil {
+		return nil, err
+	}
+	tl := make(map[string]bool)
+	for k, v := range currentSettings.TranslationLanguages {
+		tl[k] = v
+	}
+	tl[language] = !tl[language]
+	// Like with ValidateTranslationLanguages, the list can't become empty.
+	enabled := false
+	for _, v := range tl {
+		enabled = enabled || v
+	}
+	if !enabled {
+		return nil, localizedErrorf("at least one translation language should stay enabled")
+	}
+	currentSettings.TranslationLanguages = tl
+	return currentSettings, c.Set(chatid, currentSettings)
+}
+
+// defaultTranslationLanguages is an answer to keep default translation
+// languages of the input language.
+const defaultTranslationLanguages = "default"
+
+// ValidateTranslationLanguages validates comma separated list of ISO 639-3
+// codes.
+func (c *SettingsConfig) ValidateTranslationLanguages(ls string) error {
+	if ls == defaultTranslationLanguages {
+		return nil
+	}
+	cs := splitList(ls)
+	if len(cs) == 0 {
+		return localizedErrorf("no languages entered")
+	}
+	for _, l := range cs {
+		// Input language isn't known at this point, so any valid language
+		// is accepted.
+		if _, err := c.Languages.Find(l); err != nil || len(l) != 3 {
+			return localizedErrorf("%q is not an ISO 639-3 code", l)
+		}
+	}
+	return nil
+}
+
+// SetTranslationLanguages replaces translation languages with the comma
+// separated list of ISO 639-3 codes.
+func (c *SettingsConfig) SetTranslationLanguages(chatid int64, ls string) error {
+	if err := c.ValidateTranslationLanguages(ls); err != nil {
+		return err
+	}
+	if ls == defaultTranslationLanguages {
+		return nil
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	tl := make(map[string]bool)
+	for _, l := range splitList(ls) {
+		if l = strings.ToLower(l); l != currentSettings.InputLanguageISO639_3 {
+			tl[l] = true
+		}
+	}
+	currentSettings.TranslationLanguages = tl
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) ValidateReminderFrequency(f string) error {
+	if f == "off" {
+		return nil
+	}
+	n, err := strconv.Atoi(f)
+	if err != nil || n < 1 || n > maxReminderFrequency {
+		return localizedErrorf("frequency should be \"off\" or a number between 1 and %d", maxReminderFrequency)
+	}
+	return nil
+}
+
+// SetReminderFrequency sets the number of reminders per day or mutes them if
+// f is "off".
+func (c *SettingsConfig) SetReminderFrequency(chatid int64, f string) error {
+	if err := c.ValidateReminderFrequency(f); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.Muted = f == "off"
+	if !currentSettings.Muted {
+		// Already validated.
+		currentSettings.ReminderFrequency, _ = strconv.Atoi(f)
+	}
+	return c.Set(chatid, currentSettings)
+}
+
+const maxVacationDays = 365
+
+func (c *SettingsConfig) ValidateVacation(days string) error {
+	if days == "off" {
+		return nil
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 1 || n > maxVacationDays {
+		return localizedErrorf("vacation should be \"off\" or a number of days between 1 and %d", maxVacationDays)
+	}
+	return nil
+}
+
+// SetVacation pauses reminders until the given time, nil resumes them.
+func (c *SettingsConfig) SetVacation(chatid int64, until *time.Time) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.VacationUntil = until
+	return c.Set(chatid, currentSettings)
+}
+
+const maxPracticeSessionLength = 200
+
+func (c *SettingsConfig) ValidatePracticeSessionLength(l string) error {
+	n, err := strconv.Atoi(l)
+	if err != nil || n < 1 || n > maxPracticeSessionLength {
+		return localizedErrorf("length should be a number between 1 and %d", maxPracticeSessionLength)
+	}
+	return nil
+}
+
+func (c *SettingsConfig) SetPracticeSessionLength(chatid int64, l string) error {
+	if err := c.ValidatePracticeSessionLength(l); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	// Already validated.
+	currentSettings.PracticeSessionLength, _ = strconv.Atoi(l)
+	return c.Set(chatid, currentSettings)
+}
+
+const maxDailyGoal = 1000
+
+func (c *SettingsConfig) ValidateDailyGoal(g string) error {
+	if g == "off" {
+		return nil
+	}
+	n, err := strconv.Atoi(g)
+	if err != nil || n < 1 || n > maxDailyGoal {
+		return localizedErrorf("goal should be \"off\" or a number between 1 and %d", maxDailyGoal)
+	}
+	return nil
+}
+
+// SetDailyGoal sets the number of reviews to do every day or removes the goal
+// if g is "off".
+func (c *SettingsConfig) SetDailyGoal(chatid int64, g string) error {
+	if err := c.ValidateDailyGoal(g); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	// Already validated, "off" is 0.
+	currentSettings.DailyGoal, _ = strconv.Atoi(g)
+	return c.Set(chatid, currentSettings)
+}
+
+const maxCatchUp = 1000
+
+func (c *SettingsConfig) ValidateCatchUp(n string) error {
+	if n == "off" {
+		return nil
+	}
+	v, err := strconv.Atoi(n)
+	if err != nil || v < 1 || v > maxCatchUp {
+		return localizedErrorf("the number of cards should be \"off\" or a number between 1 and %d", maxCatchUp)
+	}
+	return nil
+}
+
+// SetCatchUp sets the number of overdue cards to practice a day or turns the
+// catch-up mode off if n is "off".
+func (c *SettingsConfig) SetCatchUp(chatid int64, n string) error {
+	if err := c.ValidateCatchUp(n); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	// Already validated, "off" is 0.
+	currentSettings.CatchUp, _ = strconv.Atoi(n)
+	return c.Set(chatid, currentSettings)
+}
+
+const maxMaxIntervalDays = 3650
+
+// MaxInterval returns the longest interval between reviews, 0 if unlimited.
+func (s *Settings) MaxInterval() time.Duration {
+	return time.Duration(s.MaxIntervalDays) * 24 * time.Hour
+}
+
+// Scheduling returns how answers reschedule the cards.
+func (s *Settings) Scheduling() Scheduling {
+	return Scheduling{
+		Leitner:       s.Scheduler == LeitnerScheduler,
+		LearningSteps: s.LearningSteps,
+		MaxInterval:   s.MaxInterval(),
+	}
+}
+
+func (c *SettingsConfig) ValidateMaxInterval(days string) error {
+	if days == "off" {
+		return nil
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 1 || n > maxMaxIntervalDays {
+		return localizedErrorf("interval should be \"off\" or a number of days between 1 and %d", maxMaxIntervalDays)
+	}
+	return nil
+}
+
+// SetMaxInterval sets the longest interval between reviews in days or removes
+// the limit if days is "off".
+func (c *SettingsConfig) SetMaxInterval(chatid int64, days string) error {
+	if err := c.ValidateMaxInterval(days); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	// Already validated, "off" is 0.
+	currentSettings.MaxIntervalDays, _ = strconv.Atoi(days)
+	return c.Set(chatid, currentSettings)
+}
+
+const (
+	maxLearningSteps = 10
+	maxLearningStep  = 30 * 24 * time.Hour
+)
+
+// ParseLearningSteps parses space separated durations like "1m 10m 1d", "off"
+// is no steps.
+func ParseLearningSteps(v string) ([]time.Duration, error) {
+	if v == "off" {
+		return nil, nil
+	}
+	fs := strings.Fields(v)
+	if len(fs) == 0 || len(fs) > maxLearningSteps {
+		return nil, localizedErrorf("there should be between 1 and %d steps", maxLearningSteps)
+	}
+	var steps []time.Duration
+	for _, f := range fs {
+		var d time.Duration
+		var err error
+		if days, ok := strings.CutSuffix(f, "d"); ok {
+			var n int
+			n, err = strconv.Atoi(days)
+			d = time.Duration(n) * 24 * time.Hour
+		} else {
+			d, err = time.ParseDuration(f)
+		}
+		if err != nil || d < time.Minute || d > maxLearningStep {
+			return nil, localizedErrorf("step %q should be a duration between 1m and 30d, e.g. 10m, 2h or 1d", f)
+		}
+		steps = append(steps, d)
+	}
+	return steps, nil
+}
+
+// FormatLearningSteps formats the steps like ParseLearningSteps expects them.
+func FormatLearningSteps(steps []time.Duration) string {
+	var fs []string
+	for _, d := range steps {
+		switch {
+		case d%(24*time.Hour) == 0:
+			fs = append(fs, fmt.Sprintf("%dd", d/(24*time.Hour)))
+		case d%time.Hour == 0:
+			fs = append(fs, fmt.Sprintf("%dh", d/time.Hour))
+		case d%time.Minute == 0:
+			fs = append(fs, fmt.Sprintf("%dm", d/time.Minute))
+		default:
+			fs = append(fs, d.String())
+		}
+	}
+	return strings.Join(fs, " ")
+}
+
+func (c *SettingsConfig) ValidateLearningSteps(v string) error {
+	_, err := ParseLearningSteps(v)
+	return err
+}
+
+func (c *SettingsConfig) SetLearningSteps(chatid int64, v string) error {
+	steps, err := ParseLearningSteps(v)
+	if err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.LearningSteps = steps
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) ValidateDayStartHour(h string) error {
+	n, err := strconv.Atoi(h)
+	if err != nil || n < 0 || n > 23 {
+		return localizedErrorf("hour should be a number between 0 and 23")
+	}
+	return nil
+}
+
+func (c *SettingsConfig) SetDayStartHour(chatid int64, h string) error {
+	if err := c.ValidateDayStartHour(h); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	// Already validated.
+	currentSettings.DayStartHour, _ = strconv.Atoi(h)
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) ValidateAvailabilityWindow(w string) error {
+	_, err := ParseAvailabilityWindows(w)
+	return err
+}
+
+// SetAvailabilityWindow sets the window for every day or, if w has days or
+// several windows, the list of windows.
+func (c *SettingsConfig) SetAvailabilityWindow(chatid int64, w string) error {
+	ws, err := ParseAvailabilityWindows(w)
+	if err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.AvailabilityWindow, currentSettings.AvailabilityWindows = nil, ws
+	if len(ws) == 1 && ws[0].Weekdays == 0 {
+		currentSettings.AvailabilityWindow, currentSettings.AvailabilityWindows = ws[0], nil
+	}
+	return c.Set(chatid, currentSettings)
+}
+
+// parseOnOff parses the answer to a question about a setting that is either
+// "on" or "off".
+func parseOnOff(v string) (bool, error) {
+	switch v {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, localizedErrorf("answer should be \"on\" or \"off\"")
+}
+
+// SetOnOff switches the setting returned by field on or off.
+func (c *SettingsConfig) SetOnOff(chatid int64, v string, field func(*Settings) *bool) error {
+	on, err := parseOnOff(v)
+	if err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	*field(currentSettings) = on
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) ValidateScheduler(v string) error {
+	if v != "default" && v != LeitnerScheduler {
+		return localizedErrorf("answer should be \"default\" or \"leitner\"")
+	}
+	return nil
+}
+
+func (c *SettingsConfig) SetScheduler(chatid int64, v string) error {
+	if err := c.ValidateScheduler(v); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.Scheduler = StagesScheduler
+	if v == LeitnerScheduler {
+		currentSettings.Scheduler = LeitnerScheduler
+	}
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) ValidateNewCardOrder(v string) error {
+	if v != "added" && v != NewCardsRandom && v != NewCardsFrequent {
+		return localizedErrorf("answer should be \"added\", \"random\" or \"frequent\"")
+	}
+	return nil
+}
+
+func (c *SettingsConfig) SetNewCardOrder(chatid int64, v string) error {
+	if err := c.ValidateNewCardOrder(v); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.NewCardOrder = NewCardsAdded
+	if v != "added" {
+		currentSettings.NewCardOrder = v
+	}
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) ValidateBotLanguage(l string) error {
+	_, err := FindBotLanguage(l)
+	return err
+}
+
+func (c *SettingsConfig) SetBotLanguage(chatid int64, l string) error {
+	code, err := FindBotLanguage(l)
+	if err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.BotLanguage = code
+	return c.Set(chatid, currentSettings)
+}
@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestSettings(t *testing.T) {
@@ -29,13 +30,19 @@ func TestSettings(t *testing.T) {
 	t.Logf("Temp dir: %q", dir)
 	defer os.RemoveAll(dir)
 
-	db := filepath.Join(dir, "tmpdb")
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	settings, err := NewSettingsConfig(db)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	var chatID int64 = 0
+	if e, err := settings.Exists(chatID); err != nil || e {
+		t.Errorf("settings.Exists before Set: %t, %v want false, nil", e, err)
+	}
 	s, err := settings.Get(chatID)
 	if err != nil {
 		t.Error(err)
@@ -43,6 +50,9 @@ func TestSettings(t *testing.T) {
 	if err := settings.Set(chatID, s); err != nil {
 		t.Error(err)
 	}
+	if e, err := settings.Exists(chatID); err != nil || !e {
+		t.Errorf("settings.Exists after Set: %t, %v want true, nil", e, err)
+	}
 
 	s.InputLanguage = "foo_bar"
 	if err := settings.Set(chatID, s); err != nil {
@@ -66,4 +76,218 @@ func TestSettings(t *testing.T) {
 	if !reflect.DeepEqual(gotAll, wantAll) {
 		t.Errorf("settings.GetAll() got: %v want: %v", gotAll, wantAll)
 	}
+
+	if err := settings.SetLanguage(chatID, "Hungarian"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []bool{false, true} {
+		s, err := settings.ToggleTranslationLanguage(chatID, "eng")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.TranslationLanguages["eng"]; got != want {
+			t.Errorf("TranslationLanguages[eng] after toggle: got %t want %t", got, want)
+		}
+	}
+	if _, err := settings.ToggleTranslationLanguage(chatID, "hun"); err == nil {
+		t.Errorf("ToggleTranslationLanguage(input language): got nil error")
+	}
+	// Toggling all of them off stops at the last one.
+	s, err = settings.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last string
+	for l, v := range s.TranslationLanguages {
+		if !v {
+			continue
+		}
+		if _, err := settings.ToggleTranslationLanguage(chatID, l); err != nil {
+			last = l
+		}
+	}
+	if s, err := settings.Get(chatID); err != nil || last == "" || !s.TranslationLanguages[last] {
+		t.Errorf("Toggled off all translation languages: got %v, %v", s, err)
+	}
+
+	for _, l := range []string{"German", "deu", "fra"} {
+		if err := settings.ValidateLanguage(l); err != nil {
+			t.Errorf("ValidateLanguage(%q): %v", l, err)
+		}
+	}
+	for _, l := range []string{"NotARealLanguage", "xyz", ""} {
+		if err := settings.ValidateLanguage(l); err == nil {
+			t.Errorf("ValidateLanguage(%q): got nil error", l)
+		}
+	}
+	if err := settings.SetLanguage(chatID, "fra"); err != nil {
+		t.Fatal(err)
+	}
+	s, err = settings.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.InputLanguage != "French" || s.InputLanguageISO639_3 != "fra" || !s.TranslationLanguages["eng"] {
+		t.Errorf("SetLanguage(fra): got %v", s)
+	}
+
+	for _, ls := range []string{"", "English", "eng,xyz"} {
+		if err := settings.ValidateTranslationLanguages(ls); err == nil {
+			t.Errorf("ValidateTranslationLanguages(%q): got nil error", ls)
+		}
+	}
+	if err := settings.SetTranslationLanguages(chatID, "deu, FRA,eng"); err != nil {
+		t.Fatal(err)
+	}
+	s, err = settings.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"deu": true, "eng": true}; !reflect.DeepEqual(s.TranslationLanguages, want) {
+		t.Errorf("SetTranslationLanguages: got %v want %v", s.TranslationLanguages, want)
+	}
+
+	autoSave := func(s *Settings) *bool { return &s.AutoSave }
+	for _, v := range []string{"on", "off"} {
+		if err := settings.SetOnOff(chatID, v, autoSave); err != nil {
+			t.Fatal(err)
+		}
+		if s, err := settings.Get(chatID); err != nil || s.AutoSave != (v == "on") {
+			t.Errorf("SetOnOff(%q): got AutoSave %v, %v", v, s.AutoSave, err)
+		}
+	}
+	if err := settings.SetOnOff(chatID, "yes", autoSave); err == nil {
+		t.Error("SetOnOff(yes): got nil error")
+	}
+}
+
+func TestStudiedLanguages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	check := func(step string, want ...string) {
+		t.Helper()
+		s, err := settings.Get(chatID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Languages(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Languages() = %q want %q", step, got, want)
+		}
+	}
+
+	check("default", "hun")
+	if err := settings.RemoveLanguage(chatID, "hun"); err == nil {
+		t.Error("removing the only language succeeded")
+	}
+	if err := settings.AddLanguage(chatID, "German"); err != nil {
+		t.Fatal(err)
+	}
+	check("added", "deu", "hun")
+	if err := settings.AddLanguage(chatID, "hun"); err != nil {
+		t.Fatal(err)
+	}
+	check("switched", "hun", "deu")
+	if err := settings.RemoveLanguage(chatID, "hun"); err != nil {
+		t.Fatal(err)
+	}
+	check("removed the input language", "deu")
+	if err := settings.RemoveLanguage(chatID, "eng"); err == nil {
+		t.Error("removing a language that isn't studied succeeded")
+	}
+}
+
+func TestParseLearningSteps(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []time.Duration
+		err  bool
+	}{
+		{in: "off"},
+		{in: "1m 10m 1d", want: []time.Duration{time.Minute, 10 * time.Minute, 24 * time.Hour}},
+		{in: " 90m  2h ", want: []time.Duration{90 * time.Minute, 2 * time.Hour}},
+		{in: "", err: true},
+		{in: "10s", err: true},
+		{in: "31d", err: true},
+		{in: "1x", err: true},
+		{in: "1m 1m 1m 1m 1m 1m 1m 1m 1m 1m 1m", err: true},
+	} {
+		got, err := ParseLearningSteps(tc.in)
+		if (err != nil) != tc.err || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseLearningSteps(%q) = %v, %v want %v, error %t", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+	if got, want := FormatLearningSteps([]time.Duration{time.Minute, 90 * time.Minute, 2 * time.Hour, 3 * 24 * time.Hour}), "1m 90m 2h 3d"; got != want {
+		t.Errorf("FormatLearningSteps() = %q want %q", got, want)
+	}
+}
+
+func TestDayLocation(t *testing.T) {
+	s := DefaultSettings()
+	s.TimeZone = "UTC+1"
+	loc := s.Location()
+	now := time.Date(2020, 6, 2, 3, 0, 0, 0, loc)
+	if got, want := localDay(now, s.DayLocation()), time.Date(2020, 6, 2, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("day without DayStartHour: got %v want %v", got, want)
+	}
+	s.DayStartHour = 4
+	if got, want := localDay(now, s.DayLocation()), time.Date(2020, 6, 1, 4, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("day starting at 4: got %v want %v", got, want)
+	}
+	if got, want := localDay(now.Add(time.Hour), s.DayLocation()), time.Date(2020, 6, 2, 4, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("day starting at 4 at 4: got %v want %v", got, want)
+	}
+}
+
+func TestAvailabilityWindows(t *testing.T) {
+	ws, err := ParseAvailabilityWindows("mon-fri 07:00-09:00; sat,sun 10:00-12:00; fri 22:00 - 02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ws.String(), "mon-fri 07:00-09:00; sat,sun 10:00-12:00; fri 22:00-02:00"; got != want {
+		t.Errorf("String() = %q want %q", got, want)
+	}
+	// 2020-06-05 is a Friday.
+	day := time.Date(2020, 6, 5, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		t, want time.Time
+	}{
+		{day.Add(8 * time.Hour), day.Add(8 * time.Hour)},
+		{day.Add(12 * time.Hour), day.Add(22 * time.Hour)},
+		// The Friday window goes on after midnight.
+		{day.Add(25 * time.Hour), day.Add(25 * time.Hour)},
+		{day.Add(27 * time.Hour), day.Add(34 * time.Hour)},
+		{day.AddDate(0, 0, 2).Add(12 * time.Hour), day.AddDate(0, 0, 3).Add(7 * time.Hour)},
+	} {
+		if got := ws.Clamp(tc.t); !got.Equal(tc.want) {
+			t.Errorf("Clamp(%v) = %v want %v", tc.t, got, tc.want)
+		}
+	}
+	for w, want := range map[string]string{
+		"09:00-21:00":             "09:00-21:00",
+		"mon,wed,fri 09:00-21:00": "mon,wed,fri 09:00-21:00",
+		"Sun-Tue 09:00-21:00":     "mon,tue,sun 09:00-21:00",
+	} {
+		ws, err := ParseAvailabilityWindows(w)
+		if err != nil || ws.String() != want {
+			t.Errorf("ParseAvailabilityWindows(%q) = %v, %v want %s", w, ws, err, want)
+		}
+	}
+	for _, w := range []string{"", "sometimes 09:00-21:00", "mon-fri", "09:00-21:00;"} {
+		if _, err := ParseAvailabilityWindows(w); err == nil {
+			t.Errorf("ParseAvailabilityWindows(%q) succeeded", w)
+		}
+	}
 }
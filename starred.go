@@ -0,0 +1,124 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Starred cards, marked by the user for quick access and for practicing them
+// separately from the schedule.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// starredListLimit is the number of words listed by /starred, so that the
+// message fits into telegram's limit.
+const starredListLimit = 100
+
+// StarCallback stars or unstars the saved word. The button is replaced with
+// the opposite one.
+type StarCallback struct {
+	Word string
+	// Whether the word is starred, the button unstars it then.
+	Starred bool
+}
+
+// starCallback returns the button for the current state of the saved word.
+func starCallback(s *State, chatID int64, word string) (StarCallback, error) {
+	starred, err := s.Repetitions.Starred(chatID, word)
+	return StarCallback{word, starred}, err
+}
+
+func (StarCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	starred := info.Index == ""
+	if err := s.Repetitions.SetStarred(chatID, info.Word, starred); err != nil {
+		return err
+	}
+	l := s.L(chatID)
+	msg := l.T("Unstarred %q", info.Word)
+	if starred {
+		msg = l.T("Starred %q", info.Word)
+	}
+	s.AnswerCallback(q, msg)
+	return replaceButton(s, q, StarCallback{info.Word, starred}.AsInlineKeyboard(l))
+}
+
+func (StarCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == StarAction
+}
+
+func (c StarCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	text, index := l.T("☆ Star"), ""
+	if c.Starred {
+		text, index = l.T("★ Unstar"), "1"
+	}
+	return &InlineKeyboard{
+		Text: text,
+		CallbackData: CallbackInfo{
+			Action: StarAction,
+			Word:   c.Word,
+			Index:  index,
+		}.String(),
+	}
+}
+
+// starredReply lists the starred words with their stages and offers to
+// practice them.
+func starredReply(s *State, chatID int64) error {
+	cs, err := s.Repetitions.StarredCards(chatID)
+	if err != nil {
+		return err
+	}
+	l := s.L(chatID)
+	if len(cs) == 0 {
+		return s.Telegram.SendTextMessage(chatID, l.T("No starred cards yet. Star cards with the ☆ Star button under them."))
+	}
+	lines := []string{l.T("Starred cards: %d", len(cs))}
+	for i, c := range cs {
+		if i == starredListLimit {
+			lines = append(lines, l.T("… and %d more", len(cs)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s (%s)", c.Word, l.T("stage %d", c.Stage+1)))
+	}
+	return s.Telegram.SendMessage(NewMessageReply(l, chatID, strings.Join(lines, "\n"), []Callback{PracticeStarredCallback{}}))
+}
+
+// PracticeStarredCallback starts a session with the starred cards.
+type PracticeStarredCallback struct{}
+
+func (PracticeStarredCallback) Call(s *State, q *CallbackQuery) error {
+	s.AnswerCallback(q, "")
+	if err := editKeyboard(s, q.Message, nil); err != nil {
+		return err
+	}
+	chatID := q.Message.Chat.Id
+	s.Practice.StartStarred(chatID, s.Clock.Now())
+	return practiceReply(s, chatID, "")
+}
+
+func (PracticeStarredCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == PracticeStarredAction
+}
+
+func (PracticeStarredCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text:         l.T("Practice starred"),
+		CallbackData: CallbackInfo{Action: PracticeStarredAction}.String(),
+	}
+}
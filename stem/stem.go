@@ -0,0 +1,125 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Package stem guesses the dictionary forms of inflected words by stripping
+// their suffixes. It doesn't know any words, so the guesses have to be checked
+// against a dictionary.
+package stem
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// minStem is the length in runes of the shortest stem, so that short words
+// aren't stripped to nothing.
+const minStem = 2
+
+// hungarianSuffixes are the layers of Hungarian suffixes in the order they
+// are stripped from the end of the word: case endings follow the plural and
+// the possessive ones. Within a layer longer suffixes come first.
+var hungarianSuffixes = [][]string{
+	// Case endings in all variants of the vowel harmony, except the
+	// assimilated ones, see hungarianAssimilated.
+	{
+		"ként", "ból", "ből", "ról", "ről", "tól", "től", "nál", "nél",
+		"hoz", "hez", "höz", "nak", "nek", "val", "vel", "ban", "ben",
+		"ért", "ig", "ba", "be", "ra", "re", "on", "en", "ön",
+		"at", "ot", "et", "öt", "n", "t",
+	},
+	// Plural and possessive endings.
+	{
+		"jaink", "jeink", "aink", "eink", "jaim", "jeim", "aim", "eim",
+		"juk", "jük", "unk", "ünk", "tok", "tek", "tök", "jai", "jei",
+		"ai", "ei", "ak", "ok", "ek", "ök", "uk", "ük", "nk",
+		"am", "om", "em", "öm", "ad", "od", "ed", "öd", "ja", "je",
+		"k", "m", "d", "a", "e", "i",
+	},
+}
+
+// hungarianAssimilated are the case endings whose consonant turns into the
+// last consonant of the stem, e.g. "kéz" + "vel" is "kézzel".
+var hungarianAssimilated = []string{"al", "el", "á", "é"}
+
+// Hungarian returns the possible stems of the Hungarian word, the ones with
+// fewer suffixes stripped first. The word itself isn't among them.
+func Hungarian(word string) []string {
+	seen := map[string]bool{word: true}
+	var stems []string
+	forms := []string{word}
+	for i, suffixes := range hungarianSuffixes {
+		var next []string
+		for _, f := range forms {
+			ss := strip(f, suffixes)
+			if i == 0 {
+				ss = append(ss, stripAssimilated(f)...)
+			}
+			for _, s := range ss {
+				if !seen[s] {
+					seen[s] = true
+					next = append(next, s)
+				}
+			}
+		}
+		stems = append(stems, next...)
+		forms = append(forms, next...)
+	}
+	return stems
+}
+
+// strip returns the word without each of the suffixes it ends with. Final a
+// and e of the stem are lengthened before most suffixes, e.g. "fekete" +
+// "ben" is "feketében", so both the short and the long vowel are tried.
+func strip(word string, suffixes []string) []string {
+	var stems []string
+	for _, s := range suffixes {
+		stem, ok := strings.CutSuffix(word, s)
+		if !ok || utf8.RuneCountInString(stem) < minStem {
+			continue
+		}
+		if short, ok := strings.CutSuffix(stem, "á"); ok {
+			stems = append(stems, short+"a")
+		}
+		if short, ok := strings.CutSuffix(stem, "é"); ok {
+			stems = append(stems, short+"e")
+		}
+		stems = append(stems, stem)
+	}
+	return stems
+}
+
+// stripAssimilated returns the word without the assimilated case endings, see
+// hungarianAssimilated.
+func stripAssimilated(word string) []string {
+	var stems []string
+	for _, s := range hungarianAssimilated {
+		rest, ok := strings.CutSuffix(word, s)
+		if !ok {
+			continue
+		}
+		last, n := utf8.DecodeLastRuneInString(rest)
+		prev, _ := utf8.DecodeLastRuneInString(rest[:len(rest)-n])
+		stem := rest[:len(rest)-n]
+		if last != prev || isVowel(last) || utf8.RuneCountInString(stem) < minStem {
+			continue
+		}
+		stems = append(stems, stem)
+	}
+	return stems
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aáeéiíoóöőuúüű", r)
+}
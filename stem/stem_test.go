@@ -0,0 +1,51 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package stem
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHungarian(t *testing.T) {
+	for _, tc := range []struct {
+		word string
+		// The stem that must be among the guesses and the one guessed
+		// first.
+		stem  string
+		first string
+	}{
+		{"faluban", "falu", "falu"},
+		{"feketében", "fekete", "fekete"},
+		{"házakban", "ház", "házak"},
+		{"kézzel", "kéz", "kéz"},
+		{"almát", "alma", "alma"},
+		{"kávéból", "kávé", "káve"},
+		{"barátaim", "barát", "barát"},
+		{"asztalunkon", "asztal", "asztalunk"},
+	} {
+		got := Hungarian(tc.word)
+		if !slices.Contains(got, tc.stem) {
+			t.Errorf("Hungarian(%q) = %q, want %q among them", tc.word, got, tc.stem)
+		}
+		if len(got) > 0 && got[0] != tc.first {
+			t.Errorf("Hungarian(%q)[0] = %q, want %q", tc.word, got[0], tc.first)
+		}
+	}
+	for _, w := range []string{"ha", "a", ""} {
+		if got := Hungarian(w); len(got) > 0 {
+			t.Errorf("Hungarian(%q) = %q, want none", w, got)
+		}
+	}
+}
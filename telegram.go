@@ -22,18 +22,26 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
 )
 
-// Note that BotToken comes from a file not in a git repository.
-// TODO: should be in the main function. For now hard and var for e2e testing.
-var telegramApiPrefix = "https://api.telegram.org/bot" + BotToken
+// telegramApiPrefix is followed by the bot token and the method. It's a var
+// for e2e testing.
+var telegramApiPrefix = "https://api.telegram.org/bot"
 
-func methodURL(m string) string {
-	return telegramApiPrefix + "/" + m
+// telegramFilePrefix is followed by the bot token and the path of a file sent
+// to the bot.
+var telegramFilePrefix = "https://api.telegram.org/file/bot"
+
+func (t *Telegram) methodURL(m string) string {
+	return telegramApiPrefix + t.token + "/" + m
 }
 
 // NOTE: For not inlined keyborad see:
@@ -49,8 +57,74 @@ type Message struct {
 		Id int64 `json:"id"`
 	} `json:"chat"`
 	ReplyMarkup ReplyMarkup `json:"reply_markup"`
+	// Document is set if the user sent a file.
+	Document *Document `json:"document,omitempty"`
+	// Photo has the available sizes of the photo the user sent.
+	Photo []PhotoSize `json:"photo,omitempty"`
+	// Caption is the text under the photo.
+	Caption string `json:"caption,omitempty"`
+	// Entities are formatting, links, etc. in Text.
+	Entities []MessageEntity `json:"entities,omitempty"`
+}
+
+type MessageEntity struct {
+	Type string `json:"type"`
+	// Offset and Length are in UTF-16 code units.
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+	// URL is set for "text_link".
+	URL string `json:"url,omitempty"`
+}
+
+// PlainText returns the text of the message with the URLs of text links in
+// parentheses after them, since otherwise they are lost without entities.
+func (m *Message) PlainText() string {
+	u := utf16.Encode([]rune(m.Text))
+	var b []uint16
+	last := 0
+	// Entities are sorted by offset.
+	for _, e := range m.Entities {
+		end := e.Offset + e.Length
+		if e.Type != "text_link" || e.Offset < last || end > len(u) {
+			continue
+		}
+		b = append(b, u[last:end]...)
+		b = append(b, utf16.Encode([]rune(" ("+e.URL+")"))...)
+		last = end
+	}
+	return string(utf16.Decode(append(b, u[last:]...)))
+}
+
+type Document struct {
+	FileId   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+}
+
+type PhotoSize struct {
+	FileId   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int64  `json:"file_size"`
+}
+
+// LargestPhoto returns the largest of the available sizes of the photo.
+func (m *Message) LargestPhoto() PhotoSize {
+	p := m.Photo[0]
+	for _, ps := range m.Photo[1:] {
+		if ps.Width*ps.Height > p.Width*p.Height {
+			p = ps
+		}
+	}
+	return p
 }
 
+// maxCaptionLength is the longest caption of a photo in characters.
+const maxCaptionLength = 1024
+
+// maxDownloadSize is the largest file bots can download.
+const maxDownloadSize = 20 << 20
+
 type CallbackQuery struct {
 	Id      string   `json:"id"`
 	Message *Message `json:"message"`
@@ -61,6 +135,8 @@ type Update struct {
 	UpdateId      int64          `json:"update_id"`
 	Message       *Message       `json:"message"`
 	CallbackQuery *CallbackQuery `json:"callback_query"`
+
+	log *slog.Logger
 }
 
 func (u *Update) ChatId() (int64, error) {
@@ -87,6 +163,40 @@ type MessageReply struct {
 	Text        string       `json:"text"`
 	ReplyMarkup *ReplyMarkup `json:"reply_markup,omitempty"`
 	ParseMode   string       `json:"parse_mode,omitempty"`
+	// Entities format Text instead of ParseMode, see Formatted.
+	Entities []MessageEntity `json:"entities,omitempty"`
+	// LinkPreviewOptions control the preview of the first link in Text.
+	LinkPreviewOptions *LinkPreviewOptions `json:"link_preview_options,omitempty"`
+	// ReplyParameters make the message a reply to another one.
+	ReplyParameters *ReplyParameters `json:"reply_parameters,omitempty"`
+}
+
+type LinkPreviewOptions struct {
+	// IsDisabled sends the message without a preview, which telegram would
+	// otherwise fetch from the link.
+	IsDisabled bool `json:"is_disabled"`
+}
+
+type ReplyParameters struct {
+	MessageId int64 `json:"message_id"`
+	// AllowSendingWithoutReply sends the message even if the replied one
+	// was deleted.
+	AllowSendingWithoutReply bool `json:"allow_sending_without_reply,omitempty"`
+}
+
+// PhotoReply sends the photo already on the Telegram servers by its file_id.
+type PhotoReply struct {
+	ChatId      int64        `json:"chat_id"`
+	Photo       string       `json:"photo"`
+	Caption     string       `json:"caption,omitempty"`
+	ReplyMarkup *ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+type EditMessageCaption struct {
+	ChatId      int64       `json:"chat_id"`
+	MessageId   int64       `json:"message_id"`
+	Caption     string      `json:"caption"`
+	ReplyMarkup ReplyMarkup `json:"reply_markup,omitempty"`
 }
 
 type EditMessageText struct {
@@ -98,17 +208,69 @@ type EditMessageText struct {
 }
 
 type Telegram struct {
-	hc         http.Client
+	hc http.Client
+	// token of the bot, BotToken for the main one. Note that BotToken comes
+	// from a file not in a git repository.
+	token      string
 	pollOffset int64
 }
 
+// NewTelegram creates a client of the bot with the token.
+func NewTelegram(token string) *Telegram {
+	return &Telegram{token: token}
+}
+
+// StatusError is returned by Call when telegram replies with an unexpected
+// HTTP status code.
+type StatusError struct {
+	Code int
+	Body string
+	// Description is the reason given by telegram, e.g. "Bad Request:
+	// message is not modified", empty if the body isn't an API error.
+	Description string
+}
+
+// Benign failures of editing messages, StatusError matches them with
+// errors.Is by the description.
+var (
+	// errMessageNotModified is returned when the message already has the
+	// new text and buttons, e.g. after a double tap.
+	errMessageNotModified = errors.New("message is not modified")
+	// errMessageToEditNotFound is returned when the message was deleted.
+	errMessageToEditNotFound = errors.New("message to edit not found")
+)
+
+// errChatInactive is returned when the user blocked the bot or the bot was
+// removed from the group, telegram then refuses all messages to the chat.
+var errChatInactive = errors.New("chat is inactive")
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case errChatInactive:
+		return e.Code == http.StatusForbidden
+	case errMessageNotModified, errMessageToEditNotFound:
+		return e.Code == http.StatusBadRequest && strings.Contains(e.Description, target.Error())
+	}
+	return false
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: got %d, want 200; %s", e.Code, e.Body)
+}
+
+// Unrecoverable reports whether retrying the call can't help, e.g. because
+// the bot token is wrong.
+func (e *StatusError) Unrecoverable() bool {
+	return e.Code == http.StatusUnauthorized || e.Code == http.StatusNotFound
+}
+
 func (t *Telegram) Call(method string, req, res interface{}) error {
-	log.Printf("Calling %q with req %v", method, req)
+	slog.Debug("Calling telegram", "method", method, "req", req)
 	mq, err := json.Marshal(req)
 	if err != nil {
 		return err
 	}
-	r, err := t.hc.Post(methodURL(method), "application/json", bytes.NewBuffer(mq))
+	r, err := t.hc.Post(t.methodURL(method), "application/json", bytes.NewBuffer(mq))
 	if err != nil {
 		return err
 	}
@@ -121,10 +283,15 @@ func (t *Telegram) callHandleResponse(r *http.Response, res interface{}) error {
 		return err
 	}
 	if r.StatusCode != 200 {
-		return fmt.Errorf("unexpected status code: got %d, want 200; %s", r.StatusCode, b.String())
+		var apiErr struct {
+			Description string `json:"description"`
+		}
+		// Errors of other servers, e.g. proxies, aren't JSON.
+		json.Unmarshal(b.Bytes(), &apiErr)
+		return &StatusError{Code: r.StatusCode, Body: b.String(), Description: apiErr.Description}
 	}
 
-	log.Printf("DEBUG: body: %s", b.String())
+	slog.Debug("Telegram response", "body", b.String())
 	raw := new(struct {
 		Ok     bool            `json:"ok"`
 		Result json.RawMessage `json:"result"`
@@ -154,17 +321,177 @@ func (t *Telegram) Poll() (updates []*Update, err error) {
 	return
 }
 
+// DownloadFile returns the contents of the file sent to the bot.
+func (t *Telegram) DownloadFile(fileId string) ([]byte, error) {
+	var f struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := t.Call("getFile", map[string]string{"file_id": fileId}, &f); err != nil {
+		return nil, err
+	}
+	r, err := t.hc.Get(telegramFilePrefix + t.token + "/" + f.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return nil, &StatusError{Code: r.StatusCode}
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxDownloadSize {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", f.FilePath, maxDownloadSize)
+	}
+	return b, nil
+}
+
 func (t *Telegram) SendTextMessage(chatId int64, s string) error {
-	var m Message
-	return t.Call("sendMessage", &MessageReply{
+	return t.SendMessage(&MessageReply{
 		ChatId: chatId,
 		Text:   s,
-	}, &m)
+	})
 }
 
 func (t *Telegram) SendMessage(mr *MessageReply) error {
+	_, err := t.Send(mr)
+	return err
+}
+
+// Send sends the message and returns it as sent, e.g. to edit it later. Texts
+// longer than telegram allows are sent in several messages, see
+// splitMessage, and the first one is returned.
+func (t *Telegram) Send(mr *MessageReply) (*Message, error) {
+	var first *Message
+	for _, part := range splitMessage(mr) {
+		var m Message
+		if err := t.Call("sendMessage", part, &m); err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = &m
+		}
+	}
+	return first, nil
+}
+
+// maxMessageLength is the longest text of a message in UTF-16 code units.
+const maxMessageLength = 4096
+
+// splitMessage splits the text longer than maxMessageLength into parts at
+// paragraph boundaries, or at line breaks and spaces if a paragraph is too
+// long, with the entities cut accordingly. The first part replies to the
+// message replied to and the buttons are under the last one. Texts with
+// ParseMode aren't split, since that could break their markup.
+func splitMessage(mr *MessageReply) []*MessageReply {
+	u := utf16.Encode([]rune(mr.Text))
+	if len(u) <= maxMessageLength || mr.ParseMode != "" {
+		return []*MessageReply{mr}
+	}
+	var parts []*MessageReply
+	for start := 0; start < len(u); {
+		end, next := splitPoint(u[start:])
+		end, next = start+end, start+next
+		part := &MessageReply{
+			ChatId:             mr.ChatId,
+			Text:               string(utf16.Decode(u[start:end])),
+			LinkPreviewOptions: mr.LinkPreviewOptions,
+		}
+		for _, e := range mr.Entities {
+			from, to := max(e.Offset, start), min(e.Offset+e.Length, end)
+			if from >= to {
+				continue
+			}
+			e.Offset, e.Length = from-start, to-from
+			part.Entities = append(part.Entities, e)
+		}
+		parts = append(parts, part)
+		start = next
+	}
+	parts[0].ReplyParameters = mr.ReplyParameters
+	parts[len(parts)-1].ReplyMarkup = mr.ReplyMarkup
+	return parts
+}
+
+// splitPoint returns the end of the first part of u and the start of the
+// rest, which skips the separator.
+func splitPoint(u []uint16) (end, next int) {
+	if len(u) <= maxMessageLength {
+		return len(u), len(u)
+	}
+	s := string(utf16.Decode(u[:maxMessageLength]))
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(s, sep); i > 0 {
+			end = utf16Len(s[:i])
+			return end, end + utf16Len(sep)
+		}
+	}
+	// Surrogate pairs aren't split.
+	end = maxMessageLength
+	if utf16.IsSurrogate(rune(u[end-1])) && u[end-1] < 0xdc00 {
+		end--
+	}
+	return end, end
+}
+
+// SendPhoto sends the photo and returns it as sent, e.g. to edit its caption
+// later.
+func (t *Telegram) SendPhoto(pr *PhotoReply) (*Message, error) {
 	var m Message
-	return t.Call("sendMessage", mr, &m)
+	if err := t.Call("sendPhoto", pr, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SendDocument uploads the file to the chat.
+func (t *Telegram) SendDocument(chatId int64, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return t.upload("sendDocument", "document", chatId, filepath.Base(path), f)
+}
+
+// SendDocumentData uploads the data to the chat as a file with the name.
+func (t *Telegram) SendDocumentData(chatId int64, name string, data []byte) error {
+	return t.upload("sendDocument", "document", chatId, name, bytes.NewReader(data))
+}
+
+// SendVoice sends the OGG Opus audio to the chat as a voice message.
+func (t *Telegram) SendVoice(chatId int64, audio []byte) error {
+	return t.upload("sendVoice", "voice", chatId, "voice.ogg", bytes.NewReader(audio))
+}
+
+// upload calls the method sending the file in the field to the chat.
+func (t *Telegram) upload(method, field string, chatId int64, name string, r io.Reader) error {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	if err := w.WriteField("chat_id", strconv.FormatInt(chatId, 10)); err != nil {
+		return err
+	}
+	fw, err := w.CreateFormFile(field, name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, r); err != nil {
+		return err
+	}
+	w.Close()
+
+	req, err := http.NewRequest("POST", t.methodURL(method), &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	res, err := t.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	var m Message
+	return t.callHandleResponse(res, &m)
 }
 
 func (t *Telegram) AnswerCallback(id string, text string) error {
@@ -189,11 +516,18 @@ func (t *Telegram) AnswerCallback(id string, text string) error {
 
 func (t *Telegram) AnswerCallbackLog(id string, text string) {
 	if err := t.AnswerCallback(id, text); err != nil {
-		log.Printf("Error answering callback: %w", err)
+		slog.Error("Answering callback", "err", err)
 	}
 }
 
-func (t *Telegram) SetWebhook(url string, certPath string) error {
+// secretTokenHeader carries the secret given to SetWebhook in every update
+// telegram pushes.
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// SetWebhook makes telegram push updates to url. The certificate is uploaded
+// for self-signed ones, if secret isn't empty it's sent with every update in
+// secretTokenHeader.
+func (t *Telegram) SetWebhook(url, certPath, secret string) error {
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
 
@@ -214,6 +548,11 @@ func (t *Telegram) SetWebhook(url string, certPath string) error {
 	if err := w.WriteField("url", url); err != nil {
 		return err
 	}
+	if secret != "" {
+		if err := w.WriteField("secret_token", secret); err != nil {
+			return err
+		}
+	}
 	// TODO: Support more than 1 connection. 1 for now because not everything
 	// is safe for concurrent access.
 	if err := w.WriteField("max_connections", "1"); err != nil {
@@ -221,7 +560,7 @@ func (t *Telegram) SetWebhook(url string, certPath string) error {
 	}
 	w.Close()
 
-	req, err := http.NewRequest("POST", methodURL("setWebhook"), &b)
+	req, err := http.NewRequest("POST", t.methodURL("setWebhook"), &b)
 	if err != nil {
 		return err
 	}
@@ -244,7 +583,7 @@ func (t *Telegram) SetWebhook(url string, certPath string) error {
 func (t *Telegram) LogWebhookInfo() {
 	raw := json.RawMessage{}
 	if err := t.Call("getWebhookInfo", nil, &raw); err != nil {
-		log.Printf("getWebhhokInfo failed: %v", err)
+		slog.Error("getWebhookInfo failed", "err", err)
 	}
-	log.Printf("getWebhhokInfo: %s", string(raw))
+	slog.Info("getWebhookInfo", "info", string(raw))
 }
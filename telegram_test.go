@@ -0,0 +1,124 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMessagePlainText(t *testing.T) {
+	for _, tc := range []struct {
+		m    Message
+		want string
+	}{
+		{Message{Text: "just text"}, "just text"},
+		{Message{
+			Text:     "bold and link",
+			Entities: []MessageEntity{{Type: "bold", Offset: 0, Length: 4}},
+		}, "bold and link"},
+		{Message{
+			// Offsets are in UTF-16, the emoji takes 2 units.
+			Text: "😀 see szótár and this",
+			Entities: []MessageEntity{
+				{Type: "text_link", Offset: 7, Length: 6, URL: "https://wiktionary.org"},
+				{Type: "text_link", Offset: 18, Length: 4, URL: "https://example.com"},
+			},
+		}, "😀 see szótár (https://wiktionary.org) and this (https://example.com)"},
+	} {
+		if got := tc.m.PlainText(); got != tc.want {
+			t.Errorf("PlainText(%q) = %q want %q", tc.m.Text, got, tc.want)
+		}
+	}
+}
+
+func TestStatusErrorIs(t *testing.T) {
+	for _, tc := range []struct {
+		code        int
+		body        string
+		notModified bool
+		notFound    bool
+	}{
+		{400, `{"ok":false,"error_code":400,"description":"Bad Request: message is not modified: specified new message content and reply markup are exactly the same as a current content and reply markup of the message"}`, true, false},
+		{400, `{"ok":false,"error_code":400,"description":"Bad Request: message to edit not found"}`, false, true},
+		{400, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`, false, false},
+		{403, `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`, false, false},
+		{502, `<html>message is not modified</html>`, false, false},
+	} {
+		r := &http.Response{StatusCode: tc.code, Body: io.NopCloser(strings.NewReader(tc.body))}
+		err := fmt.Errorf("editing message: %w", (&Telegram{}).callHandleResponse(r, nil))
+		if got := errors.Is(err, errMessageNotModified); got != tc.notModified {
+			t.Errorf("errors.Is(%v, errMessageNotModified) = %t want %t", err, got, tc.notModified)
+		}
+		if got := errors.Is(err, errMessageToEditNotFound); got != tc.notFound {
+			t.Errorf("errors.Is(%v, errMessageToEditNotFound) = %t want %t", err, got, tc.notFound)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	paragraph := strings.Repeat("a", 3000)
+	// The emoji takes 2 UTF-16 code units.
+	long := "😀" + strings.Repeat("b", maxMessageLength)
+	mr := &MessageReply{
+		ChatId: 1,
+		Text:   paragraph + "\n\n" + paragraph + "\n\n" + long,
+		Entities: []MessageEntity{
+			{Type: "bold", Offset: 0, Length: 10},
+			// Spans the first two parts.
+			{Type: "italic", Offset: 2990, Length: 20},
+			{Type: "text_link", Offset: 6004, Length: 4, URL: "https://example.com"},
+		},
+		ReplyMarkup:     &ReplyMarkup{InlineKeyboard: [][]*InlineKeyboard{{{Text: "Learn"}}}},
+		ReplyParameters: &ReplyParameters{MessageId: 5},
+	}
+	got := splitMessage(mr)
+	want := []*MessageReply{
+		{
+			ChatId:          1,
+			Text:            paragraph,
+			Entities:        []MessageEntity{{Type: "bold", Offset: 0, Length: 10}, {Type: "italic", Offset: 2990, Length: 10}},
+			ReplyParameters: mr.ReplyParameters,
+		},
+		{
+			ChatId:   1,
+			Text:     paragraph,
+			Entities: []MessageEntity{{Type: "italic", Offset: 0, Length: 8}},
+		},
+		{
+			ChatId:   1,
+			Text:     "😀" + strings.Repeat("b", maxMessageLength-2),
+			Entities: []MessageEntity{{Type: "text_link", Offset: 0, Length: 4, URL: "https://example.com"}},
+		},
+		{
+			ChatId:      1,
+			Text:        "bb",
+			ReplyMarkup: mr.ReplyMarkup,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("splitMessage() (-want +got):\n%s", diff)
+	}
+
+	short := &MessageReply{ChatId: 1, Text: "short"}
+	if got := splitMessage(short); len(got) != 1 || got[0] != short {
+		t.Errorf("splitMessage(%q) = %v want it unchanged", short.Text, got)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Package tokenize splits sentences into words. It's used both when loading
+// the corpus and when querying it, so that words are always normalized the
+// same way.
+package tokenize
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var folder = cases.Fold()
+
+// Normalize brings the word to the form in which it's stored in the database:
+// NFC normalized and case folded.
+func Normalize(word string) string {
+	return folder.String(norm.NFC.String(word))
+}
+
+// isWordRune reports whether r can be a part of the word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+}
+
+// isJoiner reports whether r can join two parts of the word, e.g. in "don't"
+// or "ping-pong".
+func isJoiner(r rune) bool {
+	switch r {
+	case '\'', '’', '-', '‐':
+		return true
+	}
+	return false
+}
+
+// spans returns the start and the end of every word in rs.
+func spans(rs []rune) [][2]int {
+	var ss [][2]int
+	start := -1
+	for i, r := range rs {
+		switch {
+		case isWordRune(r):
+			if start < 0 {
+				start = i
+			}
+		case isJoiner(r) && start >= 0 && i+1 < len(rs) && isWordRune(rs[i+1]):
+		default:
+			if start >= 0 {
+				ss = append(ss, [2]int{start, i})
+				start = -1
+			}
+		}
+	}
+	if start >= 0 {
+		ss = append(ss, [2]int{start, len(rs)})
+	}
+	return ss
+}
+
+// Words splits text into normalized words. Any punctuation (including
+// non-ASCII one like quotes, dashes and ellipses) separates words, apart from
+// apostrophes and hyphens inside of the word.
+func Words(text string) []string {
+	rs := []rune(norm.NFC.String(text))
+	var ws []string
+	for _, s := range spans(rs) {
+		ws = append(ws, Normalize(string(rs[s[0]:s[1]])))
+	}
+	return ws
+}
+
+// Blank replaces every occurrence of the word in text with blank, e.g. for
+// fill-in-the-blank exercises. The text is NFC normalized.
+func Blank(text, word, blank string) string {
+	rs := []rune(norm.NFC.String(text))
+	word = Normalize(word)
+	var b strings.Builder
+	last := 0
+	for _, s := range spans(rs) {
+		if Normalize(string(rs[s[0]:s[1]])) != word {
+			continue
+		}
+		b.WriteString(string(rs[last:s[0]]))
+		b.WriteString(blank)
+		last = s[1]
+	}
+	b.WriteString(string(rs[last:]))
+	return b.String()
+}
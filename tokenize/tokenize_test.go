@@ -0,0 +1,63 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tokenize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWords(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		{"Fekete kutya.", []string{"fekete", "kutya"}},
+		{"„Mi a baj?” – kérdezte…", []string{"mi", "a", "baj", "kérdezte"}},
+		{"\"What's the matter?\" asked the well-known rabbit.", []string{"what's", "the", "matter", "asked", "the", "well-known", "rabbit"}},
+		{"Чорний собака!", []string{"чорний", "собака"}},
+		{"- Ja -", []string{"ja"}},
+		// Decomposed "é" is composed.
+		{"Fehe\u0301r", []string{"feh\u00e9r"}},
+		{"«Straße»", []string{Normalize("straße")}},
+		{"", nil},
+	} {
+		if diff := cmp.Diff(Words(tc.text), tc.want); diff != "" {
+			t.Errorf("Words(%q): (-got +want):\n%s", tc.text, diff)
+		}
+	}
+}
+
+func TestBlank(t *testing.T) {
+	for _, tc := range []struct {
+		text, word, want string
+	}{
+		{"Fekete kutya, fekete macska.", "fekete", "___ kutya, ___ macska."},
+		{"A feketéje fekete.", "FEKETE", "A feketéje ___."},
+		{"\"What's the matter?\"", "what's", "\"___ the matter?\""},
+		{"Fehe\u0301r ló", "fehér", "___ ló"},
+		{"No word here", "fekete", "No word here"},
+	} {
+		if got := Blank(tc.text, tc.word, "___"); got != tc.want {
+			t.Errorf("Blank(%q, %q) = %q want %q", tc.text, tc.word, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got, want := Normalize("FEH\u00c9R"), "feh\u00e9r"; got != want {
+		t.Errorf("Normalize: got %q want %q", got, want)
+	}
+}
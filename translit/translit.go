@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Package translit romanizes text in Cyrillic for learners who can't read
+// it yet.
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scheme maps lowercase letters to their romanization. initial overrides it
+// at the beginning of words.
+type scheme struct {
+	letters map[rune]string
+	initial map[rune]string
+}
+
+// schemes by ISO 639-3 code of the language.
+var schemes = map[string]*scheme{
+	// BGN/PCGN without diacritics.
+	"rus": {
+		letters: map[rune]string{
+			'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+			'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+			'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+			'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+			'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+		},
+	},
+	// The official Ukrainian transliteration of 2010.
+	"ukr": {
+		letters: map[rune]string{
+			'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d", 'е': "e",
+			'є': "ie", 'ж': "zh", 'з': "z", 'и': "y", 'і': "i", 'ї': "i", 'й': "i",
+			'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+			'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+			'ш': "sh", 'щ': "shch", 'ь': "", 'ю': "iu", 'я': "ia", '\'': "", '’': "",
+		},
+		initial: map[rune]string{
+			'є': "ye", 'ї': "yi", 'й': "y", 'ю': "yu", 'я': "ya",
+		},
+	},
+}
+
+// Romanize returns text in the language written in latin letters. Characters
+// that are not in the alphabet of the language are kept as is. ok is false if
+// the language isn't supported.
+func Romanize(language, text string) (_ string, ok bool) {
+	s := schemes[language]
+	if s == nil {
+		return "", false
+	}
+	var b strings.Builder
+	start := true
+	for _, r := range text {
+		l := unicode.ToLower(r)
+		t, found := s.initial[l]
+		if !start || !found {
+			t, found = s.letters[l]
+		}
+		switch {
+		case !found:
+			b.WriteRune(r)
+		case l != r && t != "":
+			// Capitalize only the first letter, so that "Щ" becomes "Shch".
+			b.WriteString(strings.ToUpper(t[:1]) + t[1:])
+		default:
+			b.WriteString(t)
+		}
+		start = !unicode.IsLetter(r) && !found
+	}
+	return b.String(), true
+}
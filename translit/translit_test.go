@@ -0,0 +1,38 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package translit
+
+import "testing"
+
+func TestRomanize(t *testing.T) {
+	for _, tc := range []struct {
+		language string
+		text     string
+		want     string
+	}{
+		{"rus", "Привет, мир!", "Privet, mir!"},
+		{"rus", "Щука съела ёжика.", "Shchuka sela yozhika."},
+		{"ukr", "Київ і Україна", "Kyiv i Ukraina"},
+		{"ukr", "Юлія їсть яблуко з м'ясом.", "Yuliia yist yabluko z miasom."},
+		{"ukr", "Є латиниця: abc", "Ye latynytsia: abc"},
+	} {
+		got, ok := Romanize(tc.language, tc.text)
+		if !ok || got != tc.want {
+			t.Errorf("Romanize(%q, %q) = %q, %v; want %q", tc.language, tc.text, got, ok, tc.want)
+		}
+	}
+	if _, ok := Romanize("hun", "szia"); ok {
+		t.Errorf("Romanize(\"hun\", ...) succeeded, want unsupported")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Audio of card fronts, sent on demand with the Listen button and instead of
+// the word in listening practice.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TextToSpeech synthesizes speech in the languages it has voices for.
+type TextToSpeech interface {
+	// Speaks returns whether there is a voice for the language given as
+	// ISO 639-3 code.
+	Speaks(language string) bool
+	// Speak returns the text spoken in the language as OGG Opus, which
+	// telegram plays as a voice message.
+	Speak(text, language string) ([]byte, error)
+}
+
+// parseVoice parses a voice given as language=voice in the tts_voices option,
+// e.g. hun=hu-HU-Wavenet-A.
+func parseVoice(s string) (language, voice string, err error) {
+	language, voice, ok := strings.Cut(s, "=")
+	if !ok || len(language) != 3 || voice == "" {
+		return "", "", fmt.Errorf("voice %q is not language=voice with ISO 639-3 code of the language", s)
+	}
+	return language, voice, nil
+}
+
+// googleTTSURL is the endpoint of Google Cloud Text-to-Speech, replaced in
+// tests.
+var googleTTSURL = "https://texttospeech.googleapis.com/v1/text:synthesize"
+
+// ttsTimeout limits synthesis of a single text.
+const ttsTimeout = 30 * time.Second
+
+// GoogleTTS is Google Cloud Text-to-Speech with a voice for each language.
+type GoogleTTS struct {
+	hc  *http.Client
+	key string
+	// voices are names of the voices by ISO 639-3 codes of the languages,
+	// e.g. "hu-HU-Wavenet-A" for "hun".
+	voices map[string]string
+}
+
+func NewGoogleTTS(key string, voices map[string]string) *GoogleTTS {
+	return &GoogleTTS{hc: &http.Client{Timeout: ttsTimeout}, key: key, voices: voices}
+}
+
+func (g *GoogleTTS) Speaks(language string) bool {
+	_, ok := g.voices[language]
+	return ok
+}
+
+func (g *GoogleTTS) Speak(text, language string) ([]byte, error) {
+	voice, ok := g.voices[language]
+	if !ok {
+		return nil, fmt.Errorf("no voice for %q", language)
+	}
+	// Names of the voices start with the language code, e.g. hu-HU.
+	parts := strings.SplitN(voice, "-", 3)
+	code := voice
+	if len(parts) == 3 {
+		code = parts[0] + "-" + parts[1]
+	}
+	req := map[string]any{
+		"input":       map[string]string{"text": text},
+		"voice":       map[string]string{"languageCode": code, "name": voice},
+		"audioConfig": map[string]string{"audioEncoding": "OGG_OPUS"},
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := g.hc.Post(googleTTSURL+"?key="+url.QueryEscape(g.key), "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1000))
+		return nil, fmt.Errorf("text-to-speech: %w", &StatusError{Code: res.StatusCode, Body: string(msg)})
+	}
+	var r struct {
+		// AudioContent is base64 in JSON.
+		AudioContent []byte `json:"audioContent"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decoding text-to-speech response: %w", err)
+	}
+	return r.AudioContent, nil
+}
+
+// cardLanguage returns the language of the saved word, the input language if
+// it's unknown.
+func cardLanguage(s *State, chatID int64, word string) (string, error) {
+	language, err := s.Repetitions.Language(chatID, word)
+	if err != nil || language != "" {
+		return language, err
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return "", err
+	}
+	return settings.InputLanguageISO639_3, nil
+}
+
+// sendSpeech sends the word spoken in the language as a voice message.
+func sendSpeech(s *State, chatID int64, word, language string) error {
+	audio, err := s.TTS.Speak(word, language)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: synthesizing %q in %s: %w", word, language, err)
+	}
+	return s.Telegram.SendVoice(chatID, audio)
+}
+
+// ListenCallback sends the word spoken.
+type ListenCallback struct {
+	Word string
+	// ISO 639-3 code of the language in which the word is spoken.
+	Language string
+}
+
+func (ListenCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	if s.TTS == nil || !s.TTS.Speaks(info.Setting) {
+		s.AnswerCallback(q, s.L(chatID).T("Audio isn't available anymore."))
+		return nil
+	}
+	s.AnswerCallback(q, "")
+	return sendSpeech(s, chatID, info.Word, info.Setting)
+}
+
+func (ListenCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == ListenAction
+}
+
+func (c ListenCallback) AsInlineKeyboard(l *Localizer) *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: l.T("🔊 Listen"),
+		CallbackData: CallbackInfo{
+			Action:  ListenAction,
+			Word:    c.Word,
+			Setting: c.Language,
+		}.String(),
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGoogleTTS(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "secret" {
+			http.Error(w, "bad key", http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Input struct{ Text string }
+			Voice struct{ LanguageCode, Name string }
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.Input.Text != "falu" || req.Voice.LanguageCode != "hu-HU" || req.Voice.Name != "hu-HU-Wavenet-A" {
+			t.Errorf("request: got %+v", req)
+		}
+		json.NewEncoder(w).Encode(map[string][]byte{"audioContent": []byte("OggS")})
+	}))
+	defer s.Close()
+	defer func(u string) { googleTTSURL = u }(googleTTSURL)
+	googleTTSURL = s.URL
+
+	g := NewGoogleTTS("secret", map[string]string{"hun": "hu-HU-Wavenet-A"})
+	if !g.Speaks("hun") || g.Speaks("deu") {
+		t.Errorf("Speaks: got hun %t, deu %t want only hun", g.Speaks("hun"), g.Speaks("deu"))
+	}
+	audio, err := g.Speak("falu", "hun")
+	if err != nil || string(audio) != "OggS" {
+		t.Errorf("Speak: got %q, %v want OggS", audio, err)
+	}
+	if _, err := g.Speak("Dorf", "deu"); err == nil {
+		t.Error("Speak succeeded without a voice")
+	}
+	if _, err := NewGoogleTTS("wrong", g.voices).Speak("falu", "hun"); err == nil {
+		t.Error("Speak succeeded with a wrong key")
+	}
+}
+
+func TestParseVoice(t *testing.T) {
+	if l, v, err := parseVoice("hun=hu-HU-Wavenet-A"); err != nil || l != "hun" || v != "hu-HU-Wavenet-A" {
+		t.Errorf("parseVoice: got %q, %q, %v", l, v, err)
+	}
+	for _, s := range []string{"hu-HU-Wavenet-A", "hu=hu-HU-Wavenet-A", "hun="} {
+		if _, _, err := parseVoice(s); err == nil {
+			t.Errorf("parseVoice(%q) succeeded want error", s)
+		}
+	}
+}
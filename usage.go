@@ -17,6 +17,8 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+
+	"words/tokenize"
 )
 
 // Usage is struct that is able to extract usage examples from the tatoeba
@@ -31,27 +33,50 @@ type sentence struct {
 }
 
 // NewUsageFetcher creates a new usage fetcher.
-func NewUsageFetcher(dbPath string) (*UsageFetcher, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, err
-	}
-	// Schema for the db can be found in migrate/load.go
+func NewUsageFetcher(db *sql.DB) (*UsageFetcher, error) {
+	// Schema for the db can be found in schema/schema.go, it is filled by
+	// the load subcommand.
 	return &UsageFetcher{
 		db: db,
 	}, nil
 }
 
 type UsageExample struct {
+	// ID of the sentence in tatoeba.
+	ID           int64
 	Text         string
-	Translations []string
+	Translations []Translation
+	// Author of the sentence, empty if unknown.
+	Author string
+}
+
+// Translation of a usage example.
+type Translation struct {
+	Text string
+	// ISO 639-3 code of the language.
+	Language string
+}
+
+// URL of the sentence on tatoeba, used for the attribution.
+func (e *UsageExample) URL() string {
+	return fmt.Sprintf("https://tatoeba.org/eng/sentences/show/%d", e.ID)
+}
+
+// translationsClause returns the condition on the translations of the
+// examples for n translation languages. Translations into any language are
+// kept if there are none.
+func translationsClause(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return "AND (ts.lang IS NULL OR ts.lang IN (?" + strings.Repeat(", ?", n-1) + "))"
 }
 
-// FIXME: Too many parameters
-// language is a langugage of the word in ISO 639-3 format.
-func (u *UsageFetcher) FetchExamples(word, language string, translationLanguages map[string]bool) ([]*UsageExample, error) {
+// FetchExamples fetches usage examples for the word in the input language
+// from settings, with translations into the translation languages.
+func (u *UsageFetcher) FetchExamples(word string, settings *Settings) ([]*UsageExample, error) {
 	var tls []interface{}
-	for k, v := range translationLanguages {
+	for k, v := range settings.TranslationLanguages {
 		if v {
 			tls = append(tls, k)
 		}
@@ -59,24 +84,28 @@ func (u *UsageFetcher) FetchExamples(word, language string, translationLanguages
 	// We use Sprintf only to insert variable number of ?, so it cannot cause
 	// SQL injection.
 	q := fmt.Sprintf(`
-			SELECT DISTINCT s.text, ts.text
+			SELECT DISTINCT s.id, s.text, s.author, ts.text, ts.lang
 			FROM
-				Words
+				Words w
+			INNER JOIN
+				WordSentences ws ON ws.word_id = w.word_id
 			INNER JOIN
-				Sentences s ON Words.sentence_id = s.id
+				Sentences s ON ws.sentence_id = s.id
 			LEFT JOIN
-				Translations ON Words.sentence_id = Translations.id
+				Translations ON ws.sentence_id = Translations.id
 			LEFT JOIN
 				Sentences ts ON Translations.translation_id = ts.id
 			WHERE
-			Words.word = ?
-			AND s.lang = ?
-			AND (ts.lang IS NULL OR ts.lang IN (?%s))
+			w.word = ?
+			AND w.lang = ?
+			AND (? = 0 OR LENGTH(s.text) <= ?)
+			%s
 		-- If possible get definitions with translations first.
 		ORDER BY CASE WHEN ts.text IS NULL THEN 1 ELSE 0 END
-		LIMIT 3;`, strings.Repeat(", ?", len(tls)-1))
+		LIMIT 3;`, translationsClause(len(tls)))
 	args := append([]interface{}{
-		word, language,
+		tokenize.Normalize(word), settings.InputLanguageISO639_3,
+		settings.MaxExampleLength, settings.MaxExampleLength,
 	}, tls...)
 	rows, err := u.db.Query(q, args...)
 	if err == sql.ErrNoRows {
@@ -90,19 +119,24 @@ func (u *UsageFetcher) FetchExamples(word, language string, translationLanguages
 	var ex []*UsageExample
 	for rows.Next() {
 		var (
-			e string
-			t sql.NullString
+			id int64
+			e  string
+			a  sql.NullString
+			t  sql.NullString
+			tl sql.NullString
 		)
-		if err := rows.Scan(&e, &t); err != nil {
+		if err := rows.Scan(&id, &e, &a, &t, &tl); err != nil {
 			return nil, err
 		}
-		var tr []string
+		var tr []Translation
 		if t.Valid {
-			tr = append(tr, t.String)
+			tr = append(tr, Translation{t.String, tl.String})
 		}
 		ex = append(ex, &UsageExample{
+			ID:           id,
 			Text:         e,
 			Translations: tr,
+			Author:       a.String,
 		})
 	}
 
@@ -115,3 +149,45 @@ func (u *UsageFetcher) FetchExamples(word, language string, translationLanguages
 	// TODO: Prioritize using sentences with the most translations.
 	return ex, nil
 }
+
+// clozeBlank replaces the word in cloze sentences.
+const clozeBlank = "_____"
+
+// ClozeSentence returns a random sentence in the language with the word
+// replaced by a blank. sql.ErrNoRows is returned if there are no sentences
+// with the word.
+func (u *UsageFetcher) ClozeSentence(word, language string) (string, error) {
+	w := tokenize.Normalize(word)
+	var n int
+	row := u.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM Words w
+		INNER JOIN WordSentences ws ON ws.word_id = w.word_id
+		WHERE w.word = ? AND w.lang = ?;`,
+		w, language)
+	if err := row.Scan(&n); err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", sql.ErrNoRows
+	}
+	row = u.db.QueryRow(`
+		SELECT s.text
+		FROM
+			Words w
+		INNER JOIN
+			WordSentences ws ON ws.word_id = w.word_id
+		INNER JOIN
+			Sentences s ON ws.sentence_id = s.id
+		WHERE
+			w.word = ?
+			AND w.lang = ?
+		ORDER BY s.id
+		LIMIT 1 OFFSET ?;`,
+		w, language, randIntn(n))
+	var text string
+	if err := row.Scan(&text); err != nil {
+		return "", err
+	}
+	return tokenize.Blank(text, word, clozeBlank), nil
+}
@@ -24,48 +24,27 @@ import (
 const usageSQL = `
 	PRAGMA foreign_keys = OFF;
 
-	CREATE TABLE IF NOT EXISTS Sentences (
-		id INTEGER PRIMARY KEY,
-		lang STRING,
-		text STRING
-	);
-
-	CREATE TABLE IF NOT EXISTS Translations (
-		id INTEGER,
-		translation_id INTEGER,
-		FOREIGN KEY(id) REFERENCES Sentences(id),
-		FOREIGN KEY(translation_id) REFERENCES Sentences(id)
-	);
-	CREATE INDEX IF NOT EXISTS TranslationsIdIndex
-	ON Translations (id);
-
-	CREATE TABLE IF NOT EXISTS Words (
-		word STRING,
-		lang STRING,
-		sentence_id INTEGER,
-		FOREIGN KEY(sentence_id) REFERENCES Sentences(id)
-	);
-	CREATE INDEX IF NOT EXISTS WordLangIndex
-	ON Words (word, lang);
-	
-	INSERT OR REPLACE INTO Sentences(id, lang, text) VALUES
-		(1, "hun", "fekete kutya"),
-		(2, "hun", "fekete disznó"),
-		(3, "hun", "fekete macska fehér asztalon"),
-		(4, "hun", "fehér disznó"),
-		(5, "hun", "fehér fal"),
-		(6, "hun", "fehér haj"),
-		(7, "eng", "black dog"),
-		(8, "eng", "white pig"),
-		(9, "ukr", "чорний собака");
-	INSERT OR REPLACE INTO Words(word, lang, sentence_id) VALUES
-		("fekete", "hun", 1),
-		("fekete", "hun", 2),
-		("fekete", "hun", 3),
-		("fehér", "hun", 3),
-		("fehér", "hun", 4),
-		("fehér", "hun", 5),
-		("fehér", "hun", 6);
+	INSERT OR REPLACE INTO Sentences(id, lang, text, author) VALUES
+		(1, "hun", "fekete kutya", "alice"),
+		(2, "hun", "fekete disznó", NULL),
+		(3, "hun", "fekete macska fehér asztalon", NULL),
+		(4, "hun", "fehér disznó", NULL),
+		(5, "hun", "fehér fal", NULL),
+		(6, "hun", "fehér haj", NULL),
+		(7, "eng", "black dog", NULL),
+		(8, "eng", "white pig", NULL),
+		(9, "ukr", "чорний собака", NULL);
+	INSERT OR REPLACE INTO Words(word_id, word, lang) VALUES
+		(1, "fekete", "hun"),
+		(2, "fehér", "hun");
+	INSERT OR REPLACE INTO WordSentences(word_id, sentence_id) VALUES
+		(1, 1),
+		(1, 2),
+		(1, 3),
+		(2, 3),
+		(2, 4),
+		(2, 5),
+		(2, 6);
 	INSERT OR REPLACE INTO Translations(id, translation_id) VALUES
 		(1, 7),
 		(1, 9),
@@ -83,8 +62,11 @@ func TestUsageFetcher(t *testing.T) {
 	t.Logf("Temp dir: %q", dir)
 	defer os.RemoveAll(dir)
 
-	dbPath := filepath.Join(dir, "tmpdb")
-	uf, err := NewUsageFetcher(dbPath)
+	db, err := OpenDB(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uf, err := NewUsageFetcher(db)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -94,11 +76,7 @@ func TestUsageFetcher(t *testing.T) {
 
 	for _, word := range []string{"fekete", "fehér"} {
 		t.Run(word, func(t *testing.T) {
-			ex, err := uf.FetchExamples(word, "hun", map[string]bool{
-				"eng": true,
-				"rus": true,
-				"ukr": true,
-			})
+			ex, err := uf.FetchExamples(word, DefaultSettings())
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -110,7 +88,39 @@ func TestUsageFetcher(t *testing.T) {
 				if !strings.Contains(e.Text, word) {
 					t.Errorf("%q doesn't contain query word", e)
 				}
+				if e.ID == 1 && e.Author != "alice" {
+					t.Errorf("%v: got author %q want %q", e, e.Author, "alice")
+				}
 			}
 		})
 	}
+
+	t.Run("NoTranslationLanguages", func(t *testing.T) {
+		s := DefaultSettings()
+		s.TranslationLanguages = map[string]bool{"eng": false}
+		ex, err := uf.FetchExamples("fekete", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ex) == 0 {
+			t.Errorf("got no examples")
+		}
+	})
+
+	t.Run("MaxExampleLength", func(t *testing.T) {
+		s := DefaultSettings()
+		s.MaxExampleLength = len("fekete disznó")
+		ex, err := uf.FetchExamples("fekete", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range ex {
+			if l := len([]rune(e.Text)); l > s.MaxExampleLength {
+				t.Errorf("%q is %d characters long; want <= %d", e.Text, l, s.MaxExampleLength)
+			}
+		}
+		if len(ex) == 0 {
+			t.Errorf("got no examples")
+		}
+	})
 }
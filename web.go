@@ -0,0 +1,359 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Read-only dashboard with the review forecast, a heatmap of reviews and a
+// searchable table of cards. Users log in with a link sent by /dashboard.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// webLoginTTL is how long a link sent by /dashboard can be used.
+	webLoginTTL = 15 * time.Minute
+	// webSessionTTL is how long the dashboard stays logged in.
+	webSessionTTL = 30 * 24 * time.Hour
+	// webSessionCookie keeps the session token in the browser.
+	webSessionCookie = "words_session"
+	// heatmapWeeks is the number of weeks shown in the heatmap.
+	heatmapWeeks = 12
+)
+
+// WebSessions keeps login links and sessions of the dashboard.
+type WebSessions struct {
+	db    *sql.DB
+	clock Clock
+	// url is the externally visible address of the dashboard.
+	url string
+}
+
+// LoginURL returns a link that logs the chat in once within webLoginTTL.
+func (w *WebSessions) LoginURL(chatID int64) (string, error) {
+	code, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.db.Exec(`
+		INSERT INTO WebLogins(code_hash, chat_id, expires_seconds)
+		VALUES($0, $1, $2)`,
+		hashToken(code), chatID, w.clock.Now().Add(webLoginTTL).Unix()); err != nil {
+		return "", fmt.Errorf("INTERNAL: saving login of chat %d: %w", chatID, err)
+	}
+	return w.url + "login?code=" + code, nil
+}
+
+// Login exchanges the code of a login link for a new session. The code can't
+// be used again. sql.ErrNoRows is returned for unknown or expired codes.
+func (w *WebSessions) Login(code string) (string, error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	now := w.clock.Now()
+	var chatID int64
+	if err := tx.QueryRow(`
+		SELECT chat_id
+		FROM WebLogins
+		WHERE code_hash = $0
+		  AND expires_seconds > $1`,
+		hashToken(code), now.Unix()).Scan(&chatID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", err
+		}
+		return "", fmt.Errorf("INTERNAL: looking up login: %w", err)
+	}
+	// Expired logins and sessions of all chats are cleaned up here, as
+	// logins are rare.
+	if _, err := tx.Exec(`DELETE FROM WebLogins WHERE code_hash = $0 OR expires_seconds <= $1`, hashToken(code), now.Unix()); err != nil {
+		return "", fmt.Errorf("INTERNAL: cleaning up logins: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM WebSessions WHERE expires_seconds <= $0`, now.Unix()); err != nil {
+		return "", fmt.Errorf("INTERNAL: cleaning up sessions: %w", err)
+	}
+	session, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO WebSessions(session_hash, chat_id, expires_seconds)
+		VALUES($0, $1, $2)`,
+		hashToken(session), chatID, now.Add(webSessionTTL).Unix()); err != nil {
+		return "", fmt.Errorf("INTERNAL: saving session of chat %d: %w", chatID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("INTERNAL: logging in chat %d: %w", chatID, err)
+	}
+	return session, nil
+}
+
+// Chat returns the chat logged in with the session, sql.ErrNoRows if the
+// session is unknown or expired.
+func (w *WebSessions) Chat(session string) (int64, error) {
+	var chatID int64
+	err := w.db.QueryRow(`
+		SELECT chat_id
+		FROM WebSessions
+		WHERE session_hash = $0
+		  AND expires_seconds > $1`,
+		hashToken(session), w.clock.Now().Unix()).Scan(&chatID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("INTERNAL: looking up session: %w", err)
+	}
+	return chatID, err
+}
+
+// heatDay is a cell of the heatmap, Level is from 0 for no reviews to 4 for
+// the busiest days.
+type heatDay struct {
+	Date   string
+	Count  int
+	Level  int
+	Future bool
+}
+
+// heatmap returns rows of weekdays starting with Monday and columns of weeks
+// ending with the one of today.
+func heatmap(days map[time.Time]int, today time.Time) [][]heatDay {
+	// Weeks start on Monday.
+	start := today.AddDate(0, 0, -(int(today.Weekday())+6)%7-7*(heatmapWeeks-1))
+	most := 0
+	for _, n := range days {
+		most = max(most, n)
+	}
+	rows := make([][]heatDay, 7)
+	for d := start; d.Before(start.AddDate(0, 0, 7*heatmapWeeks)); d = d.AddDate(0, 0, 1) {
+		h := heatDay{Date: d.Format("2006-01-02"), Count: days[d], Future: d.After(today)}
+		if h.Count > 0 {
+			h.Level = (4*h.Count + most - 1) / most
+		}
+		wd := (int(d.Weekday()) + 6) % 7
+		rows[wd] = append(rows[wd], h)
+	}
+	return rows
+}
+
+type webCard struct {
+	Word       string
+	Definition string
+	NextReview string
+}
+
+type dashboard struct {
+	T        func(string, ...interface{}) string
+	Forecast *DueForecast
+	Weeks    int
+	Heatmap  [][]heatDay
+	Query    string
+	Cards    []webCard
+	Total    int
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Words</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 1em auto; padding: 0 1em; }
+table.heat { border-spacing: 3px; }
+table.heat td { width: 12px; height: 12px; padding: 0; }
+.l0 { background: #ebedf0; } .l1 { background: #c6e48b; } .l2 { background: #7bc96f; }
+.l3 { background: #239a3b; } .l4 { background: #196127; }
+table.cards { border-collapse: collapse; width: 100%; }
+table.cards th, table.cards td { border-bottom: 1px solid #ddd; padding: .3em; text-align: left; vertical-align: top; }
+td.definition { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h2>{{call .T "Your review forecast:"}}</h2>
+<p>
+{{call .T "Due now: %d" .Forecast.Now}}<br>
+{{call .T "Later today: %d" .Forecast.LaterToday}}<br>
+{{call .T "Tomorrow: %d" .Forecast.Tomorrow}}<br>
+{{call .T "Next 7 days: %d" .Forecast.Week}}
+</p>
+<h2>{{call .T "Reviews in the last %d weeks" .Weeks}}</h2>
+<table class="heat">
+{{range .Heatmap}}<tr>{{range .}}{{if .Future}}<td></td>{{else}}<td class="l{{.Level}}" title="{{.Date}}: {{.Count}}"></td>{{end}}{{end}}</tr>
+{{end}}</table>
+<h2>{{call .T "Cards: %d" .Total}}</h2>
+<form><input name="q" value="{{.Query}}" placeholder="{{call .T "Search"}}"></form>
+<table class="cards">
+<tr><th>{{call .T "Word"}}</th><th>{{call .T "Definition"}}</th><th>{{call .T "Next review"}}</th></tr>
+{{range .Cards}}<tr><td>{{.Word}}</td><td class="definition">{{.Definition}}</td><td>{{.NextReview}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// loginTemplate confirms the login, so that opening the link, e.g. by a link
+// preview or a mail scanner, doesn't spend the code.
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Words</title>
+</head>
+<body>
+<form method="post" action="/web/login">
+<input type="hidden" name="code" value="{{.}}">
+<button type="submit">Open the dashboard</button>
+</form>
+</body>
+</html>
+`))
+
+// WebHandler serves the dashboard under /web/ and logs in with the links sent
+// by /dashboard at /web/login: GET shows a confirmation, POST spends the code.
+func WebHandler(s *State) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/web/login", func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			if err := loginTemplate.Execute(w, req.URL.Query().Get("code")); err != nil {
+				slog.Error("Web: rendering login", "err", err)
+			}
+			return
+		case http.MethodPost:
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		session, err := s.Web.Login(req.PostFormValue("code"))
+		if err == sql.ErrNoRows {
+			http.Error(w, "The link has expired, send /dashboard to the bot for a new one.", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.Error("Web: login", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     webSessionCookie,
+			Value:    session,
+			Path:     "/web/",
+			MaxAge:   int(webSessionTTL.Seconds()),
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, req, "/web/", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/web/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/web/" {
+			http.NotFound(w, req)
+			return
+		}
+		c, err := req.Cookie(webSessionCookie)
+		if err != nil {
+			http.Error(w, "Send /dashboard to the bot to log in.", http.StatusUnauthorized)
+			return
+		}
+		chatID, err := s.Web.Chat(c.Value)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Send /dashboard to the bot to log in.", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.Error("Web", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		d, err := dashboardOf(s, chatID, req.URL.Query().Get("q"))
+		if err != nil {
+			slog.Error("Web", "chat_id", chatID, "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardTemplate.Execute(w, d); err != nil {
+			slog.Error("Web: rendering dashboard", "chat_id", chatID, "err", err)
+		}
+	})
+	return mux
+}
+
+// dashboardOf collects the dashboard of the chat, cards are filtered by the
+// query in their words and definitions.
+func dashboardOf(s *State, chatID int64, query string) (*dashboard, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return nil, err
+	}
+	loc := settings.DayLocation()
+	now := s.Clock.Now()
+	f, err := s.Repetitions.Forecast(chatID, now, loc)
+	if err != nil {
+		return nil, err
+	}
+	today := localDay(now, loc)
+	days, err := s.Repetitions.ReviewsByDay(chatID, today.AddDate(0, 0, -7*heatmapWeeks), loc)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := s.Repetitions.Cards(chatID)
+	if err != nil {
+		return nil, err
+	}
+	d := &dashboard{
+		T:        NewLocalizer(settings.BotLanguage).T,
+		Forecast: f,
+		Weeks:    heatmapWeeks,
+		Heatmap:  heatmap(days, today),
+		Query:    query,
+		Total:    len(cs),
+	}
+	q := strings.ToLower(strings.TrimSpace(query))
+	for _, c := range cs {
+		if q != "" && !strings.Contains(strings.ToLower(c.Word), q) && !strings.Contains(strings.ToLower(c.Definition), q) {
+			continue
+		}
+		// Unlike the days above, times are shown on the user's clock.
+		d.Cards = append(d.Cards, webCard{c.Word, c.Definition, c.NextReview.In(settings.Location()).Format("2006-01-02 15:04")})
+	}
+	return d, nil
+}
+
+// dashboardReply sends a link logging into the dashboard.
+func dashboardReply(s *State, chatID int64) error {
+	l := s.L(chatID)
+	if s.Web == nil {
+		return s.Telegram.SendTextMessage(chatID, l.T("The dashboard isn't enabled on this bot."))
+	}
+	url, err := s.Web.LoginURL(chatID)
+	if err != nil {
+		return err
+	}
+	// The code must not be spent by telegram fetching the preview.
+	return s.Telegram.SendMessage(&MessageReply{
+		ChatId:             chatID,
+		Text:               l.T("Open your dashboard within %d minutes, the link works once:\n%s", int(webLoginTTL.Minutes()), url),
+		LinkPreviewOptions: &LinkPreviewOptions{IsDisabled: true},
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeatmap(t *testing.T) {
+	// Wednesday.
+	today := time.Date(2020, 6, 3, 0, 0, 0, 0, time.UTC)
+	days := map[time.Time]int{
+		today:                   4,
+		today.AddDate(0, 0, -1): 1,
+		today.AddDate(0, 0, -2): 2,
+	}
+	rows := heatmap(days, today)
+	if len(rows) != 7 {
+		t.Fatalf("got %d rows want 7", len(rows))
+	}
+	for i, r := range rows {
+		if len(r) != heatmapWeeks {
+			t.Errorf("row %d: got %d weeks want %d", i, len(r), heatmapWeeks)
+		}
+	}
+	last := heatmapWeeks - 1
+	for _, tc := range []struct {
+		weekday int
+		want    heatDay
+	}{
+		{0, heatDay{Date: "2020-06-01", Count: 2, Level: 2}},
+		{1, heatDay{Date: "2020-06-02", Count: 1, Level: 1}},
+		{2, heatDay{Date: "2020-06-03", Count: 4, Level: 4}},
+		{3, heatDay{Date: "2020-06-04", Future: true}},
+	} {
+		if got := rows[tc.weekday][last]; got != tc.want {
+			t.Errorf("weekday %d: got %+v want %+v", tc.weekday, got, tc.want)
+		}
+	}
+	if got, want := rows[0][0].Date, "2020-03-16"; got != want {
+		t.Errorf("first day: got %s want %s", got, want)
+	}
+}
+
+func TestWebHandler(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	clock := newFakeClock(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+	r, err := NewRepetition(db, []time.Duration{time.Hour, 24 * time.Hour}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := &WebSessions{db: db, clock: clock, url: "https://words.example.com/web/"}
+	s := &State{Clients: &Clients{Repetitions: r, Settings: sc, Web: web, Clock: clock}, Log: slog.Default()}
+	const chatID int64 = 1
+	for _, w := range []string{"fekete", "fehér"} {
+		if err := r.Save(chatID, w, w+" <b>definition</b>", "hun"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := WebHandler(s)
+	get := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/web/"); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /web/ without session: got status %d want %d", w.Code, http.StatusUnauthorized)
+	}
+	link, err := web.LoginURL(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(link, web.url) {
+		t.Errorf("LoginURL: got %s want prefix %s", link, web.url)
+	}
+	post := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/web/login", strings.NewReader(url.Values{"code": {code}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	// Opening the link, e.g. for a preview, only asks to confirm.
+	code := u.Query().Get("code")
+	for i := 0; i < 2; i++ {
+		w := get(u.RequestURI())
+		if w.Code != http.StatusOK || len(w.Result().Cookies()) != 0 || !strings.Contains(w.Body.String(), code) {
+			t.Fatalf("GET %s: got status %d, cookies %v want the confirmation", u.RequestURI(), w.Code, w.Result().Cookies())
+		}
+	}
+	login := post(code)
+	if login.Code != http.StatusSeeOther {
+		t.Fatalf("POST /web/login: got status %d want %d", login.Code, http.StatusSeeOther)
+	}
+	cookies := login.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != webSessionCookie {
+		t.Fatalf("login cookies: got %v", cookies)
+	}
+	// Links work once.
+	if w := post(code); w.Code != http.StatusUnauthorized {
+		t.Errorf("POST /web/login again: got status %d want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	settings, err := sc.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.TimeZone = "UTC+2"
+	settings.DayStartHour = 4
+	if err := sc.Set(chatID, settings); err != nil {
+		t.Fatal(err)
+	}
+	w := get("/web/?q=fek", cookies[0])
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /web/: got status %d", w.Code)
+	}
+	body := w.Body.String()
+	// The next review at 13:00 UTC isn't shifted by DayStartHour.
+	for _, want := range []string{"Due now: 0", "Cards: 2", "fekete &lt;b&gt;definition&lt;/b&gt;", "2020-06-01 15:00"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET /web/?q=fek: %q is missing in\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "fehér") {
+		t.Errorf("GET /web/?q=fek: got fehér, which doesn't match the query")
+	}
+
+	clock.Advance(webSessionTTL)
+	if w := get("/web/", cookies[0]); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /web/ with expired session: got status %d want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -19,20 +19,71 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/net/html"
+
+	"words/stem"
 )
 
 var wikiUrlPrefix = "https://en.wiktionary.org/w/api.php"
 
+// SpeechPart is a part of speech with definitions on wiktionary.
+type SpeechPart string
+
+const (
+	Noun        SpeechPart = "Noun"
+	Verb        SpeechPart = "Verb"
+	Adjective   SpeechPart = "Adjective"
+	Adverb      SpeechPart = "Adverb"
+	Pronoun     SpeechPart = "Pronoun"
+	Preposition SpeechPart = "Preposition"
+	Conjunction SpeechPart = "Conjunction"
+)
+
+// speechParts are the parts of speech with definitions that are extracted,
+// other sections are ignored.
+var speechParts = []SpeechPart{Noun, Verb, Adjective, Adverb, Pronoun, Preposition, Conjunction}
+
+// speechPart returns the part of speech of the section, e.g. Noun for "Noun"
+// and "Noun_2". ok is false for other sections.
+func speechPart(section string) (_ SpeechPart, ok bool) {
+	for _, p := range speechParts {
+		if strings.HasPrefix(section, string(p)) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// WikiDefinition is a single sense of the word.
 type WikiDefinition struct {
 	Word       string
-	Definition string
-	SpeechPart string // FIXME: Can be an enum
+	SpeechPart SpeechPart
+	// Labels qualify the sense, e.g. "figuratively" or "colloquial".
+	Labels []string
+	// Gloss is the definition itself, without labels and examples.
+	Gloss string
+	// Examples of usage with their translations, in the order they are on
+	// the page.
+	Examples []string
+	// Notes are the lines about related terms, e.g. "Synonym: község".
+	Notes []string
+	// Gender of the noun as abbreviated in the headword line, e.g. "m" or
+	// "m or n", empty if it's not there.
+	Gender string
+	// Plural form of the noun from the headword line, if any.
+	Plural string
+	// Form is the inflected word that was looked up if the definitions are
+	// of its stem, see fetchWikiStem.
+	Form string
 	// ?? Synonyms   []string
 	// ?? Antonyms   []string
 	// ?? Etymology
@@ -52,22 +103,12 @@ func (w WikiParser) ParseWiki(text string) ([]*WikiDefinition, error) {
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("subsections: %v", s)
-
-	whitelisted := func(s string) bool {
-		whitelist := []string{"Noun", "Verb", "Adjective", "Adverb", "Pronoun", "Preposition", "Conjunction"}
-		for _, w := range whitelist {
-			if strings.HasPrefix(s, w) {
-				return true
-			}
-		}
-		return false
-	}
+	slog.Debug("Wiki subsections", "subsections", s)
 
 	var defs []*WikiDefinition
 	for _, n := range s[w.InputLanguage] {
-		if !whitelisted(n) {
-			log.Printf("Ignoring %q, not whitelisted", n)
+		if _, ok := speechPart(n); !ok {
+			slog.Debug("Ignoring section, not whitelisted", "section", n)
 			continue
 		}
 		r := m[n]
@@ -79,6 +120,43 @@ func (w WikiParser) ParseWiki(text string) ([]*WikiDefinition, error) {
 	return defs, nil
 }
 
+// wikiRedirect returns the title of the page that the page in h redirects to,
+// empty if it's not a redirect.
+func wikiRedirect(h string) string {
+	doc, err := html.Parse(strings.NewReader(h))
+	if err != nil {
+		return ""
+	}
+	hasClass := func(n *html.Node, c string) bool {
+		for _, a := range n.Attr {
+			if a.Key == "class" && a.Val == c {
+				return true
+			}
+		}
+		return false
+	}
+	var f func(n *html.Node, inRedirect bool) string
+	f = func(n *html.Node, inRedirect bool) string {
+		if n.Type == html.ElementNode {
+			inRedirect = inRedirect || hasClass(n, "redirectText")
+			if inRedirect && n.Data == "a" {
+				for _, a := range n.Attr {
+					if a.Key == "title" {
+						return a.Val
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if t := f(c, inRedirect); t != "" {
+				return t
+			}
+		}
+		return ""
+	}
+	return f(doc, false)
+}
+
 // extractDefs extracts what it can from one chunk of text corresponding to
 // definition.
 // It assume following structure:
@@ -91,32 +169,89 @@ func (w WikiParser) ParseWiki(text string) ([]*WikiDefinition, error) {
 func (WikiParser) extractDefs(text string) []*WikiDefinition {
 	lines := strings.Split(text, "\n\n")
 	if len(lines) < 2 {
-		log.Printf("ERROR parsing %s: too few lines", text)
+		slog.Error("Parsing wiki definition: too few lines", "text", text)
 		return nil
 	}
 	pl := strings.Split(lines[0], "\n")
 	if len(pl) < 2 {
-		log.Printf("ERROR parsing word and part of speech %s: too few lines", lines[0])
+		slog.Error("Parsing word and part of speech: too few lines", "text", lines[0])
 	}
-	p := pl[0]
-	var w string
-	if ws := strings.Split(pl[1], " "); len(ws) > 0 {
-		w = ws[0]
+	p, _ := speechPart(pl[0])
+	var w, g, pp string
+	if len(pl) >= 2 {
+		if ws := strings.Split(pl[1], " "); len(ws) > 0 {
+			w = ws[0]
+		}
+		if p == Noun {
+			g, pp = parseHeadword(pl[1])
+		}
 	}
 
 	var d []*WikiDefinition
 	for _, ll := range lines[1:] {
 		if s := strings.TrimSpace(ll); len(s) > 0 {
-			d = append(d, &WikiDefinition{
-				Word:       w,
-				SpeechPart: p,
-				Definition: s,
-			})
+			def := parseSense(s)
+			def.Word, def.SpeechPart, def.Gender, def.Plural = w, p, g, pp
+			d = append(d, def)
+		}
+	}
+	return d
+}
+
+// note matches the lines about related terms, e.g. "Synonyms: község".
+var note = regexp.MustCompile(`^[A-Z][a-z ]+: `)
+
+// parseSense splits the text of a single sense into its parts. The first line
+// is the gloss, optionally starting with the labels, e.g. "(figuratively,
+// colloquial) tragic". The rest are examples and notes.
+func parseSense(text string) *WikiDefinition {
+	lines := strings.Split(text, "\n")
+	d := &WikiDefinition{Gloss: strings.TrimSpace(lines[0])}
+	if strings.HasPrefix(d.Gloss, "(") {
+		if labels, gloss, ok := strings.Cut(d.Gloss[1:], ")"); ok && strings.TrimSpace(gloss) != "" {
+			for _, l := range strings.Split(labels, ",") {
+				d.Labels = append(d.Labels, strings.TrimSpace(l))
+			}
+			d.Gloss = strings.TrimSpace(gloss)
+		}
+	}
+	for _, l := range lines[1:] {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+		case note.MatchString(l):
+			d.Notes = append(d.Notes, l)
+		default:
+			d.Examples = append(d.Examples, l)
 		}
 	}
 	return d
 }
 
+// genders are the abbreviations of grammatical genders in headword lines.
+var genders = map[string]bool{"m": true, "f": true, "n": true, "c": true}
+
+// parseHeadword extracts the gender and the plural form from the headword line
+// of a noun, e.g. "Hund m (strong, genitive Hundes or Hunds, plural Hunde)".
+func parseHeadword(line string) (gender, plural string) {
+	head, info, _ := strings.Cut(line, "(")
+	var gs []string
+	for _, f := range strings.Fields(head)[1:] {
+		if genders[f] {
+			gs = append(gs, f)
+		}
+	}
+	gender = strings.Join(gs, " or ")
+	info, _, _ = strings.Cut(info, ")")
+	for _, f := range strings.Split(info, ",") {
+		if p, ok := strings.CutPrefix(strings.TrimSpace(f), "plural "); ok {
+			plural = strings.TrimSpace(p)
+			break
+		}
+	}
+	return gender, plural
+}
+
 // FIXME: Remove this nonsence probably?
 const DebugWikiParser = false
 
@@ -128,7 +263,7 @@ func (w WikiParser) parseWikiHTML(h string) (ms map[string]string, subs map[stri
 		if err = ioutil.WriteFile(file, []byte(h), 0644); err != nil {
 			return
 		}
-		log.Printf("Written debug html to %s", file)
+		slog.Debug("Written debug html", "file", file)
 	}
 
 	doc, err := html.Parse(strings.NewReader(h))
@@ -292,21 +427,104 @@ func (e *Extractor) Extract1(f string, i interface{}) interface{} {
 	return r[0]
 }
 
-// FIXME: Might make sense to have additional information from which language
-// wikipedia to extract data.
-// Queries, parses one by one result until some definitions are found.
-func FetchWikiDefinition(parser WikiParser, c *http.Client, w string) ([]*WikiDefinition, error) {
-	get := func(p map[string]string) (_ string, err error) {
+// wikiUserAgent identifies the bot, as required by the User-Agent policy of
+// wikimedia.
+const wikiUserAgent = "words-telegram-bot (https://github.com/attila0x2A/words-telegram-bot)"
+
+// wikiMaxLag makes the API refuse requests while its database replicas lag by
+// more seconds than that, so that the bot backs off when wiktionary is under
+// load.
+const wikiMaxLag = "5"
+
+// maxWikiRetries is the number of retries of a request that was refused
+// because of the load.
+const maxWikiRetries = 3
+
+// maxWikiRetryAfter caps the wait before a retry requested by the API.
+const maxWikiRetryAfter = 10 * time.Second
+
+// wikiInterval is the minimum interval between requests to wiktionary, the
+// API asks for requests in series rather than in parallel.
+var wikiInterval = 100 * time.Millisecond
+
+// wikiThrottle spaces out all requests to wiktionary, see wikiInterval.
+var wikiThrottle struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// waitForWiki blocks until the next request to wiktionary may be sent.
+func waitForWiki() {
+	wikiThrottle.mu.Lock()
+	now := time.Now()
+	start := now
+	if wikiThrottle.next.After(now) {
+		start = wikiThrottle.next
+	}
+	wikiThrottle.next = start.Add(wikiInterval)
+	wikiThrottle.mu.Unlock()
+	time.Sleep(start.Sub(now))
+}
+
+// delayWiki postpones all requests to wiktionary by d.
+func delayWiki(d time.Duration) {
+	wikiThrottle.mu.Lock()
+	defer wikiThrottle.mu.Unlock()
+	if t := time.Now().Add(d); t.After(wikiThrottle.next) {
+		wikiThrottle.next = t
+	}
+}
+
+// retryAfter returns the wait requested in the Retry-After header of the
+// response.
+func retryAfter(resp *http.Response) time.Duration {
+	d := time.Second
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+		d = time.Duration(s) * time.Second
+	}
+	return min(d, maxWikiRetryAfter)
+}
+
+// wikiError is the error returned by the API in the response body.
+type wikiError struct {
+	Error *struct {
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+// errorCode returns the code of the API error in the body, empty if there is
+// none.
+func (w *wikiError) errorCode(body string) string {
+	if json.Unmarshal([]byte(body), w) != nil || w.Error == nil {
+		return ""
+	}
+	return w.Error.Code
+}
+
+// wikiBreaker skips wiktionary while it keeps failing.
+var wikiBreaker = newBreaker("wiktionary", SystemClock{})
+
+// wikiGet sends the query to the API, retrying it when wiktionary is
+// overloaded. Failed queries count towards wikiBreaker.
+func wikiGet(c *http.Client, p map[string]string) (body string, err error) {
+	if err := wikiBreaker.Allow(); err != nil {
+		return "", err
+	}
+	defer func() { wikiBreaker.Record(err) }()
+	v := url.Values{}
+	for k, pp := range p {
+		v.Add(k, pp)
+	}
+	v.Set("maxlag", wikiMaxLag)
+	for attempt := 0; ; attempt++ {
+		waitForWiki()
 		q, err := http.NewRequest("GET", wikiUrlPrefix, nil)
 		if err != nil {
-			return
-		}
-		v := url.Values{}
-		for k, pp := range p {
-			v.Add(k, pp)
+			return "", err
 		}
 		q.URL.RawQuery = v.Encode()
-		log.Printf("DEBUG RawQuery: %q", q.URL.RawQuery)
+		q.Header.Set("User-Agent", wikiUserAgent)
+		slog.Debug("Querying wiki", "query", q.URL.RawQuery)
 
 		resp, err := c.Do(q)
 		if err != nil {
@@ -314,13 +532,119 @@ func FetchWikiDefinition(parser WikiParser, c *http.Client, w string) ([]*WikiDe
 		}
 		// TODO: This is not the most optimal way to decode it. Extra pass over bytes for json parsing.
 		b := new(bytes.Buffer)
-		if _, err = b.ReadFrom(resp.Body); err != nil {
-			return
+		_, err = b.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		overloaded := resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode == http.StatusServiceUnavailable ||
+			new(wikiError).errorCode(b.String()) == "maxlag"
+		if !overloaded && resp.StatusCode >= http.StatusInternalServerError {
+			return "", fmt.Errorf("%s: wiktionary returned %s", q.URL.RawQuery, resp.Status)
+		}
+		if !overloaded {
+			return b.String(), nil
+		}
+		if attempt == maxWikiRetries {
+			return "", fmt.Errorf("%s: wiktionary is overloaded, gave up after %d retries", q.URL.RawQuery, attempt)
+		}
+		d := retryAfter(resp)
+		slog.Warn("Wiktionary is overloaded, retrying", "status", resp.StatusCode, "after", d)
+		delayWiki(d)
+	}
+}
+
+// fetchWikiPage returns the definitions from the page with the title, none if
+// there is no such page. Redirects are followed once, if follow is true.
+func fetchWikiPage(parser WikiParser, c *http.Client, title string, follow bool) ([]*WikiDefinition, error) {
+	slog.Debug("Considering page", "title", title)
+	// Extract all the section.
+	resp, err := wikiGet(c, map[string]string{
+		"action":             "parse",
+		"format":             "json",
+		"prop":               "text",
+		"disableeditsection": "true",
+		"sectionpreview":     "true",
+		"page":               title,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if code := new(wikiError).errorCode(resp); code != "" {
+		slog.Debug("No page", "title", title, "code", code)
+		return nil, nil
+	}
+	var i interface{}
+	if err := json.Unmarshal([]byte(resp), &i); err != nil {
+		return nil, err
+	}
+	// TODO: Improve error handling. Bad requests happen, panic is bad.
+	// FIXME: Should these be an explicit maybe with error checks on access?
+	e := new(Extractor)
+	text := e.Extract1("parse.text.*", i)
+	if e.err != nil {
+		return nil, e.err
+	}
+	if target := wikiRedirect(text.(string)); target != "" {
+		if !follow {
+			slog.Warn("Not following a double redirect", "title", title, "target", target)
+			return nil, nil
+		}
+		slog.Debug("Following redirect", "title", title, "target", target)
+		return fetchWikiPage(parser, c, target, false)
+	}
+	return parser.ParseWiki(text.(string))
+}
+
+// maxStemLookups limits the pages tried for the stems of an inflected word,
+// each of them is a request.
+const maxStemLookups = 5
+
+// fetchWikiStem returns the definitions from the page of the first stem of
+// the Hungarian word that has one, see stem.Hungarian. Wiktionary has pages
+// only for some of the inflected forms. Nothing is returned for other
+// languages.
+func fetchWikiStem(parser WikiParser, c *http.Client, w string) ([]*WikiDefinition, error) {
+	if parser.InputLanguage != "Hungarian" {
+		return nil, nil
+	}
+	stems := stem.Hungarian(w)
+	if len(stems) > maxStemLookups {
+		stems = stems[:maxStemLookups]
+	}
+	for _, s := range stems {
+		defs, err := fetchWikiPage(parser, c, s, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(defs) > 0 {
+			slog.Debug("Found the stem", "word", w, "stem", s)
+			for _, d := range defs {
+				d.Form = w
+			}
+			return defs, nil
 		}
-		return b.String(), nil
+	}
+	return nil, nil
+}
+
+// FIXME: Might make sense to have additional information from which language
+// wikipedia to extract data.
+// Tries the page with the word as the title first, since it's there most of the
+// time. Otherwise queries, parses one by one result until some definitions are
+// found.
+func FetchWikiDefinition(parser WikiParser, c *http.Client, w string) ([]*WikiDefinition, error) {
+	defs, err := fetchWikiPage(parser, c, w, true)
+	if err != nil || len(defs) > 0 {
+		return defs, err
+	}
+	defs, err = fetchWikiStem(parser, c, w)
+	if err != nil || len(defs) > 0 {
+		return defs, err
 	}
 
-	resp, err := get(map[string]string{
+	resp, err := wikiGet(c, map[string]string{
 		"action":   "query",
 		"format":   "json",
 		"list":     "search",
@@ -329,7 +653,7 @@ func FetchWikiDefinition(parser WikiParser, c *http.Client, w string) ([]*WikiDe
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("DEBUG: Search results: %s", resp)
+	slog.Debug("Wiki search results", "results", resp)
 	var i interface{}
 	if err := json.Unmarshal([]byte(resp), &i); err != nil {
 		return nil, err
@@ -337,43 +661,20 @@ func FetchWikiDefinition(parser WikiParser, c *http.Client, w string) ([]*WikiDe
 	e := new(Extractor)
 	ti := e.Extract("query.search.title", i)
 	if len(ti) == 0 || e.err != nil {
-		log.Printf("DEBUG: query.search.title : %v", err)
+		slog.Debug("No query.search.title", "err", e.err)
 		return nil, errors.New("No search results")
 	}
 
-	var defs []*WikiDefinition
 	for _, tti := range ti {
 		title := tti.(string)
-		log.Printf("DEBUG: Considering search result: %s", title)
-
-		// Extract all the section.
-		resp, err = get(map[string]string{
-			"action":             "parse",
-			"format":             "json",
-			"prop":               "text",
-			"disableeditsection": "true",
-			"sectionpreview":     "true",
-			"page":               title,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		if err := json.Unmarshal([]byte(resp), &i); err != nil {
-			return nil, err
-		}
-
-		// TODO: Improve error handling. Bad requests happen, panic is bad.
-		// FIXME: Should these be an explicit maybe with error checks on access?
-		text := e.Extract1("parse.text.*", i).(string)
-		if e.err != nil {
-			return nil, e.err
+		if title == w {
+			// Already tried.
+			continue
 		}
-		wd, err := parser.ParseWiki(text)
+		defs, err = fetchWikiPage(parser, c, title, true)
 		if err != nil {
 			return nil, err
 		}
-		defs = append(defs, wd...)
 		if len(defs) > 0 {
 			break
 		}
@@ -14,14 +14,21 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 )
 
 func TestParseWiki(t *testing.T) {
-	f, err := ioutil.ReadFile("testdata/test.html")
+	f, err := ioutil.ReadFile("testdata/wiki/fekete.html")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,58 +43,67 @@ func TestParseWiki(t *testing.T) {
 	want := []*WikiDefinition{
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "black (absorbing all light and reflecting none)",
+			Gloss:      "black (absorbing all light and reflecting none)",
 			SpeechPart: "Adjective",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "black (pertaining to a dark-skinned ethnic group)",
+			Gloss:      "black (pertaining to a dark-skinned ethnic group)",
 			SpeechPart: "Adjective",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "black (darker than other varieties, especially of fruits and drinks)",
+			Gloss:      "black (darker than other varieties, especially of fruits and drinks)",
 			SpeechPart: "Adjective",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "(figuratively) tragic, mournful, black (causing great sadness or suffering)",
+			Gloss:      "tragic, mournful, black (causing great sadness or suffering)",
+			Labels:     []string{"figuratively"},
 			SpeechPart: "Adjective",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "(figuratively) black (derived from evil forces, or performed with the intention of doing harm)",
+			Gloss:      "black (derived from evil forces, or performed with the intention of doing harm)",
+			Labels:     []string{"figuratively"},
 			SpeechPart: "Adjective",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "(figuratively, in compounds) illegal (contrary to or forbidden by criminal law)",
+			Gloss:      "illegal (contrary to or forbidden by criminal law)",
+			Labels:     []string{"figuratively", "in compounds"},
 			SpeechPart: "Adjective",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "black (color perceived in the absence of light)",
+			Gloss:      "black (color perceived in the absence of light)",
 			SpeechPart: "Noun",
+			Plural:     "feketék",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "black clothes (especially as mourning attire)",
+			Gloss:      "black clothes (especially as mourning attire)",
 			SpeechPart: "Noun",
+			Plural:     "feketék",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "black person (member of a dark-skinned ethnic group)",
+			Gloss:      "black person (member of a dark-skinned ethnic group)",
 			SpeechPart: "Noun",
+			Plural:     "feketék",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "dark-haired person (especially a woman with dark hair)",
+			Gloss:      "dark-haired person (especially a woman with dark hair)",
 			SpeechPart: "Noun",
+			Plural:     "feketék",
 		},
 		&WikiDefinition{
 			Word:       "fekete",
-			Definition: "(colloquial) black coffee (coffee without cream or milk)",
+			Gloss:      "black coffee (coffee without cream or milk)",
+			Labels:     []string{"colloquial"},
 			SpeechPart: "Noun",
+			Plural:     "feketék",
 		},
 	}
 
@@ -95,3 +111,281 @@ func TestParseWiki(t *testing.T) {
 		t.Errorf("ParseWiki: (-got +want):\n%s", diff)
 	}
 }
+
+func TestParseHeadword(t *testing.T) {
+	for _, tc := range []struct {
+		line   string
+		gender string
+		plural string
+	}{
+		{"falu (plural falvak)", "", "falvak"},
+		{"Hund m (strong, genitive Hundes or Hunds, plural Hunde, diminutive Hündchen n)", "m", "Hunde"},
+		{"Joghurt m or n (strong, genitive Joghurts, plural Joghurts)", "m or n", "Joghurts"},
+		{"Leute pl", "", ""},
+		{"fekete", "", ""},
+	} {
+		gender, plural := parseHeadword(tc.line)
+		if gender != tc.gender || plural != tc.plural {
+			t.Errorf("parseHeadword(%q) = %q, %q; want %q, %q", tc.line, gender, plural, tc.gender, tc.plural)
+		}
+	}
+}
+
+var recordWiki = flag.Bool("record_wiki", false, "If true the fake wiki forwards requests to wiktionary and saves the fetched pages to testdata/wiki.")
+
+// startFakeWiki serves the api of wiktionary from recorded pages in
+// testdata/wiki, so that tests don't depend on the internet and on edits of
+// the real pages. Only words with a recorded page are found.
+//
+// With -record_wiki the tests run against wiktionary instead, which records
+// the pages of new words and shows how the parsing copes with the current
+// ones, e.g. go test -run Wiki -record_wiki.
+func startFakeWiki(t *testing.T) *httptest.Server {
+	page := func(title string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join("testdata", "wiki", filepath.Base(title)+".html"))
+	}
+	live := wikiUrlPrefix
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.UserAgent() != wikiUserAgent || q.Get("maxlag") == "" {
+			t.Errorf("fake wiki: request without User-Agent or maxlag: %q", r.URL.RawQuery)
+		}
+		if *recordWiki {
+			if err := recordWikiPage(live, w, r); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+		var res interface{}
+		switch q.Get("action") {
+		case "query":
+			results := []map[string]string{}
+			if _, err := page(q.Get("srsearch")); err == nil {
+				results = append(results, map[string]string{"title": q.Get("srsearch")})
+			}
+			res = map[string]interface{}{"query": map[string]interface{}{"search": results}}
+		case "parse":
+			b, err := page(q.Get("page"))
+			if os.IsNotExist(err) {
+				// The API responds with an error in the body.
+				res = map[string]interface{}{"error": map[string]string{"code": "missingtitle"}}
+				break
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res = map[string]interface{}{"parse": map[string]interface{}{"text": map[string]string{"*": string(b)}}}
+		default:
+			t.Errorf("fake wiki: unexpected query %q", r.URL.RawQuery)
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			t.Error(err)
+		}
+	}))
+	prefix, interval := wikiUrlPrefix, wikiInterval
+	wikiUrlPrefix = s.URL
+	if !*recordWiki {
+		wikiInterval = 0
+	}
+	t.Cleanup(func() {
+		wikiUrlPrefix, wikiInterval = prefix, interval
+		s.Close()
+	})
+	return s
+}
+
+// recordWikiPage forwards the request to wiktionary at prefix and writes its
+// response to w. Parsed pages are saved to testdata/wiki, missing ones aren't.
+func recordWikiPage(prefix string, w http.ResponseWriter, r *http.Request) error {
+	req, err := http.NewRequest("GET", prefix+"?"+r.URL.RawQuery, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", wikiUserAgent)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if d := res.Header.Get("Retry-After"); d != "" {
+		w.Header().Set("Retry-After", d)
+	}
+	w.WriteHeader(res.StatusCode)
+	w.Write(b)
+
+	q := r.URL.Query()
+	if res.StatusCode != http.StatusOK || q.Get("action") != "parse" {
+		return nil
+	}
+	var parsed struct {
+		Parse struct {
+			Text struct {
+				HTML string `json:"*"`
+			} `json:"text"`
+		} `json:"parse"`
+	}
+	if err := json.Unmarshal(b, &parsed); err != nil || parsed.Parse.Text.HTML == "" {
+		// Errors, e.g. missingtitle, are in the body.
+		return nil
+	}
+	f := filepath.Join("testdata", "wiki", filepath.Base(q.Get("page"))+".html")
+	return ioutil.WriteFile(f, []byte(parsed.Parse.Text.HTML), 0644)
+}
+
+func TestFetchWikiDefinition(t *testing.T) {
+	s := startFakeWiki(t)
+	p := WikiParser{InputLanguage: "Hungarian"}
+	got, err := FetchWikiDefinition(p, s.Client(), "falu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*WikiDefinition{{
+		Word:       "falu",
+		SpeechPart: "Noun",
+		Plural:     "falvak",
+		Gloss:      "village",
+		Examples:   []string{"A világ egy falu.The world is a village."},
+		Notes: []string{
+			"Synonym: község",
+			"Hypernyms: település, helység",
+			"Hyponyms: törpefalu (<100), aprófalu (100–500), kisfalu (500–1,000), középfalu (1,000–2,000), nagyfalu (2,000–5,000), óriásfalu (5,000–10,000 of population)",
+			"Coordinate term: város (town or city)",
+		},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FetchWikiDefinition (-want +got):\n%s", diff)
+	}
+
+	if _, err := FetchWikiDefinition(p, s.Client(), "oijasdki"); err == nil {
+		t.Error("FetchWikiDefinition of an unknown word succeeded")
+	}
+}
+
+func TestWikiGetRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// Number of requests refused before the successful one.
+		refused int
+		refuse  func(w http.ResponseWriter)
+		wantErr bool
+	}{
+		{"maxlag", 2, func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "0")
+			fmt.Fprint(w, `{"error": {"code": "maxlag"}}`)
+		}, false},
+		{"too many requests", 1, func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}, false},
+		{"gives up", maxWikiRetries + 1, func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, true},
+		{"server error", 1, func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tc.refused {
+					tc.refuse(w)
+					return
+				}
+				fmt.Fprint(w, `{"ok": true}`)
+			}))
+			defer s.Close()
+			prefix, interval := wikiUrlPrefix, wikiInterval
+			wikiUrlPrefix, wikiInterval = s.URL, 0
+			defer func() { wikiUrlPrefix, wikiInterval = prefix, interval }()
+
+			got, err := wikiGet(s.Client(), map[string]string{"action": "query"})
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wikiGet() = %q; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != `{"ok": true}` {
+				t.Errorf("wikiGet() = %q; want the response after retries", got)
+			}
+			if requests != tc.refused+1 {
+				t.Errorf("Sent %d requests; want %d", requests, tc.refused+1)
+			}
+		})
+	}
+}
+
+func TestFetchWikiDefinitionEtymologies(t *testing.T) {
+	s := startFakeWiki(t)
+	p := WikiParser{InputLanguage: "Hungarian"}
+	got, err := FetchWikiDefinition(p, s.Client(), "fog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*WikiDefinition{
+		{Word: "fog", SpeechPart: Noun, Gloss: "tooth", Plural: "fogak"},
+		{Word: "fog", SpeechPart: Verb, Labels: []string{"transitive"}, Gloss: "to hold, grasp"},
+		{Word: "fog", SpeechPart: Verb, Labels: []string{"transitive"}, Gloss: "to catch (an animal, a ball)"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FetchWikiDefinition (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchWikiDefinitionStem(t *testing.T) {
+	s := startFakeWiki(t)
+	p := WikiParser{InputLanguage: "Hungarian"}
+	for _, w := range []string{"faluban", "feketében"} {
+		defs, err := FetchWikiDefinition(p, s.Client(), w)
+		if err != nil {
+			t.Fatalf("FetchWikiDefinition(%q): %v", w, err)
+		}
+		if len(defs) == 0 || defs[0].Form != w {
+			t.Errorf("FetchWikiDefinition(%q) = %v, want definitions with Form %q", w, defs, w)
+		}
+	}
+	if _, err := FetchWikiDefinition(WikiParser{InputLanguage: "German"}, s.Client(), "faluban"); err == nil {
+		t.Error("FetchWikiDefinition of an inflected word in German succeeded, stems are only for Hungarian")
+	}
+}
+
+func TestFetchWikiDefinitionRedirect(t *testing.T) {
+	s := startFakeWiki(t)
+	p := WikiParser{InputLanguage: "Hungarian"}
+	got, err := FetchWikiDefinition(p, s.Client(), "faluk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Word != "falu" || got[0].Gloss != "village" {
+		t.Errorf("FetchWikiDefinition(\"faluk\") = %v; want the definition of falu", got)
+	}
+}
+
+func TestWikiRedirect(t *testing.T) {
+	f, err := ioutil.ReadFile("testdata/wiki/faluk.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wikiRedirect(string(f)); got != "falu" {
+		t.Errorf("wikiRedirect(faluk.html) = %q; want %q", got, "falu")
+	}
+	f, err = ioutil.ReadFile("testdata/wiki/falu.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wikiRedirect(string(f)); got != "" {
+		t.Errorf("wikiRedirect(falu.html) = %q; want no redirect", got)
+	}
+}